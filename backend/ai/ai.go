@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Calrus/ourdreamjournal/backend/config"
+)
+
+// DreamAI generates the AI-derived content attached to a dream
+type DreamAI interface {
+	// Summarize returns a one-sentence summary of the dream
+	Summarize(ctx context.Context, text string) (string, error)
+	// Prophesy returns a short one-sentence interpretation of the dream
+	Prophesy(ctx context.Context, text string) (string, error)
+	// ExtractTags returns up to five short keyword tags for the dream
+	ExtractTags(ctx context.Context, text string) ([]string, error)
+}
+
+// ErrNotConfigured is returned by every method of the provider used when the
+// OpenAI-compatible backend is selected but no API key is set
+var ErrNotConfigured = errors.New("AI provider not configured")
+
+// New returns the DreamAI implementation selected by cfg.AIProvider
+func New(cfg *config.Config) (DreamAI, error) {
+	switch cfg.AIProvider {
+	case "", "openai":
+		if cfg.AIAPIKey == "" {
+			return disabled{}, nil
+		}
+		return NewOpenAI(OpenAIConfig{
+			APIKey:        cfg.AIAPIKey,
+			BaseURL:       cfg.AIBaseURL,
+			SummaryModel:  cfg.AISummaryModel,
+			ProphecyModel: cfg.AIProphecyModel,
+			TagsModel:     cfg.AITagsModel,
+		}), nil
+	case "fake":
+		return Fake{}, nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", cfg.AIProvider)
+	}
+}
+
+// disabled is used when no API key is configured
+type disabled struct{}
+
+func (disabled) Summarize(context.Context, string) (string, error)     { return "", ErrNotConfigured }
+func (disabled) Prophesy(context.Context, string) (string, error)      { return "", ErrNotConfigured }
+func (disabled) ExtractTags(context.Context, string) ([]string, error) { return nil, ErrNotConfigured }
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fake is a deterministic offline DreamAI for tests and air-gapped
+// deployments. The same text always produces the same output.
+type Fake struct{}
+
+var fakeInterpretations = []string{
+	"This dream means you are working through feelings about %s.",
+	"This dream means %s is taking up more of your attention than you admit.",
+	"This dream means you want more control over %s.",
+	"This dream means you are ready to let go of %s.",
+}
+
+// stopWords are skipped when picking tags
+var stopWords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "been": true,
+	"before": true, "being": true, "could": true, "dream": true, "dreamed": true,
+	"dreamt": true, "from": true, "have": true, "into": true, "just": true,
+	"like": true, "other": true, "over": true, "some": true, "that": true,
+	"their": true, "them": true, "then": true, "there": true, "they": true,
+	"this": true, "through": true, "very": true, "was": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "while": true,
+	"with": true, "would": true, "your": true,
+}
+
+func (Fake) Summarize(_ context.Context, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "An empty dream.", nil
+	}
+	end := strings.IndexAny(text, ".!?")
+	if end >= 0 {
+		text = text[:end+1]
+	}
+	if r := []rune(text); len(r) > 200 {
+		text = string(r[:200]) + "…"
+	}
+	return text, nil
+}
+
+func (Fake) Prophesy(_ context.Context, text string) (string, error) {
+	subject := "the unknown"
+	if words := keywords(text); len(words) > 0 {
+		subject = words[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	return fmt.Sprintf(fakeInterpretations[h.Sum32()%uint32(len(fakeInterpretations))], subject), nil
+}
+
+func (Fake) ExtractTags(_ context.Context, text string) ([]string, error) {
+	words := keywords(text)
+	if len(words) > 5 {
+		words = words[:5]
+	}
+	return words, nil
+}
+
+// keywords returns the distinct non-stop words of text ordered by frequency,
+// ties broken by first occurrence
+func keywords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	counts := map[string]int{}
+	var order []string
+	for _, w := range fields {
+		if len([]rune(w)) < 4 || stopWords[w] {
+			continue
+		}
+		if counts[w] == 0 {
+			order = append(order, w)
+		}
+		counts[w]++
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	return order
+}
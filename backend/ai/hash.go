@@ -0,0 +1,15 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ContentHash returns the hex SHA-256 of a dream text. Cached model output
+// stores the hash of the text it was generated from so it can be reused until
+// the text changes. It matches encode(sha256(convert_to(text, 'UTF8')), 'hex')
+// in Postgres.
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
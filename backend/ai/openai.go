@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	summaryPrompt  = "Summarize the following dream in one direct sentence."
+	prophecyPrompt = "Give a short, direct, one-sentence interpretation of the dream's meaning. Do not write a story, poem, or prophecy. Example: 'This dream means you desire more social interaction in college.'"
+	tagsPrompt     = `Extract 1-5 keyword tags from this dream. Each tag must be 1-2 words only. Tags should be the main setting(s) (e.g., forest, school, city) and main actions (e.g., cutting wood, making smores). If you cannot extract any tags that fit these requirements, return an empty string. Return only a comma-separated list of tags, no extra text.`
+)
+
+// OpenAIConfig configures an OpenAI-compatible chat completion backend
+type OpenAIConfig struct {
+	APIKey        string
+	BaseURL       string // e.g. https://openrouter.ai/api/v1
+	SummaryModel  string
+	ProphecyModel string
+	TagsModel     string
+}
+
+// OpenAI implements DreamAI against any OpenAI-compatible endpoint
+type OpenAI struct {
+	client *openai.Client
+	cfg    OpenAIConfig
+}
+
+// NewOpenAI creates an OpenAI-compatible DreamAI
+func NewOpenAI(cfg OpenAIConfig) *OpenAI {
+	clientCfg := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		clientCfg.BaseURL = cfg.BaseURL
+	}
+	return &OpenAI{client: openai.NewClientWithConfig(clientCfg), cfg: cfg}
+}
+
+func (o *OpenAI) Summarize(ctx context.Context, text string) (string, error) {
+	return o.complete(ctx, o.cfg.SummaryModel, summaryPrompt, text, 120)
+}
+
+func (o *OpenAI) Prophesy(ctx context.Context, text string) (string, error) {
+	return o.complete(ctx, o.cfg.ProphecyModel, prophecyPrompt, text, 60)
+}
+
+func (o *OpenAI) ExtractTags(ctx context.Context, text string) ([]string, error) {
+	content, err := o.complete(ctx, o.cfg.TagsModel, tagsPrompt, text, 60)
+	if err != nil {
+		return nil, err
+	}
+	return splitTags(content), nil
+}
+
+// complete runs a single-turn chat completion and returns the first choice
+func (o *OpenAI) complete(ctx context.Context, model, system, text string, maxTokens int) (string, error) {
+	resp, err := o.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: system},
+			{Role: openai.ChatMessageRoleUser, Content: text},
+		},
+		MaxTokens: maxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", nil
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,30 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+)
+
+var tagSeparator = regexp.MustCompile(`[\,\n]`)
+
+// splitTags cleans a model's comma-separated tag list and trims whitespace
+func splitTags(s string) []string {
+	// Remove triple backticks and single backticks
+	cleaned := strings.ReplaceAll(s, "```", "")
+	cleaned = strings.ReplaceAll(cleaned, "`", "")
+	// Remove quotes
+	cleaned = strings.ReplaceAll(cleaned, "\"", "")
+	cleaned = strings.ReplaceAll(cleaned, "'", "")
+	// Remove Markdown bullets
+	cleaned = strings.ReplaceAll(cleaned, "-", "")
+	// Split by comma or newline
+	parts := tagSeparator.Split(cleaned, -1)
+	tags := []string{}
+	for _, tag := range parts {
+		t := strings.TrimSpace(tag)
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
@@ -1,25 +1,91 @@
 package config
 
 import (
+	"crypto/rand"
 	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/ratelimit"
 )
 
 // Config holds all configuration for the application
 type Config struct {
+	// Store is "postgres" (the default) or "memory", which keeps everything
+	// in process and needs no database
+	Store       string
 	DatabaseURL string
 	Port        int
+	GRPCPort    int
+
+	// AI provider settings. AIProvider is "openai" (any OpenAI-compatible
+	// endpoint, the default) or "fake" for the deterministic offline provider.
+	AIProvider      string
+	AIAPIKey        string
+	AIBaseURL       string
+	AISummaryModel  string
+	AIProphecyModel string
+	AITagsModel     string
+	AIWorkers       int
+	AIConcurrency   int
+
+	// JWTKeys are the HMAC keys accepted for access tokens, by key ID.
+	// Tokens are signed with JWTActiveKey; the others stay valid so keys
+	// can be rotated without signing everyone out.
+	JWTKeys         map[string][]byte
+	JWTActiveKey    string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Mail settings. MailProvider is "log" (the default, prints messages),
+	// "file" (writes them to MailDir) or "smtp". AppURL is the frontend
+	// address that links in emails point to.
+	MailProvider string
+	MailFrom     string
+	MailDir      string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	AppURL       string
+
+	// Per-client quotas of the auth, AI and other API routes, and whether
+	// client addresses come from X-Forwarded-For
+	RateLimitAuth ratelimit.Rate
+	RateLimitAI   ratelimit.Rate
+	RateLimitAPI  ratelimit.Rate
+	TrustProxy    bool
+}
+
+const (
+	defaultAIBaseURL = "https://openrouter.ai/api/v1"
+	defaultAIModel   = "deepseek/deepseek-prover-v2:free"
+)
+
+// getEnv returns the environment variable or def when it is unset
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
 }
 
 // New creates a new Config instance by reading from environment variables
 func New() (*Config, error) {
 	config := &Config{}
+	var err error
 
-	// Read DATABASE_URL
+	config.Store = getEnv("STORE", "postgres")
+	if config.Store != "postgres" && config.Store != "memory" {
+		return nil, fmt.Errorf("invalid STORE value: %q", config.Store)
+	}
+
+	// Read DATABASE_URL, which only the Postgres store needs
 	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
 		config.DatabaseURL = dbURL
-	} else {
+	} else if config.Store == "postgres" {
 		return nil, fmt.Errorf("DATABASE_URL environment variable is required")
 	}
 
@@ -34,5 +100,139 @@ func New() (*Config, error) {
 		config.Port = 8080 // Default port
 	}
 
+	// Read GRPC_PORT with default value of 50052
+	if portStr := os.Getenv("GRPC_PORT"); portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRPC_PORT value: %v", err)
+		}
+		config.GRPCPort = port
+	} else {
+		config.GRPCPort = 50052 // Default gRPC port
+	}
+
+	// AI provider; AI_MODEL sets the default for every task
+	config.AIProvider = getEnv("AI_PROVIDER", "openai")
+	config.AIAPIKey = os.Getenv("OPENAI_API_KEY")
+	config.AIBaseURL = getEnv("AI_BASE_URL", defaultAIBaseURL)
+	model := getEnv("AI_MODEL", defaultAIModel)
+	config.AISummaryModel = getEnv("AI_SUMMARY_MODEL", model)
+	config.AIProphecyModel = getEnv("AI_PROPHECY_MODEL", model)
+	config.AITagsModel = getEnv("AI_TAGS_MODEL", model)
+
+	// Number of background AI job workers
+	if workersStr := os.Getenv("AI_WORKERS"); workersStr != "" {
+		workers, err := strconv.Atoi(workersStr)
+		if err != nil || workers < 1 {
+			return nil, fmt.Errorf("invalid AI_WORKERS value: %q", workersStr)
+		}
+		config.AIWorkers = workers
+	} else {
+		config.AIWorkers = 2
+	}
+
+	// Maximum parallel model calls made while serving a single request
+	if concStr := os.Getenv("AI_CONCURRENCY"); concStr != "" {
+		conc, err := strconv.Atoi(concStr)
+		if err != nil || conc < 1 {
+			return nil, fmt.Errorf("invalid AI_CONCURRENCY value: %q", concStr)
+		}
+		config.AIConcurrency = conc
+	} else {
+		config.AIConcurrency = 5
+	}
+
+	if err := config.loadJWTKeys(); err != nil {
+		return nil, err
+	}
+	if config.AccessTokenTTL, err = getDuration("ACCESS_TOKEN_TTL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if config.RefreshTokenTTL, err = getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	// Outgoing mail for password resets and email verification
+	config.MailProvider = getEnv("MAIL_PROVIDER", "log")
+	config.MailFrom = getEnv("MAIL_FROM", "SleepTalk <no-reply@sleeptalk.to>")
+	config.MailDir = getEnv("MAIL_DIR", "mail")
+	config.SMTPAddr = os.Getenv("SMTP_ADDR")
+	config.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	config.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	config.AppURL = strings.TrimSuffix(getEnv("APP_URL", "http://localhost:3000"), "/")
+
+	// Rate limits such as "30/m"; "off" disables one
+	for _, l := range []struct {
+		key  string
+		def  string
+		rate *ratelimit.Rate
+	}{
+		{"RATE_LIMIT_AUTH", "30/m", &config.RateLimitAuth},
+		{"RATE_LIMIT_AI", "60/h", &config.RateLimitAI},
+		{"RATE_LIMIT_API", "600/m", &config.RateLimitAPI},
+	} {
+		if *l.rate, err = ratelimit.ParseRate(getEnv(l.key, l.def)); err != nil {
+			return nil, fmt.Errorf("invalid %s value: %v", l.key, err)
+		}
+	}
+	config.TrustProxy = os.Getenv("TRUST_PROXY") == "true"
+
 	return config, nil
+}
+
+// getDuration parses a duration such as "15m" from the environment
+func getDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s value: %q", key, v)
+	}
+	return d, nil
+}
+
+// loadJWTKeys reads JWT_KEYS as comma-separated kid:secret pairs, or a
+// single JWT_SECRET with the key ID "default". JWT_ACTIVE_KEY picks the
+// signing key and defaults to the first one listed. The in-memory store
+// falls back to a random key, since nothing survives a restart anyway.
+func (c *Config) loadJWTKeys() error {
+	c.JWTKeys = map[string][]byte{}
+	if keys := os.Getenv("JWT_KEYS"); keys != "" {
+		for _, pair := range strings.Split(keys, ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || kid == "" || secret == "" {
+				return fmt.Errorf("invalid JWT_KEYS entry %q, want kid:secret", pair)
+			}
+			if _, dup := c.JWTKeys[kid]; dup {
+				return fmt.Errorf("duplicate JWT_KEYS key ID %q", kid)
+			}
+			c.JWTKeys[kid] = []byte(secret)
+			if c.JWTActiveKey == "" {
+				c.JWTActiveKey = kid
+			}
+		}
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		c.JWTKeys["default"] = []byte(secret)
+		c.JWTActiveKey = "default"
+	} else if c.Store == "memory" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("generating JWT key: %v", err)
+		}
+		log.Printf("JWT_KEYS not set; using a random signing key")
+		c.JWTKeys["ephemeral"] = secret
+		c.JWTActiveKey = "ephemeral"
+	} else {
+		return fmt.Errorf("JWT_KEYS or JWT_SECRET environment variable is required")
+	}
+
+	if kid := os.Getenv("JWT_ACTIVE_KEY"); kid != "" {
+		if _, ok := c.JWTKeys[kid]; !ok {
+			return fmt.Errorf("JWT_ACTIVE_KEY %q is not in JWT_KEYS", kid)
+		}
+		c.JWTActiveKey = kid
+	}
+	return nil
 } 
\ No newline at end of file
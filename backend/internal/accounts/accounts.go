@@ -0,0 +1,111 @@
+// Package accounts creates user accounts and mails their one-time links,
+// shared by the HTTP and gRPC APIs
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/mail"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Store is the subset of store.Store that keeps users and account tokens
+type Store interface {
+	CreateUser(ctx context.Context, u *model.User, passwordHash string) error
+	CreateAccountToken(ctx context.Context, t *model.AccountToken) error
+}
+
+// emails holds the subject, frontend path and text of each kind of account
+// token email, by purpose
+var emails = map[string]struct {
+	subject, path, text string
+}{
+	model.TokenPasswordReset: {
+		subject: "Reset your SleepTalk password",
+		path:    "/reset-password",
+		text:    "Someone asked to reset the password of your SleepTalk account. To choose a new password, open this link within %s:",
+	},
+	model.TokenEmailVerify: {
+		subject: "Verify your SleepTalk email address",
+		path:    "/verify-email",
+		text:    "Welcome to SleepTalk! To confirm this is your email address, open this link within %s:",
+	},
+}
+
+// Manager keeps users in Store and sends their links through Mailer. The
+// links point at the frontend under AppURL and stay valid for ResetTTL or
+// VerifyTTL.
+type Manager struct {
+	Store     Store
+	Mailer    mail.Mailer
+	AppURL    string
+	ResetTTL  time.Duration
+	VerifyTTL time.Duration
+}
+
+// Register creates a user and mails them a link to verify their email
+// address in the background. It returns store.ErrConflict if the email or
+// username is taken.
+func (m *Manager) Register(ctx context.Context, email, username, password string) (*model.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+	u := &model.User{Email: email, Username: username}
+	if err := m.Store.CreateUser(ctx, u, string(hash)); err != nil {
+		return nil, err
+	}
+	// The user can ask for another link from their profile
+	m.SendLater(ctx, u, model.TokenEmailVerify)
+	return u, nil
+}
+
+// SendToken stores a new one-time token for u and mails the link that uses
+// it. Earlier links for the same purpose stop working.
+func (m *Manager) SendToken(ctx context.Context, u *model.User, purpose string) error {
+	ttl := m.ResetTTL
+	if purpose == model.TokenEmailVerify {
+		ttl = m.VerifyTTL
+	}
+	token, hash, err := auth.NewAccountToken()
+	if err != nil {
+		return err
+	}
+	err = m.Store.CreateAccountToken(ctx, &model.AccountToken{
+		UserID:    u.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	e := emails[purpose]
+	link := m.AppURL + e.path + "?token=" + url.QueryEscape(token)
+	return m.Mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: e.subject,
+		Body: fmt.Sprintf(e.text, ttl) + "\n\n" + link + "\n\n" +
+			"If you did not ask for this, you can ignore this email.\n",
+	})
+}
+
+// SendLater runs SendToken in the background, so a slow mail server does
+// not hold up the caller, and reports failures only in the log
+func (m *Manager) SendLater(ctx context.Context, u *model.User, purpose string) {
+	ctx = context.WithoutCancel(ctx)
+	// The caller may go on changing u
+	user := *u
+	go func() {
+		if err := m.SendToken(ctx, &user, purpose); err != nil {
+			log.Printf("[MAIL] Failed to send %s link to user %s: %v", purpose, user.ID, err)
+		}
+	}()
+}
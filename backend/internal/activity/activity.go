@@ -0,0 +1,89 @@
+// Package activity tells users what other users did to them, as stored
+// notifications and real-time events. The REST and gRPC APIs share it so an
+// action reaches the same people whichever API it came through.
+package activity
+
+import (
+	"context"
+	"log"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/events"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+// Store is the subset of store.Store that keeps notifications
+type Store interface {
+	CreateNotification(ctx context.Context, n *model.Notification) error
+}
+
+// friendEvent is the data of a friend event: UserID's request to, or
+// friendship with, FriendID now has Status
+type friendEvent struct {
+	UserID   string `json:"user_id"`
+	FriendID string `json:"friend_id"`
+	Status   string `json:"status"`
+}
+
+// Notifier stores notifications in Store and publishes events to Events.
+// Both are side effects of the write that caused them, so failures are
+// logged rather than returned.
+type Notifier struct {
+	Store  Store
+	Events events.Publisher
+}
+
+// Publish sends e through Events
+func (a *Notifier) Publish(ctx context.Context, e events.Event) {
+	if err := a.Events.Publish(ctx, e); err != nil {
+		log.Printf("[EVENTS] Failed to publish %s event: %v", e.Type, err)
+	}
+}
+
+// Notify stores n for its recipient and pushes it to their event stream.
+// Nobody is notified about their own doings.
+func (a *Notifier) Notify(ctx context.Context, n model.Notification) {
+	if n.UserID == n.Actor.ID {
+		return
+	}
+	if err := a.Store.CreateNotification(ctx, &n); err != nil {
+		log.Printf("[NOTIFICATIONS] Failed to notify user %s of %s by %s: %v", n.UserID, n.Type, n.Actor.ID, err)
+		return
+	}
+	if n.ID == 0 {
+		// The recipient turned this type off
+		return
+	}
+	e := events.New(events.TypeNotification, n)
+	e.UserID, e.ActorID = n.UserID, n.Actor.ID
+	a.Publish(ctx, e)
+}
+
+// PublishFriend tells `to` that the request or friendship between userID
+// and friendID now has status
+func (a *Notifier) PublishFriend(ctx context.Context, to, actorID, userID, friendID, status string) {
+	e := events.New(events.TypeFriend, friendEvent{UserID: userID, FriendID: friendID, Status: status})
+	e.UserID, e.ActorID = to, actorID
+	a.Publish(ctx, e)
+}
+
+// FriendRequested tells friendID about userID's new friend request
+func (a *Notifier) FriendRequested(ctx context.Context, userID, friendID string) {
+	a.PublishFriend(ctx, friendID, userID, userID, friendID, "pending")
+	a.Notify(ctx, model.Notification{Type: model.NotifyFriendRequest, UserID: friendID, Actor: model.UserSummary{ID: userID}})
+}
+
+// FriendAccepted tells userID that friendID accepted their friend request
+func (a *Notifier) FriendAccepted(ctx context.Context, userID, friendID string) {
+	a.PublishFriend(ctx, userID, friendID, userID, friendID, "accepted")
+	a.Notify(ctx, model.Notification{Type: model.NotifyFriendAccepted, UserID: userID, Actor: model.UserSummary{ID: friendID}})
+}
+
+// FriendRemoved tells whichever of userID and friendID is not actorID that
+// their request or friendship ended
+func (a *Notifier) FriendRemoved(ctx context.Context, actorID, userID, friendID string) {
+	for _, to := range []string{userID, friendID} {
+		if to != actorID {
+			a.PublishFriend(ctx, to, actorID, userID, friendID, "removed")
+		}
+	}
+}
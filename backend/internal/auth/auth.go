@@ -0,0 +1,88 @@
+// Package auth issues and verifies the bearer tokens used by the HTTP and
+// gRPC APIs
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Authenticator issues tokens for a user and resolves tokens back to the
+// user ID they were issued for and the time they expire
+type Authenticator interface {
+	IssueToken(userID string) (string, error)
+	ParseToken(token string) (userID string, expires time.Time, err error)
+}
+
+// JWT is an Authenticator issuing HS256 tokens valid for TTL. Every token
+// names its signing key in the kid header, so any of the configured keys
+// can verify it while only the active one signs.
+type JWT struct {
+	keys      map[string][]byte
+	activeKey string
+	TTL       time.Duration
+}
+
+// NewJWT creates a JWT authenticator signing with keys[activeKey] and a 15
+// minute token lifetime
+func NewJWT(keys map[string][]byte, activeKey string) (*JWT, error) {
+	if len(keys[activeKey]) == 0 {
+		return nil, fmt.Errorf("active key %q not configured", activeKey)
+	}
+	return &JWT{keys: keys, activeKey: activeKey, TTL: 15 * time.Minute}, nil
+}
+
+func (j *JWT) IssueToken(userID string) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(j.TTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = j.activeKey
+	return token.SignedString(j.keys[j.activeKey])
+}
+
+// ParseToken validates a token and returns the user ID it was issued for
+// and its expiry
+func (j *JWT) ParseToken(tokenStr string) (string, time.Time, error) {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return "", time.Time{}, fmt.Errorf("invalid token: %v", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("invalid token claims")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("user_id not found in token")
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return "", time.Time{}, fmt.Errorf("exp not found in token")
+	}
+	return userID, exp.Time, nil
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>"
+// header value
+func BearerToken(header string) (string, error) {
+	if header == "" {
+		return "", fmt.Errorf("missing Authorization header")
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", fmt.Errorf("invalid Authorization header format")
+	}
+	return parts[1], nil
+}
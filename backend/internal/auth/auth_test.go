@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestKeyRotation(t *testing.T) {
+	old, err := NewJWT(map[string][]byte{"k1": []byte("first")}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := old.IssueToken("42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After rotation k2 signs, and tokens signed with k1 still verify
+	rotated, err := NewJWT(map[string][]byte{"k1": []byte("first"), "k2": []byte("second")}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, _ := rotated.IssueToken("43")
+	for token, want := range map[string]string{oldToken: "42", newToken: "43"} {
+		got, expires, err := rotated.ParseToken(token)
+		if err != nil || got != want {
+			t.Errorf("ParseToken = %q, %v; want %q", got, err, want)
+		}
+		if d := time.Until(expires); d <= 0 || d > rotated.TTL {
+			t.Errorf("token expires in %v, want within %v", d, rotated.TTL)
+		}
+	}
+
+	// Once k1 is retired its tokens are rejected
+	retired, _ := NewJWT(map[string][]byte{"k2": []byte("second")}, "k2")
+	if _, _, err := retired.ParseToken(oldToken); err == nil {
+		t.Error("token signed with a retired key was accepted")
+	}
+	if _, _, err := retired.ParseToken(newToken); err != nil {
+		t.Errorf("token signed with the active key: %v", err)
+	}
+
+	// A key ID pointing at the wrong secret fails verification
+	forged, _ := NewJWT(map[string][]byte{"k2": []byte("guess")}, "k2")
+	forgedToken, _ := forged.IssueToken("1")
+	if _, _, err := rotated.ParseToken(forgedToken); err == nil {
+		t.Error("token with a forged signature was accepted")
+	}
+
+	if _, err := NewJWT(map[string][]byte{"k1": []byte("first")}, "k2"); err == nil {
+		t.Error("NewJWT accepted an active key that is not configured")
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(string(hash), "secret") {
+		t.Errorf("right password rejected")
+	}
+	if CheckPassword(string(hash), "wrong") {
+		t.Errorf("wrong password accepted")
+	}
+	if CheckPassword("", "") || CheckPassword("", "dummy password") {
+		t.Errorf("empty hash accepted")
+	}
+	if LoginKey("  Ann@Example.COM ") != "ann@example.com" {
+		t.Errorf("LoginKey did not normalize the email")
+	}
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// CheckPassword reports whether password matches the bcrypt hash. An empty
+// hash, as for an unknown account, is checked against a dummy hash so the
+// answer takes as long as for a real account and timing does not reveal
+// which emails are registered.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		dummyOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// LoginKey normalizes an email address for counting failed logins, so
+// variants of one address share a lockout
+func LoginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewRefreshToken returns a random opaque refresh token and the hash that is
+// stored in its place. The token itself is only ever given to the client.
+func NewRefreshToken() (token, hash string, err error) {
+	token, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hex SHA-256 of a refresh token. The tokens are
+// long and random, so an unsalted fast hash is enough.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewFamilyID returns the ID shared by a refresh token and every token it is
+// rotated into
+func NewFamilyID() (string, error) {
+	return randomString(16)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewAccountToken returns a random one-time token for a password reset or
+// email verification link and the hash that is stored in its place
+func NewAccountToken() (token, hash string, err error) {
+	token, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashAccountToken(token), nil
+}
+
+// HashAccountToken returns the hex SHA-256 of an account token, hashed the
+// same way as refresh tokens
+func HashAccountToken(token string) string {
+	return HashRefreshToken(token)
+}
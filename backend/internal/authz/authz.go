@@ -0,0 +1,253 @@
+// Package authz decides who may do what. The HTTP and gRPC layers put the
+// authenticated Principal in the request context and ask the Policy before
+// touching a dream, comment or friendship, so the access rules live in one
+// place.
+//
+// The rules are built from four relationships between the caller and a
+// resource: owner, friend, public and admin. A dream's visibility level says
+// which of them may see it. Admins may do anything except act as another
+// user. On top of that, users who blocked one another do not see each other
+// at all.
+package authz
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+var (
+	// ErrUnauthenticated means the rule needs a signed-in caller
+	ErrUnauthenticated = errors.New("authentication required")
+	// ErrForbidden means the caller may see the resource but not do this
+	ErrForbidden = errors.New("forbidden")
+	// ErrHidden means the caller may not know the resource exists; report it
+	// as not found
+	ErrHidden = errors.New("not found")
+)
+
+// Principal is the authenticated caller. ExpiresAt is when the token they
+// signed in with expires.
+type Principal struct {
+	UserID    string
+	IsAdmin   bool
+	ExpiresAt time.Time
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the caller, or nil for anonymous requests
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// ID returns the caller's user ID, or "" when p is nil
+func (p *Principal) ID() string {
+	if p == nil {
+		return ""
+	}
+	return p.UserID
+}
+
+// is reports whether p is signed in as userID
+func (p *Principal) is(userID string) bool {
+	return p != nil && userID != "" && p.UserID == userID
+}
+
+func (p *Principal) admin() bool {
+	return p != nil && p.IsAdmin
+}
+
+// Friendships is the lookup the friend rules need
+type Friendships interface {
+	FriendStatus(ctx context.Context, userID, friendID string) (string, error)
+}
+
+// Policy applies the access rules. Every check returns nil when allowed and
+// otherwise ErrUnauthenticated, ErrForbidden or ErrHidden.
+type Policy struct {
+	friends Friendships
+}
+
+func New(friends Friendships) *Policy {
+	return &Policy{friends: friends}
+}
+
+// Authenticated requires a signed-in caller
+func (pol *Policy) Authenticated(p *Principal) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// ActAs allows only userID themselves, for actions taken in a user's name
+// such as sending a friend request. Admins are not exempt.
+func (pol *Policy) ActAs(p *Principal, userID string) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	if !p.is(userID) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ReadPrivate allows the user and admins to read data derived from the
+// user's private dreams or account, such as stats and insights
+func (pol *Policy) ReadPrivate(p *Principal, userID string) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	if !p.is(userID) && !p.admin() {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ViewDream allows anyone to see public and unlisted dreams, the owner's
+// friends to see friends-only ones, and only the owner and admins to see
+// private ones
+func (pol *Policy) ViewDream(ctx context.Context, p *Principal, d *model.Dream) error {
+	switch {
+	case d.Visibility == model.VisibilityPublic || d.Visibility == model.VisibilityUnlisted:
+		return nil
+	case p.is(d.UserID) || p.admin():
+		return nil
+	case d.Visibility == model.VisibilityFriends && p != nil:
+		friends, err := pol.areFriends(ctx, p.UserID, d.UserID)
+		if err != nil {
+			return err
+		}
+		if friends {
+			return nil
+		}
+	}
+	return ErrHidden
+}
+
+// EditDream allows the owner and admins to change or delete a dream and
+// see its history
+func (pol *Policy) EditDream(ctx context.Context, p *Principal, d *model.Dream) error {
+	if err := pol.ViewDream(ctx, p, d); err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	if !p.is(d.UserID) && !p.admin() {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ManageDream is EditDream for resources that hang off a dream, such as AI
+// jobs, whose existence is not revealed to other users
+func (pol *Policy) ManageDream(ctx context.Context, p *Principal, d *model.Dream) error {
+	if err := pol.EditDream(ctx, p, d); errors.Is(err, ErrForbidden) {
+		return ErrHidden
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Comment allows any signed-in user who can see a dream to comment on it
+func (pol *Policy) Comment(ctx context.Context, p *Principal, d *model.Dream) error {
+	if err := pol.ViewDream(ctx, p, d); err != nil {
+		return err
+	}
+	return pol.Authenticated(p)
+}
+
+// React allows any signed-in user who can see a dream to react to it or
+// its comments
+func (pol *Policy) React(ctx context.Context, p *Principal, d *model.Dream) error {
+	return pol.Comment(ctx, p, d)
+}
+
+// EditComment allows only the comment's author. Admins are not exempt,
+// since an edit puts words in the author's mouth; they delete instead.
+func (pol *Policy) EditComment(p *Principal, c *model.Comment) error {
+	return pol.ActAs(p, c.User.ID)
+}
+
+// DeleteComment allows the comment's author, the owner of the dream it is
+// on and admins
+func (pol *Policy) DeleteComment(p *Principal, c *model.Comment, d *model.Dream) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	if !p.is(c.User.ID) && !p.is(d.UserID) && !p.admin() {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Moderate allows only admins, for the comment report queue
+func (pol *Policy) Moderate(p *Principal) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	if !p.admin() {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ListFriends allows the user, their friends and admins to see who the
+// user's friends are
+func (pol *Policy) ListFriends(ctx context.Context, p *Principal, userID string) error {
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	if p.is(userID) || p.admin() {
+		return nil
+	}
+	friends, err := pol.areFriends(ctx, p.UserID, userID)
+	if err != nil {
+		return err
+	}
+	if !friends {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// SeeUser hides a user, and everything they wrote, from a caller who
+// blocked them or was blocked by them. Admins see everyone.
+func (pol *Policy) SeeUser(ctx context.Context, p *Principal, userID string) error {
+	if p == nil || p.is(userID) || p.admin() {
+		return nil
+	}
+	for _, pair := range [][2]string{{p.UserID, userID}, {userID, p.UserID}} {
+		status, err := pol.friends.FriendStatus(ctx, pair[0], pair[1])
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if status == "blocked" {
+			return ErrHidden
+		}
+	}
+	return nil
+}
+
+// areFriends reports whether a and b have an accepted friendship
+func (pol *Policy) areFriends(ctx context.Context, a, b string) (bool, error) {
+	status, err := pol.friends.FriendStatus(ctx, a, b)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return status == "accepted", nil
+}
@@ -0,0 +1,159 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+// friendships maps "a:b" to the status of a's row towards b
+type friendships map[string]string
+
+func (f friendships) FriendStatus(ctx context.Context, userID, friendID string) (string, error) {
+	if status, ok := f[userID+":"+friendID]; ok {
+		return status, nil
+	}
+	return "", store.ErrNotFound
+}
+
+var (
+	anonymous *Principal
+	owner     = &Principal{UserID: "1"}
+	friend    = &Principal{UserID: "2"}
+	stranger  = &Principal{UserID: "3"}
+	pending   = &Principal{UserID: "4"}
+	blocked   = &Principal{UserID: "5"}
+	blocker   = &Principal{UserID: "6"}
+	admin     = &Principal{UserID: "9", IsAdmin: true}
+
+	callers = []struct {
+		name string
+		p    *Principal
+	}{
+		{"anonymous", anonymous},
+		{"owner", owner},
+		{"friend", friend},
+		{"stranger", stranger},
+		{"pending", pending},
+		{"blocked", blocked},
+		{"blocker", blocker},
+		{"admin", admin},
+	}
+)
+
+func newPolicy() *Policy {
+	return New(friendships{
+		"1:2": "accepted", "2:1": "accepted",
+		"4:1": "pending",
+		"1:5": "blocked",
+		"6:1": "blocked",
+	})
+}
+
+// check runs rule for every caller and compares with want, keyed by caller
+// name
+func check(t *testing.T, rule string, want map[string]error, fn func(p *Principal) error) {
+	t.Helper()
+	for _, c := range callers {
+		if err := fn(c.p); !errors.Is(err, want[c.name]) || (err == nil) != (want[c.name] == nil) {
+			t.Errorf("%s as %s: got %v, want %v", rule, c.name, err, want[c.name])
+		}
+	}
+}
+
+func TestDreamRules(t *testing.T) {
+	pol := newPolicy()
+	ctx := context.Background()
+	public := &model.Dream{UserID: "1", Visibility: model.VisibilityPublic}
+	unlisted := &model.Dream{UserID: "1", Visibility: model.VisibilityUnlisted}
+	friendsOnly := &model.Dream{UserID: "1", Visibility: model.VisibilityFriends}
+	private := &model.Dream{UserID: "1", Visibility: model.VisibilityPrivate}
+
+	check(t, "view public", map[string]error{}, func(p *Principal) error { return pol.ViewDream(ctx, p, public) })
+	check(t, "view unlisted", map[string]error{}, func(p *Principal) error { return pol.ViewDream(ctx, p, unlisted) })
+	check(t, "view friends-only", map[string]error{
+		"anonymous": ErrHidden, "stranger": ErrHidden, "pending": ErrHidden, "blocked": ErrHidden, "blocker": ErrHidden,
+	}, func(p *Principal) error { return pol.ViewDream(ctx, p, friendsOnly) })
+	check(t, "view private", map[string]error{
+		"anonymous": ErrHidden, "friend": ErrHidden, "stranger": ErrHidden, "pending": ErrHidden, "blocked": ErrHidden, "blocker": ErrHidden,
+	}, func(p *Principal) error { return pol.ViewDream(ctx, p, private) })
+
+	check(t, "edit public", map[string]error{
+		"anonymous": ErrUnauthenticated, "friend": ErrForbidden, "stranger": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden,
+	}, func(p *Principal) error { return pol.EditDream(ctx, p, public) })
+	check(t, "edit private", map[string]error{
+		"anonymous": ErrHidden, "friend": ErrHidden, "stranger": ErrHidden, "pending": ErrHidden, "blocked": ErrHidden, "blocker": ErrHidden,
+	}, func(p *Principal) error { return pol.EditDream(ctx, p, private) })
+
+	check(t, "manage public", map[string]error{
+		"anonymous": ErrUnauthenticated, "friend": ErrHidden, "stranger": ErrHidden, "pending": ErrHidden, "blocked": ErrHidden, "blocker": ErrHidden,
+	}, func(p *Principal) error { return pol.ManageDream(ctx, p, public) })
+}
+
+func TestCommentRules(t *testing.T) {
+	pol := newPolicy()
+	ctx := context.Background()
+	public := &model.Dream{UserID: "1", Visibility: model.VisibilityPublic}
+	private := &model.Dream{UserID: "1", Visibility: model.VisibilityPrivate}
+
+	check(t, "comment on public", map[string]error{
+		"anonymous": ErrUnauthenticated,
+	}, func(p *Principal) error { return pol.Comment(ctx, p, public) })
+	check(t, "comment on private", map[string]error{
+		"anonymous": ErrHidden, "friend": ErrHidden, "stranger": ErrHidden, "pending": ErrHidden, "blocked": ErrHidden, "blocker": ErrHidden,
+	}, func(p *Principal) error { return pol.Comment(ctx, p, private) })
+	check(t, "react to private", map[string]error{
+		"anonymous": ErrHidden, "friend": ErrHidden, "stranger": ErrHidden, "pending": ErrHidden, "blocked": ErrHidden, "blocker": ErrHidden,
+	}, func(p *Principal) error { return pol.React(ctx, p, private) })
+
+	byStranger := &model.Comment{User: model.UserSummary{ID: "3"}}
+	check(t, "edit comment", map[string]error{
+		"anonymous": ErrUnauthenticated, "owner": ErrForbidden, "friend": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden, "admin": ErrForbidden,
+	}, func(p *Principal) error { return pol.EditComment(p, byStranger) })
+	check(t, "delete comment on owner's dream", map[string]error{
+		"anonymous": ErrUnauthenticated, "friend": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden,
+	}, func(p *Principal) error { return pol.DeleteComment(p, byStranger, public) })
+	check(t, "delete comment on friend's dream", map[string]error{
+		"anonymous": ErrUnauthenticated, "owner": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden,
+	}, func(p *Principal) error {
+		return pol.DeleteComment(p, byStranger, &model.Dream{UserID: "2", Visibility: model.VisibilityPublic})
+	})
+	check(t, "moderate", map[string]error{
+		"anonymous": ErrUnauthenticated, "owner": ErrForbidden, "friend": ErrForbidden, "stranger": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden,
+	}, func(p *Principal) error { return pol.Moderate(p) })
+}
+
+func TestUserRules(t *testing.T) {
+	pol := newPolicy()
+	ctx := context.Background()
+
+	check(t, "authenticated", map[string]error{
+		"anonymous": ErrUnauthenticated,
+	}, func(p *Principal) error { return pol.Authenticated(p) })
+	check(t, "act as owner", map[string]error{
+		"anonymous": ErrUnauthenticated, "friend": ErrForbidden, "stranger": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden, "admin": ErrForbidden,
+	}, func(p *Principal) error { return pol.ActAs(p, "1") })
+	check(t, "read owner's private data", map[string]error{
+		"anonymous": ErrUnauthenticated, "friend": ErrForbidden, "stranger": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden,
+	}, func(p *Principal) error { return pol.ReadPrivate(p, "1") })
+	check(t, "list owner's friends", map[string]error{
+		"anonymous": ErrUnauthenticated, "stranger": ErrForbidden, "pending": ErrForbidden, "blocked": ErrForbidden, "blocker": ErrForbidden,
+	}, func(p *Principal) error { return pol.ListFriends(ctx, p, "1") })
+	check(t, "see owner", map[string]error{
+		"blocked": ErrHidden, "blocker": ErrHidden,
+	}, func(p *Principal) error { return pol.SeeUser(ctx, p, "1") })
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if p := FromContext(ctx); p != nil || p.ID() != "" {
+		t.Errorf("empty context has principal %+v", p)
+	}
+	ctx = WithPrincipal(ctx, owner)
+	if p := FromContext(ctx); p != owner || p.ID() != "1" {
+		t.Errorf("FromContext = %+v", p)
+	}
+}
@@ -0,0 +1,126 @@
+// Package events fans real-time events out to the clients streaming
+// GET /api/events. A Hub delivers events to the subscribers of one process;
+// Postgres relays them through LISTEN/NOTIFY so that every replica's hub
+// sees every event, whichever replica published it.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// Event types
+const (
+	// TypeComment: a comment was added to the dream in DreamID
+	TypeComment = "comment"
+	// TypeFriend: a friend request or friendship involving UserID changed
+	TypeFriend = "friend"
+	// TypeNotification: UserID got a new notification
+	TypeNotification = "notification"
+	// TypeJob: an AI job on one of UserID's dreams finished
+	TypeJob = "job"
+)
+
+// Event goes to the subscriber for UserID, or to everyone watching the
+// dream with public ID DreamID. ActorID is the user who caused it, so
+// subscribers can skip events from users they cannot see. Data is sent to
+// clients as is.
+type Event struct {
+	Type    string          `json:"type"`
+	UserID  string          `json:"user_id,omitempty"`
+	DreamID string          `json:"dream_id,omitempty"`
+	ActorID string          `json:"actor_id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// New builds an event with v encoded as its data
+func New(typ string, v interface{}) Event {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Only the API's own types are published, so this is a bug
+		panic("events: " + err.Error())
+	}
+	return Event{Type: typ, Data: data}
+}
+
+// Publisher sends an event to every subscriber it concerns
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Subscription receives the events for one user, and for one dream they
+// are viewing if DreamID is set
+type Subscription struct {
+	UserID  string
+	DreamID string
+
+	hub    *Hub
+	events chan Event
+}
+
+// Events returns the channel events arrive on. It is never closed; stop
+// reading when the client goes away and call Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops delivery to the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subs, s)
+	s.hub.mu.Unlock()
+}
+
+func (s *Subscription) wants(e Event) bool {
+	return (e.UserID != "" && e.UserID == s.UserID) || (e.DreamID != "" && e.DreamID == s.DreamID)
+}
+
+// Hub delivers events to the subscriptions of this process. A subscriber
+// whose buffer is full misses the event rather than holding up the
+// others; clients refetch when they reconnect anyway.
+type Hub struct {
+	// Buffer is how many events a subscription holds before it starts
+	// missing them
+	Buffer int
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{Buffer: 16, subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe starts delivering events for userID, and for dreamID when it
+// is not empty
+func (h *Hub) Subscribe(userID, dreamID string) *Subscription {
+	s := &Subscription{UserID: userID, DreamID: dreamID, hub: h, events: make(chan Event, h.Buffer)}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Publish delivers e to this process's subscribers only
+func (h *Hub) Publish(ctx context.Context, e Event) error {
+	h.Deliver(e)
+	return nil
+}
+
+// Deliver hands e to every subscription that wants it without blocking
+func (h *Hub) Deliver(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !s.wants(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			log.Printf("[EVENTS] Dropped %s event for a slow subscriber (user %s)", e.Type, s.UserID)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package events
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// received drains what has arrived on sub without waiting
+func received(sub *Subscription) []string {
+	var got []string
+	for {
+		select {
+		case e := <-sub.Events():
+			got = append(got, e.Type)
+		default:
+			return got
+		}
+	}
+}
+
+func TestHubRouting(t *testing.T) {
+	hub := NewHub()
+	ann := hub.Subscribe("1", "")
+	bob := hub.Subscribe("2", "ocean")
+	carl := hub.Subscribe("3", "ocean")
+
+	hub.Publish(context.Background(), Event{Type: TypeFriend, UserID: "1"})
+	hub.Publish(context.Background(), Event{Type: TypeComment, DreamID: "ocean"})
+	hub.Publish(context.Background(), Event{Type: TypeJob, UserID: "2"})
+	hub.Publish(context.Background(), Event{Type: TypeComment, DreamID: "forest"})
+
+	for _, c := range []struct {
+		name string
+		sub  *Subscription
+		want string
+	}{
+		{"ann", ann, "friend"},
+		{"bob", bob, "comment job"},
+		{"carl", carl, "comment"},
+	} {
+		if got := strings.Join(received(c.sub), " "); got != c.want {
+			t.Errorf("%s received %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	carl.Close()
+	hub.Publish(context.Background(), Event{Type: TypeComment, DreamID: "ocean"})
+	if got := received(carl); len(got) != 0 {
+		t.Errorf("closed subscription received %v", got)
+	}
+}
+
+func TestHubDropsForSlowSubscribers(t *testing.T) {
+	hub := NewHub()
+	hub.Buffer = 2
+	slow := hub.Subscribe("1", "")
+	for i := 0; i < 5; i++ {
+		hub.Deliver(Event{Type: TypeNotification, UserID: "1"})
+	}
+	if got := received(slow); len(got) != 2 {
+		t.Errorf("slow subscriber received %d events, want 2", len(got))
+	}
+	hub.Deliver(Event{Type: TypeNotification, UserID: "1"})
+	if got := received(slow); len(got) != 1 {
+		t.Errorf("after catching up received %d events, want 1", len(got))
+	}
+}
+
+// Postgres relaying needs a database:
+//
+//	TEST_DATABASE_URL=postgres://... go test ./internal/events
+func TestPostgres(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	// Two replicas: one publishes, both deliver
+	hubA, hubB := NewHub(), NewHub()
+	pgA, pgB := NewPostgres(pool, hubA), NewPostgres(pool, hubB)
+	go pgA.Listen(ctx)
+	go pgB.Listen(ctx)
+	subA, subB := hubA.Subscribe("1", ""), hubB.Subscribe("1", "")
+
+	big := New(TypeComment, map[string]string{"text": strings.Repeat("x", 10000)})
+	big.UserID = "1"
+	wait := func(sub *Subscription) Event {
+		t.Helper()
+		select {
+		case e := <-sub.Events():
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return Event{}
+		}
+	}
+	// LISTEN starts in the background, so keep publishing until it is up
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := pgA.Publish(ctx, Event{Type: TypeFriend, UserID: "1"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(received(subA)) > 0 && len(received(subB)) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("listeners never started")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	received(subA)
+	received(subB)
+
+	if err := pgA.Publish(ctx, big); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []*Subscription{subA, subB} {
+		if e := wait(sub); e.Type != TypeComment || e.Data != nil {
+			t.Errorf("oversized event arrived as %+v, want it without data", e)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel is the Postgres NOTIFY channel events travel on
+const Channel = "dream_events"
+
+// maxPayload stays under the 8000 byte limit Postgres puts on NOTIFY
+// payloads
+const maxPayload = 7900
+
+// Postgres publishes events with NOTIFY and delivers those from every
+// replica, its own included, to a local Hub
+type Postgres struct {
+	pool *pgxpool.Pool
+	hub  *Hub
+
+	// RetryInterval is how long Listen waits before reconnecting after
+	// losing its connection
+	RetryInterval time.Duration
+}
+
+func NewPostgres(pool *pgxpool.Pool, hub *Hub) *Postgres {
+	return &Postgres{pool: pool, hub: hub, RetryInterval: 5 * time.Second}
+}
+
+// Publish sends e to every replica. An event too big for a NOTIFY payload
+// goes out without its data, and clients fetch what changed themselves.
+func (p *Postgres) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayload {
+		e.Data = nil
+		if payload, err = json.Marshal(e); err != nil {
+			return err
+		}
+	}
+	_, err = p.pool.Exec(ctx, "SELECT pg_notify($1, $2)", Channel, string(payload))
+	return err
+}
+
+// Listen delivers notifications to the hub until ctx is cancelled,
+// reconnecting whenever the connection drops. Events published while it
+// was reconnecting are lost.
+func (p *Postgres) Listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := p.listen(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[EVENTS] Listening on %s failed, retrying in %s: %v", Channel, p.RetryInterval, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(p.RetryInterval):
+			}
+		}
+	}
+}
+
+func (p *Postgres) listen(ctx context.Context) error {
+	pooled, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Take the connection out of the pool for good: one left listening
+	// would hand notifications to whoever borrowed it next
+	conn := pooled.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			log.Printf("[EVENTS] Ignoring malformed notification: %v", err)
+			continue
+		}
+		p.hub.Deliver(e)
+	}
+}
@@ -0,0 +1,263 @@
+// Package feed builds a user's ranked home feed from their own recent
+// dreams, their friends' dreams and popular public dreams
+package feed
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+// Source says why a dream is in the feed
+type Source string
+
+const (
+	SourceOwn     Source = "own"
+	SourceFriends Source = "friends"
+	SourcePopular Source = "popular"
+)
+
+const (
+	// CandidatesPerSource is how many dreams are read from each source
+	// before ranking. It also bounds the whole feed: paging ends once these
+	// candidates have all been shown.
+	CandidatesPerSource = 100
+	// PopularWindow is how far back popular public dreams are looked for
+	PopularWindow = 30 * 24 * time.Hour
+	// HalfLife is the age at which a dream's recency score halves
+	HalfLife = 48 * time.Hour
+
+	// Weights of the parts of a score. Recency and tag overlap range from
+	// 0 to 1; activity grows with the log of comments and reactions.
+	RecencyWeight  = 1.0
+	ActivityWeight = 0.4
+	TagWeight      = 0.8
+	// CommentWeight is how many reactions a comment counts as
+	CommentWeight = 2
+)
+
+// Store is the subset of store.Store the feed is built from
+type Store interface {
+	ListDreams(ctx context.Context, f model.DreamFilter) ([]model.Dream, *model.Cursor, error)
+	PopularDreams(ctx context.Context, viewerID string, since, until time.Time, limit int) ([]model.Dream, error)
+	FriendIDs(ctx context.Context, userID string) ([]string, error)
+	CountComments(ctx context.Context, dreamRowIDs []int) (map[int]int, error)
+}
+
+// Item is one ranked dream in the feed. Sources lists every source the
+// dream came from, in the order of the Source constants.
+type Item struct {
+	Dream    model.Dream `json:"dream"`
+	Score    float64     `json:"score"`
+	Sources  []Source    `json:"sources"`
+	Comments int         `json:"comments"`
+}
+
+// Cursor is the position of the last item on a feed page. AsOf is the time
+// the first page was ranked at; later pages rank at the same time so scores
+// do not drift with the clock while a client is paging.
+type Cursor struct {
+	AsOf  time.Time
+	Score float64
+	ID    int
+}
+
+// Encode returns the opaque cursor string sent to clients as next_cursor
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.AsOf.UnixMicro(), 10) + ":" +
+		strconv.FormatFloat(c.Score, 'g', -1, 64) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	micros, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	score, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.IsNaN(score) || math.IsInf(score, 0) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &Cursor{AsOf: time.UnixMicro(micros).UTC(), Score: score, ID: id}, nil
+}
+
+// before reports whether an item with score and row ID ranks ahead of c
+func (c Cursor) before(score float64, id int) bool {
+	if score != c.Score {
+		return score > c.Score
+	}
+	return id >= c.ID
+}
+
+// Score ranks a dream as of asOf. Recency halves every HalfLife, activity
+// counts comments and reactions on a log scale so a few busy dreams do not
+// drown out the rest, and tag overlap is the share of the dream's tags the
+// user has used on their own dreams. ownTags holds those tags lowercased;
+// pass nil for the user's own dreams, which would always match.
+func Score(d *model.Dream, comments int, ownTags map[string]bool, asOf time.Time) float64 {
+	age := asOf.Sub(d.CreatedAt)
+	if age < 0 {
+		age = 0
+	}
+	recency := math.Exp2(-float64(age) / float64(HalfLife))
+
+	reactions := 0
+	for _, n := range d.Reactions {
+		reactions += n
+	}
+	activity := math.Log1p(float64(CommentWeight*comments + reactions))
+
+	overlap := 0.0
+	if len(ownTags) > 0 {
+		tags := tagSet(d.Tags)
+		shared := 0
+		for tag := range tags {
+			if ownTags[tag] {
+				shared++
+			}
+		}
+		if len(tags) > 0 {
+			overlap = float64(shared) / float64(len(tags))
+		}
+	}
+	return RecencyWeight*recency + ActivityWeight*activity + TagWeight*overlap
+}
+
+func tagSet(tags []string) map[string]bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			set[tag] = true
+		}
+	}
+	return set
+}
+
+// Load returns one page of userID's feed, highest score first, and the
+// cursor of the next page, which is nil on the last page. The first page is
+// ranked at now and later pages at the cursor's AsOf. Dreams created after
+// that time wait for the next first page. A dream that several sources
+// return appears once, with all of them in Sources.
+//
+// Scores mix recency with activity and tags, so they cannot bound the
+// source queries. Each source instead contributes its CandidatesPerSource
+// newest, or for popular dreams most active, dreams created by AsOf, so
+// dreams posted while a client is paging do not push candidates out. The
+// feed ends with a nil cursor once all of them have been paged through;
+// older dreams are left to the per-user and public listings.
+//
+// Scores are recomputed for every page, so a dream whose activity changes
+// while the client is paging can move across the cursor and be skipped or
+// shown twice; page sizes are small enough that this is rare.
+func Load(ctx context.Context, st Store, userID string, limit int, after *Cursor, now time.Time) ([]Item, *Cursor, error) {
+	asOf := now
+	if after != nil {
+		asOf = after.AsOf
+	}
+	// Listings continue after a cursor, so one at asOf with the largest row
+	// ID leaves out only dreams created later
+	candidates := model.Page{Limit: CandidatesPerSource, After: &model.Cursor{CreatedAt: asOf, ID: math.MaxInt32}}
+
+	own, _, err := st.ListDreams(ctx, model.DreamFilter{
+		Owners: []string{userID},
+		Viewer: userID,
+		Page:   candidates,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var friends []model.Dream
+	friendIDs, err := st.FriendIDs(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(friendIDs) > 0 {
+		friends, _, err = st.ListDreams(ctx, model.DreamFilter{
+			Owners: friendIDs,
+			Viewer: userID,
+			Page:   candidates,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	popular, err := st.PopularDreams(ctx, userID, asOf.Add(-PopularWindow), asOf, CandidatesPerSource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := []Item{}
+	byRowID := map[int]int{}
+	add := func(dreams []model.Dream, src Source) {
+		for _, d := range dreams {
+			if i, ok := byRowID[d.RowID]; ok {
+				items[i].Sources = append(items[i].Sources, src)
+				continue
+			}
+			byRowID[d.RowID] = len(items)
+			items = append(items, Item{Dream: d, Sources: []Source{src}})
+		}
+	}
+	add(own, SourceOwn)
+	add(friends, SourceFriends)
+	add(popular, SourcePopular)
+
+	ownTags := map[string]bool{}
+	for _, d := range own {
+		for tag := range tagSet(d.Tags) {
+			ownTags[tag] = true
+		}
+	}
+	rowIDs := make([]int, len(items))
+	for i, it := range items {
+		rowIDs[i] = it.Dream.RowID
+	}
+	comments, err := st.CountComments(ctx, rowIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ranked := items[:0]
+	for _, it := range items {
+		it.Comments = comments[it.Dream.RowID]
+		tags := ownTags
+		if it.Dream.UserID == userID {
+			tags = nil
+		}
+		it.Score = Score(&it.Dream, it.Comments, tags, asOf)
+		if after == nil || !after.before(it.Score, it.Dream.RowID) {
+			ranked = append(ranked, it)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Dream.RowID > ranked[j].Dream.RowID
+	})
+	if limit <= 0 || len(ranked) <= limit {
+		return ranked, nil, nil
+	}
+	last := ranked[limit-1]
+	return ranked[:limit], &Cursor{AsOf: asOf, Score: last.Score, ID: last.Dream.RowID}, nil
+}
@@ -0,0 +1,229 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store/memstore"
+)
+
+func TestScore(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	dream := func(age time.Duration, tags ...string) *model.Dream {
+		return &model.Dream{CreatedAt: now.Add(-age), Tags: tags, Reactions: model.NewReactionCounts()}
+	}
+	if got := Score(dream(0), 0, nil, now); got != RecencyWeight {
+		t.Errorf("new dream scored %v, want %v", got, RecencyWeight)
+	}
+	if got := Score(dream(HalfLife), 0, nil, now); got != RecencyWeight/2 {
+		t.Errorf("dream one half-life old scored %v, want %v", got, RecencyWeight/2)
+	}
+	if got := Score(dream(-time.Hour), 0, nil, now); got != RecencyWeight {
+		t.Errorf("dream from the future scored %v, want %v", got, RecencyWeight)
+	}
+
+	quiet := Score(dream(time.Hour), 0, nil, now)
+	reacted := dream(time.Hour)
+	reacted.Reactions[model.ReactionSpooky] = 2
+	if a, b := Score(reacted, 0, nil, now), Score(dream(time.Hour), 1, nil, now); a <= quiet || a != b {
+		t.Errorf("two reactions scored %v and one comment %v, want equal and above %v", a, b, quiet)
+	}
+
+	own := map[string]bool{"water": true, "flying": true}
+	half := Score(dream(time.Hour, "Water", "teeth"), 0, own, now)
+	if want := quiet + TagWeight/2; half != want {
+		t.Errorf("half the tags shared scored %v, want %v", half, want)
+	}
+	if got := Score(dream(time.Hour, "teeth"), 0, own, now); got != quiet {
+		t.Errorf("no tags shared scored %v, want %v", got, quiet)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c := Cursor{AsOf: time.Date(2026, 1, 10, 12, 0, 0, 123000, time.UTC), Score: 1.0 / 3, ID: 42}
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != c {
+		t.Errorf("round trip = %+v, want %+v", *got, c)
+	}
+	for _, bad := range []string{"", "!!", "MTox", model.Cursor{ID: 1}.Encode()} {
+		if _, err := DecodeCursor(bad); err == nil {
+			t.Errorf("DecodeCursor(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	st := memstore.New()
+	user := func(name string) *model.User {
+		t.Helper()
+		u := &model.User{Email: name + "@example.com", Username: name}
+		if err := st.CreateUser(ctx, u, "hash"); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	dream := func(owner *model.User, title string, v model.Visibility) *model.Dream {
+		t.Helper()
+		d := &model.Dream{UserID: owner.ID, Title: title, Text: title, Visibility: v}
+		if err := st.CreateDream(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	ann, bob, carl, dan := user("ann"), user("bob"), user("carl"), user("dan")
+	if _, _, err := st.RequestFriend(ctx, ann.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AcceptFriend(ctx, ann.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.BlockUser(ctx, ann.ID, dan.ID); err != nil {
+		t.Fatal(err)
+	}
+	mine := dream(ann, "Mine", model.VisibilityPrivate)
+	dream(bob, "Shared", model.VisibilityFriends)
+	dream(bob, "Both", model.VisibilityPublic)
+	popular := dream(carl, "Popular", model.VisibilityPublic)
+	dream(carl, "Private", model.VisibilityPrivate)
+	dream(dan, "Blocked", model.VisibilityPublic)
+	if err := st.ReplaceTags(ctx, mine.RowID, []string{"water"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.ReplaceTags(ctx, popular.RowID, []string{"Water"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var after *Cursor
+	// memstore may nudge timestamps a little past the clock
+	now := time.Now().Add(time.Second)
+	for page := 0; page < 5; page++ {
+		items, next, err := Load(ctx, st, ann.ID, 2, after, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range items {
+			got = append(got, fmt.Sprintf("%s%v", it.Dream.Title, it.Sources))
+		}
+		if next == nil {
+			break
+		}
+		after = next
+	}
+	// Popular shares a tag with ann's dream, which lifts it to the top
+	want := "[Popular[popular] Both[friends popular] Shared[friends] Mine[own]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("feed = %v, want %s", got, want)
+	}
+
+	later := dream(ann, "Later", model.VisibilityPrivate)
+	ranked := later.CreatedAt.Add(-time.Microsecond)
+	items, _, err := Load(ctx, st, ann.ID, 10, &Cursor{AsOf: ranked, Score: 100}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range items {
+		if it.Dream.ID == later.ID {
+			t.Errorf("a later page showed a dream created after it was ranked")
+		}
+	}
+}
+
+func TestLoadEndsWithCandidates(t *testing.T) {
+	ctx := context.Background()
+	st := memstore.New()
+	ann := &model.User{Email: "ann@example.com", Username: "ann"}
+	if err := st.CreateUser(ctx, ann, "hash"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < CandidatesPerSource+5; i++ {
+		d := &model.Dream{UserID: ann.ID, Title: fmt.Sprint(i), Text: "Dream", Visibility: model.VisibilityPrivate}
+		if err := st.CreateDream(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[int]bool{}
+	var after *Cursor
+	now := time.Now().Add(time.Second)
+	for page := 0; ; page++ {
+		if page > CandidatesPerSource {
+			t.Fatal("paging did not end")
+		}
+		items, next, err := Load(ctx, st, ann.ID, 40, after, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range items {
+			if seen[it.Dream.RowID] {
+				t.Errorf("dream %s shown twice", it.Dream.Title)
+			}
+			seen[it.Dream.RowID] = true
+		}
+		if next == nil {
+			break
+		}
+		after = next
+	}
+	if len(seen) != CandidatesPerSource {
+		t.Errorf("feed showed %d dreams, want the %d candidates", len(seen), CandidatesPerSource)
+	}
+}
+
+func TestLoadIgnoresNewDreams(t *testing.T) {
+	ctx := context.Background()
+	st := memstore.New()
+	ann := &model.User{Email: "ann@example.com", Username: "ann"}
+	if err := st.CreateUser(ctx, ann, "hash"); err != nil {
+		t.Fatal(err)
+	}
+	dream := func(title string) *model.Dream {
+		t.Helper()
+		d := &model.Dream{UserID: ann.ID, Title: title, Text: "Dream", Visibility: model.VisibilityPublic}
+		if err := st.CreateDream(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	var last *model.Dream
+	for i := 0; i < CandidatesPerSource; i++ {
+		last = dream(fmt.Sprint(i))
+	}
+
+	// Dreams posted after the first page would be the newest candidates,
+	// but later pages are ranked as of the first one
+	seen := map[int]bool{}
+	var after *Cursor
+	for page := 0; ; page++ {
+		if page > CandidatesPerSource {
+			t.Fatal("paging did not end")
+		}
+		items, next, err := Load(ctx, st, ann.ID, 40, after, last.CreatedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range items {
+			if seen[it.Dream.RowID] {
+				t.Errorf("dream %s shown twice", it.Dream.Title)
+			}
+			seen[it.Dream.RowID] = true
+		}
+		if next == nil {
+			break
+		}
+		after = next
+		for i := 0; i < 10; i++ {
+			dream(fmt.Sprint("new ", page, i))
+		}
+	}
+	if len(seen) != CandidatesPerSource {
+		t.Errorf("feed showed %d dreams, want the %d from before the first page", len(seen), CandidatesPerSource)
+	}
+}
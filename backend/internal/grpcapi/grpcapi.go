@@ -0,0 +1,631 @@
+// Package grpcapi implements the DreamJournal gRPC service on top of the
+// same store, authenticator and AI provider as the REST API
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/accounts"
+	"github.com/Calrus/ourdreamjournal/backend/internal/activity"
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/authz"
+	"github.com/Calrus/ourdreamjournal/backend/internal/events"
+	"github.com/Calrus/ourdreamjournal/backend/internal/insights"
+	"github.com/Calrus/ourdreamjournal/backend/internal/mail"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/ratelimit"
+	"github.com/Calrus/ourdreamjournal/backend/internal/sessions"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/internal/validate"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+	pb "github.com/Calrus/ourdreamjournal/backend/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+	// nextCursorTrailer carries the next page's cursor of a streamed list
+	nextCursorTrailer = "next-cursor"
+)
+
+// Notifier wakes the AI job workers after the store queued jobs
+type Notifier interface {
+	Notify()
+}
+
+// Server implements pb.DreamJournalServer
+type Server struct {
+	pb.UnimplementedDreamJournalServer
+
+	store  store.Store
+	auth   auth.Authenticator
+	policy *authz.Policy
+	ai     ai.DreamAI
+	jobs   Notifier
+
+	// InsightConcurrency caps the summaries generated in parallel for one
+	// GetAIInsights call
+	InsightConcurrency int
+	// Lockout counts failed logins per email. Share the REST server's so a
+	// locked account cannot keep guessing over gRPC.
+	Lockout *ratelimit.Lockout
+	// AuthLimiter charges the sign-in RPCs per peer IP and AILimiter the
+	// RPCs that call the AI model per peer IP and account. Share the REST
+	// server's Limiters so each quota covers both APIs. Nil limiters allow
+	// everything. They are read by NewGRPCServer.
+	AuthLimiter *ratelimit.Limiter
+	AILimiter   *ratelimit.Limiter
+	// RefreshTTL is how long a refresh token stays valid
+	RefreshTTL time.Duration
+	// Events receives the events friend requests publish. Use the REST
+	// server's Events so they reach its GET /api/events streams.
+	Events events.Publisher
+	// Mailer sends the verification links of accounts created by Register.
+	// They point at the frontend under AppURL and stay valid for VerifyTTL,
+	// so use the REST server's settings.
+	Mailer    mail.Mailer
+	AppURL    string
+	VerifyTTL time.Duration
+}
+
+func New(st store.Store, authn auth.Authenticator, dreamAI ai.DreamAI, queue Notifier) *Server {
+	return &Server{store: st, auth: authn, policy: authz.New(st), ai: dreamAI, jobs: queue, InsightConcurrency: 5, Lockout: ratelimit.NewLockout(), RefreshTTL: 30 * 24 * time.Hour, Events: events.NewHub(), Mailer: mail.Log{}, AppURL: "http://localhost:3000", VerifyTTL: 48 * time.Hour}
+}
+
+// Listen serves the DreamJournal service on the given port in the background
+func (s *Server) Listen(port int) (*grpc.Server, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	gs := s.NewGRPCServer()
+	go func() {
+		log.Printf("gRPC server listening on :%d", port)
+		if err := gs.Serve(lis); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+	return gs, nil
+}
+
+// NewGRPCServer returns a grpc.Server with the DreamJournal service and its
+// interceptors registered
+func (s *Server) NewGRPCServer() *grpc.Server {
+	authUnary, authStream := limit(authMethods, s.AuthLimiter)
+	aiUnary, aiStream := limit(aiMethods, s.AILimiter)
+	gs := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authUnary, s.authUnaryInterceptor, aiUnary),
+		grpc.ChainStreamInterceptor(authStream, s.authStreamInterceptor, aiStream),
+	)
+	pb.RegisterDreamJournalServer(gs, s)
+	return gs
+}
+
+// authenticate reads an optional "authorization: Bearer <jwt>" metadata
+// entry and stores the caller's authz.Principal in the returned context.
+// Requests without a token pass through; handlers that need a user call
+// requireUser.
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ctx, nil
+	}
+	token, err := auth.BearerToken(values[0])
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+	userID, expires, err := s.auth.ParseToken(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	u, err := s.store.GetUser(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "user no longer exists")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "failed to load user")
+	}
+	return authz.WithPrincipal(ctx, &authz.Principal{UserID: u.ID, IsAdmin: u.IsAdmin, ExpiresAt: expires}), nil
+}
+
+func (s *Server) authUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authServerStream overrides the stream context with the authenticated one
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Server) authStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// requireUser returns the authenticated user ID or an Unauthenticated error
+func requireUser(ctx context.Context) (string, error) {
+	userID := authz.FromContext(ctx).ID()
+	if userID == "" {
+		return "", status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+	return userID, nil
+}
+
+// denied converts an error from s.policy to a gRPC status. Hidden resources
+// are reported as NotFound, exactly like missing ones.
+func denied(err error) error {
+	switch {
+	case errors.Is(err, authz.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, "missing authorization metadata")
+	case errors.Is(err, authz.ErrForbidden):
+		return status.Error(codes.PermissionDenied, "forbidden")
+	case errors.Is(err, authz.ErrHidden):
+		return status.Error(codes.NotFound, "not found")
+	default:
+		return status.Error(codes.Internal, "failed to check permissions")
+	}
+}
+
+func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.AuthResponse, error) {
+	err := validate.Struct(struct {
+		Email    string `json:"email" validate:"required,email"`
+		Username string `json:"username" validate:"required,username"`
+		Password string `json:"password" validate:"required,password"`
+	}{req.Email, req.Username, req.Password})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	user, err := s.accounts().Register(ctx, req.Email, req.Username, req.Password)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, status.Error(codes.AlreadyExists, "user already exists")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "failed to create user")
+	}
+	return s.authResponse(ctx, user)
+}
+
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.AuthResponse, error) {
+	if req.Email == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing required fields")
+	}
+	key := auth.LoginKey(req.Email)
+	if retry := s.Lockout.Locked(key); retry > 0 {
+		return nil, tooManyRequests(retry)
+	}
+	user, passwordHash, err := s.store.GetCredentials(ctx, req.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, status.Error(codes.Internal, "failed to look up user")
+	}
+	if !auth.CheckPassword(passwordHash, req.Password) {
+		s.Lockout.Fail(key)
+		return nil, status.Error(codes.Unauthenticated, "invalid email or password")
+	}
+	s.Lockout.Reset(key)
+	return s.authResponse(ctx, user)
+}
+
+func (s *Server) authResponse(ctx context.Context, u *model.User) (*pb.AuthResponse, error) {
+	sess, err := s.sessions().Issue(ctx, u.ID, "")
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to issue session")
+	}
+	return &pb.AuthResponse{
+		User: &pb.User{
+			Id:        u.ID,
+			Email:     u.Email,
+			Username:  u.Username,
+			CreatedAt: u.CreatedAt,
+		},
+		Token:        sess.Token,
+		RefreshToken: sess.RefreshToken,
+	}, nil
+}
+
+// activity returns the notifier that publishes to s.Events
+func (s *Server) activity() *activity.Notifier {
+	return &activity.Notifier{Store: s.store, Events: s.Events}
+}
+
+// accounts returns the account manager for the server's settings
+func (s *Server) accounts() *accounts.Manager {
+	return &accounts.Manager{Store: s.store, Mailer: s.Mailer, AppURL: s.AppURL, VerifyTTL: s.VerifyTTL}
+}
+
+// sessions returns the session manager for the server's settings
+func (s *Server) sessions() *sessions.Manager {
+	return &sessions.Manager{Store: s.store, Auth: s.auth, TTL: s.RefreshTTL}
+}
+
+func (s *Server) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.Session, error) {
+	if req.RefreshToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing refresh token")
+	}
+	sess, err := s.sessions().Refresh(ctx, req.RefreshToken)
+	if errors.Is(err, store.ErrRevoked) {
+		log.Printf("[REFRESH] Reused or revoked refresh token presented over gRPC; family revoked")
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	} else if errors.Is(err, sessions.ErrExpired) {
+		return nil, status.Error(codes.Unauthenticated, "refresh token expired")
+	} else if errors.Is(err, store.ErrNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "failed to refresh token")
+	}
+	return &pb.Session{Token: sess.Token, RefreshToken: sess.RefreshToken}, nil
+}
+
+func (s *Server) Logout(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.LogoutResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing refresh token")
+	}
+	if err := s.sessions().Revoke(ctx, req.RefreshToken); err != nil {
+		return nil, status.Error(codes.Internal, "failed to log out")
+	}
+	return &pb.LogoutResponse{}, nil
+}
+
+func (s *Server) CreateDream(ctx context.Context, req *pb.DreamRequest) (*pb.DreamResponse, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId != "" && req.UserId != userID {
+		return nil, status.Error(codes.PermissionDenied, "cannot create dreams for another user")
+	}
+	err = validate.Struct(struct {
+		Title      string  `json:"title" validate:"max=200"`
+		Text       string  `json:"text" validate:"required,max=20000"`
+		Visibility *string `json:"visibility" validate:"oneof=private friends public unlisted"`
+	}{req.Title, req.Text, optionalString(req.Visibility)})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// Zero means "not rated" in proto3, anything else must be 1-10
+	ratings := []int32{req.NightmareRating, req.VividnessRating, req.ClarityRating, req.EmotionalIntensityRating}
+	for _, r := range ratings {
+		if r != 0 && (r < 1 || r > 10) {
+			return nil, status.Error(codes.InvalidArgument, "all ratings must be between 1 and 10")
+		}
+	}
+	dream := model.Dream{
+		UserID:                   userID,
+		Title:                    req.Title,
+		Text:                     req.Text,
+		Visibility:               model.VisibilityOf(req.Public),
+		NightmareRating:          optionalRating(req.NightmareRating),
+		VividnessRating:          optionalRating(req.VividnessRating),
+		ClarityRating:            optionalRating(req.ClarityRating),
+		EmotionalIntensityRating: optionalRating(req.EmotionalIntensityRating),
+	}
+	if req.Visibility != "" {
+		dream.Visibility = model.Visibility(req.Visibility)
+	}
+	if err := s.store.CreateDream(ctx, &dream, jobs.KindTags); err != nil {
+		log.Printf("[GRPC] Failed to create dream: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create dream")
+	}
+	s.jobs.Notify()
+	return &pb.DreamResponse{Dream: protoDream(dream)}, nil
+}
+
+// ListDreams streams the caller's dreams (or the dreams of another user the
+// caller may see listed when user_id is set), optionally followed by the
+// dreams other users have shared with the caller.
+func (s *Server) ListDreams(req *pb.ListRequest, stream pb.DreamJournal_ListDreamsServer) error {
+	ctx := stream.Context()
+	callerID, err := requireUser(ctx)
+	if err != nil {
+		return err
+	}
+	targetID := req.UserId
+	if targetID == "" {
+		targetID = callerID
+	}
+	page, err := listPage(req.Limit, req.Cursor)
+	if err != nil {
+		return err
+	}
+	f := model.DreamFilter{Viewer: callerID, Page: page}
+	if !req.IncludePublic {
+		f.Owners = []string{targetID}
+	} else if targetID != callerID {
+		// The target's public dreams are part of everyone's public dreams
+		f.PublicOnly = true
+	}
+	dreams, next, err := s.store.ListDreams(ctx, f)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to fetch dreams")
+	}
+	if next != nil {
+		stream.SetTrailer(metadata.Pairs(nextCursorTrailer, next.Encode()))
+	}
+	for _, d := range dreams {
+		if err := stream.Send(protoDream(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) SummarizeDream(ctx context.Context, req *pb.DreamRequest) (*pb.DreamSummary, error) {
+	if _, err := requireUser(ctx); err != nil {
+		return nil, err
+	}
+	summary, err := s.ai.Summarize(ctx, req.Text)
+	if err != nil {
+		return nil, aiStatusError(err)
+	}
+	return &pb.DreamSummary{Summary: summary}, nil
+}
+
+func (s *Server) DreamProphecy(ctx context.Context, req *pb.DreamRequest) (*pb.ProphecyResponse, error) {
+	if _, err := requireUser(ctx); err != nil {
+		return nil, err
+	}
+	prophecy, err := s.ai.Prophesy(ctx, req.Text)
+	if err != nil {
+		return nil, aiStatusError(err)
+	}
+	return &pb.ProphecyResponse{Prophecy: prophecy}, nil
+}
+
+func (s *Server) TagDream(ctx context.Context, req *pb.DreamRequest) (*pb.TagResponse, error) {
+	if _, err := requireUser(ctx); err != nil {
+		return nil, err
+	}
+	tags, err := s.ai.ExtractTags(ctx, req.Text)
+	if err != nil {
+		return nil, aiStatusError(err)
+	}
+	return &pb.TagResponse{Tags: tags}, nil
+}
+
+// GetAIInsights streams the cached (or freshly generated) summary and the
+// stored tags for the caller's most recent dreams. Admins may ask for
+// another user's.
+func (s *Server) GetAIInsights(req *pb.UserRequest, stream pb.DreamJournal_GetAIInsightsServer) error {
+	ctx := stream.Context()
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return err
+	}
+	if req.UserId != "" {
+		userID = req.UserId
+	}
+	if err := s.policy.ReadPrivate(authz.FromContext(ctx), userID); err != nil {
+		return denied(err)
+	}
+	result, err := insights.Load(ctx, s.store, s.ai, s.InsightConcurrency, userID)
+	if errors.Is(err, ai.ErrNotConfigured) {
+		return aiStatusError(err)
+	} else if err != nil {
+		return status.Error(codes.Internal, "failed to fetch dreams")
+	}
+	for _, in := range result {
+		if err := stream.Send(&pb.DreamInsight{DreamId: in.DreamID, Summary: in.Summary, Tags: in.Tags}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) SendFriendRequest(ctx context.Context, req *pb.FriendRequestMsg) (*pb.FriendResponseMsg, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId != userID {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	if req.FriendId == userID {
+		return nil, status.Error(codes.InvalidArgument, "cannot befriend yourself")
+	}
+	current, created, err := s.store.RequestFriend(ctx, req.UserId, req.FriendId)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	} else if errors.Is(err, store.ErrConflict) {
+		return nil, status.Error(codes.FailedPrecondition, "unblock the user first")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "failed to send friend request")
+	}
+	// Only a new request or friendship is news to friend_id
+	if created && current == "pending" {
+		s.activity().FriendRequested(ctx, req.UserId, req.FriendId)
+	} else if created && current == "accepted" {
+		// friend_id had asked first, so this accepted their request
+		s.activity().FriendAccepted(ctx, req.FriendId, req.UserId)
+	}
+	return &pb.FriendResponseMsg{Status: current}, nil
+}
+
+func (s *Server) AcceptFriendRequest(ctx context.Context, req *pb.FriendRequestMsg) (*pb.FriendResponseMsg, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.FriendId != userID {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	if err := s.store.AcceptFriend(ctx, req.UserId, req.FriendId); errors.Is(err, store.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "friend request not found")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, "failed to accept friend request")
+	}
+	s.activity().FriendAccepted(ctx, req.UserId, req.FriendId)
+	return &pb.FriendResponseMsg{Status: "accepted"}, nil
+}
+
+func (s *Server) RemoveFriend(ctx context.Context, req *pb.FriendRequestMsg) (*pb.FriendResponseMsg, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId != userID && req.FriendId != userID {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	if err := s.store.RemoveFriend(ctx, req.UserId, req.FriendId); err != nil {
+		return nil, status.Error(codes.Internal, "failed to remove friend")
+	}
+	s.activity().FriendRemoved(ctx, userID, req.UserId, req.FriendId)
+	return &pb.FriendResponseMsg{Status: "removed"}, nil
+}
+
+func (s *Server) ListFriends(ctx context.Context, req *pb.UserRequest) (*pb.FriendList, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId != "" {
+		userID = req.UserId
+	}
+	if err := s.policy.ListFriends(ctx, authz.FromContext(ctx), userID); err != nil {
+		return nil, denied(err)
+	}
+	friends, err := s.store.ListFriends(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list friends")
+	}
+	list := &pb.FriendList{}
+	for _, f := range friends {
+		list.Friends = append(list.Friends, &pb.Friend{
+			Id:              f.ID,
+			Username:        f.Username,
+			DisplayName:     f.DisplayName,
+			ProfileImageUrl: f.ProfileImageURL,
+		})
+	}
+	return list, nil
+}
+
+func (s *Server) ListFriendsDreams(ctx context.Context, req *pb.FriendsDreamsRequest) (*pb.FriendsDreamsResponse, error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId != "" {
+		userID = req.UserId
+	}
+	if err := s.policy.ReadPrivate(authz.FromContext(ctx), userID); err != nil {
+		return nil, denied(err)
+	}
+	page, err := listPage(req.Limit, req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	friendIDs, err := s.store.FriendIDs(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch friends' dreams")
+	}
+	resp := &pb.FriendsDreamsResponse{}
+	if len(friendIDs) == 0 {
+		return resp, nil
+	}
+	// Viewing as userID includes their friends' friends-only dreams and
+	// leaves out anyone they blocked, as the REST listing does
+	dreams, next, err := s.store.ListDreams(ctx, model.DreamFilter{Owners: friendIDs, Viewer: userID, Page: page})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch friends' dreams")
+	}
+	for _, d := range dreams {
+		resp.Dreams = append(resp.Dreams, protoDream(d))
+	}
+	if next != nil {
+		resp.NextCursor = next.Encode()
+	}
+	return resp, nil
+}
+
+// listPage reads the limit and cursor of a paged request, where a zero
+// limit means the default page size
+func listPage(limit int32, cursor string) (model.Page, error) {
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	if limit < 1 || limit > maxPageLimit {
+		return model.Page{}, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", maxPageLimit)
+	}
+	p := model.Page{Limit: int(limit)}
+	if cursor != "" {
+		after, err := model.DecodeCursor(cursor)
+		if err != nil {
+			return p, status.Error(codes.InvalidArgument, err.Error())
+		}
+		p.After = after
+	}
+	return p, nil
+}
+
+// protoDream converts a dream to its gRPC message
+func protoDream(d model.Dream) *pb.Dream {
+	rating := func(v *int) int32 {
+		if v == nil {
+			return 0
+		}
+		return int32(*v)
+	}
+	return &pb.Dream{
+		Id:                       d.ID,
+		UserId:                   d.UserID,
+		Title:                    d.Title,
+		Text:                     d.Text,
+		Public:                   d.Visibility == model.VisibilityPublic,
+		Visibility:               string(d.Visibility),
+		Timestamp:                d.CreatedAt.Unix(),
+		NightmareRating:          rating(d.NightmareRating),
+		VividnessRating:          rating(d.VividnessRating),
+		ClarityRating:            rating(d.ClarityRating),
+		EmotionalIntensityRating: rating(d.EmotionalIntensityRating),
+	}
+}
+
+// optionalRating maps the proto3 zero value to an absent rating
+func optionalRating(r int32) *int {
+	if r == 0 {
+		return nil
+	}
+	v := int(r)
+	return &v
+}
+
+// optionalString maps the proto3 zero value to an absent string
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// aiStatusError maps DreamAI failures to gRPC status errors
+func aiStatusError(err error) error {
+	if errors.Is(err, ai.ErrNotConfigured) {
+		return status.Error(codes.FailedPrecondition, "OpenAI API key not set")
+	}
+	log.Printf("[GRPC] AI error: %v", err)
+	return status.Error(codes.Unavailable, "AI request failed")
+}
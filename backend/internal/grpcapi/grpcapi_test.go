@@ -0,0 +1,382 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/events"
+	"github.com/Calrus/ourdreamjournal/backend/internal/mail"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/ratelimit"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store/memstore"
+	pb "github.com/Calrus/ourdreamjournal/backend/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testEnv is a Server backed by the in-memory store and the deterministic
+// AI provider, served over an in-process connection
+type testEnv struct {
+	store  *memstore.Store
+	api    *Server
+	client pb.DreamJournalClient
+}
+
+func newTestEnv(t *testing.T, configure ...func(*Server)) *testEnv {
+	t.Helper()
+	st := memstore.New()
+	authn, err := auth.NewJWT(map[string][]byte{"test": []byte("test-secret")}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(st, authn, ai.Fake{}, st)
+	for _, f := range configure {
+		f(s)
+	}
+	lis := bufconn.Listen(1 << 20)
+	gs := s.NewGRPCServer()
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testEnv{store: st, api: s, client: pb.NewDreamJournalClient(conn)}
+}
+
+// register signs up a user and returns their ID and a context carrying
+// their token
+func (e *testEnv) register(t *testing.T, username string) (string, context.Context) {
+	t.Helper()
+	resp, err := e.client.Register(context.Background(), &pb.RegisterRequest{
+		Email:    username + "@example.com",
+		Username: username,
+		Password: "correct horse battery",
+	})
+	if err != nil {
+		t.Fatalf("Register(%s): %v", username, err)
+	}
+	return resp.User.Id, metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+resp.Token)
+}
+
+func (e *testEnv) createDream(t *testing.T, ctx context.Context, title string, v model.Visibility) *pb.Dream {
+	t.Helper()
+	resp, err := e.client.CreateDream(ctx, &pb.DreamRequest{Title: title, Text: title + ".", Visibility: string(v)})
+	if err != nil {
+		t.Fatalf("CreateDream(%s): %v", title, err)
+	}
+	return resp.Dream
+}
+
+func expectCode(t *testing.T, what string, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("%s: got %v (%v), want %v", what, got, err, want)
+	}
+}
+
+func titles(dreams []*pb.Dream) string {
+	list := []string{}
+	for _, d := range dreams {
+		list = append(list, d.Title)
+	}
+	return fmt.Sprint(list)
+}
+
+func TestListFriendsDreams(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	carlID, carl := e.register(t, "carl")
+	for _, friendID := range []string{bobID, carlID} {
+		if _, err := e.client.SendFriendRequest(ann, &pb.FriendRequestMsg{UserId: annID, FriendId: friendID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := e.client.AcceptFriendRequest(bob, &pb.FriendRequestMsg{UserId: annID, FriendId: bobID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.client.AcceptFriendRequest(carl, &pb.FriendRequestMsg{UserId: annID, FriendId: carlID}); err != nil {
+		t.Fatal(err)
+	}
+	e.createDream(t, bob, "Private", model.VisibilityPrivate)
+	e.createDream(t, bob, "Friends", model.VisibilityFriends)
+	e.createDream(t, bob, "Public", model.VisibilityPublic)
+	e.createDream(t, carl, "Carl's", model.VisibilityFriends)
+
+	list := func(req *pb.FriendsDreamsRequest) *pb.FriendsDreamsResponse {
+		t.Helper()
+		resp, err := e.client.ListFriendsDreams(ann, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if got := titles(list(&pb.FriendsDreamsRequest{}).Dreams); got != "[Carl's Public Friends]" {
+		t.Errorf("friends' dreams = %s, want friends-only ones included", got)
+	}
+
+	first := list(&pb.FriendsDreamsRequest{Limit: 2})
+	if first.NextCursor == "" {
+		t.Fatal("first page has no next_cursor")
+	}
+	second := list(&pb.FriendsDreamsRequest{Limit: 2, Cursor: first.NextCursor})
+	if got := titles(append(first.Dreams, second.Dreams...)); got != "[Carl's Public Friends]" || second.NextCursor != "" {
+		t.Errorf("paged friends' dreams = %s (next %q)", got, second.NextCursor)
+	}
+
+	// Blocking ends the friendship, and the blocked user's dreams go with it
+	if err := e.store.BlockUser(context.Background(), annID, carlID); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(list(&pb.FriendsDreamsRequest{}).Dreams); got != "[Public Friends]" {
+		t.Errorf("friends' dreams after blocking = %s", got)
+	}
+
+	_, err := e.client.ListFriendsDreams(ann, &pb.FriendsDreamsRequest{Limit: 101})
+	expectCode(t, "limit too large", err, codes.InvalidArgument)
+	_, err = e.client.ListFriendsDreams(ann, &pb.FriendsDreamsRequest{Cursor: "nonsense"})
+	expectCode(t, "bad cursor", err, codes.InvalidArgument)
+	_, err = e.client.ListFriendsDreams(ann, &pb.FriendsDreamsRequest{UserId: bobID})
+	expectCode(t, "another user's friends' dreams", err, codes.PermissionDenied)
+}
+
+func TestListDreamsPages(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	for _, title := range []string{"First", "Second", "Third"} {
+		e.createDream(t, ann, title, model.VisibilityPrivate)
+	}
+	list := func(req *pb.ListRequest) ([]*pb.Dream, string) {
+		t.Helper()
+		stream, err := e.client.ListDreams(ann, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dreams []*pb.Dream
+		for {
+			d, err := stream.Recv()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			dreams = append(dreams, d)
+		}
+		var cursor string
+		if v := stream.Trailer().Get("next-cursor"); len(v) == 1 {
+			cursor = v[0]
+		}
+		return dreams, cursor
+	}
+
+	first, cursor := list(&pb.ListRequest{Limit: 2})
+	if got := titles(first); got != "[Third Second]" || cursor == "" {
+		t.Fatalf("first page = %s, cursor %q", got, cursor)
+	}
+	second, cursor := list(&pb.ListRequest{Limit: 2, Cursor: cursor})
+	if got := titles(second); got != "[First]" || cursor != "" {
+		t.Errorf("second page = %s, cursor %q", got, cursor)
+	}
+
+	stream, err := e.client.ListDreams(ann, &pb.ListRequest{Limit: 101})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	expectCode(t, "limit too large", err, codes.InvalidArgument)
+}
+
+func TestAIRateLimit(t *testing.T) {
+	e := newTestEnv(t, func(s *Server) { s.AILimiter = ratelimit.NewLimiter(ratelimit.Rate{Limit: 2, Per: time.Hour}) })
+	_, ann := e.register(t, "ann")
+
+	for i := 0; i < 2; i++ {
+		if _, err := e.client.TagDream(ann, &pb.DreamRequest{Text: "Flying over water."}); err != nil {
+			t.Fatalf("TagDream %d: %v", i+1, err)
+		}
+	}
+	_, err := e.client.SummarizeDream(ann, &pb.DreamRequest{Text: "Flying over water."})
+	expectCode(t, "third AI call", err, codes.ResourceExhausted)
+	stream, err := e.client.GetAIInsights(ann, &pb.UserRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	expectCode(t, "AI insights over the limit", err, codes.ResourceExhausted)
+
+	// Other RPCs have no AI quota
+	if _, err := e.client.ListFriends(ann, &pb.UserRequest{}); err != nil {
+		t.Errorf("ListFriends after the AI limit: %v", err)
+	}
+}
+
+func TestAuthRateLimit(t *testing.T) {
+	e := newTestEnv(t, func(s *Server) { s.AuthLimiter = ratelimit.NewLimiter(ratelimit.Rate{Limit: 2, Per: time.Hour}) })
+	bg := context.Background()
+
+	if _, err := e.client.Register(bg, &pb.RegisterRequest{Email: "ann@example.com", Username: "ann", Password: "correct horse battery"}); err != nil {
+		t.Fatal(err)
+	}
+	login, err := e.client.Login(bg, &pb.LoginRequest{Email: "ann@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.client.Login(bg, &pb.LoginRequest{Email: "ann@example.com", Password: "correct horse battery"})
+	expectCode(t, "third sign-in call", err, codes.ResourceExhausted)
+	_, err = e.client.Register(bg, &pb.RegisterRequest{Email: "bob@example.com", Username: "bob", Password: "correct horse battery"})
+	expectCode(t, "register over the limit", err, codes.ResourceExhausted)
+
+	// Signed-in RPCs have no auth quota
+	ctx := metadata.AppendToOutgoingContext(bg, "authorization", "Bearer "+login.Token)
+	if _, err := e.client.ListFriends(ctx, &pb.UserRequest{}); err != nil {
+		t.Errorf("ListFriends after the auth limit: %v", err)
+	}
+}
+
+// mailbox is a mail.Mailer that hands each message to a channel
+type mailbox chan mail.Message
+
+func (m mailbox) Send(ctx context.Context, msg mail.Message) error {
+	m <- msg
+	return nil
+}
+
+func TestRegisterSendsVerification(t *testing.T) {
+	box := make(mailbox, 1)
+	e := newTestEnv(t, func(s *Server) {
+		s.Mailer = box
+		s.AppURL = "https://sleeptalk.example"
+	})
+	e.register(t, "ann")
+	select {
+	case msg := <-box:
+		if msg.To != "ann@example.com" || !strings.Contains(msg.Body, "https://sleeptalk.example/verify-email?token=") {
+			t.Errorf("verification mail = %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no verification mail sent")
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	e := newTestEnv(t)
+	bg := context.Background()
+	reg, err := e.client.Register(bg, &pb.RegisterRequest{Email: "ann@example.com", Username: "ann", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.RefreshToken == "" {
+		t.Fatal("Register returned no refresh token")
+	}
+	login, err := e.client.Login(bg, &pb.LoginRequest{Email: "ann@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.RefreshToken == "" || login.RefreshToken == reg.RefreshToken {
+		t.Fatalf("Login refresh token = %q, want a new one", login.RefreshToken)
+	}
+
+	sess, err := e.client.RefreshToken(bg, &pb.RefreshTokenRequest{RefreshToken: reg.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Token == "" || sess.RefreshToken == "" || sess.RefreshToken == reg.RefreshToken {
+		t.Fatalf("RefreshToken = %+v, want a new token pair", sess)
+	}
+	ctx := metadata.AppendToOutgoingContext(bg, "authorization", "Bearer "+sess.Token)
+	if _, err := e.client.ListFriends(ctx, &pb.UserRequest{UserId: reg.User.Id}); err != nil {
+		t.Errorf("ListFriends with the refreshed token: %v", err)
+	}
+
+	// Replaying the used token revokes the family, including its successor
+	_, err = e.client.RefreshToken(bg, &pb.RefreshTokenRequest{RefreshToken: reg.RefreshToken})
+	expectCode(t, "replayed refresh", err, codes.Unauthenticated)
+	_, err = e.client.RefreshToken(bg, &pb.RefreshTokenRequest{RefreshToken: sess.RefreshToken})
+	expectCode(t, "refresh after replay", err, codes.Unauthenticated)
+	_, err = e.client.RefreshToken(bg, &pb.RefreshTokenRequest{RefreshToken: "bogus"})
+	expectCode(t, "unknown refresh token", err, codes.Unauthenticated)
+	_, err = e.client.RefreshToken(bg, &pb.RefreshTokenRequest{})
+	expectCode(t, "empty refresh token", err, codes.InvalidArgument)
+
+	// The login session is a separate family until it logs out
+	if _, err := e.client.Logout(bg, &pb.RefreshTokenRequest{RefreshToken: login.RefreshToken}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.client.RefreshToken(bg, &pb.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	expectCode(t, "refresh after logout", err, codes.Unauthenticated)
+	if _, err := e.client.Logout(bg, &pb.RefreshTokenRequest{RefreshToken: login.RefreshToken}); err != nil {
+		t.Errorf("second Logout: %v", err)
+	}
+}
+
+func TestFriendRequestActivity(t *testing.T) {
+	hub := events.NewHub()
+	e := newTestEnv(t, func(s *Server) { s.Events = hub })
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	annEvents, bobEvents := hub.Subscribe(annID, ""), hub.Subscribe(bobID, "")
+	defer annEvents.Close()
+	defer bobEvents.Close()
+	next := func(sub *events.Subscription, want, data string) {
+		t.Helper()
+		select {
+		case ev := <-sub.Events():
+			if ev.Type != want || !strings.Contains(string(ev.Data), data) {
+				t.Errorf("event = %s %s, want %s with %s", ev.Type, ev.Data, want, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+
+	req := &pb.FriendRequestMsg{UserId: bobID, FriendId: annID}
+	if _, err := e.client.SendFriendRequest(bob, req); err != nil {
+		t.Fatal(err)
+	}
+	next(annEvents, events.TypeFriend, `"pending"`)
+	next(annEvents, events.TypeNotification, string(model.NotifyFriendRequest))
+	// Asking again is not news
+	if _, err := e.client.SendFriendRequest(bob, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.client.AcceptFriendRequest(ann, req); err != nil {
+		t.Fatal(err)
+	}
+	next(bobEvents, events.TypeFriend, `"accepted"`)
+	next(bobEvents, events.TypeNotification, string(model.NotifyFriendAccepted))
+	select {
+	case ev := <-annEvents.Events():
+		t.Errorf("unexpected event for ann: %s %s", ev.Type, ev.Data)
+	default:
+	}
+
+	notes, _, err := e.store.ListNotifications(context.Background(), bobID, false, model.Page{})
+	if err != nil || len(notes) != 1 || notes[0].Type != model.NotifyFriendAccepted {
+		t.Errorf("bob's notifications = %+v, %v", notes, err)
+	}
+
+	// Ending the friendship tells the other side
+	if _, err := e.client.RemoveFriend(ann, req); err != nil {
+		t.Fatal(err)
+	}
+	next(bobEvents, events.TypeFriend, `"removed"`)
+	select {
+	case ev := <-annEvents.Events():
+		t.Errorf("unexpected event for ann: %s %s", ev.Type, ev.Data)
+	default:
+	}
+}
@@ -0,0 +1,94 @@
+package grpcapi
+
+import (
+	"context"
+	"math"
+	"net"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/authz"
+	"github.com/Calrus/ourdreamjournal/backend/internal/ratelimit"
+	pb "github.com/Calrus/ourdreamjournal/backend/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// aiMethods are the RPCs that call the AI model
+var aiMethods = map[string]bool{
+	pb.DreamJournal_SummarizeDream_FullMethodName: true,
+	pb.DreamJournal_DreamProphecy_FullMethodName:  true,
+	pb.DreamJournal_TagDream_FullMethodName:       true,
+	pb.DreamJournal_GetAIInsights_FullMethodName:  true,
+}
+
+// authMethods are the sign-in RPCs. They run before authentication, so
+// they are charged per peer IP only.
+var authMethods = map[string]bool{
+	pb.DreamJournal_Register_FullMethodName:     true,
+	pb.DreamJournal_Login_FullMethodName:        true,
+	pb.DreamJournal_RefreshToken_FullMethodName: true,
+	pb.DreamJournal_Logout_FullMethodName:       true,
+}
+
+// limit returns interceptors charging methods against limiter, once for the
+// caller's peer IP and, after authentication, once for their account, like
+// the REST route groups. A nil limiter allows everything.
+func limit(methods map[string]bool, limiter *ratelimit.Limiter) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if limiter != nil && methods[info.FullMethod] {
+			if err := allow(ctx, limiter); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if limiter != nil && methods[info.FullMethod] {
+			if err := allow(ss.Context(), limiter); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+	return unary, stream
+}
+
+// allow takes a token for the caller's peer IP and account
+func allow(ctx context.Context, limiter *ratelimit.Limiter) error {
+	keys := []string{"ip:" + peerIP(ctx)}
+	if id := authz.FromContext(ctx).ID(); id != "" {
+		keys = append(keys, "user:"+id)
+	}
+	for _, key := range keys {
+		if ok, retry := limiter.Allow(key); !ok {
+			return tooManyRequests(retry)
+		}
+	}
+	return nil
+}
+
+// peerIP returns the address the call came from, without the port
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// tooManyRequests is the error for a rate limit or lockout. Both look the
+// same, so a lockout does not reveal that an account exists.
+func tooManyRequests(retry time.Duration) error {
+	seconds := int(math.Ceil(retry.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return status.Errorf(codes.ResourceExhausted, "too many requests, retry after %d seconds", seconds)
+}
@@ -0,0 +1,92 @@
+// Package insights builds the AI insight summaries shared by the HTTP and
+// gRPC APIs
+package insights
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+// RecentDreams is how many recent dreams insights cover
+const RecentDreams = 5
+
+// Store is the subset of store.Store used to load and cache insights
+type Store interface {
+	ListDreams(ctx context.Context, f model.DreamFilter) ([]model.Dream, *model.Cursor, error)
+	SetSummary(ctx context.Context, rowID int, text, summary string) error
+}
+
+// Load returns insights for the user's most recent dreams. Summaries are
+// cached on the dream together with a hash of the text they were made from,
+// so the model is only called for dreams that are new or were edited. Those
+// calls run in parallel, at most concurrency at a time. A failed summary is
+// returned empty rather than failing the whole request, except when no AI
+// provider is configured.
+func Load(ctx context.Context, st Store, dreamAI ai.DreamAI, concurrency int, userID string) ([]model.DreamInsight, error) {
+	dreams, _, err := st.ListDreams(ctx, model.DreamFilter{
+		Owners: []string{userID},
+		Viewer: userID,
+		Page:   model.Page{Limit: RecentDreams},
+	})
+	if err != nil {
+		return nil, err
+	}
+	insights := make([]model.DreamInsight, len(dreams))
+	var missing []int
+	for i, d := range dreams {
+		insights[i] = model.DreamInsight{DreamID: d.ID, Tags: d.Tags}
+		if d.Summary != "" && d.SummaryHash == ai.ContentHash(d.Text) {
+			insights[i].Summary = d.Summary
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return insights, nil
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg            sync.WaitGroup
+		mu            sync.Mutex
+		notConfigured error
+	)
+	sem := make(chan struct{}, concurrency)
+	for _, i := range missing {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			d := dreams[i]
+			summary, err := dreamAI.Summarize(ctx, d.Text)
+			if err != nil {
+				if errors.Is(err, ai.ErrNotConfigured) {
+					mu.Lock()
+					notConfigured = err
+					mu.Unlock()
+				} else {
+					log.Printf("[INSIGHTS] Failed to summarize dream %s: %v", d.ID, err)
+				}
+				return
+			}
+			// Each goroutine owns its own slot, so no lock is needed here
+			insights[i].Summary = summary
+			if err := st.SetSummary(ctx, d.RowID, d.Text, summary); err != nil {
+				log.Printf("[INSIGHTS] Failed to cache summary for dream %s: %v", d.ID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if notConfigured != nil {
+		return nil, notConfigured
+	}
+	return insights, nil
+}
@@ -0,0 +1,137 @@
+// Package mail sends account emails such as password reset and email
+// verification links. SMTP delivers real mail; File and Log keep messages
+// local so the flows can be tried without a mail server.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a message
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New returns the mailer selected by cfg.MailProvider
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailProvider {
+	case "", "log":
+		return Log{From: cfg.MailFrom}, nil
+	case "file":
+		return &File{Dir: cfg.MailDir, From: cfg.MailFrom}, nil
+	case "smtp":
+		if cfg.SMTPAddr == "" {
+			return nil, fmt.Errorf("SMTP_ADDR is required with MAIL_PROVIDER=smtp")
+		}
+		return &SMTP{Addr: cfg.SMTPAddr, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.MailFrom}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail provider %q", cfg.MailProvider)
+	}
+}
+
+// SMTP sends mail through an SMTP server, using STARTTLS when the server
+// offers it and PLAIN auth when Username is set
+type SMTP struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	msg, err := format(s.From, m, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, address(s.From), []string{m.To}, msg)
+}
+
+// File writes each message to its own .eml file in Dir, for local
+// development and tests
+type File struct {
+	Dir  string
+	From string
+
+	seq atomic.Int64
+}
+
+func (f *File) Send(ctx context.Context, m Message) error {
+	now := time.Now()
+	msg, err := format(f.From, m, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0o700); err != nil {
+		return err
+	}
+	// Names sort in the order the messages were sent
+	name := fmt.Sprintf("%s-%04d.eml", now.UTC().Format("20060102T150405.000000000"), f.seq.Add(1))
+	return os.WriteFile(filepath.Join(f.Dir, name), msg, 0o600)
+}
+
+// Log writes each message to the standard logger. It is the default, so a
+// fresh checkout can follow reset and verification links from the log.
+type Log struct {
+	From string
+}
+
+func (l Log) Send(ctx context.Context, m Message) error {
+	if _, err := format(l.From, m, time.Now()); err != nil {
+		return err
+	}
+	log.Printf("[MAIL] To: %s\nSubject: %s\n\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// format renders m as an RFC 5322 message. Header values must not contain
+// line breaks, which would let a caller inject headers.
+func format(from string, m Message, date time.Time) ([]byte, error) {
+	for _, v := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("mail: line break in header value %q", v)
+		}
+	}
+	if m.To == "" {
+		return nil, fmt.Errorf("mail: missing recipient")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
+
+// address returns the bare address of a "Name <addr>" sender
+func address(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
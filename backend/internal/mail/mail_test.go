@@ -0,0 +1,77 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/config"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	f := &File{Dir: dir, From: "SleepTalk <no-reply@example.com>"}
+	for _, subject := range []string{"First", "Second"} {
+		if err := f.Send(context.Background(), Message{To: "ann@example.com", Subject: subject, Body: "Hello\nthere"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("wrote %d files, want 2", len(entries))
+	}
+	b, err := os.ReadFile(dir + "/" + entries[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(b)
+	for _, want := range []string{"From: SleepTalk <no-reply@example.com>\r\n", "To: ann@example.com\r\n", "Subject: First\r\n", "\r\n\r\nHello\r\nthere"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message is missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	for _, m := range []Message{
+		{To: "ann@example.com\r\nBcc: eve@example.com", Subject: "Hi"},
+		{To: "ann@example.com", Subject: "Hi\nBcc: eve@example.com"},
+		{Subject: "No recipient"},
+	} {
+		if _, err := format("no-reply@example.com", m, time.Now()); err == nil {
+			t.Errorf("format(%+v) succeeded", m)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	for from, want := range map[string]string{
+		"SleepTalk <no-reply@example.com>": "no-reply@example.com",
+		"no-reply@example.com":             "no-reply@example.com",
+	} {
+		if got := address(from); got != want {
+			t.Errorf("address(%q) = %q, want %q", from, got, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&config.Config{MailProvider: "smtp"}); err == nil {
+		t.Errorf("smtp without SMTP_ADDR succeeded")
+	}
+	if _, err := New(&config.Config{MailProvider: "pigeon"}); err == nil {
+		t.Errorf("unknown provider succeeded")
+	}
+	m, err := New(&config.Config{MailProvider: "file", MailDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*File); !ok {
+		t.Errorf("New(file) = %T", m)
+	}
+}
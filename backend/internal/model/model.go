@@ -0,0 +1,205 @@
+// Package model holds the domain types shared by the store, HTTP and gRPC
+// layers. JSON tags match the REST API.
+package model
+
+import (
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+type User struct {
+	ID              string `json:"id"`
+	Email           string `json:"email"`
+	Username        string `json:"username"`
+	DisplayName     string `json:"display_name"`
+	Description     string `json:"description"`
+	ProfileImageURL string `json:"profile_image_url"`
+	CreatedAt       int64  `json:"created_at"`
+	EmailVerified   bool   `json:"email_verified"`
+	IsAdmin         bool   `json:"-"`
+}
+
+// UserSummary is the short form of a user shown in friend lists and on
+// comments
+type UserSummary struct {
+	ID              string `json:"id"`
+	Username        string `json:"username"`
+	DisplayName     string `json:"display_name"`
+	ProfileImageURL string `json:"profile_image_url"`
+}
+
+// Visibility says who may see a dream
+type Visibility string
+
+const (
+	// VisibilityPrivate dreams are seen only by their owner and admins
+	VisibilityPrivate Visibility = "private"
+	// VisibilityFriends dreams are also seen by the owner's friends
+	VisibilityFriends Visibility = "friends"
+	// VisibilityPublic dreams are seen by everyone and listed in feeds,
+	// search and profiles
+	VisibilityPublic Visibility = "public"
+	// VisibilityUnlisted dreams are seen by anyone with the link but only
+	// listed for their owner
+	VisibilityUnlisted Visibility = "unlisted"
+)
+
+// Valid reports whether v is one of the visibility levels
+func (v Visibility) Valid() bool {
+	switch v {
+	case VisibilityPrivate, VisibilityFriends, VisibilityPublic, VisibilityUnlisted:
+		return true
+	}
+	return false
+}
+
+// VisibilityOf maps the old public flag to a visibility level
+func VisibilityOf(public bool) Visibility {
+	if public {
+		return VisibilityPublic
+	}
+	return VisibilityPrivate
+}
+
+type Dream struct {
+	ID                       string         `json:"id"`
+	UserID                   string         `json:"userId"`
+	Username                 string         `json:"username"`
+	DisplayName              string         `json:"displayName"`
+	ProfileImageURL          string         `json:"profileImageURL"`
+	Title                    string         `json:"title"`
+	Text                     string         `json:"text"`
+	Visibility               Visibility     `json:"visibility"`
+	CreatedAt                time.Time      `json:"createdAt"`
+	UpdatedAt                time.Time      `json:"updatedAt"`
+	Tags                     []string       `json:"tags,omitempty"`
+	NightmareRating          *int           `json:"nightmare_rating,omitempty"`
+	VividnessRating          *int           `json:"vividness_rating,omitempty"`
+	ClarityRating            *int           `json:"clarity_rating,omitempty"`
+	EmotionalIntensityRating *int           `json:"emotional_intensity_rating,omitempty"`
+	Reactions                ReactionCounts `json:"reactions"`
+	Jobs                     []*jobs.Job    `json:"jobs,omitempty"` // AI work queued by the request
+
+	RowID       int    `json:"-"` // dreams.id, used for tags, jobs and cursors
+	Summary     string `json:"-"` // cached AI summary, valid while SummaryHash matches the text
+	SummaryHash string `json:"-"`
+	Prophecy    string `json:"-"`
+}
+
+// Cursor returns the pagination cursor pointing at this dream
+func (d *Dream) Cursor() Cursor {
+	return Cursor{CreatedAt: d.CreatedAt, ID: d.RowID}
+}
+
+// DreamState holds the editable fields of a dream
+type DreamState struct {
+	Title                    string
+	Text                     string
+	Visibility               Visibility
+	NightmareRating          *int
+	VividnessRating          *int
+	ClarityRating            *int
+	EmotionalIntensityRating *int
+}
+
+func (s DreamState) Equal(o DreamState) bool {
+	return s.Title == o.Title && s.Text == o.Text && s.Visibility == o.Visibility &&
+		IntPtrEqual(s.NightmareRating, o.NightmareRating) &&
+		IntPtrEqual(s.VividnessRating, o.VividnessRating) &&
+		IntPtrEqual(s.ClarityRating, o.ClarityRating) &&
+		IntPtrEqual(s.EmotionalIntensityRating, o.EmotionalIntensityRating)
+}
+
+// IntPtrEqual compares two optional ratings
+func IntPtrEqual(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// DreamRevision is a snapshot of a dream's editable fields. Revision 1 is the
+// dream as originally created; the highest revision matches the live dream.
+type DreamRevision struct {
+	Revision                 int        `json:"revision"`
+	Title                    string     `json:"title"`
+	Text                     string     `json:"text"`
+	Visibility               Visibility `json:"visibility"`
+	NightmareRating          *int       `json:"nightmare_rating,omitempty"`
+	VividnessRating          *int       `json:"vividness_rating,omitempty"`
+	ClarityRating            *int       `json:"clarity_rating,omitempty"`
+	EmotionalIntensityRating *int       `json:"emotional_intensity_rating,omitempty"`
+	EditedBy                 string     `json:"editedBy,omitempty"`
+	CreatedAt                time.Time  `json:"createdAt"`
+}
+
+// State returns the editable fields stored in the revision
+func (r *DreamRevision) State() DreamState {
+	return DreamState{
+		Title:                    r.Title,
+		Text:                     r.Text,
+		Visibility:               r.Visibility,
+		NightmareRating:          r.NightmareRating,
+		VividnessRating:          r.VividnessRating,
+		ClarityRating:            r.ClarityRating,
+		EmotionalIntensityRating: r.EmotionalIntensityRating,
+	}
+}
+
+type Comment struct {
+	ID int `json:"id"`
+	// ParentID is the comment this one replies to, nil for top-level
+	// comments
+	ParentID  *int           `json:"parentId"`
+	Text      string         `json:"text"`
+	Edited    bool           `json:"edited"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	User      UserSummary    `json:"user"`
+	Reactions ReactionCounts `json:"reactions,omitempty"`
+
+	DreamRowID int `json:"-"`
+}
+
+// Cursor returns the pagination cursor pointing at this comment
+func (c *Comment) Cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+// CommentReport is a user's complaint about a comment. Open reports wait in
+// the admin moderation queue until an admin dismisses them or removes the
+// comment.
+type CommentReport struct {
+	ID        int         `json:"id"`
+	Reason    string      `json:"reason"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Reporter  UserSummary `json:"reporter"`
+	Comment   Comment     `json:"comment"`
+	// DreamID is the public ID of the dream the comment is on
+	DreamID string `json:"dreamId"`
+	// ResolvedAt is set once the report is dismissed; open reports have none
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// Cursor returns the pagination cursor pointing at this report
+func (r *CommentReport) Cursor() Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+// DreamFilter selects dreams for a listing. Dreams are only included when
+// they are public, owned by Viewer, or shared with friends and Viewer is one
+// of them; never when their author and Viewer have blocked one another.
+type DreamFilter struct {
+	Owners     []string // restrict to these authors; nil means everyone
+	Viewer     string   // empty for anonymous callers
+	PublicOnly bool     // only public dreams, even for Viewer and friends
+	Page       Page
+}
+
+// DreamInsight is the summary and tags of one recent dream
+type DreamInsight struct {
+	DreamID string   `json:"dreamId"`
+	Summary string   `json:"summary"`
+	Tags    []string `json:"tags"`
+}
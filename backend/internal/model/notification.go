@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// NotificationType is the event a notification tells its recipient about
+type NotificationType string
+
+const (
+	// NotifyFriendRequest: Actor sent the recipient a friend request
+	NotifyFriendRequest NotificationType = "friend_request"
+	// NotifyFriendAccepted: Actor accepted the recipient's friend request
+	NotifyFriendAccepted NotificationType = "friend_accepted"
+	// NotifyComment: Actor commented on the recipient's dream
+	NotifyComment NotificationType = "comment"
+	// NotifyReply: Actor replied to the recipient's comment
+	NotifyReply NotificationType = "reply"
+)
+
+// NotificationTypes lists every notification type
+var NotificationTypes = []NotificationType{NotifyFriendRequest, NotifyFriendAccepted, NotifyComment, NotifyReply}
+
+// Valid reports whether t is one of NotificationTypes
+func (t NotificationType) Valid() bool {
+	for _, typ := range NotificationTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification tells UserID that Actor did something. Comment and reply
+// notifications point at the dream and comment; friend ones at neither.
+type Notification struct {
+	ID         int              `json:"id"`
+	Type       NotificationType `json:"type"`
+	UserID     string           `json:"-"`
+	Actor      UserSummary      `json:"actor"`
+	DreamRowID int              `json:"-"`
+	DreamID    string           `json:"dreamId,omitempty"`
+	CommentID  *int             `json:"commentId,omitempty"`
+	Read       bool             `json:"read"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+// Cursor returns the pagination cursor pointing at this notification
+func (n *Notification) Cursor() Cursor {
+	return Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+}
+
+// NotificationPrefs says which notification types a user wants. Every
+// type is present; they are all on until the user turns them off.
+type NotificationPrefs map[NotificationType]bool
+
+// DefaultNotificationPrefs returns every type turned on
+func DefaultNotificationPrefs() NotificationPrefs {
+	prefs := make(NotificationPrefs, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		prefs[t] = true
+	}
+	return prefs
+}
+
+// NotificationCounts is the number of unread notifications of each type.
+// Every type is present.
+type NotificationCounts map[NotificationType]int
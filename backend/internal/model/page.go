@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cursor is the (created_at, id) of the last row on a page. Listings
+// continue strictly after it, so rows inserted while a client is paging
+// never shift or repeat results.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Page selects one page of a listing. A zero Limit means no limit.
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// Encode returns the opaque cursor string sent to clients as next_cursor
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	micros, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	rowID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	// created_at is a timestamp without time zone, which pgx reads as UTC
+	return &Cursor{CreatedAt: time.UnixMicro(micros).UTC(), ID: rowID}, nil
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// ReactionKind is one of the fixed set of reactions users can leave
+type ReactionKind string
+
+const (
+	ReactionRelatable ReactionKind = "relatable"
+	ReactionSpooky    ReactionKind = "spooky"
+	ReactionBeautiful ReactionKind = "beautiful"
+)
+
+// ReactionKinds lists every reaction in display order
+var ReactionKinds = []ReactionKind{ReactionRelatable, ReactionSpooky, ReactionBeautiful}
+
+// Valid reports whether k is one of ReactionKinds
+func (k ReactionKind) Valid() bool {
+	for _, kind := range ReactionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ReactionCounts is the number of reactions of each kind on a dream or
+// comment. Every kind is present, with zero when nobody used it.
+type ReactionCounts map[ReactionKind]int
+
+// NewReactionCounts returns counts of zero for every kind
+func NewReactionCounts() ReactionCounts {
+	counts := make(ReactionCounts, len(ReactionKinds))
+	for _, k := range ReactionKinds {
+		counts[k] = 0
+	}
+	return counts
+}
+
+// ReactionTarget is what a reaction is on: a dream or a comment, never
+// both
+type ReactionTarget struct {
+	DreamRowID int
+	CommentID  int
+}
+
+// Reaction is one user's reaction of one kind
+type Reaction struct {
+	ID        int          `json:"id"`
+	Kind      ReactionKind `json:"kind"`
+	User      UserSummary  `json:"user"`
+	CreatedAt time.Time    `json:"createdAt"`
+
+	Target ReactionTarget `json:"-"`
+}
+
+// Cursor returns the pagination cursor pointing at this reaction
+func (r *Reaction) Cursor() Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
@@ -0,0 +1,31 @@
+package model
+
+// SearchResult is one dream matching a search, with highlighted fragments.
+// Highlights are HTML-escaped with matches wrapped in <mark> tags.
+type SearchResult struct {
+	Dream          Dream   `json:"dream"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"titleHighlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+// SearchResponse is a page of search results
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
+
+// SearchFilter is a text query plus the optional filters applied on top of it
+type SearchFilter struct {
+	Query     string
+	ViewerID  string // empty for anonymous searches
+	Scope     string // "all", "mine", "friends" or "public"
+	Tags      []string
+	Dates     DateRange
+	RatingMin map[string]int // keyed by rating name, see RatingNames
+	RatingMax map[string]int
+	Limit     int
+	Offset    int
+}
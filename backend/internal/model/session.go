@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// RefreshToken is a stored refresh token. Only the hash of the token is
+// kept. Each refresh marks the token used and issues a new one in the same
+// family, so presenting a used token again means it leaked and the whole
+// family is revoked.
+type RefreshToken struct {
+	ID        int64
+	UserID    string
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// Account token purposes
+const (
+	TokenPasswordReset = "password_reset"
+	TokenEmailVerify   = "email_verify"
+)
+
+// AccountToken is a one-time token mailed to a user to reset their password
+// or verify their email address. Only the hash of the token is kept.
+type AccountToken struct {
+	ID        int64
+	UserID    string
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+// DateRange is an optional [From, To) filter on dreams.created_at
+type DateRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// MaxFrequencyDays is the longest range DreamStats fills in a daily
+// DreamFrequency series for. Longer ranges keep their most recent days.
+const MaxFrequencyDays = 366
+
+// TagCount is a tag together with the number of dreams it appears on
+type TagCount struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// PeriodCount is the number of dreams recorded in a day, week or month
+type PeriodCount struct {
+	Period time.Time `json:"period"`
+	Count  int       `json:"count"`
+}
+
+// RatingStats aggregates one of the four rating columns
+type RatingStats struct {
+	Average   *float64 `json:"average"`
+	Count     int      `json:"count"`
+	Histogram [10]int  `json:"histogram"` // index 0 holds the count of 1s
+}
+
+// StreakStats describes runs of consecutive days with at least one dream
+type StreakStats struct {
+	Current     int        `json:"current"`
+	Longest     int        `json:"longest"`
+	LastDreamOn *time.Time `json:"lastDreamOn,omitempty"`
+}
+
+// DreamStats is the response body of GET /api/users/{id}/stats
+type DreamStats struct {
+	TotalDreams    int                    `json:"totalDreams"`
+	PublicDreams   int                    `json:"publicDreams"`
+	PrivateDreams  int                    `json:"privateDreams"`
+	MostCommonTags []TagCount             `json:"mostCommonTags"`
+	DreamFrequency []int                  `json:"dreamFrequency"` // daily counts, oldest first
+	DreamsPerDay   []PeriodCount          `json:"dreamsPerDay"`
+	DreamsPerWeek  []PeriodCount          `json:"dreamsPerWeek"`
+	DreamsPerMonth []PeriodCount          `json:"dreamsPerMonth"`
+	Ratings        map[string]RatingStats `json:"ratings"`
+	Streak         StreakStats            `json:"streak"`
+	From           *time.Time             `json:"from,omitempty"`
+	To             *time.Time             `json:"to,omitempty"`
+}
+
+// TagAnalytics describes how one tag is used across a user's dreams
+type TagAnalytics struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Count       int           `json:"count"`
+	FirstSeen   time.Time     `json:"firstSeen"`
+	LastSeen    time.Time     `json:"lastSeen"`
+	CoOccurring []TagCount    `json:"coOccurring"`
+	Trend       []PeriodCount `json:"trend"` // dreams per month carrying this tag
+}
+
+// RatingNames are the JSON names of the four dream ratings, in column order
+var RatingNames = []string{"nightmare", "vividness", "clarity", "emotional_intensity"}
@@ -0,0 +1,239 @@
+// Package ratelimit throttles clients with in-memory token buckets and
+// locks out accounts after repeated failed logins. State is kept per
+// process, so every replica enforces its own limits.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate allows Limit requests per Per, with bursts of up to Limit. The zero
+// Rate allows everything.
+type Rate struct {
+	Limit int
+	Per   time.Duration
+}
+
+// Unlimited reports whether r allows everything
+func (r Rate) Unlimited() bool {
+	return r.Limit <= 0 || r.Per <= 0
+}
+
+func (r Rate) String() string {
+	if r.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, r.Per)
+}
+
+// ParseRate parses a rate such as "30/m", "1000/h" or "5/10s". "off" and
+// "0" disable the limit.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Rate{}, nil
+	}
+	count, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q, want count/period", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: bad count", s)
+	}
+	// A bare unit means one of it
+	if per == "s" || per == "m" || per == "h" {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: bad period", s)
+	}
+	return Rate{Limit: n, Per: d}, nil
+}
+
+// Limiter keeps a token bucket per key. Buckets start full, hold up to
+// Limit tokens and refill continuously at Limit per Per.
+type Limiter struct {
+	rate Rate
+	now  func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(rate Rate) *Limiter {
+	return &Limiter{rate: rate, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.rate.Unlimited() {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	capacity := float64(l.rate.Limit)
+	perToken := l.rate.Per / time.Duration(l.rate.Limit)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(perToken))
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// starts out the same. It runs at most once per period. The caller holds
+// the lock.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.rate.Per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.rate.Per {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Lockout tracks failed attempts per key, such as logins per email. After
+// Threshold failures the key is locked for Base, and every further failure
+// doubles the lock up to Max. Failures are forgotten after Window without
+// one, or when Reset is called after a success.
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+
+	now       func() time.Time
+	mu        sync.Mutex
+	entries   map[string]*lockEntry
+	lastSweep time.Time
+}
+
+type lockEntry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+// NewLockout returns a Lockout that locks after 5 failures for 30 seconds,
+// doubling up to 15 minutes, and forgets failures after an hour
+func NewLockout() *Lockout {
+	return &Lockout{
+		Threshold: 5,
+		Base:      30 * time.Second,
+		Max:       15 * time.Minute,
+		Window:    time.Hour,
+		now:       time.Now,
+		entries:   map[string]*lockEntry{},
+	}
+}
+
+// Locked returns how long key stays locked, or 0 if it is not
+func (l *Lockout) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(l.now())
+	e := l.entry(key, l.now())
+	if e == nil {
+		return 0
+	}
+	if d := e.until.Sub(l.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Fail records a failed attempt and returns how long key is now locked
+// for, or 0 if it is still under the threshold
+func (l *Lockout) Fail(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	e := l.entry(key, now)
+	if e == nil {
+		e = &lockEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+	if e.failures < l.Threshold {
+		return 0
+	}
+	lock := l.Base
+	for i := l.Threshold; i < e.failures && lock < l.Max; i++ {
+		lock *= 2
+	}
+	if lock > l.Max {
+		lock = l.Max
+	}
+	e.until = now.Add(lock)
+	return lock
+}
+
+// Reset forgets key's failures
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// entry returns key's entry, dropping it once its failures have expired.
+// The caller holds the lock.
+func (l *Lockout) entry(key string, now time.Time) *lockEntry {
+	e, ok := l.entries[key]
+	if !ok {
+		return nil
+	}
+	if l.expired(e, now) {
+		delete(l.entries, key)
+		return nil
+	}
+	return e
+}
+
+// expired reports whether e's failures are forgotten and its lock is over
+func (l *Lockout) expired(e *lockEntry, now time.Time) bool {
+	return now.Sub(e.last) >= l.Window && !now.Before(e.until)
+}
+
+// sweep drops expired entries, so keys that are never tried again, such as
+// made-up emails, do not pile up. It runs at most once per Window. The
+// caller holds the lock.
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.Window {
+		return
+	}
+	l.lastSweep = now
+	for key, e := range l.entries {
+		if l.expired(e, now) {
+			delete(l.entries, key)
+		}
+	}
+}
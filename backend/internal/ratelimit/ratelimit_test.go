@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a fake time source advanced by the tests
+type clock struct{ t time.Time }
+
+func newClock() *clock {
+	return &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) now() time.Time      { return c.t }
+func (c *clock) add(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestParseRate(t *testing.T) {
+	for in, want := range map[string]Rate{
+		"30/m":   {30, time.Minute},
+		"1000/h": {1000, time.Hour},
+		"5/10s":  {5, 10 * time.Second},
+		"off":    {},
+		"0":      {},
+	} {
+		got, err := ParseRate(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "30", "x/m", "-1/m", "5/0s", "5/fortnight"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q) succeeded", in)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	c := newClock()
+	l := NewLimiter(Rate{Limit: 3, Per: 3 * time.Second})
+	l.now = c.now
+
+	// A full bucket allows a burst of Limit
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	ok, retry := l.Allow("a")
+	if ok || retry != time.Second {
+		t.Fatalf("Allow after burst = %v, %v; want false, 1s", ok, retry)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Errorf("other keys share the bucket")
+	}
+
+	c.add(500 * time.Millisecond)
+	if ok, retry := l.Allow("a"); ok || retry != 500*time.Millisecond {
+		t.Errorf("Allow after half a token = %v, %v", ok, retry)
+	}
+	c.add(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Errorf("refilled token refused")
+	}
+
+	// Idle buckets are swept once they are full again
+	c.add(time.Minute)
+	l.Allow("c")
+	if _, ok := l.buckets["a"]; ok {
+		t.Errorf("idle bucket was not swept")
+	}
+
+	if ok, _ := NewLimiter(Rate{}).Allow("a"); !ok {
+		t.Errorf("zero rate refused a request")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	c := newClock()
+	l := NewLockout()
+	l.now = c.now
+	l.Threshold, l.Base, l.Max, l.Window = 3, time.Second, 5*time.Second, time.Minute
+
+	for i := 0; i < 2; i++ {
+		if d := l.Fail("ann"); d != 0 {
+			t.Fatalf("failure %d locked for %v", i+1, d)
+		}
+	}
+	if d := l.Locked("ann"); d != 0 {
+		t.Fatalf("locked under the threshold: %v", d)
+	}
+	// The lock doubles with every failure past the threshold, up to Max
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if d := l.Fail("ann"); d != want {
+			t.Errorf("failure %d locked for %v, want %v", i+3, d, want)
+		}
+	}
+	if d := l.Locked("ann"); d != 5*time.Second {
+		t.Errorf("Locked = %v", d)
+	}
+	if d := l.Locked("bob"); d != 0 {
+		t.Errorf("unrelated key locked for %v", d)
+	}
+
+	c.add(5 * time.Second)
+	if d := l.Locked("ann"); d != 0 {
+		t.Errorf("still locked after the lock expired: %v", d)
+	}
+	// Failures are remembered within the window...
+	if d := l.Fail("ann"); d != 5*time.Second {
+		t.Errorf("failure after the lock expired locked for %v", d)
+	}
+	// ...and forgotten after it
+	c.add(time.Minute)
+	if d := l.Fail("ann"); d != 0 {
+		t.Errorf("failure after the window locked for %v", d)
+	}
+
+	l.Fail("ann")
+	l.Reset("ann")
+	if d := l.Fail("ann"); d != 0 {
+		t.Errorf("failure after Reset locked for %v", d)
+	}
+
+	// Expired entries are swept even if their key is never tried again
+	for _, key := range []string{"x1", "x2", "x3"} {
+		l.Fail(key)
+	}
+	c.add(time.Minute)
+	l.Locked("bob")
+	if len(l.entries) != 0 {
+		t.Errorf("%d expired entries were not swept", len(l.entries))
+	}
+}
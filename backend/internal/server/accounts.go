@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Username string `json:"username" validate:"required,username"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// LoginRequest only checks for presence, so the rules for new passwords
+// can change without locking anyone out
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RenameRequest is the body of PUT /api/users/me/username
+type RenameRequest struct {
+	Username string `json:"username" validate:"required,username"`
+}
+
+// UpdateProfileRequest is the body of PUT /api/users/me/profile
+type UpdateProfileRequest struct {
+	DisplayName     string `json:"display_name" validate:"max=50"`
+	Description     string `json:"description" validate:"max=500"`
+	ProfileImageURL string `json:"profile_image_url" validate:"max=2048"`
+}
+
+// registerHandler serves POST /api/register, signs the new user in and
+// mails them a link to verify their email address
+func (s *Server) registerHandler(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	log.Printf("[REGISTER] Attempt for email: %s", req.Email)
+	user, err := s.accounts().Register(r.Context(), req.Email, req.Username, req.Password)
+	if errors.Is(err, store.ErrConflict) {
+		log.Printf("[REGISTER] User already exists: %s", req.Email)
+		writeError(w, http.StatusConflict, ErrCodeConflict, "Email or username already taken")
+		return
+	} else if err != nil {
+		log.Printf("[REGISTER] Failed to create user %s: %v", req.Email, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to create user")
+		return
+	}
+	log.Printf("[REGISTER] Success for email: %s", req.Email)
+	sess, err := s.sessions().Issue(r.Context(), user.ID, "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate JWT")
+		return
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = user.Username
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user":         user,
+		"token":        sess.Token,
+		"refreshToken": sess.RefreshToken,
+		"isAdmin":      user.IsAdmin,
+	})
+}
+
+// loginHandler serves POST /api/login. Unknown emails and wrong passwords
+// get the same error, and repeated failures lock the email out for a while
+// whether or not it is registered.
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	key := auth.LoginKey(req.Email)
+	if retry := s.Lockout.Locked(key); retry > 0 {
+		tooManyRequests(w, retry)
+		return
+	}
+	user, passwordHash, err := s.store.GetCredentials(r.Context(), req.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return
+	}
+	if !auth.CheckPassword(passwordHash, req.Password) {
+		if lock := s.Lockout.Fail(key); lock > 0 {
+			log.Printf("[LOGIN] Too many failed logins for %s; locked for %v", req.Email, lock)
+		}
+		writeError(w, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid email or password")
+		return
+	}
+	s.Lockout.Reset(key)
+	sess, err := s.sessions().Issue(r.Context(), user.ID, "")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate JWT")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":         user.ID,
+			"email":      user.Email,
+			"username":   user.Username,
+			"created_at": user.CreatedAt,
+		},
+		"token":        sess.Token,
+		"refreshToken": sess.RefreshToken,
+		"isAdmin":      user.IsAdmin,
+	})
+}
+
+// meHandler serves GET /api/me
+func (s *Server) meHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	user, err := s.store.GetUser(r.Context(), p.UserID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": model.User{
+			ID:            user.ID,
+			Email:         user.Email,
+			Username:      user.Username,
+			CreatedAt:     user.CreatedAt,
+			EmailVerified: user.EmailVerified,
+		},
+		"isAdmin": user.IsAdmin,
+	})
+}
+
+// publicProfileHandler serves GET /api/users/{username}/public: the profile
+// and a page of the user's dreams the caller may see listed. Strangers see
+// public dreams, friends also see friends-only ones.
+func (s *Server) publicProfileHandler(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	log.Printf("[PUBLIC PROFILE] Looking up user with username: %s", username)
+	user, err := s.store.GetUserByUsername(r.Context(), username)
+	if errors.Is(err, store.ErrNotFound) {
+		// Links to a former username point at the current one. The redirect
+		// is temporary, since the name could be taken back later.
+		if renamed, err := s.store.GetUserByAlias(r.Context(), username); err == nil {
+			target := url.URL{Path: "/api/users/" + url.PathEscape(renamed.Username) + "/public", RawQuery: r.URL.RawQuery}
+			http.Redirect(w, r, target.String(), http.StatusFound)
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("[PUBLIC PROFILE] Lookup failed for username '%s': %v", username, err)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if err := s.policy.SeeUser(r.Context(), principal(r), user.ID); err != nil {
+		deny(w, err, "User not found")
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	dreams, next, err := s.store.ListDreams(r.Context(), model.DreamFilter{Owners: []string{user.ID}, Viewer: principal(r).ID(), Page: page})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch dreams")
+		return
+	}
+	user.Email = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user":        user,
+		"dreams":      dreams,
+		"next_cursor": encodeCursor(next),
+	})
+}
+
+// profileHandler serves GET /api/users/me/profile
+func (s *Server) profileHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	user, err := s.store.GetUser(r.Context(), p.UserID)
+	if err != nil {
+		log.Printf("[PROFILE] Lookup failed for user id %s: %v", p.UserID, err)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// renameHandler serves PUT /api/users/me/username. The old username keeps
+// redirecting to the new one and stays reserved for the caller.
+func (s *Server) renameHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	var req RenameRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	err := s.store.RenameUser(r.Context(), p.UserID, req.Username)
+	if errors.Is(err, store.ErrConflict) {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "Username already taken")
+		return
+	} else if err != nil {
+		log.Printf("[PROFILE] Failed to rename user %s: %v", p.UserID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to change username")
+		return
+	}
+	user, err := s.store.GetUser(r.Context(), p.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to change username")
+		return
+	}
+	log.Printf("[PROFILE] User %s renamed to %s", p.UserID, user.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// updateProfileHandler serves PUT /api/users/me/profile
+func (s *Server) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	var req UpdateProfileRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if err := s.store.UpdateProfile(r.Context(), p.UserID, req.DisplayName, req.Description, req.ProfileImageURL); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update profile")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/insights"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+// DreamIDRequest names the dream an AI endpoint works on
+type DreamIDRequest struct {
+	Id string `json:"id" validate:"required"`
+}
+
+// prophecyHandler serves POST /api/dreams/prophecy. A cached prophecy is
+// returned to anyone who can see the dream; otherwise the owner gets a job
+// queued and 202 returned so they can poll it.
+func (s *Server) prophecyHandler(w http.ResponseWriter, r *http.Request) {
+	var req DreamIDRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	d, ok := s.loadDream(w, r, req.Id, s.policy.ViewDream)
+	if !ok {
+		return
+	}
+	if d.Prophecy != "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"prophecy": d.Prophecy})
+		return
+	}
+	// Queueing takes the rule GET /api/jobs/{id} checks, so whoever starts
+	// a job can poll it
+	if err := s.policy.ManageDream(r.Context(), principal(r), d); err != nil {
+		deny(w, err, "Dream not found")
+		return
+	}
+	job, err := s.jobs.Enqueue(r.Context(), jobs.KindProphecy, d.RowID)
+	if err != nil {
+		log.Printf("[PROPHECY] Failed to queue job: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate prophecy")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"job": job})
+}
+
+// summaryHandler serves POST /api/dreams/summary. Only a summary generated
+// from the dream's current text is reused; otherwise the owner gets a job
+// queued, as for prophecies.
+func (s *Server) summaryHandler(w http.ResponseWriter, r *http.Request) {
+	var req DreamIDRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	d, ok := s.loadDream(w, r, req.Id, s.policy.ViewDream)
+	if !ok {
+		return
+	}
+	if d.Summary != "" && d.SummaryHash == ai.ContentHash(d.Text) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"summary": d.Summary})
+		return
+	}
+	if err := s.policy.ManageDream(r.Context(), principal(r), d); err != nil {
+		deny(w, err, "Dream not found")
+		return
+	}
+	job, err := s.jobs.Enqueue(r.Context(), jobs.KindSummary, d.RowID)
+	if err != nil {
+		log.Printf("[SUMMARY] Failed to queue job: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to summarize dream")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"job": job})
+}
+
+// extractTagsHandler serves POST /api/dreams/tags, a synchronous preview of
+// the tags the model would pick for a text
+func (s *Server) extractTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.policy.Authenticated(principal(r)); err != nil {
+		deny(w, err, "")
+		return
+	}
+	var req struct {
+		Text string `json:"text" validate:"required,max=20000"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	tags, err := s.ai.ExtractTags(r.Context(), req.Text)
+	if errors.Is(err, ai.ErrNotConfigured) {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeAIUnavailable, "AI features are not configured")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to extract tags")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"tags": tags})
+}
+
+// insightsHandler serves POST /api/ai-insights for the caller, or for
+// userId when an admin asks
+func (s *Server) insightsHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserId string `json:"userId"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.UserId == "" {
+		req.UserId = principal(r).ID()
+	}
+	if err := s.policy.ReadPrivate(principal(r), req.UserId); err != nil {
+		deny(w, err, "")
+		return
+	}
+	result, err := insights.Load(r.Context(), s.store, s.ai, s.InsightConcurrency, req.UserId)
+	if errors.Is(err, ai.ErrNotConfigured) {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeAIUnavailable, "AI features are not configured")
+		return
+	} else if err != nil {
+		log.Printf("[INSIGHTS] Failed to load insights: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch dreams")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/authz"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+type CreateCommentRequest struct {
+	Text string `json:"text" validate:"required,max=5000"`
+	// ParentID makes the comment a reply to another comment on the dream
+	ParentID *int `json:"parent_id,omitempty"`
+}
+
+type UpdateCommentRequest struct {
+	Text string `json:"text" validate:"required,max=5000"`
+}
+
+type ReportCommentRequest struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+// ResolveReportRequest is the body of POST
+// /api/admin/reports/{report_id}/resolve. "dismiss" keeps the comment and
+// closes its reports; "remove" deletes the comment, its replies and reports.
+type ResolveReportRequest struct {
+	Action string `json:"action" validate:"required,oneof=dismiss remove"`
+}
+
+// listCommentsHandler serves GET /api/dreams/{dream_id}/comments, oldest
+// first. Replies are listed with the rest and carry a parentId for clients
+// to build threads from. Comments are visible to whoever can see the dream,
+// except those by users the caller blocked or was blocked by.
+func (s *Server) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.loadDream(w, r, mux.Vars(r)["dream_id"], s.policy.ViewDream)
+	if !ok {
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	comments, next, err := s.store.ListComments(r.Context(), d.RowID, principal(r).ID(), page)
+	if err != nil {
+		log.Printf("[COMMENTS] Failed to fetch comments for dream %s: %v", d.ID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch comments")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"comments":    comments,
+		"next_cursor": encodeCursor(next),
+	})
+}
+
+// createCommentHandler serves POST /api/dreams/{dream_id}/comments
+func (s *Server) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.loadDream(w, r, mux.Vars(r)["dream_id"], s.policy.Comment)
+	if !ok {
+		return
+	}
+	var req CreateCommentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	comment := model.Comment{DreamRowID: d.RowID, ParentID: req.ParentID, Text: req.Text, User: model.UserSummary{ID: principal(r).UserID}}
+	if err := s.store.CreateComment(r.Context(), &comment); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "parent_id is not a comment on this dream")
+		return
+	} else if err != nil {
+		log.Printf("[COMMENTS] Failed to add comment: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to add comment")
+		return
+	}
+	log.Printf("[COMMENTS] Added comment id=%d for dream %s", comment.ID, d.ID)
+	s.publishComment(r.Context(), d, &comment)
+	s.notifyComment(r.Context(), d, &comment)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// loadComment looks up the comment named in the URL and the dream it is
+// on. It writes the error response itself.
+func (s *Server) loadComment(w http.ResponseWriter, r *http.Request) (*model.Comment, *model.Dream, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["comment_id"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Comment not found")
+		return nil, nil, false
+	}
+	c, err := s.store.GetComment(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Comment not found")
+		return nil, nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return nil, nil, false
+	}
+	d, err := s.store.GetDreamByRowID(r.Context(), c.DreamRowID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return nil, nil, false
+	}
+	return c, d, true
+}
+
+// checkComment applies rule to the dream a comment is on, and hides the
+// comment when the caller and its author or the dream's owner have blocked
+// one another. It writes the error response itself.
+func (s *Server) checkComment(w http.ResponseWriter, r *http.Request, c *model.Comment, d *model.Dream, rule func(context.Context, *authz.Principal, *model.Dream) error) bool {
+	p := principal(r)
+	if err := rule(r.Context(), p, d); err != nil {
+		deny(w, err, "Comment not found")
+		return false
+	}
+	for _, userID := range []string{d.UserID, c.User.ID} {
+		if err := s.policy.SeeUser(r.Context(), p, userID); err != nil {
+			deny(w, err, "Comment not found")
+			return false
+		}
+	}
+	return true
+}
+
+// updateCommentHandler serves PATCH /api/comments/{comment_id}. Only the
+// author may edit, and the comment is marked edited. A comment the caller
+// cannot see answers 404 like a missing one.
+func (s *Server) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	c, d, ok := s.loadComment(w, r)
+	if !ok {
+		return
+	}
+	if !s.checkComment(w, r, c, d, s.policy.ViewDream) {
+		return
+	}
+	if err := s.policy.EditComment(p, c); err != nil {
+		deny(w, err, "Comment not found")
+		return
+	}
+	var req UpdateCommentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	updated, err := s.store.UpdateComment(r.Context(), c.ID, req.Text)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Comment not found")
+		return
+	} else if err != nil {
+		log.Printf("[COMMENTS] Failed to edit comment id=%d: %v", c.ID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to edit comment")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// deleteCommentHandler serves DELETE /api/comments/{comment_id}. The author,
+// the dream's owner and admins may delete a comment they can see; its
+// replies go with it.
+func (s *Server) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	c, d, ok := s.loadComment(w, r)
+	if !ok {
+		return
+	}
+	if !s.checkComment(w, r, c, d, s.policy.ViewDream) {
+		return
+	}
+	if err := s.policy.DeleteComment(p, c, d); err != nil {
+		deny(w, err, "Comment not found")
+		return
+	}
+	if err := s.store.DeleteComment(r.Context(), c.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete comment")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reportCommentHandler serves POST /api/comments/{comment_id}/reports. Any
+// signed-in user who can see a comment may report it once; the report
+// waits in the admin moderation queue.
+func (s *Server) reportCommentHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	c, d, ok := s.loadComment(w, r)
+	if !ok {
+		return
+	}
+	if !s.checkComment(w, r, c, d, s.policy.ViewDream) {
+		return
+	}
+	if c.User.ID == p.UserID {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "You cannot report your own comment")
+		return
+	}
+	var req ReportCommentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	report := model.CommentReport{Reason: req.Reason, Reporter: model.UserSummary{ID: p.UserID}, Comment: model.Comment{ID: c.ID}}
+	if err := s.store.ReportComment(r.Context(), &report); errors.Is(err, store.ErrConflict) {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "You already reported this comment")
+		return
+	} else if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Comment not found")
+		return
+	} else if err != nil {
+		log.Printf("[COMMENTS] Failed to report comment id=%d: %v", c.ID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to report comment")
+		return
+	}
+	log.Printf("[COMMENTS] Comment id=%d reported by user %s", c.ID, p.UserID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+// reportsHandler serves GET /api/admin/reports, the open comment reports
+// oldest first. Admins only.
+func (s *Server) reportsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.policy.Moderate(principal(r)); err != nil {
+		deny(w, err, "")
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	reports, next, err := s.store.ListOpenReports(r.Context(), page)
+	if err != nil {
+		log.Printf("[MODERATION] Failed to list reports: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch reports")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports":     reports,
+		"next_cursor": encodeCursor(next),
+	})
+}
+
+// resolveReportHandler serves POST /api/admin/reports/{report_id}/resolve.
+// The action applies to the reported comment, so it settles every open
+// report on it. A report that is no longer open answers 409. Admins only.
+func (s *Server) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Moderate(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["report_id"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Report not found")
+		return
+	}
+	var req ResolveReportRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	report, err := s.store.GetReport(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Report not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return
+	}
+	if report.ResolvedAt != nil {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "Report already resolved")
+		return
+	}
+	status := "dismissed"
+	if req.Action == "remove" {
+		status = "removed"
+		err = s.store.DeleteComment(r.Context(), report.Comment.ID)
+	} else if err = s.store.DismissReports(r.Context(), report.Comment.ID, p.UserID); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "Report already resolved")
+		return
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("[MODERATION] Failed to resolve report id=%d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to resolve report")
+		return
+	}
+	log.Printf("[MODERATION] Report id=%d on comment id=%d %s by admin %s", id, report.Comment.ID, status, p.UserID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
@@ -0,0 +1,144 @@
+package server
+
+import "strings"
+
+// DiffOp is one run of lines in a line diff
+type DiffOp struct {
+	Op   string `json:"op"` // "equal", "insert" or "delete"
+	Text string `json:"text"`
+}
+
+// diffLines computes a line-based diff of a into b using the longest common
+// subsequence. Adjacent lines with the same operation are merged. Dream
+// texts may run to 20000 lines, so the LCS is found with Hirschberg's
+// algorithm, which needs memory linear in the length of b rather than a
+// table of every pair of lines.
+func diffLines(a, b string) []DiffOp {
+	d := &differ{x: strings.Split(a, "\n"), y: strings.Split(b, "\n"), ops: []DiffOp{}}
+	// Compare small integers rather than strings in the inner loops
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	d.xi, d.yi = intern(d.x), intern(d.y)
+	d.fwd, d.rev = make([]int, len(d.y)+1), make([]int, len(d.y)+1)
+	d.diff(0, len(d.x), 0, len(d.y))
+	return d.ops
+}
+
+// differ holds the state of one diffLines call. fwd and rev are the two
+// rows of LCS lengths reused at every level of the recursion.
+type differ struct {
+	x, y     []string
+	xi, yi   []int
+	fwd, rev []int
+	ops      []DiffOp
+}
+
+func (d *differ) emit(op, line string) {
+	if last := len(d.ops) - 1; last >= 0 && d.ops[last].Op == op {
+		d.ops[last].Text += "\n" + line
+		return
+	}
+	d.ops = append(d.ops, DiffOp{Op: op, Text: line})
+}
+
+// diff emits the diff of x[i0:i1] into y[j0:j1]. Where lines could be
+// either deleted or inserted first, deletions come first.
+func (d *differ) diff(i0, i1, j0, j1 int) {
+	for i0 < i1 && j0 < j1 && d.xi[i0] == d.yi[j0] {
+		d.emit("equal", d.x[i0])
+		i0++
+		j0++
+	}
+	suffix := 0
+	for i0 < i1 && j0 < j1 && d.xi[i1-1] == d.yi[j1-1] {
+		i1--
+		j1--
+		suffix++
+	}
+	defer func() {
+		for k := 0; k < suffix; k++ {
+			d.emit("equal", d.x[i1+k])
+		}
+	}()
+
+	switch {
+	case i0 == i1:
+		for j := j0; j < j1; j++ {
+			d.emit("insert", d.y[j])
+		}
+		return
+	case j0 == j1:
+		for i := i0; i < i1; i++ {
+			d.emit("delete", d.x[i])
+		}
+		return
+	case i1-i0 == 1:
+		for j := j0; j < j1; j++ {
+			if d.xi[i0] == d.yi[j] {
+				d.diff(i0, i0, j0, j)
+				d.emit("equal", d.x[i0])
+				d.diff(i1, i1, j+1, j1)
+				return
+			}
+		}
+		d.emit("delete", d.x[i0])
+		d.diff(i1, i1, j0, j1)
+		return
+	}
+
+	// Split x in half and find where the LCS crosses the split in y: the
+	// point where the LCS of the top half with y[j0:k] plus that of the
+	// bottom half with y[k:j1] is largest
+	mid := (i0 + i1) / 2
+	m := j1 - j0
+	fwd, rev := d.fwd[:m+1], d.rev[:m+1]
+	for k := range fwd {
+		fwd[k] = 0
+		rev[k] = 0
+	}
+	// fwd[k] is the LCS length of x[i0:i] and y[j0:j0+k]
+	for i := i0; i < mid; i++ {
+		diag := 0
+		for k := 1; k <= m; k++ {
+			up := fwd[k]
+			if d.xi[i] == d.yi[j0+k-1] {
+				fwd[k] = diag + 1
+			} else if fwd[k-1] > up {
+				fwd[k] = fwd[k-1]
+			}
+			diag = up
+		}
+	}
+	// rev[k] is the LCS length of x[i:i1] and y[j0+k:j1]
+	for i := i1 - 1; i >= mid; i-- {
+		diag := 0
+		for k := m - 1; k >= 0; k-- {
+			down := rev[k]
+			if d.xi[i] == d.yi[j0+k] {
+				rev[k] = diag + 1
+			} else if rev[k+1] > down {
+				rev[k] = rev[k+1]
+			}
+			diag = down
+		}
+	}
+	best, split := -1, 0
+	for k := 0; k <= m; k++ {
+		if l := fwd[k] + rev[k]; l > best {
+			best, split = l, k
+		}
+	}
+	d.diff(i0, mid, j0, j0+split)
+	d.diff(mid, i1, j0+split, j1)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateDreamRequest is the body of POST /api/dreams. Public is the old
+// on/off switch, used only when Visibility is omitted.
+type CreateDreamRequest struct {
+	Title                    string            `json:"title" validate:"max=200"`
+	Text                     string            `json:"text" validate:"required,max=20000"`
+	Visibility               *model.Visibility `json:"visibility,omitempty" validate:"oneof=private friends public unlisted"`
+	Public                   bool              `json:"public"`
+	NightmareRating          *int              `json:"nightmare_rating,omitempty" validate:"min=1,max=10"`
+	VividnessRating          *int              `json:"vividness_rating,omitempty" validate:"min=1,max=10"`
+	ClarityRating            *int              `json:"clarity_rating,omitempty" validate:"min=1,max=10"`
+	EmotionalIntensityRating *int              `json:"emotional_intensity_rating,omitempty" validate:"min=1,max=10"`
+}
+
+// ReplaceTagsRequest is the body of PUT /api/dreams/{public_id}/tags
+type ReplaceTagsRequest struct {
+	Tags []string `json:"tags" validate:"max=20"`
+}
+
+// createDreamHandler serves POST /api/dreams. Tagging is queued in the same
+// transaction as the insert.
+func (s *Server) createDreamHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	var req CreateDreamRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	dream := model.Dream{
+		UserID:                   p.UserID,
+		Title:                    req.Title,
+		Text:                     req.Text,
+		Visibility:               model.VisibilityOf(req.Public),
+		NightmareRating:          req.NightmareRating,
+		VividnessRating:          req.VividnessRating,
+		ClarityRating:            req.ClarityRating,
+		EmotionalIntensityRating: req.EmotionalIntensityRating,
+	}
+	if req.Visibility != nil {
+		dream.Visibility = *req.Visibility
+	}
+	if err := s.store.CreateDream(r.Context(), &dream, jobs.KindTags); err != nil {
+		log.Printf("[DREAMS] Failed to create dream: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to create dream")
+		return
+	}
+	s.jobs.Notify()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dream)
+}
+
+// listDreamsHandler serves GET /api/dreams. ?userId= limits the listing to
+// one author and ?public=true keeps only public dreams. The store filters by
+// viewer, which applies authz.Policy.ViewDream and SeeUser to every row
+// except that unlisted dreams are only listed for their owner, and admins
+// are not shown other users' private dreams in listings.
+func (s *Server) listDreamsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	f := model.DreamFilter{Viewer: principal(r).ID(), Page: page}
+	if userID := r.URL.Query().Get("userId"); userID != "" {
+		f.Owners = []string{userID}
+	}
+	f.PublicOnly = r.URL.Query().Get("public") == "true"
+	dreams, next, err := s.store.ListDreams(r.Context(), f)
+	if err != nil {
+		log.Printf("[DREAMS] Failed to list dreams: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch dreams")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDreamPage(dreams, next))
+}
+
+// getDreamHandler serves GET /api/dreams/{public_id}. Dreams the caller may
+// not see, such as private ones or friends-only ones of a stranger, look
+// missing.
+func (s *Server) getDreamHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.loadDream(w, r, mux.Vars(r)["public_id"], s.policy.ViewDream)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// deleteDreamHandler serves DELETE /api/dreams/{public_id}
+func (s *Server) deleteDreamHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.loadDream(w, r, mux.Vars(r)["public_id"], s.policy.EditDream)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteDream(r.Context(), d.RowID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete dream")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replaceTagsHandler serves PUT /api/dreams/{public_id}/tags
+func (s *Server) replaceTagsHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.loadDream(w, r, mux.Vars(r)["public_id"], s.policy.EditDream)
+	if !ok {
+		return
+	}
+	var req ReplaceTagsRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if err := s.store.ReplaceTags(r.Context(), d.RowID, req.Tags); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update tags")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
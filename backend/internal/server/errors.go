@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/validate"
+)
+
+// Error codes. They are part of the API: clients branch on them, so they
+// never change once published, while messages may.
+const (
+	ErrCodeBadRequest         = "ERR_BAD_REQUEST"
+	ErrCodeDecodeJSON         = "ERR_DECODE_JSON"
+	ErrCodeValidation         = "ERR_VALIDATION"
+	ErrCodeUnauthorized       = "ERR_UNAUTHORIZED"
+	ErrCodeInvalidCredentials = "ERR_INVALID_CREDENTIALS"
+	ErrCodeInvalidToken       = "ERR_INVALID_TOKEN"
+	ErrCodeForbidden          = "ERR_FORBIDDEN"
+	ErrCodeNotFound           = "ERR_NOT_FOUND"
+	ErrCodeMethodNotAllowed   = "ERR_METHOD_NOT_ALLOWED"
+	ErrCodeConflict           = "ERR_CONFLICT"
+	ErrCodeRateLimited        = "ERR_RATE_LIMITED"
+	ErrCodeAIUnavailable      = "ERR_AI_UNAVAILABLE"
+	ErrCodeInternal           = "ERR_INTERNAL"
+)
+
+// ErrorResponse is the body of every error response:
+//
+//	{"error": {"code": "ERR_VALIDATION", "message": "...", "fields": [...]}}
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Fields lists the failed fields of an ERR_VALIDATION error
+	Fields []validate.FieldError `json:"fields,omitempty"`
+}
+
+// writeError writes an error response with a stable code and a message for
+// people
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorBody(w, status, ErrorBody{Code: code, Message: message})
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, body ErrorBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(ErrorResponse{Error: body}); err != nil {
+		log.Printf("[HTTP] Failed to write error response: %v", err)
+	}
+}
+
+// writeValidation writes a 400 listing the fields of a validate.Errors, or
+// a plain bad request for any other error
+func writeValidation(w http.ResponseWriter, err error) {
+	var fields validate.Errors
+	if !errors.As(err, &fields) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	writeErrorBody(w, http.StatusBadRequest, ErrorBody{
+		Code:    ErrCodeValidation,
+		Message: "Invalid fields: " + fields.Error(),
+		Fields:  fields,
+	})
+}
+
+// decode reads a JSON request body into v and checks its validate tags. It
+// writes the error response itself.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeDecodeJSON, "Invalid JSON request body")
+		return false
+	}
+	if err := validate.Struct(v); err != nil {
+		writeValidation(w, err)
+		return false
+	}
+	return true
+}
+
+// notFoundHandler and methodNotAllowedHandler replace the router's
+// plain-text defaults
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, ErrCodeNotFound, "No such endpoint")
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/activity"
+	"github.com/Calrus/ourdreamjournal/backend/internal/authz"
+	"github.com/Calrus/ourdreamjournal/backend/internal/events"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+// eventHeartbeat is how often an idle event stream gets a comment line, so
+// proxies don't time it out
+const eventHeartbeat = 25 * time.Second
+
+// jobEvent is the data of a job event
+type jobEvent struct {
+	*jobs.Job
+	DreamID string `json:"dreamId"`
+}
+
+// activity returns the notifier that publishes to s.Events
+func (s *Server) activity() *activity.Notifier {
+	return &activity.Notifier{Store: s.store, Events: s.Events}
+}
+
+// publish sends e through s.Events. Like notifications, events are a side
+// effect, so failures are logged rather than failing the request.
+func (s *Server) publish(ctx context.Context, e events.Event) {
+	s.activity().Publish(ctx, e)
+}
+
+// JobFinished tells a dream's owner that an AI job on it is done or dead.
+// It is meant for jobs.Queue.OnFinish and memstore.Store.OnJobFinish.
+func (s *Server) JobFinished(ctx context.Context, job *jobs.Job) {
+	d, err := s.store.GetDreamByRowID(ctx, job.DreamID)
+	if err != nil {
+		// The dream was deleted while the job ran
+		return
+	}
+	e := events.New(events.TypeJob, jobEvent{Job: job, DreamID: d.ID})
+	e.UserID = d.UserID
+	s.publish(ctx, e)
+}
+
+// queryToken lets GET /api/events take the access token from
+// ?access_token=, since browsers' EventSource cannot set headers. The token
+// is moved to the Authorization header and dropped from the URL, which is
+// shared with the middleware around the router, so request logs never
+// record it.
+func queryToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if token := q.Get("access_token"); token != "" {
+			if r.Header.Get("Authorization") == "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			q.Del("access_token")
+			r.URL.RawQuery = q.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// eventsHandler serves GET /api/events, a Server-Sent Events stream of the
+// caller's notifications, friend changes and finished AI jobs. ?dream=
+// adds new comments on that dream for as long as the caller can see it.
+// Events from users the caller cannot see are left out. The stream ends
+// when the access token it was opened with expires, and the client
+// reconnects with a fresh one.
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Streaming unsupported")
+		return
+	}
+	var dreamID string
+	if publicID := r.URL.Query().Get("dream"); publicID != "" {
+		d, ok := s.loadDream(w, r, publicID, s.policy.ViewDream)
+		if !ok {
+			return
+		}
+		dreamID = d.ID
+	}
+	sub := s.Hub.Subscribe(p.UserID, dreamID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	expired := time.NewTimer(time.Until(p.ExpiresAt))
+	defer expired.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expired.C:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e := <-sub.Events():
+			if e.ActorID != "" && s.policy.SeeUser(r.Context(), p, e.ActorID) != nil {
+				continue
+			}
+			if e.DreamID != "" && e.UserID != p.UserID && !s.canWatch(r.Context(), p, e.DreamID) {
+				continue
+			}
+			data := e.Data
+			if data == nil {
+				data = []byte("{}")
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// canWatch reports whether p may still get events about the dream with
+// public ID dreamID. The dream may have been hidden or deleted, or its
+// owner may have blocked p, since the stream started.
+func (s *Server) canWatch(ctx context.Context, p *authz.Principal, dreamID string) bool {
+	d, err := s.store.GetDream(ctx, dreamID)
+	if err != nil {
+		return false
+	}
+	return s.policy.ViewDream(ctx, p, d) == nil && s.policy.SeeUser(ctx, p, d.UserID) == nil
+}
+
+// publishComment sends a new comment to everyone viewing its dream
+func (s *Server) publishComment(ctx context.Context, d *model.Dream, c *model.Comment) {
+	e := events.New(events.TypeComment, c)
+	e.DreamID, e.ActorID = d.ID, c.User.ID
+	s.publish(ctx, e)
+}
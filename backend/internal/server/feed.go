@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/feed"
+)
+
+// FeedPage is one page of the home feed. NextCursor is passed back as
+// ?cursor= to fetch the following page and is null on the last page, which
+// comes once the feed's candidates run out (see feed.Load).
+type FeedPage struct {
+	Items      []feed.Item `json:"items"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// feedHandler serves GET /api/feed, the caller's own recent dreams, their
+// friends' dreams and popular public dreams ranked together. A dream from
+// several sources is listed once.
+func (s *Server) feedHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultPageLimit, 1, maxPageLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	var after *feed.Cursor
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		if after, err = feed.DecodeCursor(c); err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+	}
+	items, next, err := feed.Load(r.Context(), s.store, p.UserID, limit, after, time.Now())
+	if err != nil {
+		log.Printf("[FEED] Failed to build feed for user %s: %v", p.UserID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch feed")
+		return
+	}
+	page := FeedPage{Items: items}
+	if next != nil {
+		c := next.Encode()
+		page.NextCursor = &c
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/authz"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+// friendRequest is the body of the friend request, accept, reject, cancel,
+// remove, block and unblock endpoints. UserID is the user who sent the
+// request, or who blocks FriendID.
+type friendRequest struct {
+	UserID   string `json:"user_id" validate:"required"`
+	FriendID string `json:"friend_id" validate:"required"`
+}
+
+// decodeFriendRequest authenticates the caller and decodes the body. It
+// writes the error response itself.
+func (s *Server) decodeFriendRequest(w http.ResponseWriter, r *http.Request) (*authz.Principal, *friendRequest, bool) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return nil, nil, false
+	}
+	var req friendRequest
+	if !decode(w, r, &req) {
+		return nil, nil, false
+	}
+	if req.UserID == req.FriendID {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "user_id and friend_id must differ")
+		return nil, nil, false
+	}
+	return p, &req, true
+}
+
+// friendRequestHandler serves POST /api/friends/request. An existing
+// request or friendship is reported as is, and a pending request the other
+// way is accepted. Users who blocked the caller look missing.
+func (s *Server) friendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	p, req, ok := s.decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.policy.ActAs(p, req.UserID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	status, created, err := s.store.RequestFriend(r.Context(), req.UserID, req.FriendID)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	} else if errors.Is(err, store.ErrConflict) {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "Unblock this user first")
+		return
+	} else if err != nil {
+		log.Printf("[FRIEND REQUEST ERROR] userID=%v friendID=%v error=%v", req.UserID, req.FriendID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to send friend request")
+		return
+	}
+	// Only a new request or friendship is news to friend_id
+	if created && status == "pending" {
+		s.activity().FriendRequested(r.Context(), req.UserID, req.FriendID)
+	} else if created && status == "accepted" {
+		// friend_id had asked first, so this accepted their request
+		s.activity().FriendAccepted(r.Context(), req.FriendID, req.UserID)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
+
+// acceptFriendHandler serves POST /api/friends/accept. Only the recipient
+// (friend_id) may accept, including a request they rejected earlier.
+func (s *Server) acceptFriendHandler(w http.ResponseWriter, r *http.Request) {
+	p, req, ok := s.decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.policy.ActAs(p, req.FriendID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	if err := s.store.AcceptFriend(r.Context(), req.UserID, req.FriendID); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Friend request not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to accept friend request")
+		return
+	}
+	s.activity().FriendAccepted(r.Context(), req.UserID, req.FriendID)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+// rejectFriendHandler serves POST /api/friends/reject. Only the recipient
+// (friend_id) may reject. The sender keeps seeing the request as pending,
+// so no event tells them otherwise.
+func (s *Server) rejectFriendHandler(w http.ResponseWriter, r *http.Request) {
+	p, req, ok := s.decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.policy.ActAs(p, req.FriendID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	if err := s.store.RejectFriend(r.Context(), req.UserID, req.FriendID); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Friend request not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to reject friend request")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "rejected"})
+}
+
+// cancelFriendHandler serves POST /api/friends/cancel. Only the sender
+// (user_id) may withdraw their request.
+func (s *Server) cancelFriendHandler(w http.ResponseWriter, r *http.Request) {
+	p, req, ok := s.decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.policy.ActAs(p, req.UserID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	if err := s.store.CancelFriendRequest(r.Context(), req.UserID, req.FriendID); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Friend request not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to cancel friend request")
+		return
+	}
+	s.activity().PublishFriend(r.Context(), req.FriendID, req.UserID, req.UserID, req.FriendID, "cancelled")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+}
+
+// removeFriendHandler serves POST /api/friends/remove
+func (s *Server) removeFriendHandler(w http.ResponseWriter, r *http.Request) {
+	p, req, ok := s.decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+	// Either side may end a friendship or withdraw a request
+	if s.policy.ActAs(p, req.UserID) != nil && s.policy.ActAs(p, req.FriendID) != nil {
+		deny(w, authz.ErrForbidden, "")
+		return
+	}
+	if err := s.store.RemoveFriend(r.Context(), req.UserID, req.FriendID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to remove friend")
+		return
+	}
+	s.activity().FriendRemoved(r.Context(), p.UserID, req.UserID, req.FriendID)
+	json.NewEncoder(w).Encode(map[string]string{"status": "removed"})
+}
+
+// blockUserHandler serves POST /api/friends/block. user_id blocks
+// friend_id, which also ends any friendship or request between them.
+func (s *Server) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	p, req, ok := s.decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.policy.ActAs(p, req.UserID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	if err := s.store.BlockUser(r.Context(), req.UserID, req.FriendID); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	} else if err != nil {
+		log.Printf("[FRIEND BLOCK ERROR] userID=%v blockedID=%v error=%v", req.UserID, req.FriendID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to block user")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "blocked"})
+}
+
+// unblockUserHandler serves POST /api/friends/unblock. Friendships ended by
+// the block are not restored.
+func (s *Server) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	p, req, ok := s.decodeFriendRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.policy.ActAs(p, req.UserID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	if err := s.store.UnblockUser(r.Context(), req.UserID, req.FriendID); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Block not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to unblock user")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "unblocked"})
+}
+
+// listFriendsHandler serves GET /api/friends. ?pending_for= lists incoming
+// requests instead; ?user_id= lists another user's friends, which only
+// their friends and admins may see.
+func (s *Server) listFriendsHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if pendingFor := r.URL.Query().Get("pending_for"); pendingFor != "" {
+		s.listRequests(w, r, pendingFor, s.store.ListFriendRequests)
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		userID = p.ID()
+	}
+	if err := s.policy.ListFriends(r.Context(), p, userID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	friends, err := s.store.ListFriends(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list friends")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"friends": friends})
+}
+
+// friendRequestsHandler serves GET /api/friends/requests, the caller's
+// pending requests. ?direction=outgoing lists the requests they sent rather
+// than received. Admins may pass ?user_id= to see another user's.
+func (s *Server) friendRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		userID = principal(r).ID()
+	}
+	switch r.URL.Query().Get("direction") {
+	case "", "incoming":
+		s.listRequests(w, r, userID, s.store.ListFriendRequests)
+	case "outgoing":
+		s.listRequests(w, r, userID, s.store.ListSentFriendRequests)
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "direction must be incoming or outgoing")
+	}
+}
+
+// listRequests writes the friend requests that list returns for userID,
+// which only the user and admins may see
+func (s *Server) listRequests(w http.ResponseWriter, r *http.Request, userID string, list func(context.Context, string) ([]model.UserSummary, error)) {
+	if err := s.policy.ReadPrivate(principal(r), userID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	requests, err := list(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list friend requests")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"requests": requests})
+}
+
+// blockedUsersHandler serves GET /api/friends/blocked, the users the caller
+// has blocked. Admins may pass ?user_id= to see another user's.
+func (s *Server) blockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		userID = p.ID()
+	}
+	if err := s.policy.ReadPrivate(p, userID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	blocked, err := s.store.ListBlocked(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list blocked users")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"blocked": blocked})
+}
+
+// friendsDreamsHandler serves GET /api/friends/dreams, a page of the public
+// and friends-only dreams by the caller's friends. Admins may pass ?user_id=
+// to see another user's feed.
+func (s *Server) friendsDreamsHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		userID = p.ID()
+	}
+	if err := s.policy.ReadPrivate(p, userID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	friendIDs, err := s.store.FriendIDs(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list friends")
+		return
+	}
+	if len(friendIDs) == 0 {
+		json.NewEncoder(w).Encode(newDreamPage([]model.Dream{}, nil))
+		return
+	}
+	dreams, next, err := s.store.ListDreams(r.Context(), model.DreamFilter{Owners: friendIDs, Viewer: userID, Page: page})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch friends' dreams")
+		return
+	}
+	json.NewEncoder(w).Encode(newDreamPage(dreams, next))
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// jobResponse is a job plus the public ID of the dream it belongs to
+type jobResponse struct {
+	*jobs.Job
+	DreamID string `json:"dreamId"`
+}
+
+// authorizeJob loads a job and checks that the caller may manage its dream.
+// It writes the error response itself.
+func (s *Server) authorizeJob(w http.ResponseWriter, r *http.Request) (*jobResponse, bool) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return nil, false
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Job not found")
+		return nil, false
+	}
+	job, err := s.jobs.Get(r.Context(), id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Job not found")
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return nil, false
+	}
+	d, err := s.store.GetDreamByRowID(r.Context(), job.DreamID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Job not found")
+		return nil, false
+	}
+	if err := s.policy.ManageDream(r.Context(), p, d); err != nil {
+		deny(w, err, "Job not found")
+		return nil, false
+	}
+	return &jobResponse{Job: job, DreamID: d.ID}, true
+}
+
+// jobHandler serves GET /api/jobs/{id} so clients can poll queued AI work
+func (s *Server) jobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.authorizeJob(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// retryJobHandler serves POST /api/jobs/{id}/retry for dead-lettered jobs
+func (s *Server) retryJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.authorizeJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != jobs.StatusDead {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "Only dead jobs can be retried")
+		return
+	}
+	retried, err := s.jobs.Retry(r.Context(), job.ID)
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "Only dead jobs can be retried")
+		return
+	} else if errors.Is(err, jobs.ErrConflict) {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "This job is already queued again")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to retry job")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(jobResponse{Job: retried, DreamID: job.DreamID})
+}
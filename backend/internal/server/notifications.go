@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+// MarkNotificationsReadRequest names the notifications to mark read, or
+// all of them
+type MarkNotificationsReadRequest struct {
+	IDs []int `json:"ids"`
+	All bool  `json:"all"`
+}
+
+// NotificationPrefsRequest turns notification types on or off. Types left
+// out keep their setting.
+type NotificationPrefsRequest struct {
+	Preferences model.NotificationPrefs `json:"preferences"`
+}
+
+// notify stores n for its recipient and pushes it to their event stream.
+// Notifications are a side effect of the write that caused them, so
+// failures are logged rather than failing the request. Nobody is notified
+// about their own doings.
+func (s *Server) notify(ctx context.Context, n model.Notification) {
+	s.activity().Notify(ctx, n)
+}
+
+// notifyComment tells the author of the comment c replies to, and the
+// owner of the dream it is on, about it. An owner replied to gets only
+// the reply.
+func (s *Server) notifyComment(ctx context.Context, d *model.Dream, c *model.Comment) {
+	base := model.Notification{Actor: c.User, DreamRowID: d.RowID, CommentID: &c.ID}
+	repliedTo := ""
+	if c.ParentID != nil {
+		parent, err := s.store.GetComment(ctx, *c.ParentID)
+		if err != nil {
+			log.Printf("[NOTIFICATIONS] Failed to look up comment %d: %v", *c.ParentID, err)
+		} else {
+			repliedTo = parent.User.ID
+			n := base
+			n.Type, n.UserID = model.NotifyReply, repliedTo
+			s.notify(ctx, n)
+		}
+	}
+	if d.UserID != repliedTo {
+		n := base
+		n.Type, n.UserID = model.NotifyComment, d.UserID
+		s.notify(ctx, n)
+	}
+}
+
+// notificationsHandler serves GET /api/notifications, the caller's
+// notifications newest first. ?unread=true leaves out the read ones.
+func (s *Server) notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	notifications, next, err := s.store.ListNotifications(r.Context(), p.UserID, unreadOnly, page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch notifications")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+		"next_cursor":   encodeCursor(next),
+	})
+}
+
+// unreadNotificationsHandler serves GET /api/notifications/unread: how many
+// unread notifications the caller has, in total and by type
+func (s *Server) unreadNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	counts, err := s.store.CountUnreadNotifications(r.Context(), p.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to count notifications")
+		return
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"total": total, "by_type": counts})
+}
+
+// markNotificationsReadHandler serves POST /api/notifications/read. IDs
+// that are not the caller's unread notifications are skipped.
+func (s *Server) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	var req MarkNotificationsReadRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.All == (len(req.IDs) > 0) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "Pass either ids or all")
+		return
+	}
+	ids := req.IDs
+	if req.All {
+		ids = nil
+	}
+	marked, err := s.store.MarkNotificationsRead(r.Context(), p.UserID, ids)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to mark notifications read")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"marked": marked})
+}
+
+// notificationPrefsHandler serves GET /api/notifications/preferences
+func (s *Server) notificationPrefsHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	s.writeNotificationPrefs(w, r, p.UserID)
+}
+
+// updateNotificationPrefsHandler serves PUT /api/notifications/preferences
+// and answers with the preferences as they now stand
+func (s *Server) updateNotificationPrefsHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	var req NotificationPrefsRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	for t := range req.Preferences {
+		if !t.Valid() {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Unknown notification type %q", t))
+			return
+		}
+	}
+	if err := s.store.SetNotificationPrefs(r.Context(), p.UserID, req.Preferences); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to save notification preferences")
+		return
+	}
+	s.writeNotificationPrefs(w, r, p.UserID)
+}
+
+func (s *Server) writeNotificationPrefs(w http.ResponseWriter, r *http.Request, userID string) {
+	prefs, err := s.store.NotificationPrefs(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch notification preferences")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"preferences": prefs})
+}
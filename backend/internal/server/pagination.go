@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// DreamPage is one page of a dream listing. NextCursor is passed back as
+// ?cursor= to fetch the following page and is null on the last page.
+type DreamPage struct {
+	Dreams     []model.Dream `json:"dreams"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+// parsePage reads the limit and cursor query parameters
+func parsePage(r *http.Request) (model.Page, error) {
+	var p model.Page
+	var err error
+	if p.Limit, err = queryInt(r, "limit", defaultPageLimit, 1, maxPageLimit); err != nil {
+		return p, err
+	}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		if p.After, err = model.DecodeCursor(c); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// encodeCursor returns the next_cursor value for a listing
+func encodeCursor(c *model.Cursor) *string {
+	if c == nil {
+		return nil
+	}
+	s := c.Encode()
+	return &s
+}
+
+func newDreamPage(dreams []model.Dream, next *model.Cursor) DreamPage {
+	return DreamPage{Dreams: dreams, NextCursor: encodeCursor(next)}
+}
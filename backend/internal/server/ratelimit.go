@@ -0,0 +1,114 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/ratelimit"
+
+	"github.com/gorilla/mux"
+)
+
+// RateLimits are the quotas of each route group. Each applies separately
+// to every client IP address and every signed-in account.
+type RateLimits struct {
+	// Auth covers registering, logging in and the token and email
+	// endpoints. Failed logins also lock the account out, see
+	// Server.Lockout.
+	Auth ratelimit.Rate
+	// AI covers the endpoints that call the AI model
+	AI ratelimit.Rate
+	// API covers every other endpoint
+	API ratelimit.Rate
+}
+
+// Limiters are the token buckets of each route group. Events charges the
+// API rate to GET /api/events apart from the rest of the API.
+type Limiters struct {
+	Auth, AI, API, Events *ratelimit.Limiter
+}
+
+// Limiters returns the limiters enforcing RateLimits, creating them on the
+// first call. The gRPC server shares Auth and AI so a client gets each
+// quota once, whichever API it uses.
+func (s *Server) Limiters() Limiters {
+	s.limitersOnce.Do(func() {
+		s.limiters = Limiters{
+			Auth:   ratelimit.NewLimiter(s.RateLimits.Auth),
+			AI:     ratelimit.NewLimiter(s.RateLimits.AI),
+			API:    ratelimit.NewLimiter(s.RateLimits.API),
+			Events: ratelimit.NewLimiter(s.RateLimits.API),
+		}
+	})
+	return s.limiters
+}
+
+// limit returns middleware charging limiter for one route group
+func (s *Server) limit(limiter *ratelimit.Limiter) mux.MiddlewareFunc {
+	byIP, byUser := s.splitLimit(limiter)
+	return func(next http.Handler) http.Handler {
+		return byIP(byUser(next))
+	}
+}
+
+// splitLimit returns the two halves of limit. byIP charges the client IP
+// and goes before authenticate, so requests with bad or expired tokens use
+// up the quota too; byUser charges the signed-in account and goes after
+// it.
+func (s *Server) splitLimit(limiter *ratelimit.Limiter) (byIP, byUser mux.MiddlewareFunc) {
+	charge := func(key func(r *http.Request) string) mux.MiddlewareFunc {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if k := key(r); k != "" {
+					if ok, retry := limiter.Allow(k); !ok {
+						tooManyRequests(w, retry)
+						return
+					}
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	byIP = charge(func(r *http.Request) string { return "ip:" + s.clientIP(r) })
+	byUser = charge(func(r *http.Request) string {
+		if id := principal(r).ID(); id != "" {
+			return "user:" + id
+		}
+		return ""
+	})
+	return byIP, byUser
+}
+
+// clientIP returns the caller's IP address. Behind a trusted reverse proxy
+// it is the last address the proxy appended to X-Forwarded-For, since
+// earlier entries come from the client and can be forged.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.TrustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tooManyRequests writes the response for a rate limit or lockout. Both
+// look the same, so a lockout does not reveal that an account exists.
+func tooManyRequests(w http.ResponseWriter, retry time.Duration) {
+	seconds := int(math.Ceil(retry.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many requests")
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/authz"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+// reactionTarget resolves the dream or comment named in the URL and applies
+// rule to the dream. It writes the error response itself.
+func (s *Server) reactionTarget(w http.ResponseWriter, r *http.Request, rule func(context.Context, *authz.Principal, *model.Dream) error) (model.ReactionTarget, bool) {
+	if publicID, ok := mux.Vars(r)["public_id"]; ok {
+		d, ok := s.loadDream(w, r, publicID, rule)
+		if !ok {
+			return model.ReactionTarget{}, false
+		}
+		return model.ReactionTarget{DreamRowID: d.RowID}, true
+	}
+	c, d, ok := s.loadComment(w, r)
+	if !ok || !s.checkComment(w, r, c, d, rule) {
+		return model.ReactionTarget{}, false
+	}
+	return model.ReactionTarget{CommentID: c.ID}, true
+}
+
+// reactionKind reads the {kind} path segment. It writes the error response
+// itself.
+func reactionKind(w http.ResponseWriter, r *http.Request) (model.ReactionKind, bool) {
+	kind := model.ReactionKind(mux.Vars(r)["kind"])
+	if !kind.Valid() {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "Unknown reaction; use one of "+reactionKindList())
+		return "", false
+	}
+	return kind, true
+}
+
+func reactionKindList() string {
+	kinds := make([]string, len(model.ReactionKinds))
+	for i, k := range model.ReactionKinds {
+		kinds[i] = string(k)
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// writeReactionCounts answers an add or remove with the target's new
+// counts
+func (s *Server) writeReactionCounts(w http.ResponseWriter, r *http.Request, target model.ReactionTarget) {
+	counts, err := s.store.CountReactions(r.Context(), target)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to count reactions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"reactions": counts})
+}
+
+// addReactionHandler serves PUT /api/dreams/{public_id}/reactions/{kind}
+// and PUT /api/comments/{comment_id}/reactions/{kind}. Reacting twice with
+// the same kind changes nothing.
+func (s *Server) addReactionHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.reactionTarget(w, r, s.policy.React)
+	if !ok {
+		return
+	}
+	kind, ok := reactionKind(w, r)
+	if !ok {
+		return
+	}
+	reaction := model.Reaction{Kind: kind, User: model.UserSummary{ID: principal(r).UserID}, Target: target}
+	if err := s.store.AddReaction(r.Context(), &reaction); errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Not found")
+		return
+	} else if err != nil {
+		log.Printf("[REACTIONS] Failed to add reaction %s by user %s: %v", kind, reaction.User.ID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to add reaction")
+		return
+	}
+	s.writeReactionCounts(w, r, target)
+}
+
+// removeReactionHandler serves DELETE on the same paths as
+// addReactionHandler. Removing a reaction that is not there changes
+// nothing.
+func (s *Server) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.reactionTarget(w, r, s.policy.React)
+	if !ok {
+		return
+	}
+	kind, ok := reactionKind(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.RemoveReaction(r.Context(), target, principal(r).UserID, kind); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to remove reaction")
+		return
+	}
+	s.writeReactionCounts(w, r, target)
+}
+
+// listReactionsHandler serves GET /api/dreams/{public_id}/reactions and GET
+// /api/comments/{comment_id}/reactions: who reacted, newest first.
+// ?kind= narrows the list to one reaction.
+func (s *Server) listReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.reactionTarget(w, r, s.policy.ViewDream)
+	if !ok {
+		return
+	}
+	kind := model.ReactionKind(r.URL.Query().Get("kind"))
+	if kind != "" && !kind.Valid() {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "kind must be one of "+reactionKindList())
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	reactions, next, err := s.store.ListReactions(r.Context(), target, kind, principal(r).ID(), page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch reactions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reactions":   reactions,
+		"next_cursor": encodeCursor(next),
+	})
+}
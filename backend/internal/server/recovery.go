@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/accounts"
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// accounts returns the account manager for the server's settings
+func (s *Server) accounts() *accounts.Manager {
+	return &accounts.Manager{Store: s.store, Mailer: s.Mailer, AppURL: s.AppURL, ResetTTL: s.ResetTTL, VerifyTTL: s.VerifyTTL}
+}
+
+// useAccountToken consumes a token for purpose and writes the error
+// response if it is unknown, used or expired
+func (s *Server) useAccountToken(w http.ResponseWriter, r *http.Request, purpose, token string) (*model.AccountToken, bool) {
+	t, err := s.store.UseAccountToken(r.Context(), purpose, auth.HashAccountToken(token))
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrRevoked) {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidToken, "Invalid or expired token")
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to check token")
+		return nil, false
+	}
+	if time.Now().After(t.ExpiresAt) {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidToken, "Invalid or expired token")
+		return nil, false
+	}
+	return t, true
+}
+
+// forgotPasswordHandler serves POST /api/password/forgot and mails a reset
+// link. The response is the same, and as quick, whether or not the email
+// is registered.
+func (s *Server) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	user, _, err := s.store.GetCredentials(r.Context(), req.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		log.Printf("[PASSWORD] Reset requested for unknown email: %s", req.Email)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return
+	}
+	// Send in the background so the answer takes as long as for an unknown
+	// address
+	s.accounts().SendLater(r.Context(), user, model.TokenPasswordReset)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPasswordHandler serves POST /api/password/reset. It sets the new
+// password and signs the user out everywhere. Following the link also
+// proves the user owns the email address.
+func (s *Server) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	t, ok := s.useAccountToken(w, r, model.TokenPasswordReset, req.Token)
+	if !ok {
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to hash password")
+		return
+	}
+	if err := s.store.SetPassword(r.Context(), t.UserID, string(hash)); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to reset password")
+		return
+	}
+	if err := s.store.RevokeUserRefreshTokens(r.Context(), t.UserID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to reset password")
+		return
+	}
+	if err := s.store.SetEmailVerified(r.Context(), t.UserID); err != nil {
+		log.Printf("[PASSWORD] Failed to mark email verified for user %s: %v", t.UserID, err)
+	}
+	// The new password should work straight away, even after a lockout
+	if u, err := s.store.GetUser(r.Context(), t.UserID); err == nil {
+		s.Lockout.Reset(auth.LoginKey(u.Email))
+	}
+	log.Printf("[PASSWORD] Password reset for user %s", t.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyEmailHandler serves POST /api/email/verify
+func (s *Server) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	t, ok := s.useAccountToken(w, r, model.TokenEmailVerify, req.Token)
+	if !ok {
+		return
+	}
+	if err := s.store.SetEmailVerified(r.Context(), t.UserID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to verify email")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resendVerificationHandler serves POST /api/email/verify/resend and mails
+// the caller a new verification link, unless they are already verified
+func (s *Server) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	if err := s.policy.Authenticated(p); err != nil {
+		deny(w, err, "")
+		return
+	}
+	user, err := s.store.GetUser(r.Context(), p.UserID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "User not found")
+		return
+	}
+	if user.EmailVerified {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := s.accounts().SendToken(r.Context(), user, model.TokenEmailVerify); err != nil {
+		log.Printf("[EMAIL] Failed to send verification link to user %s: %v", user.ID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to send verification email")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+// optionalRating distinguishes an absent rating from an explicit null in a
+// PATCH body. Null clears the rating.
+type optionalRating struct {
+	Set   bool
+	Value *int
+}
+
+func (o *optionalRating) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	return json.Unmarshal(b, &o.Value)
+}
+
+// FieldValue lets validate check the rating only when it is given
+func (o optionalRating) FieldValue() (interface{}, bool) {
+	return o.Value, o.Set
+}
+
+// UpdateDreamRequest is the body of PATCH /api/dreams/{public_id}. Omitted
+// fields are left unchanged. Public is the old on/off switch, used only when
+// Visibility is omitted.
+type UpdateDreamRequest struct {
+	Title                    *string           `json:"title" validate:"max=200"`
+	Text                     *string           `json:"text" validate:"notblank,max=20000"`
+	Visibility               *model.Visibility `json:"visibility" validate:"oneof=private friends public unlisted"`
+	Public                   *bool             `json:"public"`
+	NightmareRating          optionalRating    `json:"nightmare_rating" validate:"min=1,max=10"`
+	VividnessRating          optionalRating    `json:"vividness_rating" validate:"min=1,max=10"`
+	ClarityRating            optionalRating    `json:"clarity_rating" validate:"min=1,max=10"`
+	EmotionalIntensityRating optionalRating    `json:"emotional_intensity_rating" validate:"min=1,max=10"`
+}
+
+// updateDreamHandler serves PATCH /api/dreams/{public_id}
+func (s *Server) updateDreamHandler(w http.ResponseWriter, r *http.Request) {
+	publicID := mux.Vars(r)["public_id"]
+	var req UpdateDreamRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	d, ok := s.loadDream(w, r, publicID, s.policy.EditDream)
+	if !ok {
+		return
+	}
+	dream, err := s.store.EditDream(r.Context(), d.RowID, principal(r).UserID, func(st *model.DreamState) {
+		if req.Title != nil {
+			st.Title = *req.Title
+		}
+		if req.Text != nil {
+			st.Text = *req.Text
+		}
+		if req.Visibility != nil {
+			st.Visibility = *req.Visibility
+		} else if req.Public != nil {
+			st.Visibility = model.VisibilityOf(*req.Public)
+		}
+		if req.NightmareRating.Set {
+			st.NightmareRating = req.NightmareRating.Value
+		}
+		if req.VividnessRating.Set {
+			st.VividnessRating = req.VividnessRating.Value
+		}
+		if req.ClarityRating.Set {
+			st.ClarityRating = req.ClarityRating.Value
+		}
+		if req.EmotionalIntensityRating.Set {
+			st.EmotionalIntensityRating = req.EmotionalIntensityRating.Value
+		}
+	})
+	writeEditResult(w, publicID, dream, err)
+}
+
+func writeEditResult(w http.ResponseWriter, publicID string, dream *model.Dream, err error) {
+	if errors.Is(err, store.ErrNoChanges) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		log.Printf("[EDIT] Failed to update dream %s: %v", publicID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update dream")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dream)
+}
+
+// revisionsHandler serves GET /api/dreams/{public_id}/revisions
+func (s *Server) revisionsHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.loadDream(w, r, mux.Vars(r)["public_id"], s.policy.EditDream)
+	if !ok {
+		return
+	}
+	revisions, err := s.store.ListRevisions(r.Context(), d.RowID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch revisions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"revisions": revisions})
+}
+
+// loadRevision writes a 404 or 500 response itself when the revision cannot
+// be loaded
+func (s *Server) loadRevision(w http.ResponseWriter, r *http.Request, rowID, revision int) (*model.DreamRevision, bool) {
+	rev, err := s.store.GetRevision(r.Context(), rowID, revision)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Revision not found")
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return nil, false
+	}
+	return rev, true
+}
+
+// revisionHandler serves GET /api/dreams/{public_id}/revisions/{revision}
+func (s *Server) revisionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	d, ok := s.loadDream(w, r, vars["public_id"], s.policy.EditDream)
+	if !ok {
+		return
+	}
+	revision, _ := strconv.Atoi(vars["revision"])
+	rev, ok := s.loadRevision(w, r, d.RowID, revision)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+// FieldChange is a before/after pair for a changed scalar field
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// RevisionDiff is the response body of the revision diff endpoint
+type RevisionDiff struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Changes map[string]FieldChange `json:"changes"`
+	Text    []DiffOp               `json:"text"`
+}
+
+// revisionDiffHandler serves GET /api/dreams/{public_id}/revisions/diff?from=N&to=M.
+// to defaults to the latest revision and from to the one before it.
+func (s *Server) revisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.loadDream(w, r, mux.Vars(r)["public_id"], s.policy.EditDream)
+	if !ok {
+		return
+	}
+	latest, err := s.store.LatestRevision(r.Context(), d.RowID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return
+	}
+	if latest == 0 {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Dream has no revisions")
+		return
+	}
+	to, err := queryInt(r, "to", latest, 1, latest)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	defFrom := to - 1
+	if defFrom < 1 {
+		defFrom = 1
+	}
+	from, err := queryInt(r, "from", defFrom, 1, latest)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	a, ok := s.loadRevision(w, r, d.RowID, from)
+	if !ok {
+		return
+	}
+	b, ok := s.loadRevision(w, r, d.RowID, to)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diffRevisions(a, b))
+}
+
+func diffRevisions(a, b *model.DreamRevision) RevisionDiff {
+	diff := RevisionDiff{From: a.Revision, To: b.Revision, Changes: map[string]FieldChange{}, Text: diffLines(a.Text, b.Text)}
+	if a.Title != b.Title {
+		diff.Changes["title"] = FieldChange{a.Title, b.Title}
+	}
+	if a.Visibility != b.Visibility {
+		diff.Changes["visibility"] = FieldChange{a.Visibility, b.Visibility}
+	}
+	ratings := []struct {
+		name string
+		a, b *int
+	}{
+		{"nightmare_rating", a.NightmareRating, b.NightmareRating},
+		{"vividness_rating", a.VividnessRating, b.VividnessRating},
+		{"clarity_rating", a.ClarityRating, b.ClarityRating},
+		{"emotional_intensity_rating", a.EmotionalIntensityRating, b.EmotionalIntensityRating},
+	}
+	for _, rt := range ratings {
+		if !model.IntPtrEqual(rt.a, rt.b) {
+			diff.Changes[rt.name] = FieldChange{rt.a, rt.b}
+		}
+	}
+	return diff
+}
+
+// restoreRevisionHandler serves POST /api/dreams/{public_id}/revisions/{revision}/restore.
+// Restoring is itself an edit, so it creates a new revision.
+func (s *Server) restoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	publicID := vars["public_id"]
+	d, ok := s.loadDream(w, r, publicID, s.policy.EditDream)
+	if !ok {
+		return
+	}
+	revision, _ := strconv.Atoi(vars["revision"])
+	rev, ok := s.loadRevision(w, r, d.RowID, revision)
+	if !ok {
+		return
+	}
+	dream, err := s.store.EditDream(r.Context(), d.RowID, principal(r).UserID, func(st *model.DreamState) {
+		*st = rev.State()
+	})
+	writeEditResult(w, publicID, dream, err)
+}
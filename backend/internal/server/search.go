@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchOffset    = 10000
+)
+
+// searchHandler serves GET /api/dreams/search?q=... Anonymous callers only
+// search public dreams; signed-in users also search their own private dreams.
+// Rating filters are passed as e.g. nightmare_min=3&nightmare_max=7.
+func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := model.SearchFilter{
+		Query:     strings.TrimSpace(q.Get("q")),
+		Scope:     q.Get("scope"),
+		RatingMin: map[string]int{},
+		RatingMax: map[string]int{},
+	}
+	if f.Query == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidation, "Missing search query")
+		return
+	}
+	if f.Scope == "" {
+		f.Scope = "all"
+	}
+	switch f.Scope {
+	case "all", "mine", "friends", "public":
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "scope must be one of all, mine, friends or public")
+		return
+	}
+	f.ViewerID = principal(r).ID()
+	if f.Scope == "mine" || f.Scope == "friends" {
+		if err := s.policy.Authenticated(principal(r)); err != nil {
+			deny(w, err, "")
+			return
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range q["tag"] {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			f.Tags = append(f.Tags, tag)
+		}
+	}
+	var err error
+	if f.Dates, err = parseDateRange(r); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	for _, rating := range model.RatingNames {
+		for _, bound := range []string{"min", "max"} {
+			name := rating + "_" + bound
+			if q.Get(name) == "" {
+				continue
+			}
+			v, err := queryInt(r, name, 0, 1, 10)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+				return
+			}
+			if bound == "min" {
+				f.RatingMin[rating] = v
+			} else {
+				f.RatingMax[rating] = v
+			}
+		}
+	}
+	if f.Limit, err = queryInt(r, "limit", defaultSearchLimit, 1, maxSearchLimit); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if f.Offset, err = queryInt(r, "offset", 0, 0, maxSearchOffset); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	resp, err := s.store.SearchDreams(r.Context(), f)
+	if err != nil {
+		log.Printf("[SEARCH] Failed to search dreams: %v", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to search dreams")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,345 @@
+// Package server implements the REST API. Handlers are methods on Server so
+// the store, authenticator, AI provider and job queue can be swapped out in
+// tests.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/authz"
+	"github.com/Calrus/ourdreamjournal/backend/internal/events"
+	"github.com/Calrus/ourdreamjournal/backend/internal/mail"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/ratelimit"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// JobQueue is the part of jobs.Queue the handlers use
+type JobQueue interface {
+	Enqueue(ctx context.Context, kind jobs.Kind, dreamID int) (*jobs.Job, error)
+	Get(ctx context.Context, id int64) (*jobs.Job, error)
+	Retry(ctx context.Context, id int64) (*jobs.Job, error)
+	// Notify wakes a worker after jobs were queued by the store
+	Notify()
+}
+
+// Server holds the dependencies shared by every handler
+type Server struct {
+	store  store.Store
+	auth   auth.Authenticator
+	policy *authz.Policy
+	ai     ai.DreamAI
+	jobs   JobQueue
+
+	// InsightConcurrency caps the summaries generated in parallel for one
+	// insights request
+	InsightConcurrency int
+	// RefreshTTL is how long a refresh token stays valid
+	RefreshTTL time.Duration
+
+	// Mailer sends password reset and email verification links, which
+	// point at the frontend under AppURL
+	Mailer mail.Mailer
+	AppURL string
+	// ResetTTL and VerifyTTL are how long those links stay valid
+	ResetTTL  time.Duration
+	VerifyTTL time.Duration
+
+	// RateLimits are read the first time Limiters or Routes is called
+	RateLimits   RateLimits
+	limitersOnce sync.Once
+	limiters     Limiters
+	// TrustProxy takes client addresses from X-Forwarded-For; only set it
+	// behind a reverse proxy that appends to that header
+	TrustProxy bool
+	// Lockout counts failed logins per email. The gRPC server can share it
+	// so both APIs count the same failures.
+	Lockout *ratelimit.Lockout
+
+	// Hub streams events to this process's GET /api/events clients.
+	// Handlers publish to Events, which is Hub itself unless replicas
+	// share events through Postgres.
+	Hub    *events.Hub
+	Events events.Publisher
+}
+
+func New(st store.Store, authn auth.Authenticator, dreamAI ai.DreamAI, queue JobQueue) *Server {
+	hub := events.NewHub()
+	return &Server{
+		store:              st,
+		auth:               authn,
+		policy:             authz.New(st),
+		ai:                 dreamAI,
+		jobs:               queue,
+		InsightConcurrency: 5,
+		RefreshTTL:         30 * 24 * time.Hour,
+		Mailer:             mail.Log{},
+		AppURL:             "http://localhost:3000",
+		ResetTTL:           time.Hour,
+		VerifyTTL:          48 * time.Hour,
+		RateLimits: RateLimits{
+			Auth: ratelimit.Rate{Limit: 30, Per: time.Minute},
+			AI:   ratelimit.Rate{Limit: 60, Per: time.Hour},
+			API:  ratelimit.Rate{Limit: 600, Per: time.Minute},
+		},
+		Lockout: ratelimit.NewLockout(),
+		Hub:     hub,
+		Events:  hub,
+	}
+}
+
+// Routes registers every REST endpoint on a new router
+func (s *Server) Routes() *mux.Router {
+	root := mux.NewRouter()
+	root.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	root.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+
+	// Endpoints that issue or revoke tokens ignore any bearer token sent
+	// along, so a stale one cannot get in the way of signing in again
+	a := root.NewRoute().Subrouter()
+	a.Use(s.limit(s.Limiters().Auth))
+	a.HandleFunc("/api/register", s.registerHandler).Methods("POST")
+	a.HandleFunc("/api/login", s.loginHandler).Methods("POST")
+	a.HandleFunc("/api/token/refresh", s.refreshHandler).Methods("POST")
+	a.HandleFunc("/api/logout", s.logoutHandler).Methods("POST")
+	a.HandleFunc("/api/password/forgot", s.forgotPasswordHandler).Methods("POST")
+	a.HandleFunc("/api/password/reset", s.resetPasswordHandler).Methods("POST")
+	a.HandleFunc("/api/email/verify", s.verifyEmailHandler).Methods("POST")
+
+	// The event stream may carry its token in the URL
+	ev := root.NewRoute().Subrouter()
+	evByIP, evByUser := s.splitLimit(s.Limiters().Events)
+	ev.Use(queryToken, evByIP, s.authenticate, evByUser)
+	ev.HandleFunc("/api/events", s.eventsHandler).Methods("GET")
+
+	// Everything else runs behind authenticate and checks access with
+	// s.policy. The client IP is charged before the token is checked.
+	r := root.NewRoute().Subrouter()
+	byIP, byUser := s.splitLimit(s.Limiters().API)
+	r.Use(byIP, s.authenticate, byUser)
+
+	// Endpoints that call the AI model count against their own quota too.
+	// They are registered first so /api/dreams/{public_id} does not shadow
+	// them.
+	ai := r.NewRoute().Subrouter()
+	ai.Use(s.limit(s.Limiters().AI))
+	ai.HandleFunc("/api/dreams/prophecy", s.prophecyHandler).Methods("POST")
+	ai.HandleFunc("/api/dreams/tags", s.extractTagsHandler).Methods("POST")
+	ai.HandleFunc("/api/dreams/summary", s.summaryHandler).Methods("POST")
+	ai.HandleFunc("/api/ai-insights", s.insightsHandler).Methods("POST")
+	ai.HandleFunc("/api/jobs/{id:[0-9]+}/retry", s.retryJobHandler).Methods("POST")
+
+	// Accounts and profiles
+	r.HandleFunc("/api/me", s.meHandler).Methods("GET")
+	r.HandleFunc("/api/email/verify/resend", s.resendVerificationHandler).Methods("POST")
+	r.HandleFunc("/api/users/{username}/public", s.publicProfileHandler).Methods("GET")
+	r.HandleFunc("/api/users/me/profile", s.profileHandler).Methods("GET")
+	r.HandleFunc("/api/users/me/profile", s.updateProfileHandler).Methods("PUT")
+	r.HandleFunc("/api/users/me/username", s.renameHandler).Methods("PUT")
+
+	// Server-side dream statistics
+	r.HandleFunc("/api/users/{id}/stats", s.statsHandler).Methods("GET")
+	// Tag analytics: usage, co-occurrence and monthly trends
+	r.HandleFunc("/api/users/{id}/tags", s.tagsHandler).Methods("GET")
+
+	// Dreams. Fixed paths are registered before /api/dreams/{public_id} so
+	// "search" and friends are not taken for dream IDs.
+	r.HandleFunc("/api/dreams", s.createDreamHandler).Methods("POST")
+	r.HandleFunc("/api/dreams", s.listDreamsHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/search", s.searchHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/{public_id}", s.getDreamHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/{public_id}", s.deleteDreamHandler).Methods("DELETE")
+	r.HandleFunc("/api/dreams/{public_id}/tags", s.replaceTagsHandler).Methods("PUT")
+
+	// Ranked home feed of own, friends' and popular dreams
+	r.HandleFunc("/api/feed", s.feedHandler).Methods("GET")
+
+	// Dream editing with revision history
+	r.HandleFunc("/api/dreams/{public_id}", s.updateDreamHandler).Methods("PATCH")
+	r.HandleFunc("/api/dreams/{public_id}/revisions", s.revisionsHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/{public_id}/revisions/diff", s.revisionDiffHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/{public_id}/revisions/{revision:[0-9]+}", s.revisionHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/{public_id}/revisions/{revision:[0-9]+}/restore", s.restoreRevisionHandler).Methods("POST")
+
+	// Background AI job status
+	r.HandleFunc("/api/jobs/{id:[0-9]+}", s.jobHandler).Methods("GET")
+
+	// Friend system
+	r.HandleFunc("/api/friends/request", s.friendRequestHandler).Methods("POST")
+	r.HandleFunc("/api/friends/accept", s.acceptFriendHandler).Methods("POST")
+	r.HandleFunc("/api/friends/reject", s.rejectFriendHandler).Methods("POST")
+	r.HandleFunc("/api/friends/cancel", s.cancelFriendHandler).Methods("POST")
+	r.HandleFunc("/api/friends/remove", s.removeFriendHandler).Methods("POST")
+	r.HandleFunc("/api/friends/block", s.blockUserHandler).Methods("POST")
+	r.HandleFunc("/api/friends/unblock", s.unblockUserHandler).Methods("POST")
+	r.HandleFunc("/api/friends", s.listFriendsHandler).Methods("GET")
+	r.HandleFunc("/api/friends/requests", s.friendRequestsHandler).Methods("GET")
+	r.HandleFunc("/api/friends/blocked", s.blockedUsersHandler).Methods("GET")
+	r.HandleFunc("/api/friends/dreams", s.friendsDreamsHandler).Methods("GET")
+
+	// Comments
+	r.HandleFunc("/api/dreams/{dream_id}/comments", s.listCommentsHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/{dream_id}/comments", s.createCommentHandler).Methods("POST")
+	r.HandleFunc("/api/comments/{comment_id}", s.updateCommentHandler).Methods("PATCH")
+	r.HandleFunc("/api/comments/{comment_id}", s.deleteCommentHandler).Methods("DELETE")
+	r.HandleFunc("/api/comments/{comment_id}/reports", s.reportCommentHandler).Methods("POST")
+
+	// Reactions on dreams and comments
+	r.HandleFunc("/api/dreams/{public_id}/reactions", s.listReactionsHandler).Methods("GET")
+	r.HandleFunc("/api/dreams/{public_id}/reactions/{kind}", s.addReactionHandler).Methods("PUT")
+	r.HandleFunc("/api/dreams/{public_id}/reactions/{kind}", s.removeReactionHandler).Methods("DELETE")
+	r.HandleFunc("/api/comments/{comment_id}/reactions", s.listReactionsHandler).Methods("GET")
+	r.HandleFunc("/api/comments/{comment_id}/reactions/{kind}", s.addReactionHandler).Methods("PUT")
+	r.HandleFunc("/api/comments/{comment_id}/reactions/{kind}", s.removeReactionHandler).Methods("DELETE")
+
+	// The caller's notifications
+	r.HandleFunc("/api/notifications", s.notificationsHandler).Methods("GET")
+	r.HandleFunc("/api/notifications/unread", s.unreadNotificationsHandler).Methods("GET")
+	r.HandleFunc("/api/notifications/read", s.markNotificationsReadHandler).Methods("POST")
+	r.HandleFunc("/api/notifications/preferences", s.notificationPrefsHandler).Methods("GET")
+	r.HandleFunc("/api/notifications/preferences", s.updateNotificationPrefsHandler).Methods("PUT")
+
+	// Moderation queue for reported comments
+	r.HandleFunc("/api/admin/reports", s.reportsHandler).Methods("GET")
+	r.HandleFunc("/api/admin/reports/{report_id:[0-9]+}/resolve", s.resolveReportHandler).Methods("POST")
+
+	return root
+}
+
+// authenticate is middleware that resolves the bearer token, if any, into
+// an authz.Principal in the request context. Requests without a token pass
+// through anonymously; a token that is present but invalid or expired is
+// rejected so the client knows to refresh it.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, err := auth.BearerToken(header)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Invalid access token: "+err.Error())
+			return
+		}
+		userID, expires, err := s.auth.ParseToken(token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Invalid access token: "+err.Error())
+			return
+		}
+		u, err := s.store.GetUser(r.Context(), userID)
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Invalid access token: user no longer exists")
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+			return
+		}
+		p := &authz.Principal{UserID: u.ID, IsAdmin: u.IsAdmin, ExpiresAt: expires}
+		next.ServeHTTP(w, r.WithContext(authz.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// principal returns the caller resolved by authenticate, or nil
+func principal(r *http.Request) *authz.Principal {
+	return authz.FromContext(r.Context())
+}
+
+// deny writes the response for an error from s.policy. Hidden resources are
+// reported with the notFound message, exactly like missing ones.
+func deny(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, authz.ErrUnauthenticated):
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Sign in required")
+	case errors.Is(err, authz.ErrForbidden):
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "Forbidden")
+	case errors.Is(err, authz.ErrHidden):
+		if notFound == "" {
+			notFound = "Not found"
+		}
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, notFound)
+	default:
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+	}
+}
+
+// loadDream fetches a dream by public ID and checks it against one of the
+// s.policy dream rules. Dreams by users who blocked, or were blocked by, the
+// caller look missing. It writes the error response itself.
+func (s *Server) loadDream(w http.ResponseWriter, r *http.Request, publicID string, rule func(context.Context, *authz.Principal, *model.Dream) error) (*model.Dream, bool) {
+	d, err := s.store.GetDream(r.Context(), publicID)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Dream not found")
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Database error")
+		return nil, false
+	}
+	if err := rule(r.Context(), principal(r), d); err != nil {
+		deny(w, err, "Dream not found")
+		return nil, false
+	}
+	if err := s.policy.SeeUser(r.Context(), principal(r), d.UserID); err != nil {
+		deny(w, err, "Dream not found")
+		return nil, false
+	}
+	return d, true
+}
+
+// queryInt reads an optional bounded integer query parameter
+func queryInt(r *http.Request, name string, def, min, max int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return n, nil
+}
+
+// parseDateRange reads the optional from/to query parameters. Both accept
+// YYYY-MM-DD (to is inclusive) or RFC 3339 timestamps.
+func parseDateRange(r *http.Request) (model.DateRange, error) {
+	var dr model.DateRange
+	parse := func(name string, endOfDay bool) (*time.Time, error) {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			return nil, nil
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			if endOfDay {
+				t = t.AddDate(0, 0, 1)
+			}
+			return &t, nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s date %q", name, v)
+		}
+		return &t, nil
+	}
+	var err error
+	if dr.From, err = parse("from", false); err != nil {
+		return dr, err
+	}
+	if dr.To, err = parse("to", true); err != nil {
+		return dr, err
+	}
+	if dr.From != nil && dr.To != nil && !dr.From.Before(*dr.To) {
+		return dr, fmt.Errorf("from must be before to")
+	}
+	return dr, nil
+}
@@ -0,0 +1,2034 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/mail"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/ratelimit"
+	"github.com/Calrus/ourdreamjournal/backend/internal/sessions"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store/memstore"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+// testEnv is a Server backed by the in-memory store and the deterministic
+// AI provider, served over httptest
+type testEnv struct {
+	store  *memstore.Store
+	auth   *auth.JWT
+	api    *Server
+	outbox *outbox
+	srv    *httptest.Server
+}
+
+// outbox is a mail.Mailer that keeps what it sends
+type outbox struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (o *outbox) Send(ctx context.Context, m mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, m)
+	return nil
+}
+
+// token returns the token in the link of the last message sent to addr.
+// Some links are mailed in the background, so it waits for the first one.
+func (o *outbox) token(t *testing.T, addr string) string {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if body, ok := o.last(addr); ok {
+			for _, word := range strings.Fields(body) {
+				if u, err := url.Parse(word); err == nil && u.Query().Get("token") != "" {
+					return u.Query().Get("token")
+				}
+			}
+			t.Fatalf("no link in mail to %s: %q", addr, body)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no mail sent to %s", addr)
+		}
+	}
+}
+
+// last returns the body of the last message sent to addr
+func (o *outbox) last(addr string) (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.sent) - 1; i >= 0; i-- {
+		if o.sent[i].To == addr {
+			return o.sent[i].Body, true
+		}
+	}
+	return "", false
+}
+
+func (o *outbox) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.sent)
+}
+
+// forgotPassword asks for a reset link for addr and returns its token. The
+// link is mailed in the background, so it waits for the mail to arrive.
+func (e *testEnv) forgotPassword(t *testing.T, addr string) string {
+	t.Helper()
+	sent := e.outbox.count()
+	expect(t, e.do(t, "POST", "/api/password/forgot", "", ForgotPasswordRequest{Email: addr}), http.StatusAccepted, nil)
+	for deadline := time.Now().Add(5 * time.Second); e.outbox.count() == sent; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("no reset mail sent to %s", addr)
+		}
+	}
+	return e.outbox.token(t, addr)
+}
+
+// unconfiguredAI fails tag extraction the way a missing provider does, so
+// the job dies on its first attempt
+type unconfiguredAI struct {
+	ai.Fake
+}
+
+func (unconfiguredAI) ExtractTags(ctx context.Context, text string) ([]string, error) {
+	return nil, ai.ErrNotConfigured
+}
+
+// newTestEnv starts a test server. configure, if given, can change the
+// Server's settings before its routes are built.
+func newTestEnv(t *testing.T, configure ...func(*Server)) *testEnv {
+	t.Helper()
+	st := memstore.New()
+	authn, err := auth.NewJWT(map[string][]byte{"test": []byte("test-secret")}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(st, authn, ai.Fake{}, st)
+	box := &outbox{}
+	s.Mailer = box
+	for _, f := range configure {
+		f(s)
+	}
+	srv := httptest.NewServer(s.Routes())
+	t.Cleanup(srv.Close)
+	return &testEnv{store: st, auth: authn, api: s, outbox: box, srv: srv}
+}
+
+// do sends a request with an optional bearer token and JSON body. body may
+// be a string, which is sent verbatim.
+func (e *testEnv) do(t *testing.T, method, path, token string, body interface{}) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	switch b := body.(type) {
+	case nil:
+	case string:
+		buf.WriteString(b)
+	default:
+		if err := json.NewEncoder(&buf).Encode(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, e.srv.URL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// expect checks the status code and decodes the JSON body into out, if set
+func expect(t *testing.T, resp *http.Response, status int, out interface{}) {
+	t.Helper()
+	if resp.StatusCode != status {
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		t.Fatalf("%s %s: got status %d, want %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, status, strings.TrimSpace(buf.String()))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding body: %v", resp.Request.Method, resp.Request.URL.Path, err)
+		}
+	}
+}
+
+// apiError decodes an error response body
+func apiError(t *testing.T, resp *http.Response) ErrorBody {
+	t.Helper()
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("%s %s: error Content-Type = %q", resp.Request.Method, resp.Request.URL.Path, ct)
+	}
+	var body ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("%s %s: decoding error body: %v", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return body.Error
+}
+
+// testPassword is the password register signs users up with
+const testPassword = "sweet dreams"
+
+// register signs up a user with testPassword and returns their ID and
+// token
+func (e *testEnv) register(t *testing.T, username string) (string, string) {
+	t.Helper()
+	var body struct {
+		User  model.User `json:"user"`
+		Token string     `json:"token"`
+	}
+	expect(t, e.do(t, "POST", "/api/register", "", RegisterRequest{
+		Email: username + "@example.com", Username: username, Password: testPassword,
+	}), http.StatusOK, &body)
+	return body.User.ID, body.Token
+}
+
+// createDream posts a dream and returns it
+func (e *testEnv) createDream(t *testing.T, token, title, text string, public bool) model.Dream {
+	t.Helper()
+	var d model.Dream
+	expect(t, e.do(t, "POST", "/api/dreams", token, CreateDreamRequest{Title: title, Text: text, Public: public}), http.StatusOK, &d)
+	return d
+}
+
+func dreamIDs(dreams []model.Dream) []string {
+	ids := []string{}
+	for _, d := range dreams {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	e := newTestEnv(t)
+
+	var reg struct {
+		User    model.User `json:"user"`
+		Token   string     `json:"token"`
+		IsAdmin bool       `json:"isAdmin"`
+	}
+	expect(t, e.do(t, "POST", "/api/register", "", RegisterRequest{Email: "ann@example.com", Username: "ann", Password: testPassword}), http.StatusOK, &reg)
+	if reg.User.ID == "" || reg.Token == "" || reg.IsAdmin {
+		t.Fatalf("unexpected register response: %+v", reg)
+	}
+	if reg.User.DisplayName != "ann" {
+		t.Errorf("display name = %q, want username fallback", reg.User.DisplayName)
+	}
+
+	expect(t, e.do(t, "POST", "/api/register", "", RegisterRequest{Email: "ann@example.com", Username: "ann2", Password: testPassword}), http.StatusConflict, nil)
+	expect(t, e.do(t, "POST", "/api/register", "", RegisterRequest{Email: "bob@example.com"}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/register", "", "{"), http.StatusBadRequest, nil)
+
+	var login struct {
+		User  map[string]interface{} `json:"user"`
+		Token string                 `json:"token"`
+	}
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword}), http.StatusOK, &login)
+	if login.User["id"] != reg.User.ID || login.Token == "" {
+		t.Fatalf("unexpected login response: %+v", login)
+	}
+
+	cases := []struct {
+		body interface{}
+		want string
+	}{
+		{"{", "ERR_DECODE_JSON"},
+		{LoginRequest{Email: "ann@example.com"}, "ERR_VALIDATION"},
+		// Unknown emails and wrong passwords are indistinguishable
+		{LoginRequest{Email: "nobody@example.com", Password: testPassword}, "ERR_INVALID_CREDENTIALS"},
+		{LoginRequest{Email: "ann@example.com", Password: "wrong"}, "ERR_INVALID_CREDENTIALS"},
+	}
+	for _, c := range cases {
+		resp := e.do(t, "POST", "/api/login", "", c.body)
+		if got := apiError(t, resp).Code; got != c.want || resp.StatusCode/100 != 4 {
+			t.Errorf("login %v: got %d %q, want %q", c.body, resp.StatusCode, got, c.want)
+		}
+	}
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	e := newTestEnv(t)
+	var login struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	e.register(t, "ann")
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword}), http.StatusOK, &login)
+	if login.RefreshToken == "" {
+		t.Fatal("login returned no refresh token")
+	}
+
+	var next sessions.Session
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{login.RefreshToken}), http.StatusOK, &next)
+	if next.Token == "" || next.RefreshToken == "" || next.RefreshToken == login.RefreshToken {
+		t.Fatalf("unexpected refresh response: %+v", next)
+	}
+	expect(t, e.do(t, "GET", "/api/me", next.Token, nil), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{"bogus"}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", "{"), http.StatusBadRequest, nil)
+
+	// Replaying a used token revokes the tokens rotated from it
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{login.RefreshToken}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{next.RefreshToken}), http.StatusUnauthorized, nil)
+
+	// Logout revokes the session it belongs to and leaves others alone
+	var phone, laptop sessions.Session
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword}), http.StatusOK, &phone)
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword}), http.StatusOK, &laptop)
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{phone.RefreshToken}), http.StatusOK, &phone)
+	expect(t, e.do(t, "POST", "/api/logout", "", RefreshRequest{phone.RefreshToken}), http.StatusNoContent, nil)
+	expect(t, e.do(t, "POST", "/api/logout", "", RefreshRequest{phone.RefreshToken}), http.StatusNoContent, nil)
+	expect(t, e.do(t, "POST", "/api/logout", "", RefreshRequest{"bogus"}), http.StatusNoContent, nil)
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{phone.RefreshToken}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{laptop.RefreshToken}), http.StatusOK, nil)
+}
+
+func TestMeAndProfiles(t *testing.T) {
+	e := newTestEnv(t)
+	id, token := e.register(t, "ann")
+
+	expect(t, e.do(t, "GET", "/api/me", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/me", "not-a-token", nil), http.StatusUnauthorized, nil)
+	var me struct {
+		User model.User `json:"user"`
+	}
+	expect(t, e.do(t, "GET", "/api/me", token, nil), http.StatusOK, &me)
+	if me.User.ID != id || me.User.Email != "ann@example.com" {
+		t.Fatalf("unexpected /api/me user: %+v", me.User)
+	}
+
+	expect(t, e.do(t, "GET", "/api/users/me/profile", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "PUT", "/api/users/me/profile", token, map[string]string{
+		"display_name": "Ann", "description": "dreamer", "profile_image_url": "https://example.com/a.png",
+	}), http.StatusNoContent, nil)
+	var profile model.User
+	expect(t, e.do(t, "GET", "/api/users/me/profile", token, nil), http.StatusOK, &profile)
+	if profile.DisplayName != "Ann" || profile.Description != "dreamer" || profile.ProfileImageURL != "https://example.com/a.png" {
+		t.Fatalf("profile not updated: %+v", profile)
+	}
+
+	public := e.createDream(t, token, "Flying", "I was flying.", true)
+	e.createDream(t, token, "Secret", "Nobody knows.", false)
+	var pub struct {
+		User   model.User    `json:"user"`
+		Dreams []model.Dream `json:"dreams"`
+	}
+	expect(t, e.do(t, "GET", "/api/users/ann/public", "", nil), http.StatusOK, &pub)
+	if pub.User.Email != "" {
+		t.Errorf("public profile leaks email %q", pub.User.Email)
+	}
+	if got := dreamIDs(pub.Dreams); len(got) != 1 || got[0] != public.ID {
+		t.Errorf("public profile dreams = %v, want [%s]", got, public.ID)
+	}
+	expect(t, e.do(t, "GET", "/api/users/nobody/public", "", nil), http.StatusNotFound, nil)
+}
+
+func TestUsernames(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	e.register(t, "bob")
+
+	register := func(email, username string) *http.Response {
+		return e.do(t, "POST", "/api/register", "", RegisterRequest{Email: email, Username: username, Password: testPassword})
+	}
+	expect(t, register("ann2@example.com", "ANN"), http.StatusConflict, nil)
+	if body := apiError(t, register("admin@example.com", "Admin")); body.Code != "ERR_VALIDATION" || len(body.Fields) != 1 || body.Fields[0].Code != "reserved_username" {
+		t.Errorf("registering a reserved name: %+v", body)
+	}
+
+	rename := func(token, username string) *http.Response {
+		return e.do(t, "PUT", "/api/users/me/username", token, RenameRequest{Username: username})
+	}
+	expect(t, rename("", "annie"), http.StatusUnauthorized, nil)
+	expect(t, rename(ann, "Bob"), http.StatusConflict, nil)
+	expect(t, rename(ann, "me"), http.StatusBadRequest, nil)
+	expect(t, rename(ann, "ann-marie"), http.StatusBadRequest, nil)
+	var renamed model.User
+	expect(t, rename(ann, "annie"), http.StatusOK, &renamed)
+	if renamed.ID != annID || renamed.Username != "annie" {
+		t.Fatalf("rename returned %+v", renamed)
+	}
+
+	// Old links redirect to the new name, keeping the query
+	var pub struct {
+		User model.User `json:"user"`
+	}
+	resp := e.do(t, "GET", "/api/users/Ann/public?limit=5", "", nil)
+	if resp.Request.URL.Path != "/api/users/annie/public" || resp.Request.URL.RawQuery != "limit=5" {
+		t.Errorf("old username was served from %s", resp.Request.URL)
+	}
+	expect(t, resp, http.StatusOK, &pub)
+	if pub.User.ID != annID {
+		t.Errorf("old username resolved to %+v", pub.User)
+	}
+	expect(t, e.do(t, "GET", "/api/users/ANNIE/public", "", nil), http.StatusOK, nil)
+
+	// The old name stays reserved for its former owner
+	expect(t, register("ann3@example.com", "ann"), http.StatusConflict, nil)
+	expect(t, rename(ann, "ann"), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", "/api/users/annie/public", "", nil), http.StatusOK, &pub)
+	if pub.User.Username != "ann" {
+		t.Errorf("annie resolved to %+v after renaming back", pub.User)
+	}
+}
+
+func TestCreateAndListDreams(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+
+	expect(t, e.do(t, "POST", "/api/dreams", "", CreateDreamRequest{Title: "x", Text: "y"}), http.StatusUnauthorized, nil)
+	bad := 11
+	expect(t, e.do(t, "POST", "/api/dreams", ann, CreateDreamRequest{Title: "x", Text: "y", NightmareRating: &bad}), http.StatusBadRequest, nil)
+
+	annPublic := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	if len(annPublic.Jobs) != 1 || annPublic.Jobs[0].Kind != jobs.KindTags {
+		t.Fatalf("create did not report the tagging job: %+v", annPublic.Jobs)
+	}
+	if annPublic.UserID != annID {
+		t.Errorf("dream owner = %q, want %q", annPublic.UserID, annID)
+	}
+	annPrivate := e.createDream(t, ann, "Teeth", "My teeth fell out.", false)
+	bobPublic := e.createDream(t, bob, "Forest", "Lost in a forest.", true)
+	bobPrivate := e.createDream(t, bob, "Exam", "Late for an exam.", false)
+
+	cases := []struct {
+		name, path, token string
+		want              []string
+	}{
+		{"anonymous", "/api/dreams", "", []string{bobPublic.ID, annPublic.ID}},
+		{"owner sees own private", "/api/dreams", ann, []string{bobPublic.ID, annPrivate.ID, annPublic.ID}},
+		{"other user", "/api/dreams?userId=" + annID, bob, []string{annPublic.ID}},
+		{"own listing", "/api/dreams?userId=" + annID, ann, []string{annPrivate.ID, annPublic.ID}},
+		{"public only", "/api/dreams?public=true", bob, []string{bobPublic.ID, annPublic.ID}},
+	}
+	for _, c := range cases {
+		var page DreamPage
+		expect(t, e.do(t, "GET", c.path, c.token, nil), http.StatusOK, &page)
+		if got := dreamIDs(page.Dreams); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// Walk bob's listing one dream at a time
+	var seen []string
+	path := "/api/dreams?limit=1"
+	for i := 0; ; i++ {
+		if i > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		var page DreamPage
+		expect(t, e.do(t, "GET", path, bob, nil), http.StatusOK, &page)
+		seen = append(seen, dreamIDs(page.Dreams)...)
+		if page.NextCursor == nil {
+			break
+		}
+		path = "/api/dreams?limit=1&cursor=" + *page.NextCursor
+	}
+	want := []string{bobPrivate.ID, bobPublic.ID, annPublic.ID}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("paginated listing = %v, want %v", seen, want)
+	}
+	expect(t, e.do(t, "GET", "/api/dreams?limit=0", "", nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", "/api/dreams?cursor=garbage", "", nil), http.StatusBadRequest, nil)
+}
+
+func TestGetDeleteAndTagDream(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	adminID, admin := e.register(t, "ada")
+	e.store.SetAdmin(adminID, true)
+
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	var got model.Dream
+	expect(t, e.do(t, "GET", "/api/dreams/"+d.ID, "", nil), http.StatusOK, &got)
+	if got.Title != "Ocean" || got.Username != "ann" {
+		t.Fatalf("unexpected dream: %+v", got)
+	}
+	expect(t, e.do(t, "GET", "/api/dreams/missing", "", nil), http.StatusNotFound, nil)
+
+	expect(t, e.do(t, "PUT", "/api/dreams/"+d.ID+"/tags", "", map[string][]string{"tags": {"water"}}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "PUT", "/api/dreams/"+d.ID+"/tags", bob, map[string][]string{"tags": {"water"}}), http.StatusForbidden, nil)
+	expect(t, e.do(t, "PUT", "/api/dreams/"+d.ID+"/tags", ann, map[string][]string{"tags": {"water", "whales"}}), http.StatusNoContent, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/"+d.ID, "", nil), http.StatusOK, &got)
+	if fmt.Sprint(got.Tags) != "[water whales]" {
+		t.Errorf("tags = %v, want [water whales]", got.Tags)
+	}
+
+	expect(t, e.do(t, "DELETE", "/api/dreams/missing", ann, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "DELETE", "/api/dreams/"+d.ID, bob, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "DELETE", "/api/dreams/"+d.ID, ann, nil), http.StatusNoContent, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/"+d.ID, "", nil), http.StatusNotFound, nil)
+
+	bobDream := e.createDream(t, bob, "Forest", "Lost in a forest.", false)
+	expect(t, e.do(t, "DELETE", "/api/dreams/"+bobDream.ID, admin, nil), http.StatusNoContent, nil)
+}
+
+func TestEditAndRevisions(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", false)
+	base := "/api/dreams/" + d.ID
+
+	// Other users cannot tell a private dream exists
+	expect(t, e.do(t, "PATCH", base, bob, map[string]string{"title": "Mine now"}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "PATCH", base, ann, map[string]int{"clarity_rating": 0}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", base+"/revisions/diff", ann, nil), http.StatusNotFound, nil)
+
+	var edited model.Dream
+	expect(t, e.do(t, "PATCH", base, ann, map[string]interface{}{"title": "Deep ocean", "clarity_rating": 7}), http.StatusOK, &edited)
+	if edited.Title != "Deep ocean" || edited.Text != "Swimming with whales." || edited.ClarityRating == nil || *edited.ClarityRating != 7 {
+		t.Fatalf("unexpected edit result: %+v", edited)
+	}
+	expect(t, e.do(t, "PATCH", base, ann, map[string]string{"title": "Deep ocean"}), http.StatusNoContent, nil)
+	var cleared model.Dream
+	expect(t, e.do(t, "PATCH", base, ann, map[string]interface{}{"text": "Swimming with sharks.", "clarity_rating": nil}), http.StatusOK, &cleared)
+	if cleared.ClarityRating != nil {
+		t.Errorf("clarity rating not cleared: %v", *cleared.ClarityRating)
+	}
+
+	var revs struct {
+		Revisions []model.DreamRevision `json:"revisions"`
+	}
+	expect(t, e.do(t, "GET", base+"/revisions", bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", base+"/revisions", ann, nil), http.StatusOK, &revs)
+	if len(revs.Revisions) != 3 || revs.Revisions[0].Revision != 3 || revs.Revisions[2].Title != "Ocean" {
+		t.Fatalf("unexpected revisions: %+v", revs.Revisions)
+	}
+
+	var rev model.DreamRevision
+	expect(t, e.do(t, "GET", base+"/revisions/2", ann, nil), http.StatusOK, &rev)
+	if rev.Title != "Deep ocean" {
+		t.Errorf("revision 2 title = %q", rev.Title)
+	}
+	expect(t, e.do(t, "GET", base+"/revisions/9", ann, nil), http.StatusNotFound, nil)
+
+	var diff RevisionDiff
+	expect(t, e.do(t, "GET", base+"/revisions/diff", ann, nil), http.StatusOK, &diff)
+	if diff.From != 2 || diff.To != 3 {
+		t.Errorf("default diff range = %d..%d, want 2..3", diff.From, diff.To)
+	}
+	if _, ok := diff.Changes["clarity_rating"]; !ok {
+		t.Errorf("diff is missing the clarity change: %+v", diff.Changes)
+	}
+	if _, ok := diff.Changes["title"]; ok {
+		t.Errorf("diff reports an unchanged title: %+v", diff.Changes)
+	}
+	expect(t, e.do(t, "GET", base+"/revisions/diff?from=1&to=3", ann, nil), http.StatusOK, &diff)
+	if _, ok := diff.Changes["title"]; !ok || len(diff.Text) == 0 {
+		t.Errorf("diff 1..3 = %+v", diff)
+	}
+	expect(t, e.do(t, "GET", base+"/revisions/diff?from=4", ann, nil), http.StatusBadRequest, nil)
+
+	var restored model.Dream
+	expect(t, e.do(t, "POST", base+"/revisions/1/restore", bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", base+"/revisions/9/restore", ann, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", base+"/revisions/1/restore", ann, nil), http.StatusOK, &restored)
+	if restored.Title != "Ocean" || restored.Text != "Swimming with whales." {
+		t.Fatalf("restore did not bring back revision 1: %+v", restored)
+	}
+	expect(t, e.do(t, "GET", base+"/revisions", ann, nil), http.StatusOK, &revs)
+	if len(revs.Revisions) != 4 {
+		t.Errorf("restore should add a revision, have %d", len(revs.Revisions))
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("a\nb\nc\nd", "a\nx\nc\nd\ne")
+	want := []DiffOp{{"equal", "a"}, {"delete", "b"}, {"insert", "x"}, {"equal", "c\nd"}, {"insert", "e"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines = %+v, want %+v", got, want)
+	}
+
+	// Applying the diff must give back both texts, keeping as many lines as
+	// the longest common subsequence
+	lcs := func(x, y []string) int {
+		prev, cur := make([]int, len(y)+1), make([]int, len(y)+1)
+		for i := range x {
+			for j := range y {
+				switch {
+				case x[i] == y[j]:
+					cur[j+1] = prev[j] + 1
+				case prev[j+1] > cur[j]:
+					cur[j+1] = prev[j+1]
+				default:
+					cur[j+1] = cur[j]
+				}
+			}
+			prev, cur = cur, prev
+		}
+		return prev[len(y)]
+	}
+	rng := rand.New(rand.NewSource(1))
+	lines := func() string {
+		l := make([]string, rng.Intn(30))
+		for i := range l {
+			l[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return strings.Join(l, "\n")
+	}
+	for n := 0; n < 500; n++ {
+		a, b := lines(), lines()
+		var from, to []string
+		kept := 0
+		for _, op := range diffLines(a, b) {
+			if op.Op != "insert" {
+				from = append(from, op.Text)
+			}
+			if op.Op != "delete" {
+				to = append(to, op.Text)
+			}
+			if op.Op == "equal" {
+				kept += strings.Count(op.Text, "\n") + 1
+			}
+		}
+		if strings.Join(from, "\n") != a || strings.Join(to, "\n") != b {
+			t.Fatalf("diff of %q into %q does not apply", a, b)
+		}
+		if want := lcs(strings.Split(a, "\n"), strings.Split(b, "\n")); kept != want {
+			t.Fatalf("diff of %q into %q keeps %d lines, want %d", a, b, kept, want)
+		}
+	}
+
+	// The longest texts a dream can hold diff without a quadratic table
+	long := strings.Repeat("\n", 19999)
+	ops := diffLines(long, strings.Repeat("x\n", 9999)+"x")
+	if len(ops) != 2 || ops[0].Op != "delete" || ops[1].Op != "insert" {
+		t.Errorf("diff of long texts has %d runs", len(ops))
+	}
+}
+
+func TestJobs(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	adminID, admin := e.register(t, "ada")
+	e.store.SetAdmin(adminID, true)
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", false)
+	path := fmt.Sprintf("/api/jobs/%d", d.Jobs[0].ID)
+
+	var job jobResponse
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", path, bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", "/api/jobs/999", ann, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", path, ann, nil), http.StatusOK, &job)
+	if job.DreamID != d.ID || job.Status != jobs.StatusPending {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	expect(t, e.do(t, "GET", path, admin, nil), http.StatusOK, nil)
+
+	expect(t, e.do(t, "POST", path+"/retry", ann, nil), http.StatusConflict, nil)
+	e.store.ProcessJobs(context.Background(), unconfiguredAI{})
+	expect(t, e.do(t, "POST", path+"/retry", bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", path+"/retry", ann, nil), http.StatusAccepted, &job)
+	if job.Status != jobs.StatusPending {
+		t.Errorf("retried job status = %s", job.Status)
+	}
+
+	// A dead job cannot come back while a newer one of its kind is queued
+	e.store.ProcessJobs(context.Background(), unconfiguredAI{})
+	stored, err := e.store.GetDream(context.Background(), d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.store.Enqueue(context.Background(), jobs.KindTags, stored.RowID); err != nil {
+		t.Fatal(err)
+	}
+	resp := e.do(t, "POST", path+"/retry", ann, nil)
+	if resp.StatusCode != http.StatusConflict || apiError(t, resp).Message != "This job is already queued again" {
+		t.Errorf("retry with a newer job queued = %d", resp.StatusCode)
+	}
+}
+
+func TestProphecyAndSummary(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales. Then I woke up.", true)
+
+	// Only the owner, who may poll the job, can queue one. To anyone else
+	// the job, like GET /api/jobs/{id}, looks missing.
+	expect(t, e.do(t, "POST", "/api/dreams/prophecy", "", map[string]string{"id": d.ID}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/dreams/prophecy", bob, map[string]string{"id": d.ID}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", "/api/dreams/summary", bob, map[string]string{"id": d.ID}), http.StatusNotFound, nil)
+
+	expect(t, e.do(t, "POST", "/api/dreams/prophecy", "", map[string]string{"id": "missing"}), http.StatusNotFound, nil)
+	var queued struct {
+		Job jobs.Job `json:"job"`
+	}
+	expect(t, e.do(t, "POST", "/api/dreams/prophecy", ann, map[string]string{"id": d.ID}), http.StatusAccepted, &queued)
+	if queued.Job.Kind != jobs.KindProphecy {
+		t.Errorf("queued job kind = %s", queued.Job.Kind)
+	}
+	expect(t, e.do(t, "GET", fmt.Sprintf("/api/jobs/%d", queued.Job.ID), ann, nil), http.StatusOK, nil)
+	e.store.ProcessJobs(context.Background(), ai.Fake{})
+	want, _ := ai.Fake{}.Prophesy(context.Background(), d.Text)
+	var prophecy map[string]string
+	expect(t, e.do(t, "POST", "/api/dreams/prophecy", "", map[string]string{"id": d.ID}), http.StatusOK, &prophecy)
+	if prophecy["prophecy"] != want {
+		t.Errorf("cached prophecy = %q", prophecy["prophecy"])
+	}
+	// Anyone who can see the dream gets the cached one
+	expect(t, e.do(t, "POST", "/api/dreams/prophecy", bob, map[string]string{"id": d.ID}), http.StatusOK, &prophecy)
+	if prophecy["prophecy"] != want {
+		t.Errorf("cached prophecy for another user = %q", prophecy["prophecy"])
+	}
+
+	expect(t, e.do(t, "POST", "/api/dreams/summary", "", map[string]string{"id": "missing"}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", "/api/dreams/summary", ann, map[string]string{"id": d.ID}), http.StatusAccepted, &queued)
+	if queued.Job.Kind != jobs.KindSummary {
+		t.Errorf("queued job kind = %s", queued.Job.Kind)
+	}
+	e.store.ProcessJobs(context.Background(), ai.Fake{})
+	want, _ = ai.Fake{}.Summarize(context.Background(), d.Text)
+	var summary map[string]string
+	expect(t, e.do(t, "POST", "/api/dreams/summary", "", map[string]string{"id": d.ID}), http.StatusOK, &summary)
+	if summary["summary"] != want {
+		t.Errorf("cached summary = %q", summary["summary"])
+	}
+
+	// An edit invalidates the cached summary
+	expect(t, e.do(t, "PATCH", "/api/dreams/"+d.ID, ann, map[string]string{"text": "Swimming with sharks."}), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/dreams/summary", ann, map[string]string{"id": d.ID}), http.StatusAccepted, nil)
+}
+
+func TestExtractTagsAndInsights(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+
+	_, bob := e.register(t, "bob")
+	adminID, admin := e.register(t, "ada")
+	e.store.SetAdmin(adminID, true)
+
+	var tags map[string][]string
+	expect(t, e.do(t, "POST", "/api/dreams/tags", "", map[string]string{"text": "Giant whales swimming"}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/dreams/tags", ann, map[string]string{"text": "Giant whales swimming"}), http.StatusOK, &tags)
+	if len(tags["tags"]) == 0 {
+		t.Errorf("no tags extracted")
+	}
+	expect(t, e.do(t, "POST", "/api/dreams/tags", ann, "{"), http.StatusBadRequest, nil)
+
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales. Then I woke up.", false)
+	expect(t, e.do(t, "POST", "/api/ai-insights", "", map[string]string{"userId": annID}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/ai-insights", bob, map[string]string{"userId": annID}), http.StatusForbidden, nil)
+	var insights []model.DreamInsight
+	expect(t, e.do(t, "POST", "/api/ai-insights", ann, map[string]string{}), http.StatusOK, &insights)
+	if len(insights) != 1 || insights[0].DreamID != d.ID || insights[0].Summary == "" {
+		t.Fatalf("unexpected insights: %+v", insights)
+	}
+	expect(t, e.do(t, "POST", "/api/ai-insights", admin, map[string]string{"userId": annID}), http.StatusOK, &insights)
+	if len(insights) != 1 || insights[0].DreamID != d.ID {
+		t.Errorf("admin insights = %+v", insights)
+	}
+	if cached, _ := e.store.GetDream(context.Background(), d.ID); cached.Summary != insights[0].Summary {
+		t.Errorf("summary not cached: %q", cached.Summary)
+	}
+}
+
+func TestStatsAndTags(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	adminID, admin := e.register(t, "ada")
+	e.store.SetAdmin(adminID, true)
+
+	pub := e.createDream(t, ann, "Ocean", "Whales.", true)
+	priv := e.createDream(t, ann, "Teeth", "Teeth.", false)
+	expect(t, e.do(t, "PUT", "/api/dreams/"+pub.ID+"/tags", ann, map[string][]string{"tags": {"water"}}), http.StatusNoContent, nil)
+	expect(t, e.do(t, "PUT", "/api/dreams/"+priv.ID+"/tags", ann, map[string][]string{"tags": {"water", "anxiety"}}), http.StatusNoContent, nil)
+
+	path := "/api/users/" + annID + "/stats"
+	var stats model.DreamStats
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", path, bob, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "GET", path+"?from=yesterday", ann, nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", path+"?from=2024-02-01&to=2024-01-01", ann, nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", path+"?from=0001-01-01", ann, nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", path+"?from=2023-01-01&to=2024-12-31", ann, nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", path+"?from=2024-01-01&to=2024-12-31", ann, nil), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", path, ann, nil), http.StatusOK, &stats)
+	if stats.TotalDreams != 2 || stats.PublicDreams != 1 || stats.PrivateDreams != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	expect(t, e.do(t, "GET", path, admin, nil), http.StatusOK, nil)
+
+	var owner, other []model.TagAnalytics
+	expect(t, e.do(t, "GET", "/api/users/"+annID+"/tags", ann, nil), http.StatusOK, &owner)
+	expect(t, e.do(t, "GET", "/api/users/"+annID+"/tags", bob, nil), http.StatusOK, &other)
+	if len(owner) != 2 || owner[0].Name != "water" || owner[0].Count != 2 {
+		t.Errorf("owner tags = %+v", owner)
+	}
+	if len(other) != 1 || other[0].Count != 1 {
+		t.Errorf("tags seen by others = %+v", other)
+	}
+	expect(t, e.do(t, "GET", "/api/users/"+annID+"/tags?limit=0", ann, nil), http.StatusBadRequest, nil)
+}
+
+func TestSearch(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	pub := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	priv := e.createDream(t, ann, "Whale song", "A private whale.", false)
+	e.createDream(t, bob, "Forest", "Lost in a forest.", true)
+
+	expect(t, e.do(t, "GET", "/api/dreams/search", "", nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/search?q=whale&scope=nobody", "", nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/search?q=whale&scope=mine", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/search?q=whale&nightmare_min=0", "", nil), http.StatusBadRequest, nil)
+
+	cases := []struct {
+		path, token string
+		want        []string
+	}{
+		{"/api/dreams/search?q=whale", "", []string{pub.ID}},
+		{"/api/dreams/search?q=whale", ann, []string{priv.ID, pub.ID}},
+		{"/api/dreams/search?q=whale&scope=mine", bob, []string{}},
+		{"/api/dreams/search?q=whale&scope=public", ann, []string{pub.ID}},
+	}
+	for _, c := range cases {
+		var resp model.SearchResponse
+		expect(t, e.do(t, "GET", c.path, c.token, nil), http.StatusOK, &resp)
+		got := []string{}
+		for _, r := range resp.Results {
+			got = append(got, r.Dream.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) || resp.Total != len(c.want) {
+			t.Errorf("%s: got %v (total %d), want %v", c.path, got, resp.Total, c.want)
+		}
+	}
+}
+
+func TestFriends(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	_, carl := e.register(t, "carl")
+	bobPublic := e.createDream(t, bob, "Forest", "Lost in a forest.", true)
+	e.createDream(t, bob, "Exam", "Late for an exam.", false)
+
+	req := friendRequest{UserID: annID, FriendID: bobID}
+	var status map[string]string
+	expect(t, e.do(t, "POST", "/api/friends/request", "", req), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, req), http.StatusForbidden, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, "{"), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, req), http.StatusOK, &status)
+	if status["status"] != "pending" {
+		t.Fatalf("request status = %q", status["status"])
+	}
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, req), http.StatusOK, &status)
+	if status["status"] != "pending" {
+		t.Errorf("repeated request status = %q", status["status"])
+	}
+
+	var pending struct {
+		Requests []model.UserSummary `json:"requests"`
+	}
+	expect(t, e.do(t, "GET", "/api/friends?pending_for="+bobID, "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/friends?pending_for="+bobID, ann, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "GET", "/api/friends?pending_for="+bobID, bob, nil), http.StatusOK, &pending)
+	if len(pending.Requests) != 1 || pending.Requests[0].ID != annID {
+		t.Fatalf("pending requests = %+v", pending.Requests)
+	}
+
+	var page DreamPage
+	expect(t, e.do(t, "GET", "/api/friends/dreams", ann, nil), http.StatusOK, &page)
+	if len(page.Dreams) != 0 {
+		t.Errorf("friends feed before accepting = %v", dreamIDs(page.Dreams))
+	}
+
+	expect(t, e.do(t, "POST", "/api/friends/accept", ann, req), http.StatusForbidden, nil)
+	expect(t, e.do(t, "POST", "/api/friends/accept", bob, req), http.StatusOK, &status)
+	if status["status"] != "accepted" {
+		t.Fatalf("accept status = %q", status["status"])
+	}
+
+	var friends struct {
+		Friends []model.UserSummary `json:"friends"`
+	}
+	expect(t, e.do(t, "GET", "/api/friends", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/friends", ann, nil), http.StatusOK, &friends)
+	if len(friends.Friends) != 1 || friends.Friends[0].Username != "bob" {
+		t.Fatalf("ann's friends = %+v", friends.Friends)
+	}
+	expect(t, e.do(t, "GET", "/api/friends?user_id="+bobID, carl, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "GET", "/api/friends?user_id="+bobID, ann, nil), http.StatusOK, &friends)
+	if len(friends.Friends) != 1 || friends.Friends[0].ID != annID {
+		t.Fatalf("bob's friends = %+v", friends.Friends)
+	}
+
+	expect(t, e.do(t, "GET", "/api/friends/dreams", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/friends/dreams?user_id="+bobID, ann, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "GET", "/api/friends/dreams", ann, nil), http.StatusOK, &page)
+	if got := dreamIDs(page.Dreams); len(got) != 1 || got[0] != bobPublic.ID {
+		t.Errorf("friends feed = %v, want [%s]", got, bobPublic.ID)
+	}
+
+	expect(t, e.do(t, "POST", "/api/friends/remove", carl, req), http.StatusForbidden, nil)
+	expect(t, e.do(t, "POST", "/api/friends/remove", bob, req), http.StatusOK, &status)
+	expect(t, e.do(t, "GET", "/api/friends", ann, nil), http.StatusOK, &friends)
+	if len(friends.Friends) != 0 {
+		t.Errorf("friends after removal = %+v", friends.Friends)
+	}
+}
+
+func TestFriendRequestStates(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	carlID, carl := e.register(t, "carl")
+
+	var status map[string]string
+	var requests struct {
+		Requests []model.UserSummary `json:"requests"`
+	}
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, friendRequest{UserID: annID, FriendID: annID}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, friendRequest{UserID: annID, FriendID: "999999"}), http.StatusNotFound, nil)
+
+	// Crossing requests make friends
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, friendRequest{UserID: annID, FriendID: bobID}), http.StatusOK, &status)
+	expect(t, e.do(t, "GET", "/api/friends/requests?direction=outgoing", ann, nil), http.StatusOK, &requests)
+	if len(requests.Requests) != 1 || requests.Requests[0].ID != bobID {
+		t.Errorf("ann's outgoing requests = %+v", requests.Requests)
+	}
+	expect(t, e.do(t, "GET", "/api/friends/requests", bob, nil), http.StatusOK, &requests)
+	if len(requests.Requests) != 1 || requests.Requests[0].ID != annID {
+		t.Errorf("bob's incoming requests = %+v", requests.Requests)
+	}
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, friendRequest{UserID: bobID, FriendID: annID}), http.StatusOK, &status)
+	if status["status"] != "accepted" {
+		t.Errorf("request back status = %q", status["status"])
+	}
+	expect(t, e.do(t, "GET", "/api/friends/requests?direction=outgoing", ann, nil), http.StatusOK, &requests)
+	if len(requests.Requests) != 0 {
+		t.Errorf("ann's outgoing requests after the request back = %+v", requests.Requests)
+	}
+	expect(t, e.do(t, "GET", "/api/friends/requests?direction=sideways", ann, nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", "/api/friends/requests?user_id="+annID, bob, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "GET", "/api/friends/requests", "", nil), http.StatusUnauthorized, nil)
+
+	// Only the recipient rejects, and the sender still sees it pending
+	req := friendRequest{UserID: carlID, FriendID: annID}
+	expect(t, e.do(t, "POST", "/api/friends/request", carl, req), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/friends/reject", carl, req), http.StatusForbidden, nil)
+	expect(t, e.do(t, "POST", "/api/friends/reject", ann, req), http.StatusOK, &status)
+	if status["status"] != "rejected" {
+		t.Errorf("reject status = %q", status["status"])
+	}
+	expect(t, e.do(t, "POST", "/api/friends/reject", ann, req), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", carl, req), http.StatusOK, &status)
+	if status["status"] != "pending" {
+		t.Errorf("request status after rejection = %q", status["status"])
+	}
+	expect(t, e.do(t, "GET", "/api/friends/requests", ann, nil), http.StatusOK, &requests)
+	if len(requests.Requests) != 0 {
+		t.Errorf("ann's incoming requests after rejecting = %+v", requests.Requests)
+	}
+
+	// Only the sender cancels
+	expect(t, e.do(t, "POST", "/api/friends/cancel", ann, req), http.StatusForbidden, nil)
+	expect(t, e.do(t, "POST", "/api/friends/cancel", carl, req), http.StatusOK, &status)
+	expect(t, e.do(t, "POST", "/api/friends/cancel", carl, req), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", "/api/friends/accept", ann, req), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", "/api/friends/requests?direction=outgoing", carl, nil), http.StatusOK, &requests)
+	if len(requests.Requests) != 0 {
+		t.Errorf("carl's outgoing requests after cancelling = %+v", requests.Requests)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	_, carl := e.register(t, "carl")
+	annDream := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	bobDream := e.createDream(t, bob, "Forest", "Lost in a forest.", true)
+	comments := "/api/dreams/" + annDream.ID + "/comments"
+	expect(t, e.do(t, "POST", comments, bob, map[string]string{"text": "Lovely"}), http.StatusCreated, nil)
+	req := friendRequest{UserID: annID, FriendID: bobID}
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, req), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/friends/accept", bob, req), http.StatusOK, nil)
+
+	expect(t, e.do(t, "POST", "/api/friends/block", bob, req), http.StatusForbidden, nil)
+	expect(t, e.do(t, "POST", "/api/friends/block", ann, friendRequest{UserID: annID, FriendID: "999999"}), http.StatusNotFound, nil)
+	var status map[string]string
+	expect(t, e.do(t, "POST", "/api/friends/block", ann, req), http.StatusOK, &status)
+	if status["status"] != "blocked" {
+		t.Errorf("block status = %q", status["status"])
+	}
+	var blocked struct {
+		Blocked []model.UserSummary `json:"blocked"`
+	}
+	expect(t, e.do(t, "GET", "/api/friends/blocked", ann, nil), http.StatusOK, &blocked)
+	if len(blocked.Blocked) != 1 || blocked.Blocked[0].ID != bobID {
+		t.Errorf("ann's blocked users = %+v", blocked.Blocked)
+	}
+	var friends struct {
+		Friends []model.UserSummary `json:"friends"`
+	}
+	expect(t, e.do(t, "GET", "/api/friends", ann, nil), http.StatusOK, &friends)
+	if len(friends.Friends) != 0 {
+		t.Errorf("ann's friends after block = %+v", friends.Friends)
+	}
+
+	// Neither can befriend the other; the blocked user cannot tell why
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, friendRequest{UserID: bobID, FriendID: annID}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, req), http.StatusConflict, nil)
+
+	// Profiles, dreams, comments and feeds hide both sides from each other
+	for _, c := range []struct {
+		token, profile string
+		dream          model.Dream
+	}{{ann, "bob", bobDream}, {bob, "ann", annDream}} {
+		expect(t, e.do(t, "GET", "/api/users/"+c.profile+"/public", c.token, nil), http.StatusNotFound, nil)
+		expect(t, e.do(t, "GET", "/api/dreams/"+c.dream.ID, c.token, nil), http.StatusNotFound, nil)
+		expect(t, e.do(t, "GET", "/api/dreams/"+c.dream.ID+"/comments", c.token, nil), http.StatusNotFound, nil)
+		var page DreamPage
+		expect(t, e.do(t, "GET", "/api/dreams", c.token, nil), http.StatusOK, &page)
+		if got := dreamIDs(page.Dreams); len(got) != 1 {
+			t.Errorf("feed = %v, want only the caller's dream", got)
+		}
+	}
+	expect(t, e.do(t, "POST", comments, bob, map[string]string{"text": "Hello?"}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", "/api/users/bob/public", carl, nil), http.StatusOK, nil)
+	var list struct {
+		Comments []model.Comment `json:"comments"`
+	}
+	expect(t, e.do(t, "GET", comments, ann, nil), http.StatusOK, &list)
+	if len(list.Comments) != 0 {
+		t.Errorf("ann sees blocked comments %+v", list.Comments)
+	}
+	expect(t, e.do(t, "GET", comments, carl, nil), http.StatusOK, &list)
+	if len(list.Comments) != 1 {
+		t.Errorf("carl sees %d comments, want 1", len(list.Comments))
+	}
+
+	expect(t, e.do(t, "POST", "/api/friends/unblock", bob, friendRequest{UserID: bobID, FriendID: annID}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", "/api/friends/unblock", ann, req), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", "/api/users/bob/public", ann, nil), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/"+annDream.ID, bob, nil), http.StatusOK, nil)
+}
+
+func TestComments(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	_, carl := e.register(t, "carl")
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	path := "/api/dreams/" + d.ID + "/comments"
+
+	expect(t, e.do(t, "POST", path, "", map[string]string{"text": "hi"}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "  "}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/dreams/missing/comments", bob, map[string]string{"text": "hi"}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/missing/comments", "", nil), http.StatusNotFound, nil)
+
+	var first, second model.Comment
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Lovely"}), http.StatusCreated, &first)
+	if first.User.Username != "bob" || first.Text != "Lovely" {
+		t.Fatalf("unexpected comment: %+v", first)
+	}
+	expect(t, e.do(t, "POST", path, ann, map[string]string{"text": "Thanks"}), http.StatusCreated, &second)
+
+	var list struct {
+		Comments   []model.Comment `json:"comments"`
+		NextCursor *string         `json:"next_cursor"`
+	}
+	expect(t, e.do(t, "GET", path+"?limit=1", "", nil), http.StatusOK, &list)
+	if len(list.Comments) != 1 || list.Comments[0].ID != first.ID || list.NextCursor == nil {
+		t.Fatalf("first comment page = %+v", list)
+	}
+	expect(t, e.do(t, "GET", path+"?limit=1&cursor="+*list.NextCursor, "", nil), http.StatusOK, &list)
+	if len(list.Comments) != 1 || list.Comments[0].ID != second.ID || list.NextCursor != nil {
+		t.Fatalf("second comment page = %+v", list)
+	}
+
+	del := fmt.Sprintf("/api/comments/%d", first.ID)
+	expect(t, e.do(t, "DELETE", del, "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "DELETE", del, carl, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "DELETE", "/api/comments/999", bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "DELETE", del, bob, nil), http.StatusNoContent, nil)
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusOK, &list)
+	if len(list.Comments) != 1 || list.Comments[0].ID != second.ID {
+		t.Errorf("comments after delete = %+v", list.Comments)
+	}
+
+	// The dream's owner may remove comments others left on it
+	var third model.Comment
+	expect(t, e.do(t, "POST", path, carl, map[string]string{"text": "Boring"}), http.StatusCreated, &third)
+	expect(t, e.do(t, "DELETE", fmt.Sprintf("/api/comments/%d", third.ID), bob, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "DELETE", fmt.Sprintf("/api/comments/%d", third.ID), ann, nil), http.StatusNoContent, nil)
+
+	// Comments the caller cannot see answer 404 to edits and deletes too, so
+	// their IDs do not reveal that they exist
+	priv := e.createDream(t, ann, "Teeth", "My teeth fell out.", false)
+	var note model.Comment
+	expect(t, e.do(t, "POST", "/api/dreams/"+priv.ID+"/comments", ann, map[string]string{"text": "Note to self"}), http.StatusCreated, &note)
+	hidden := fmt.Sprintf("/api/comments/%d", note.ID)
+	expect(t, e.do(t, "PATCH", hidden, carl, map[string]string{"text": "Hacked"}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "DELETE", hidden, carl, nil), http.StatusNotFound, nil)
+
+	var blocked model.Comment
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Again"}), http.StatusCreated, &blocked)
+	expect(t, e.do(t, "POST", "/api/friends/block", ann, friendRequest{UserID: annID, FriendID: bobID}), http.StatusOK, nil)
+	own := fmt.Sprintf("/api/comments/%d", blocked.ID)
+	expect(t, e.do(t, "PATCH", own, bob, map[string]string{"text": "Still here"}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "DELETE", own, bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "DELETE", own, ann, nil), http.StatusNotFound, nil)
+}
+
+func TestCommentEditsAndReplies(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	adminID, admin := e.register(t, "ada")
+	e.store.SetAdmin(adminID, true)
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	other := e.createDream(t, ann, "Forest", "Lost.", true)
+	path := "/api/dreams/" + d.ID + "/comments"
+
+	var root, reply model.Comment
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Lovely"}), http.StatusCreated, &root)
+	expect(t, e.do(t, "POST", path, ann, map[string]interface{}{"text": "Thanks", "parent_id": root.ID}), http.StatusCreated, &reply)
+	if reply.ParentID == nil || *reply.ParentID != root.ID || root.ParentID != nil {
+		t.Fatalf("reply = %+v, root = %+v", reply, root)
+	}
+	expect(t, e.do(t, "POST", "/api/dreams/"+other.ID+"/comments", bob, map[string]interface{}{"text": "Hi", "parent_id": root.ID}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", path, bob, map[string]interface{}{"text": "Hi", "parent_id": 999}), http.StatusBadRequest, nil)
+
+	edit := fmt.Sprintf("/api/comments/%d", root.ID)
+	expect(t, e.do(t, "PATCH", edit, "", map[string]string{"text": "Hacked"}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "PATCH", edit, ann, map[string]string{"text": "Hacked"}), http.StatusForbidden, nil)
+	expect(t, e.do(t, "PATCH", edit, admin, map[string]string{"text": "Hacked"}), http.StatusForbidden, nil)
+	expect(t, e.do(t, "PATCH", edit, bob, map[string]string{"text": " "}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "PATCH", "/api/comments/999", bob, map[string]string{"text": "Hi"}), http.StatusNotFound, nil)
+	var edited model.Comment
+	expect(t, e.do(t, "PATCH", edit, bob, map[string]string{"text": "Lovely dream"}), http.StatusOK, &edited)
+	if edited.Text != "Lovely dream" || !edited.Edited || root.Edited {
+		t.Errorf("edited comment = %+v", edited)
+	}
+
+	var list struct {
+		Comments []model.Comment `json:"comments"`
+	}
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusOK, &list)
+	if len(list.Comments) != 2 || !list.Comments[0].Edited || list.Comments[1].ParentID == nil {
+		t.Errorf("comments = %+v", list.Comments)
+	}
+
+	// Deleting a comment removes the thread under it
+	expect(t, e.do(t, "DELETE", edit, ann, nil), http.StatusNoContent, nil)
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusOK, &list)
+	if len(list.Comments) != 0 {
+		t.Errorf("comments after deleting the thread = %+v", list.Comments)
+	}
+}
+
+func TestCommentModeration(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	_, carl := e.register(t, "carl")
+	adminID, admin := e.register(t, "ada")
+	e.store.SetAdmin(adminID, true)
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	priv := e.createDream(t, ann, "Teeth", "My teeth fell out.", false)
+	path := "/api/dreams/" + d.ID + "/comments"
+
+	var spam, rude, hidden model.Comment
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Buy now"}), http.StatusCreated, &spam)
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Boring"}), http.StatusCreated, &rude)
+	expect(t, e.do(t, "POST", "/api/dreams/"+priv.ID+"/comments", ann, map[string]string{"text": "Note to self"}), http.StatusCreated, &hidden)
+
+	report := func(c model.Comment) string { return fmt.Sprintf("/api/comments/%d/reports", c.ID) }
+	reason := map[string]string{"reason": "Spam"}
+	expect(t, e.do(t, "POST", report(spam), "", reason), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", report(spam), bob, reason), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", report(spam), ann, map[string]string{}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", report(hidden), carl, reason), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", "/api/comments/999/reports", carl, reason), http.StatusNotFound, nil)
+	var first model.CommentReport
+	expect(t, e.do(t, "POST", report(spam), ann, reason), http.StatusCreated, &first)
+	if first.Reason != "Spam" || first.Comment.ID != spam.ID || first.DreamID != d.ID {
+		t.Errorf("report = %+v", first)
+	}
+	expect(t, e.do(t, "POST", report(spam), ann, reason), http.StatusConflict, nil)
+	expect(t, e.do(t, "POST", report(spam), carl, map[string]string{"reason": "Ads"}), http.StatusCreated, nil)
+	var mean model.CommentReport
+	expect(t, e.do(t, "POST", report(rude), carl, map[string]string{"reason": "Mean"}), http.StatusCreated, &mean)
+
+	var queue struct {
+		Reports    []model.CommentReport `json:"reports"`
+		NextCursor *string               `json:"next_cursor"`
+	}
+	expect(t, e.do(t, "GET", "/api/admin/reports", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/admin/reports", ann, nil), http.StatusForbidden, nil)
+	expect(t, e.do(t, "GET", "/api/admin/reports?limit=2", admin, nil), http.StatusOK, &queue)
+	if len(queue.Reports) != 2 || queue.Reports[0].ID != first.ID || queue.NextCursor == nil {
+		t.Fatalf("first queue page = %+v", queue)
+	}
+
+	resolve := func(r model.CommentReport) string { return fmt.Sprintf("/api/admin/reports/%d/resolve", r.ID) }
+	expect(t, e.do(t, "POST", resolve(first), ann, map[string]string{"action": "remove"}), http.StatusForbidden, nil)
+	expect(t, e.do(t, "POST", resolve(first), admin, map[string]string{"action": "ban"}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/admin/reports/999/resolve", admin, map[string]string{"action": "remove"}), http.StatusNotFound, nil)
+
+	// Dismissing keeps the comment and closes both reports on it
+	var status map[string]string
+	expect(t, e.do(t, "POST", resolve(first), admin, map[string]string{"action": "dismiss"}), http.StatusOK, &status)
+	if status["status"] != "dismissed" {
+		t.Errorf("dismiss status = %q", status["status"])
+	}
+	expect(t, e.do(t, "POST", resolve(first), admin, map[string]string{"action": "dismiss"}), http.StatusConflict, nil)
+	expect(t, e.do(t, "POST", resolve(first), admin, map[string]string{"action": "remove"}), http.StatusConflict, nil)
+	var comments struct {
+		Comments []model.Comment `json:"comments"`
+	}
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusOK, &comments)
+	if len(comments.Comments) != 2 {
+		t.Errorf("removing through a dismissed report deleted the comment: %+v", comments.Comments)
+	}
+	expect(t, e.do(t, "GET", "/api/admin/reports", admin, nil), http.StatusOK, &queue)
+	if len(queue.Reports) != 1 || queue.Reports[0].ID != mean.ID {
+		t.Fatalf("queue after dismissal = %+v", queue.Reports)
+	}
+
+	// Removing deletes the comment and empties the queue
+	expect(t, e.do(t, "POST", resolve(mean), admin, map[string]string{"action": "remove"}), http.StatusOK, &status)
+	if status["status"] != "removed" {
+		t.Errorf("remove status = %q", status["status"])
+	}
+	expect(t, e.do(t, "GET", "/api/admin/reports", admin, nil), http.StatusOK, &queue)
+	if len(queue.Reports) != 0 {
+		t.Errorf("queue after removal = %+v", queue.Reports)
+	}
+	var list struct {
+		Comments []model.Comment `json:"comments"`
+	}
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusOK, &list)
+	if len(list.Comments) != 1 || list.Comments[0].ID != spam.ID {
+		t.Errorf("comments after moderation = %+v", list.Comments)
+	}
+}
+
+func TestReactions(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	carlID, carl := e.register(t, "carl")
+	req := friendRequest{UserID: bobID, FriendID: annID}
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, req), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/friends/accept", ann, req), http.StatusOK, nil)
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	priv := e.createDream(t, ann, "Teeth", "My teeth fell out.", false)
+	path := "/api/dreams/" + d.ID + "/reactions"
+
+	var counts struct {
+		Reactions model.ReactionCounts `json:"reactions"`
+	}
+	expect(t, e.do(t, "PUT", path+"/spooky", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "PUT", path+"/angry", bob, nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "PUT", "/api/dreams/"+priv.ID+"/reactions/spooky", carl, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "PUT", path+"/spooky", bob, nil), http.StatusOK, &counts)
+	expect(t, e.do(t, "PUT", path+"/spooky", bob, nil), http.StatusOK, &counts)
+	expect(t, e.do(t, "PUT", path+"/beautiful", carl, nil), http.StatusOK, &counts)
+	if counts.Reactions[model.ReactionSpooky] != 1 || counts.Reactions[model.ReactionBeautiful] != 1 || counts.Reactions[model.ReactionRelatable] != 0 {
+		t.Errorf("counts after reacting = %v", counts.Reactions)
+	}
+
+	// Counts come with the dream, in listings and in the friends feed
+	var got model.Dream
+	expect(t, e.do(t, "GET", "/api/dreams/"+d.ID, "", nil), http.StatusOK, &got)
+	if got.Reactions[model.ReactionSpooky] != 1 {
+		t.Errorf("dream reactions = %v", got.Reactions)
+	}
+	var feed DreamPage
+	expect(t, e.do(t, "GET", "/api/friends/dreams", bob, nil), http.StatusOK, &feed)
+	if len(feed.Dreams) != 1 || feed.Dreams[0].Reactions[model.ReactionBeautiful] != 1 {
+		t.Errorf("friends feed = %+v", feed.Dreams)
+	}
+
+	var list struct {
+		Reactions  []model.Reaction `json:"reactions"`
+		NextCursor *string          `json:"next_cursor"`
+	}
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusOK, &list)
+	if len(list.Reactions) != 2 || list.Reactions[0].User.ID != carlID || list.Reactions[1].Kind != model.ReactionSpooky {
+		t.Errorf("reactions = %+v", list.Reactions)
+	}
+	expect(t, e.do(t, "GET", path+"?kind=spooky", "", nil), http.StatusOK, &list)
+	if len(list.Reactions) != 1 || list.Reactions[0].User.ID != bobID {
+		t.Errorf("spooky reactions = %+v", list.Reactions)
+	}
+	expect(t, e.do(t, "GET", path+"?kind=angry", "", nil), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", "/api/dreams/"+priv.ID+"/reactions", bob, nil), http.StatusNotFound, nil)
+
+	expect(t, e.do(t, "DELETE", path+"/spooky", bob, nil), http.StatusOK, &counts)
+	expect(t, e.do(t, "DELETE", path+"/spooky", bob, nil), http.StatusOK, &counts)
+	if counts.Reactions[model.ReactionSpooky] != 0 || counts.Reactions[model.ReactionBeautiful] != 1 {
+		t.Errorf("counts after removing = %v", counts.Reactions)
+	}
+
+	// Comments take reactions the same way
+	var c model.Comment
+	expect(t, e.do(t, "POST", "/api/dreams/"+d.ID+"/comments", bob, map[string]string{"text": "Lovely"}), http.StatusCreated, &c)
+	commentPath := fmt.Sprintf("/api/comments/%d/reactions", c.ID)
+	expect(t, e.do(t, "PUT", commentPath+"/relatable", ann, nil), http.StatusOK, &counts)
+	if counts.Reactions[model.ReactionRelatable] != 1 {
+		t.Errorf("comment counts = %v", counts.Reactions)
+	}
+	var comments struct {
+		Comments []model.Comment `json:"comments"`
+	}
+	expect(t, e.do(t, "GET", "/api/dreams/"+d.ID+"/comments", "", nil), http.StatusOK, &comments)
+	if len(comments.Comments) != 1 || comments.Comments[0].Reactions[model.ReactionRelatable] != 1 {
+		t.Errorf("comments = %+v", comments.Comments)
+	}
+	expect(t, e.do(t, "PUT", "/api/comments/999/reactions/relatable", ann, nil), http.StatusNotFound, nil)
+
+	// Users who blocked one another can't see each other's comments to react
+	expect(t, e.do(t, "POST", "/api/friends/block", bob, friendRequest{UserID: bobID, FriendID: carlID}), http.StatusOK, nil)
+	expect(t, e.do(t, "PUT", commentPath+"/spooky", carl, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", commentPath, carl, nil), http.StatusNotFound, nil)
+}
+
+func TestNotifications(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	carlID, carl := e.register(t, "carl")
+
+	type notificationPage struct {
+		Notifications []model.Notification `json:"notifications"`
+		NextCursor    *string              `json:"next_cursor"`
+	}
+	types := func(token, query string) []string {
+		t.Helper()
+		var page notificationPage
+		expect(t, e.do(t, "GET", "/api/notifications"+query, token, nil), http.StatusOK, &page)
+		got := []string{}
+		for _, n := range page.Notifications {
+			got = append(got, string(n.Type)+":"+n.Actor.Username)
+		}
+		return got
+	}
+	unread := func(token string) (int, model.NotificationCounts) {
+		t.Helper()
+		var body struct {
+			Total  int                      `json:"total"`
+			ByType model.NotificationCounts `json:"by_type"`
+		}
+		expect(t, e.do(t, "GET", "/api/notifications/unread", token, nil), http.StatusOK, &body)
+		return body.Total, body.ByType
+	}
+	expectTypes := func(what string, got []string, want ...string) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s = %v, want %v", what, got, want)
+		}
+	}
+
+	expect(t, e.do(t, "GET", "/api/notifications", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/notifications/unread", "", nil), http.StatusUnauthorized, nil)
+
+	// Friend requests notify the recipient once; accepting notifies the
+	// sender
+	req := friendRequest{UserID: bobID, FriendID: annID}
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, req), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, req), http.StatusOK, nil)
+	expectTypes("ann's notifications", types(ann, ""), "friend_request:bob")
+	expect(t, e.do(t, "POST", "/api/friends/accept", ann, req), http.StatusOK, nil)
+	expectTypes("bob's notifications", types(bob, ""), "friend_accepted:ann")
+
+	// Asking someone who already asked you accepts their request
+	expect(t, e.do(t, "POST", "/api/friends/request", carl, friendRequest{UserID: carlID, FriendID: annID}), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, friendRequest{UserID: annID, FriendID: carlID}), http.StatusOK, nil)
+	expectTypes("carl's notifications", types(carl, ""), "friend_accepted:ann")
+
+	// Comments notify the dream's owner, and replies the parent's author
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	path := "/api/dreams/" + d.ID + "/comments"
+	var root model.Comment
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Lovely"}), http.StatusCreated, &root)
+	expect(t, e.do(t, "POST", path, carl, map[string]interface{}{"text": "Agreed", "parent_id": root.ID}), http.StatusCreated, nil)
+	expect(t, e.do(t, "POST", path, ann, map[string]interface{}{"text": "Thanks", "parent_id": root.ID}), http.StatusCreated, nil)
+	expectTypes("ann's notifications after comments", types(ann, ""), "comment:carl", "comment:bob", "friend_request:carl", "friend_request:bob")
+	expectTypes("bob's notifications after replies", types(bob, ""), "reply:ann", "reply:carl", "friend_accepted:ann")
+	var page notificationPage
+	expect(t, e.do(t, "GET", "/api/notifications?limit=1", bob, nil), http.StatusOK, &page)
+	if n := page.Notifications[0]; n.DreamID != d.ID || n.CommentID == nil || page.NextCursor == nil {
+		t.Errorf("reply notification = %+v, next %v", n, page.NextCursor)
+	}
+
+	if total, byType := unread(ann); total != 4 || byType[model.NotifyComment] != 2 || byType[model.NotifyReply] != 0 {
+		t.Errorf("ann's unread = %d, %v", total, byType)
+	}
+	expect(t, e.do(t, "GET", "/api/notifications?limit=1", ann, nil), http.StatusOK, &page)
+	var marked map[string]int
+	expect(t, e.do(t, "POST", "/api/notifications/read", ann, MarkNotificationsReadRequest{}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/notifications/read", bob, MarkNotificationsReadRequest{IDs: []int{page.Notifications[0].ID}}), http.StatusOK, &marked)
+	if marked["marked"] != 0 {
+		t.Errorf("bob marked %d of ann's notifications read", marked["marked"])
+	}
+	expect(t, e.do(t, "POST", "/api/notifications/read", ann, MarkNotificationsReadRequest{IDs: []int{page.Notifications[0].ID}}), http.StatusOK, &marked)
+	if total, _ := unread(ann); marked["marked"] != 1 || total != 3 {
+		t.Errorf("after marking one read: marked %d, unread %d", marked["marked"], total)
+	}
+	expectTypes("ann's unread notifications", types(ann, "?unread=true"), "comment:bob", "friend_request:carl", "friend_request:bob")
+	expect(t, e.do(t, "POST", "/api/notifications/read", ann, MarkNotificationsReadRequest{All: true}), http.StatusOK, &marked)
+	if total, _ := unread(ann); marked["marked"] != 3 || total != 0 {
+		t.Errorf("after marking all read: marked %d, unread %d", marked["marked"], total)
+	}
+
+	// Turning a type off stops new notifications of it
+	var prefs struct {
+		Preferences model.NotificationPrefs `json:"preferences"`
+	}
+	expect(t, e.do(t, "GET", "/api/notifications/preferences", ann, nil), http.StatusOK, &prefs)
+	if !prefs.Preferences[model.NotifyComment] || len(prefs.Preferences) != len(model.NotificationTypes) {
+		t.Errorf("default prefs = %v", prefs.Preferences)
+	}
+	expect(t, e.do(t, "PUT", "/api/notifications/preferences", ann, map[string]interface{}{"preferences": map[string]bool{"likes": false}}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "PUT", "/api/notifications/preferences", ann, NotificationPrefsRequest{Preferences: model.NotificationPrefs{model.NotifyComment: false}}), http.StatusOK, &prefs)
+	if prefs.Preferences[model.NotifyComment] || !prefs.Preferences[model.NotifyReply] {
+		t.Errorf("prefs after turning comments off = %v", prefs.Preferences)
+	}
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Again"}), http.StatusCreated, nil)
+	if total, _ := unread(ann); total != 0 {
+		t.Errorf("muted comment notification counted: %d unread", total)
+	}
+}
+
+// sseEvent is one event read off a GET /api/events stream
+type sseEvent struct {
+	Type string
+	Data string
+}
+
+// stream opens an event stream with the token in the URL and returns its
+// events as they arrive. It returns once the server has subscribed.
+func (e *testEnv) stream(t *testing.T, token, query string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", e.srv.URL+"/api/events?access_token="+token+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("event stream: status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	if !scanner.Scan() || scanner.Text() != ": connected" {
+		t.Fatalf("event stream started with %q", scanner.Text())
+	}
+	out := make(chan sseEvent, 16)
+	go func() {
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				ev.Type = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.Data = strings.TrimPrefix(line, "data: ")
+			case line == "" && ev.Type != "":
+				out <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return out
+}
+
+// next waits for the next event on a stream
+func next(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return sseEvent{}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	e := newTestEnv(t)
+	e.store.OnJobFinish = e.api.JobFinished
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	carlID, carl := e.register(t, "carl")
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+	priv := e.createDream(t, ann, "Teeth", "My teeth fell out.", false)
+
+	expect(t, e.do(t, "GET", "/api/events", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/events?access_token=bogus", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/events?dream="+priv.ID, bob, nil), http.StatusNotFound, nil)
+
+	annEvents := e.stream(t, ann, "")
+	carlEvents := e.stream(t, carl, "&dream="+d.ID)
+
+	// Friend changes reach the other user, along with the notification
+	req := friendRequest{UserID: bobID, FriendID: annID}
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, req), http.StatusOK, nil)
+	ev := next(t, annEvents)
+	var friend struct {
+		UserID string `json:"user_id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(ev.Data), &friend); ev.Type != "friend" || err != nil || friend.UserID != bobID || friend.Status != "pending" {
+		t.Errorf("friend event = %+v", ev)
+	}
+	if ev := next(t, annEvents); ev.Type != "notification" || !strings.Contains(ev.Data, `"friend_request"`) {
+		t.Errorf("notification event = %+v", ev)
+	}
+
+	// Comments reach everyone viewing the dream, and its owner through a
+	// notification. Viewers skip comments by users they blocked.
+	expect(t, e.do(t, "POST", "/api/friends/block", carl, friendRequest{UserID: carlID, FriendID: bobID}), http.StatusOK, nil)
+	path := "/api/dreams/" + d.ID + "/comments"
+	expect(t, e.do(t, "POST", path, bob, map[string]string{"text": "Lovely"}), http.StatusCreated, nil)
+	if ev := next(t, annEvents); ev.Type != "notification" || !strings.Contains(ev.Data, `"comment"`) {
+		t.Errorf("comment notification = %+v", ev)
+	}
+	expect(t, e.do(t, "POST", path, ann, map[string]string{"text": "Thanks"}), http.StatusCreated, nil)
+	ev = next(t, carlEvents)
+	var comment model.Comment
+	if err := json.Unmarshal([]byte(ev.Data), &comment); ev.Type != "comment" || err != nil || comment.Text != "Thanks" {
+		t.Errorf("carl's first event = %+v, want ann's comment", ev)
+	}
+
+	// Finished AI jobs reach the dream's owner
+	expect(t, e.do(t, "POST", "/api/dreams/summary", ann, map[string]string{"id": d.ID}), http.StatusAccepted, nil)
+	e.store.ProcessJobs(context.Background(), ai.Fake{})
+	ev = next(t, annEvents)
+	var job jobEvent
+	if err := json.Unmarshal([]byte(ev.Data), &job); ev.Type != "job" || err != nil || job.DreamID != d.ID || job.Job == nil || job.Status != jobs.StatusDone {
+		t.Errorf("job event = %+v", ev)
+	}
+
+	// Viewers stop getting comments once the dream is hidden from them
+	expect(t, e.do(t, "PATCH", "/api/dreams/"+d.ID, ann, map[string]string{"visibility": "private"}), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", path, ann, map[string]string{"text": "Just for me"}), http.StatusCreated, nil)
+	expect(t, e.do(t, "POST", "/api/friends/request", ann, friendRequest{UserID: annID, FriendID: carlID}), http.StatusOK, nil)
+	if ev := next(t, carlEvents); ev.Type != "friend" {
+		t.Errorf("carl's event after the dream was hidden = %+v, want the friend request", ev)
+	}
+	next(t, carlEvents)
+
+	select {
+	case ev := <-carlEvents:
+		t.Errorf("carl got an unexpected %s event: %s", ev.Type, ev.Data)
+	default:
+	}
+}
+
+func TestEventsEndWhenTokenExpires(t *testing.T) {
+	e := newTestEnv(t)
+	e.auth.TTL = 2 * time.Second
+	_, ann := e.register(t, "ann")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", e.srv.URL+"/api/events?access_token="+ann, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("event stream: status %d", resp.StatusCode)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, resp.Body)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("stream ended with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream outlived its token")
+	}
+}
+
+func TestQueryTokenLeavesURL(t *testing.T) {
+	var header string
+	h := queryToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+	}))
+	r := httptest.NewRequest("GET", "/api/events?access_token=secret&dream=abc", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if header != "Bearer secret" {
+		t.Errorf("Authorization = %q", header)
+	}
+	// Middleware logging the request after it was served sees no token
+	if r.URL.RawQuery != "dream=abc" {
+		t.Errorf("query after serving = %q", r.URL.RawQuery)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	_, carl := e.register(t, "carl")
+	req := friendRequest{UserID: bobID, FriendID: annID}
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, req), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/friends/accept", ann, req), http.StatusOK, nil)
+
+	mine := e.createDream(t, ann, "Mine", "A private dream.", false)
+	busy := e.createDream(t, carl, "Busy", "Everybody talked about it.", true)
+	e.createDream(t, carl, "Hidden", "Kept to myself.", false)
+	friends := e.createDream(t, bob, "Bob's", "A public dream by a friend.", true)
+	for _, text := range []string{"First", "Second"} {
+		expect(t, e.do(t, "POST", "/api/dreams/"+busy.ID+"/comments", bob, map[string]string{"text": text}), http.StatusCreated, nil)
+	}
+
+	expect(t, e.do(t, "GET", "/api/feed", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "GET", "/api/feed?cursor=nonsense", ann, nil), http.StatusBadRequest, nil)
+
+	var got []string
+	path := "/api/feed?limit=2"
+	for pages := 0; ; pages++ {
+		if pages == 5 {
+			t.Fatal("feed did not end")
+		}
+		var page FeedPage
+		expect(t, e.do(t, "GET", path, ann, nil), http.StatusOK, &page)
+		for _, it := range page.Items {
+			got = append(got, fmt.Sprintf("%s%v:%d", it.Dream.ID, it.Sources, it.Comments))
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		path = "/api/feed?limit=2&cursor=" + *page.NextCursor
+	}
+	// The busy dream's comments rank it first; the rest are newest first,
+	// and the friend's public dream is listed once for both its sources
+	want := []string{busy.ID + "[popular]:2", friends.ID + "[friends popular]:0", mine.ID + "[own]:0"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ann's feed = %v, want %v", got, want)
+	}
+}
+
+func TestAccessRules(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	adminID, admin := e.register(t, "ada")
+	e.store.SetAdmin(adminID, true)
+	priv := e.createDream(t, ann, "Teeth", "My teeth fell out.", false)
+	pub := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+
+	// Private dreams look missing to everyone but the owner and admins
+	path := "/api/dreams/" + priv.ID
+	expect(t, e.do(t, "GET", path, "", nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", path, bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "GET", path, ann, nil), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", path, admin, nil), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", path+"/comments", bob, nil), http.StatusNotFound, nil)
+	expect(t, e.do(t, "POST", path+"/comments", bob, map[string]string{"text": "hi"}), http.StatusNotFound, nil)
+	expect(t, e.do(t, "DELETE", path, bob, nil), http.StatusNotFound, nil)
+
+	// Public dreams can be read but not changed by others
+	expect(t, e.do(t, "PATCH", "/api/dreams/"+pub.ID, bob, map[string]string{"title": "Mine now"}), http.StatusForbidden, nil)
+	expect(t, e.do(t, "PATCH", "/api/dreams/"+pub.ID, admin, map[string]string{"title": "Moderated"}), http.StatusOK, nil)
+
+	var c model.Comment
+	expect(t, e.do(t, "POST", "/api/dreams/"+pub.ID+"/comments", bob, map[string]string{"text": "Spam"}), http.StatusCreated, &c)
+	expect(t, e.do(t, "DELETE", fmt.Sprintf("/api/comments/%d", c.ID), admin, nil), http.StatusNoContent, nil)
+
+	// A token that fails to verify is rejected rather than treated as
+	// anonymous, so clients know to refresh it
+	expect(t, e.do(t, "GET", "/api/dreams/"+pub.ID, "not-a-token", nil), http.StatusUnauthorized, nil)
+	ghost, err := e.auth.IssueToken("no-such-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, e.do(t, "GET", "/api/dreams/"+pub.ID, ghost, nil), http.StatusUnauthorized, nil)
+}
+
+func TestDreamVisibility(t *testing.T) {
+	e := newTestEnv(t)
+	annID, ann := e.register(t, "ann")
+	bobID, bob := e.register(t, "bob")
+	_, carl := e.register(t, "carl")
+	req := friendRequest{UserID: bobID, FriendID: annID}
+	expect(t, e.do(t, "POST", "/api/friends/request", bob, req), http.StatusOK, nil)
+	expect(t, e.do(t, "POST", "/api/friends/accept", ann, req), http.StatusOK, nil)
+
+	create := func(title string, v model.Visibility) model.Dream {
+		t.Helper()
+		var d model.Dream
+		expect(t, e.do(t, "POST", "/api/dreams", ann, CreateDreamRequest{Title: title, Text: "A whale at the window.", Visibility: &v}), http.StatusOK, &d)
+		if d.Visibility != v {
+			t.Fatalf("created %s dream with visibility %q", v, d.Visibility)
+		}
+		return d
+	}
+	friends := create("Friends", model.VisibilityFriends)
+	unlisted := create("Unlisted", model.VisibilityUnlisted)
+	public := create("Public", model.VisibilityPublic)
+	legacy := e.createDream(t, ann, "Legacy", "Posted with the old public flag.", false)
+	if legacy.Visibility != model.VisibilityPrivate {
+		t.Errorf("public=false dream has visibility %q", legacy.Visibility)
+	}
+
+	resp := e.do(t, "POST", "/api/dreams", ann, map[string]string{"title": "Odd", "text": "Odd.", "visibility": "secret"})
+	if body := apiError(t, resp); resp.StatusCode != http.StatusBadRequest || len(body.Fields) != 1 || body.Fields[0].Field != "visibility" {
+		t.Errorf("bad visibility: status %d, %+v", resp.StatusCode, body)
+	}
+
+	// Friends-only dreams and their comments look missing to strangers;
+	// unlisted ones open for anyone with the link
+	for _, c := range []struct {
+		token  string
+		dream  model.Dream
+		status int
+	}{
+		{bob, friends, http.StatusOK},
+		{carl, friends, http.StatusNotFound},
+		{"", friends, http.StatusNotFound},
+		{carl, unlisted, http.StatusOK},
+		{"", unlisted, http.StatusOK},
+	} {
+		expect(t, e.do(t, "GET", "/api/dreams/"+c.dream.ID, c.token, nil), c.status, nil)
+		expect(t, e.do(t, "GET", "/api/dreams/"+c.dream.ID+"/comments", c.token, nil), c.status, nil)
+	}
+	expect(t, e.do(t, "POST", "/api/dreams/"+friends.ID+"/comments", bob, map[string]string{"text": "Me too"}), http.StatusCreated, nil)
+	expect(t, e.do(t, "POST", "/api/dreams/"+friends.ID+"/comments", carl, map[string]string{"text": "Me too"}), http.StatusNotFound, nil)
+
+	// Unlisted dreams never appear in listings except for their owner
+	listed := func(token, path string) []string {
+		t.Helper()
+		var page DreamPage
+		expect(t, e.do(t, "GET", path, token, nil), http.StatusOK, &page)
+		return dreamIDs(page.Dreams)
+	}
+	profile := func(token string) []string {
+		t.Helper()
+		var body struct {
+			Dreams []model.Dream `json:"dreams"`
+		}
+		expect(t, e.do(t, "GET", "/api/users/ann/public", token, nil), http.StatusOK, &body)
+		return dreamIDs(body.Dreams)
+	}
+	searched := func(token string) []string {
+		t.Helper()
+		var body model.SearchResponse
+		expect(t, e.do(t, "GET", "/api/dreams/search?q=whale&scope=all", token, nil), http.StatusOK, &body)
+		var dreams []model.Dream
+		for _, r := range body.Results {
+			dreams = append(dreams, r.Dream)
+		}
+		return dreamIDs(dreams)
+	}
+	for _, c := range []struct {
+		name string
+		got  []string
+		want []model.Dream
+	}{
+		{"friends feed", listed(bob, "/api/friends/dreams"), []model.Dream{public, friends}},
+		{"owner's list", listed(ann, "/api/dreams?user_id="+annID), []model.Dream{legacy, public, unlisted, friends}},
+		{"friend's list", listed(bob, "/api/dreams?user_id="+annID), []model.Dream{public, friends}},
+		{"stranger's list", listed(carl, "/api/dreams?user_id="+annID), []model.Dream{public}},
+		{"profile for owner", profile(ann), []model.Dream{legacy, public, unlisted, friends}},
+		{"profile for friend", profile(bob), []model.Dream{public, friends}},
+		{"anonymous profile", profile(""), []model.Dream{public}},
+		{"search for friend", searched(bob), []model.Dream{public, friends}},
+		{"search for stranger", searched(carl), []model.Dream{public}},
+	} {
+		if got, want := fmt.Sprint(c.got), fmt.Sprint(dreamIDs(c.want)); got != want {
+			t.Errorf("%s = %v, want %v", c.name, got, want)
+		}
+	}
+
+	// Narrowing a dream takes it away from friends
+	var d model.Dream
+	expect(t, e.do(t, "PATCH", "/api/dreams/"+friends.ID, ann, map[string]string{"visibility": "private"}), http.StatusOK, &d)
+	if d.Visibility != model.VisibilityPrivate {
+		t.Errorf("visibility after edit = %q", d.Visibility)
+	}
+	expect(t, e.do(t, "GET", "/api/dreams/"+friends.ID, bob, nil), http.StatusNotFound, nil)
+}
+
+func TestEmailVerification(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+
+	var me struct {
+		User model.User `json:"user"`
+	}
+	expect(t, e.do(t, "GET", "/api/me", ann, nil), http.StatusOK, &me)
+	if me.User.EmailVerified {
+		t.Fatalf("new user is verified")
+	}
+	first := e.outbox.token(t, "ann@example.com")
+
+	// Asking again replaces the link sent on registration
+	expect(t, e.do(t, "POST", "/api/email/verify/resend", "", nil), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/email/verify/resend", ann, nil), http.StatusAccepted, nil)
+	token := e.outbox.token(t, "ann@example.com")
+	expect(t, e.do(t, "POST", "/api/email/verify", "", VerifyEmailRequest{Token: first}), http.StatusBadRequest, nil)
+
+	expect(t, e.do(t, "POST", "/api/email/verify", "", VerifyEmailRequest{}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/email/verify", "", VerifyEmailRequest{Token: "bogus"}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/email/verify", "", VerifyEmailRequest{Token: token}), http.StatusNoContent, nil)
+	expect(t, e.do(t, "POST", "/api/email/verify", "", VerifyEmailRequest{Token: token}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "GET", "/api/me", ann, nil), http.StatusOK, &me)
+	if !me.User.EmailVerified {
+		t.Errorf("user not verified after following the link")
+	}
+
+	sent := e.outbox.count()
+	expect(t, e.do(t, "POST", "/api/email/verify/resend", ann, nil), http.StatusNoContent, nil)
+	if e.outbox.count() != sent {
+		t.Errorf("verification mail sent to a verified user")
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	e := newTestEnv(t)
+	e.register(t, "ann")
+	verify := e.outbox.token(t, "ann@example.com")
+	var login struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword}), http.StatusOK, &login)
+
+	// Unknown addresses get the same answer and no mail
+	sent := e.outbox.count()
+	expect(t, e.do(t, "POST", "/api/password/forgot", "", ForgotPasswordRequest{Email: "nobody@example.com"}), http.StatusAccepted, nil)
+	if e.outbox.count() != sent {
+		t.Errorf("mail sent for an unknown address")
+	}
+	expect(t, e.do(t, "POST", "/api/password/forgot", "", ForgotPasswordRequest{}), http.StatusBadRequest, nil)
+	token := e.forgotPassword(t, "ann@example.com")
+
+	expect(t, e.do(t, "POST", "/api/password/reset", "", ResetPasswordRequest{Token: token}), http.StatusBadRequest, nil)
+	// A verification token cannot reset a password
+	expect(t, e.do(t, "POST", "/api/password/reset", "", ResetPasswordRequest{Token: verify, Password: "new-password"}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/password/reset", "", ResetPasswordRequest{Token: "bogus", Password: "new-password"}), http.StatusBadRequest, nil)
+	expect(t, e.do(t, "POST", "/api/password/reset", "", ResetPasswordRequest{Token: token, Password: "new-password"}), http.StatusNoContent, nil)
+	expect(t, e.do(t, "POST", "/api/password/reset", "", ResetPasswordRequest{Token: token, Password: "new-password-2"}), http.StatusBadRequest, nil)
+
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword}), http.StatusUnauthorized, nil)
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: "new-password"}), http.StatusOK, nil)
+	// Sessions from before the reset are signed out
+	expect(t, e.do(t, "POST", "/api/token/refresh", "", RefreshRequest{RefreshToken: login.RefreshToken}), http.StatusUnauthorized, nil)
+
+	// Expired links are rejected
+	e.api.ResetTTL = -time.Minute
+	token = e.forgotPassword(t, "ann@example.com")
+	expect(t, e.do(t, "POST", "/api/password/reset", "", ResetPasswordRequest{Token: token, Password: "new-password-2"}), http.StatusBadRequest, nil)
+}
+
+func TestRateLimits(t *testing.T) {
+	e := newTestEnv(t, func(s *Server) {
+		s.RateLimits = RateLimits{
+			Auth: ratelimit.Rate{Limit: 3, Per: time.Hour},
+			AI:   ratelimit.Rate{Limit: 1, Per: time.Hour},
+			API:  ratelimit.Rate{Limit: 5, Per: time.Hour},
+		}
+	})
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+
+	// The third auth request from this address uses up the quota
+	expect(t, e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword}), http.StatusOK, nil)
+	resp := e.do(t, "POST", "/api/login", "", LoginRequest{Email: "ann@example.com", Password: testPassword})
+	expect(t, resp, http.StatusTooManyRequests, nil)
+	if retry := resp.Header.Get("Retry-After"); retry != "1200" {
+		t.Errorf("Retry-After = %q, want 1200", retry)
+	}
+
+	// AI endpoints have their own, smaller quota on top of the API one
+	body := map[string]string{"text": "Whales"}
+	expect(t, e.do(t, "POST", "/api/dreams/tags", ann, body), http.StatusOK, nil)
+	resp = e.do(t, "POST", "/api/dreams/tags", ann, body)
+	expect(t, resp, http.StatusTooManyRequests, nil)
+	if resp.Header.Get("Retry-After") == "" {
+		t.Errorf("429 without Retry-After")
+	}
+	expect(t, e.do(t, "POST", "/api/dreams/tags", bob, body), http.StatusTooManyRequests, nil)
+
+	// Every request counts against the address, whoever sends it
+	expect(t, e.do(t, "GET", "/api/me", bob, nil), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", "/api/me", bob, nil), http.StatusOK, nil)
+	expect(t, e.do(t, "GET", "/api/me", ann, nil), http.StatusTooManyRequests, nil)
+}
+
+func TestRateLimitsBadTokens(t *testing.T) {
+	e := newTestEnv(t, func(s *Server) {
+		s.RateLimits.API = ratelimit.Rate{Limit: 3, Per: time.Hour}
+	})
+	// Requests with tokens that fail to verify are charged too
+	for i := 0; i < 3; i++ {
+		expect(t, e.do(t, "GET", "/api/me", "not-a-jwt", nil), http.StatusUnauthorized, nil)
+	}
+	expect(t, e.do(t, "GET", "/api/me", "not-a-jwt", nil), http.StatusTooManyRequests, nil)
+	// The event stream has its own buckets
+	for i := 0; i < 3; i++ {
+		expect(t, e.do(t, "GET", "/api/events?access_token=not-a-jwt", "", nil), http.StatusUnauthorized, nil)
+	}
+	expect(t, e.do(t, "GET", "/api/events?access_token=not-a-jwt", "", nil), http.StatusTooManyRequests, nil)
+}
+
+func TestRateLimitsPerAccount(t *testing.T) {
+	e := newTestEnv(t, func(s *Server) {
+		s.TrustProxy = true
+		s.RateLimits.API = ratelimit.Rate{Limit: 2, Per: time.Hour}
+	})
+	_, ann := e.register(t, "ann")
+	_, bob := e.register(t, "bob")
+	from := func(ip, token string) *http.Response {
+		req, _ := http.NewRequest("GET", e.srv.URL+"/api/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Forwarded-For", "203.0.113.9, "+ip)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	// An account is limited across addresses, and an address across
+	// accounts
+	expect(t, from("198.51.100.1", ann), http.StatusOK, nil)
+	expect(t, from("198.51.100.2", ann), http.StatusOK, nil)
+	expect(t, from("198.51.100.3", ann), http.StatusTooManyRequests, nil)
+	expect(t, from("198.51.100.1", bob), http.StatusOK, nil)
+	expect(t, from("198.51.100.1", bob), http.StatusTooManyRequests, nil)
+}
+
+func TestLoginLockout(t *testing.T) {
+	e := newTestEnv(t, func(s *Server) {
+		s.RateLimits.Auth = ratelimit.Rate{}
+	})
+	e.register(t, "ann")
+	login := func(email, password string) *http.Response {
+		return e.do(t, "POST", "/api/login", "", LoginRequest{Email: email, Password: password})
+	}
+
+	for i := 0; i < 5; i++ {
+		expect(t, login("ann@example.com", "wrong"), http.StatusUnauthorized, nil)
+		expect(t, login("nobody@example.com", "wrong"), http.StatusUnauthorized, nil)
+	}
+	// Locked out even with the right password, and an unknown email gets
+	// exactly the same answer
+	locked := login("Ann@Example.com", testPassword)
+	unknown := login("nobody@example.com", testPassword)
+	for _, resp := range []*http.Response{locked, unknown} {
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "30" {
+			t.Errorf("locked login: %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+	}
+	if a, b := apiError(t, locked), apiError(t, unknown); a.Code != b.Code || a.Message != b.Message {
+		t.Errorf("lockout responses differ: %q vs %q", a, b)
+	}
+	expect(t, login("bob@example.com", "wrong"), http.StatusUnauthorized, nil)
+
+	// A password reset lifts the lockout
+	token := e.forgotPassword(t, "ann@example.com")
+	expect(t, e.do(t, "POST", "/api/password/reset", "", ResetPasswordRequest{Token: token, Password: "new-password"}), http.StatusNoContent, nil)
+	expect(t, login("ann@example.com", "new-password"), http.StatusOK, nil)
+}
+
+func TestErrorResponses(t *testing.T) {
+	e := newTestEnv(t)
+	_, ann := e.register(t, "ann")
+	d := e.createDream(t, ann, "Ocean", "Swimming with whales.", true)
+
+	// fields maps each failed field to its code
+	fields := func(body ErrorBody) map[string]string {
+		m := map[string]string{}
+		for _, f := range body.Fields {
+			m[f.Field] = f.Code
+		}
+		return m
+	}
+	eleven, zero := 11, 0
+	cases := []struct {
+		name, method, path, token string
+		body                      interface{}
+		status                    int
+		code                      string
+		fields                    map[string]string
+	}{
+		{"weak registration", "POST", "/api/register", "", RegisterRequest{Email: "bob@example", Username: "b", Password: "password"},
+			http.StatusBadRequest, "ERR_VALIDATION", map[string]string{"email": "invalid_email", "username": "invalid_username", "password": "weak_password"}},
+		{"missing registration fields", "POST", "/api/register", "", RegisterRequest{},
+			http.StatusBadRequest, "ERR_VALIDATION", map[string]string{"email": "required", "username": "required", "password": "required"}},
+		{"taken email", "POST", "/api/register", "", RegisterRequest{Email: "ann@example.com", Username: "ann2", Password: testPassword},
+			http.StatusConflict, "ERR_CONFLICT", map[string]string{}},
+		{"bad json", "POST", "/api/dreams", ann, "{",
+			http.StatusBadRequest, "ERR_DECODE_JSON", map[string]string{}},
+		{"bad dream", "POST", "/api/dreams", ann, CreateDreamRequest{Title: strings.Repeat("x", 201), NightmareRating: &eleven},
+			http.StatusBadRequest, "ERR_VALIDATION", map[string]string{"title": "too_long", "text": "required", "nightmare_rating": "too_large"}},
+		{"bad edit", "PATCH", "/api/dreams/" + d.ID, ann, map[string]interface{}{"text": " ", "clarity_rating": zero},
+			http.StatusBadRequest, "ERR_VALIDATION", map[string]string{"text": "blank", "clarity_rating": "too_small"}},
+		{"anonymous", "POST", "/api/dreams", "", CreateDreamRequest{Text: "x"},
+			http.StatusUnauthorized, "ERR_UNAUTHORIZED", map[string]string{}},
+		{"bad token", "GET", "/api/me", "not-a-token", nil,
+			http.StatusUnauthorized, "ERR_INVALID_TOKEN", map[string]string{}},
+		{"missing dream", "GET", "/api/dreams/nope", ann, nil,
+			http.StatusNotFound, "ERR_NOT_FOUND", map[string]string{}},
+		{"unknown route", "GET", "/api/nowhere", "", nil,
+			http.StatusNotFound, "ERR_NOT_FOUND", map[string]string{}},
+		{"wrong method", "PUT", "/api/login", "", nil,
+			http.StatusMethodNotAllowed, "ERR_METHOD_NOT_ALLOWED", map[string]string{}},
+	}
+	for _, c := range cases {
+		resp := e.do(t, c.method, c.path, c.token, c.body)
+		if resp.StatusCode != c.status {
+			t.Errorf("%s: status %d, want %d", c.name, resp.StatusCode, c.status)
+			continue
+		}
+		body := apiError(t, resp)
+		if body.Code != c.code || body.Message == "" {
+			t.Errorf("%s: error %+v, want code %s", c.name, body, c.code)
+		}
+		if got := fields(body); !reflect.DeepEqual(got, c.fields) {
+			t.Errorf("%s: fields %v, want %v", c.name, got, c.fields)
+		}
+	}
+
+	// Null clears a rating rather than failing validation
+	expect(t, e.do(t, "PATCH", "/api/dreams/"+d.ID, ann, map[string]interface{}{"clarity_rating": nil}), http.StatusNoContent, nil)
+}
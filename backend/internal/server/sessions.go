@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/sessions"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// sessions returns the session manager for the server's settings
+func (s *Server) sessions() *sessions.Manager {
+	return &sessions.Manager{Store: s.store, Auth: s.auth, TTL: s.RefreshTTL}
+}
+
+// refreshHandler serves POST /api/token/refresh. The refresh token is
+// single use: it is swapped for a new one in the same family, and replaying
+// an old one revokes the family.
+func (s *Server) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	sess, err := s.sessions().Refresh(r.Context(), req.RefreshToken)
+	if errors.Is(err, store.ErrRevoked) {
+		log.Printf("[REFRESH] Reused or revoked refresh token presented; family revoked")
+		writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Invalid refresh token")
+		return
+	} else if errors.Is(err, sessions.ErrExpired) {
+		writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Refresh token expired")
+		return
+	} else if errors.Is(err, store.ErrNotFound) {
+		// An unknown token, or one whose user was deleted
+		writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Invalid refresh token")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to refresh token")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sess)
+}
+
+// logoutHandler serves POST /api/logout and revokes every refresh token in
+// the presented token's family. Access tokens already issued stay valid
+// until they expire.
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if err := s.sessions().Revoke(r.Context(), req.RefreshToken); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to log out")
+		return
+	}
+	// Logging out twice is not an error
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultTopTags is the number of tags returned in mostCommonTags
+	defaultTopTags    = 10
+	defaultTagLimit   = 50
+	defaultRelatedTag = 5
+)
+
+// statsHandler serves GET /api/users/{id}/stats. Only the user themselves
+// or an admin may read stats since they include private dreams.
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	if err := s.policy.ReadPrivate(principal(r), userID); err != nil {
+		deny(w, err, "")
+		return
+	}
+	dr, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	// The daily frequency series has a row per day of the range
+	if dr.From != nil {
+		to := time.Now()
+		if dr.To != nil {
+			to = *dr.To
+		}
+		if to.Sub(*dr.From) > model.MaxFrequencyDays*24*time.Hour {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("Stats cover at most %d days", model.MaxFrequencyDays))
+			return
+		}
+	}
+	topTags, err := queryInt(r, "top_tags", defaultTopTags, 1, 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	stats, err := s.store.DreamStats(r.Context(), userID, dr, topTags)
+	if err != nil {
+		log.Printf("[STATS] Failed to compute stats for user %s: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to compute stats")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// tagsHandler serves GET /api/users/{id}/tags. The owner and admins see
+// tags from every dream; everyone else only sees tags on public dreams.
+func (s *Server) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	includePrivate := s.policy.ReadPrivate(principal(r), userID) == nil
+	dr, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultTagLimit, 1, 500)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	related, err := queryInt(r, "related", defaultRelatedTag, 0, 50)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	tags, err := s.store.TagAnalytics(r.Context(), userID, includePrivate, dr, limit, related)
+	if err != nil {
+		log.Printf("[TAGS] Failed to compute tag analytics for user %s: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to compute tag analytics")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
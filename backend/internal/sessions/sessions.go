@@ -0,0 +1,95 @@
+// Package sessions issues and rotates the access and refresh token pairs
+// shared by the HTTP and gRPC APIs
+package sessions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/auth"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+// ErrExpired is returned by Refresh for a refresh token past its expiry
+var ErrExpired = errors.New("refresh token expired")
+
+// Store is the subset of store.Store that keeps refresh tokens
+type Store interface {
+	CreateRefreshToken(ctx context.Context, t *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error)
+	UseRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error)
+	RevokeRefreshFamily(ctx context.Context, familyID string) error
+}
+
+// Session is an access token with the refresh token that renews it
+type Session struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Manager signs access tokens with Auth and keeps refresh tokens, valid
+// for TTL, in Store
+type Manager struct {
+	Store Store
+	Auth  auth.Authenticator
+	TTL   time.Duration
+}
+
+// Issue signs an access token for userID and stores a new refresh token in
+// familyID, starting a new family when familyID is empty
+func (m *Manager) Issue(ctx context.Context, userID, familyID string) (*Session, error) {
+	token, err := m.Auth.IssueToken(userID)
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		if familyID, err = auth.NewFamilyID(); err != nil {
+			return nil, err
+		}
+	}
+	refresh, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	err = m.Store.CreateRefreshToken(ctx, &model.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(m.TTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Token: token, RefreshToken: refresh}, nil
+}
+
+// Refresh swaps a refresh token for a new session in the same family. The
+// token is single use, and replaying an old one revokes the family.
+// Returns store.ErrNotFound for an unknown token or a deleted user,
+// store.ErrRevoked for a used or revoked one and ErrExpired for an expired
+// one.
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	old, err := m.Store.UseRefreshToken(ctx, auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return m.Issue(ctx, old.UserID, old.FamilyID)
+}
+
+// Revoke revokes every refresh token in refreshToken's family. Access
+// tokens already issued stay valid until they expire. Revoking an unknown
+// token is not an error.
+func (m *Manager) Revoke(ctx context.Context, refreshToken string) error {
+	t, err := m.Store.GetRefreshToken(ctx, auth.HashRefreshToken(refreshToken))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return m.Store.RevokeRefreshFamily(ctx, t.FamilyID)
+}
@@ -0,0 +1,235 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+func (s *Store) comment(id int) *model.Comment {
+	for _, c := range s.comments {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// viewComment returns a copy of a stored comment with the author filled in
+func (s *Store) viewComment(c *model.Comment) model.Comment {
+	cp := *c
+	cp.User = s.summary(c.User.ID)
+	cp.Reactions = s.reactionCounts(model.ReactionTarget{CommentID: c.ID})
+	return cp
+}
+
+func (s *Store) CreateComment(ctx context.Context, c *model.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dream(c.DreamRowID) == nil || s.user(c.User.ID) == nil {
+		return store.ErrNotFound
+	}
+	var parentID *int
+	if c.ParentID != nil {
+		parent := s.comment(*c.ParentID)
+		if parent == nil || parent.DreamRowID != c.DreamRowID {
+			return store.ErrNotFound
+		}
+		id := parent.ID
+		parentID = &id
+	}
+	now := s.now()
+	s.commentSeq++
+	stored := model.Comment{
+		ID:         s.commentSeq,
+		ParentID:   parentID,
+		Text:       c.Text,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		User:       model.UserSummary{ID: c.User.ID},
+		DreamRowID: c.DreamRowID,
+	}
+	s.comments = append(s.comments, &stored)
+	*c = s.viewComment(&stored)
+	return nil
+}
+
+func (s *Store) GetComment(ctx context.Context, id int) (*model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.comment(id); c != nil {
+		cp := s.viewComment(c)
+		return &cp, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) ListComments(ctx context.Context, dreamRowID int, viewerID string, page model.Page) ([]model.Comment, *model.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocked := s.blockedIDs(viewerID)
+	// Comments are appended in creation order, so they are already oldest
+	// first and the cursor moves forward in time
+	comments := []model.Comment{}
+	for _, c := range s.comments {
+		if c.DreamRowID == dreamRowID && !blocked[c.User.ID] && (page.After == nil || after(c.Cursor(), *page.After)) {
+			comments = append(comments, s.viewComment(c))
+		}
+	}
+	n, next := trimPage(page, len(comments), func(i int) model.Cursor { return comments[i].Cursor() })
+	return comments[:n], next, nil
+}
+
+func (s *Store) CountComments(ctx context.Context, dreamRowIDs []int) (map[int]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wanted := make(map[int]bool, len(dreamRowIDs))
+	for _, id := range dreamRowIDs {
+		wanted[id] = true
+	}
+	counts := map[int]int{}
+	for _, c := range s.comments {
+		if wanted[c.DreamRowID] {
+			counts[c.DreamRowID]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *Store) UpdateComment(ctx context.Context, id int, text string) (*model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.comment(id)
+	if c == nil {
+		return nil, store.ErrNotFound
+	}
+	c.Text, c.Edited, c.UpdatedAt = text, true, s.now()
+	cp := s.viewComment(c)
+	return &cp, nil
+}
+
+func (s *Store) DeleteComment(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.comment(id) == nil {
+		return store.ErrNotFound
+	}
+	s.dropComments(func(c *model.Comment) bool { return c.ID == id })
+	return nil
+}
+
+// dropComments deletes the comments drop matches, then their replies and
+// the reports, reactions and notifications on all of them, as the foreign
+// keys cascade in Postgres. The caller holds the lock.
+func (s *Store) dropComments(drop func(c *model.Comment) bool) {
+	gone := map[int]bool{}
+	for changed := true; changed; {
+		changed = false
+		kept := s.comments[:0]
+		for _, c := range s.comments {
+			if drop(c) || (c.ParentID != nil && gone[*c.ParentID]) {
+				gone[c.ID], changed = true, true
+				continue
+			}
+			kept = append(kept, c)
+		}
+		s.comments = kept
+	}
+	reports := s.reports[:0]
+	for _, r := range s.reports {
+		if !gone[r.Comment.ID] {
+			reports = append(reports, r)
+		}
+	}
+	s.reports = reports
+	s.dropReactions(func(r *model.Reaction) bool { return gone[r.Target.CommentID] })
+	s.dropNotifications(func(n *model.Notification) bool { return n.CommentID != nil && gone[*n.CommentID] })
+}
+
+// report is a stored CommentReport. Only the IDs of its comment and
+// reporter are kept; viewReport fills in the rest.
+type report struct {
+	model.CommentReport
+	resolvedBy string
+}
+
+func (s *Store) viewReport(r *report) model.CommentReport {
+	cp := r.CommentReport
+	cp.Reporter = s.summary(r.Reporter.ID)
+	if c := s.comment(r.Comment.ID); c != nil {
+		cp.Comment = s.viewComment(c)
+		if d := s.dream(c.DreamRowID); d != nil {
+			cp.DreamID = d.ID
+		}
+	}
+	return cp
+}
+
+func (s *Store) ReportComment(ctx context.Context, r *model.CommentReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.comment(r.Comment.ID) == nil || s.user(r.Reporter.ID) == nil {
+		return store.ErrNotFound
+	}
+	for _, open := range s.reports {
+		if open.ResolvedAt == nil && open.Comment.ID == r.Comment.ID && open.Reporter.ID == r.Reporter.ID {
+			return store.ErrConflict
+		}
+	}
+	s.reportSeq++
+	stored := &report{CommentReport: model.CommentReport{
+		ID:        s.reportSeq,
+		Reason:    r.Reason,
+		CreatedAt: s.now(),
+		Reporter:  model.UserSummary{ID: r.Reporter.ID},
+		Comment:   model.Comment{ID: r.Comment.ID},
+	}}
+	s.reports = append(s.reports, stored)
+	*r = s.viewReport(stored)
+	return nil
+}
+
+func (s *Store) GetReport(ctx context.Context, id int) (*model.CommentReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reports {
+		if r.ID == id {
+			cp := s.viewReport(r)
+			return &cp, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) ListOpenReports(ctx context.Context, page model.Page) ([]model.CommentReport, *model.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Reports are appended in creation order, so they are already oldest
+	// first
+	reports := []model.CommentReport{}
+	for _, r := range s.reports {
+		if r.ResolvedAt == nil && (page.After == nil || after(r.Cursor(), *page.After)) {
+			reports = append(reports, s.viewReport(r))
+		}
+	}
+	n, next := trimPage(page, len(reports), func(i int) model.Cursor { return reports[i].Cursor() })
+	return reports[:n], next, nil
+}
+
+func (s *Store) DismissReports(ctx context.Context, commentID int, moderatorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	dismissed := false
+	for _, r := range s.reports {
+		if r.ResolvedAt == nil && r.Comment.ID == commentID {
+			r.ResolvedAt, r.resolvedBy = &now, moderatorID
+			dismissed = true
+		}
+	}
+	if !dismissed {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+func (s *Store) dream(rowID int) *model.Dream {
+	for _, d := range s.dreams {
+		if d.RowID == rowID {
+			return d
+		}
+	}
+	return nil
+}
+
+// view returns a detached copy of a stored dream with the author's profile
+// filled in, as the dreams/users join does in Postgres
+func (s *Store) view(d *model.Dream) model.Dream {
+	c := *d
+	c.Tags = append([]string{}, d.Tags...)
+	c.NightmareRating = copyInt(d.NightmareRating)
+	c.VividnessRating = copyInt(d.VividnessRating)
+	c.ClarityRating = copyInt(d.ClarityRating)
+	c.EmotionalIntensityRating = copyInt(d.EmotionalIntensityRating)
+	c.Jobs = nil
+	c.Reactions = s.reactionCounts(model.ReactionTarget{DreamRowID: d.RowID})
+	if u := s.user(d.UserID); u != nil {
+		c.Username = u.Username
+		c.DisplayName = u.DisplayName
+		c.ProfileImageURL = u.ProfileImageURL
+	}
+	return c
+}
+
+func (s *Store) CreateDream(ctx context.Context, d *model.Dream, jobKinds ...jobs.Kind) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(d.UserID) == nil {
+		return store.ErrNotFound
+	}
+	var shortcode string
+	for shortcode == "" {
+		sc, err := generateShortcode(10)
+		if err != nil {
+			return err
+		}
+		shortcode = sc
+		for _, existing := range s.dreams {
+			if existing.ID == sc {
+				shortcode = ""
+				break
+			}
+		}
+	}
+	now := s.now()
+	s.dreamSeq++
+	d.RowID = s.dreamSeq
+	d.ID = shortcode
+	d.CreatedAt, d.UpdatedAt = now, now
+	d.Tags = []string{}
+	d.Reactions = model.NewReactionCounts()
+	if d.Visibility == "" {
+		d.Visibility = model.VisibilityPrivate
+	}
+	d.Summary, d.SummaryHash, d.Prophecy = "", "", ""
+	d.Jobs = nil
+	stored := s.view(d)
+	stored.Username, stored.DisplayName, stored.ProfileImageURL = "", "", ""
+	s.dreams = append(s.dreams, &stored)
+	for _, kind := range jobKinds {
+		d.Jobs = append(d.Jobs, s.enqueue(kind, d.RowID))
+	}
+	return nil
+}
+
+func (s *Store) GetDream(ctx context.Context, publicID string) (*model.Dream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.dreams {
+		if d.ID == publicID {
+			c := s.view(d)
+			return &c, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) GetDreamByRowID(ctx context.Context, rowID int) (*model.Dream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d := s.dream(rowID); d != nil {
+		c := s.view(d)
+		return &c, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+// visible applies the author and visibility rules of a DreamFilter. friends
+// is the set of the viewer's friends.
+func visible(d *model.Dream, f model.DreamFilter, friends map[string]bool) bool {
+	if f.Owners != nil {
+		owned := false
+		for _, o := range f.Owners {
+			if o == d.UserID {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			return false
+		}
+	}
+	switch {
+	case d.Visibility == model.VisibilityPublic:
+		return true
+	case f.PublicOnly || f.Viewer == "":
+		return false
+	case d.UserID == f.Viewer:
+		return true
+	}
+	return d.Visibility == model.VisibilityFriends && friends[d.UserID]
+}
+
+func (s *Store) ListDreams(ctx context.Context, f model.DreamFilter) ([]model.Dream, *model.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	friends, blocked := s.friendIDs(f.Viewer), s.blockedIDs(f.Viewer)
+	dreams := []model.Dream{}
+	for _, d := range s.dreams {
+		if visible(d, f, friends) && !blocked[d.UserID] && (f.Page.After == nil || after(*f.Page.After, d.Cursor())) {
+			dreams = append(dreams, s.view(d))
+		}
+	}
+	sort.Slice(dreams, func(i, j int) bool { return after(dreams[i].Cursor(), dreams[j].Cursor()) })
+	n, next := trimPage(f.Page, len(dreams), func(i int) model.Cursor { return dreams[i].Cursor() })
+	return dreams[:n], next, nil
+}
+
+func (s *Store) PopularDreams(ctx context.Context, viewerID string, since, until time.Time, limit int) ([]model.Dream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocked := s.blockedIDs(viewerID)
+	activity := map[int]int{}
+	for _, c := range s.comments {
+		activity[c.DreamRowID]++
+	}
+	for _, r := range s.reactions {
+		if r.Target.DreamRowID != 0 {
+			activity[r.Target.DreamRowID]++
+		}
+	}
+	dreams := []model.Dream{}
+	for _, d := range s.dreams {
+		if d.Visibility == model.VisibilityPublic && !d.CreatedAt.Before(since) && !d.CreatedAt.After(until) && !blocked[d.UserID] {
+			dreams = append(dreams, s.view(d))
+		}
+	}
+	sort.Slice(dreams, func(i, j int) bool {
+		if a, b := activity[dreams[i].RowID], activity[dreams[j].RowID]; a != b {
+			return a > b
+		}
+		return after(dreams[i].Cursor(), dreams[j].Cursor())
+	})
+	if limit > 0 && len(dreams) > limit {
+		dreams = dreams[:limit]
+	}
+	return dreams, nil
+}
+
+func (s *Store) DeleteDream(ctx context.Context, rowID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, d := range s.dreams {
+		if d.RowID != rowID {
+			continue
+		}
+		s.dreams = append(s.dreams[:i], s.dreams[i+1:]...)
+		// Cascade like the foreign keys do
+		delete(s.revisions, rowID)
+		s.dropComments(func(c *model.Comment) bool { return c.DreamRowID == rowID })
+		s.dropReactions(func(r *model.Reaction) bool { return r.Target.DreamRowID == rowID })
+		s.dropNotifications(func(n *model.Notification) bool { return n.DreamRowID == rowID })
+		queued := s.jobs[:0]
+		for _, j := range s.jobs {
+			if j.DreamID != rowID {
+				queued = append(queued, j)
+			}
+		}
+		s.jobs = queued
+		return nil
+	}
+	return store.ErrNotFound
+}
+
+func (s *Store) ReplaceTags(ctx context.Context, rowID int, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.dream(rowID)
+	if d == nil {
+		return nil
+	}
+	d.Tags = []string{}
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) != "" {
+			d.Tags = append(d.Tags, tag)
+		}
+	}
+	return nil
+}
+
+func (s *Store) SetSummary(ctx context.Context, rowID int, text, summary string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Skip the write if the dream was edited while the model was running
+	if d := s.dream(rowID); d != nil && d.Text == text {
+		d.Summary = summary
+		d.SummaryHash = ai.ContentHash(text)
+	}
+	return nil
+}
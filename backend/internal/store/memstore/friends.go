@@ -0,0 +1,241 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+func (s *Store) FriendStatus(ctx context.Context, userID, friendID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.friends[[2]string{userID, friendID}]; ok {
+		return f.status, nil
+	}
+	return "", store.ErrNotFound
+}
+
+func (s *Store) RequestFriend(ctx context.Context, userID, friendID string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(userID) == nil || s.user(friendID) == nil {
+		return "", false, store.ErrNotFound
+	}
+	key, reverse := [2]string{userID, friendID}, [2]string{friendID, userID}
+	if f, ok := s.friends[reverse]; ok {
+		switch f.status {
+		case "blocked":
+			return "", false, store.ErrNotFound
+		case "pending", "rejected":
+			// Both want to be friends
+			f.status = "accepted"
+			s.friends[key] = &friendship{status: "accepted", createdAt: s.now()}
+			return "accepted", true, nil
+		}
+	}
+	if f, ok := s.friends[key]; ok {
+		switch f.status {
+		case "blocked":
+			return "", false, store.ErrConflict
+		case "rejected":
+			return "pending", false, nil
+		}
+		return f.status, false, nil
+	}
+	s.friends[key] = &friendship{status: "pending", createdAt: s.now()}
+	return "pending", true, nil
+}
+
+func (s *Store) AcceptFriend(ctx context.Context, userID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.friends[[2]string{userID, friendID}]
+	if !ok || (f.status != "pending" && f.status != "rejected") {
+		return store.ErrNotFound
+	}
+	f.status = "accepted"
+	s.friends[[2]string{friendID, userID}] = &friendship{status: "accepted", createdAt: s.now()}
+	return nil
+}
+
+func (s *Store) RejectFriend(ctx context.Context, userID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.friends[[2]string{userID, friendID}]
+	if !ok || f.status != "pending" {
+		return store.ErrNotFound
+	}
+	f.status = "rejected"
+	return nil
+}
+
+func (s *Store) CancelFriendRequest(ctx context.Context, userID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]string{userID, friendID}
+	f, ok := s.friends[key]
+	if !ok || (f.status != "pending" && f.status != "rejected") {
+		return store.ErrNotFound
+	}
+	delete(s.friends, key)
+	return nil
+}
+
+func (s *Store) RemoveFriend(ctx context.Context, userID, friendID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unfriend(userID, friendID)
+	return nil
+}
+
+// unfriend deletes every row between a and b except blocks. The caller
+// holds the lock.
+func (s *Store) unfriend(a, b string) {
+	for _, key := range [][2]string{{a, b}, {b, a}} {
+		if f, ok := s.friends[key]; ok && f.status != "blocked" {
+			delete(s.friends, key)
+		}
+	}
+}
+
+func (s *Store) BlockUser(ctx context.Context, userID, blockedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(blockedID) == nil {
+		return store.ErrNotFound
+	}
+	s.unfriend(userID, blockedID)
+	key := [2]string{userID, blockedID}
+	if _, ok := s.friends[key]; !ok {
+		s.friends[key] = &friendship{status: "blocked", createdAt: s.now()}
+	}
+	return nil
+}
+
+func (s *Store) UnblockUser(ctx context.Context, userID, blockedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]string{userID, blockedID}
+	if f, ok := s.friends[key]; !ok || f.status != "blocked" {
+		return store.ErrNotFound
+	}
+	delete(s.friends, key)
+	return nil
+}
+
+func (s *Store) Blocked(ctx context.Context, a, b string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blockedIDs(a)[b], nil
+}
+
+// blockedIDs returns the set of users who blocked, or were blocked by,
+// userID. The caller holds the lock.
+func (s *Store) blockedIDs(userID string) map[string]bool {
+	ids := map[string]bool{}
+	if userID == "" {
+		return ids
+	}
+	for key, f := range s.friends {
+		if f.status != "blocked" {
+			continue
+		}
+		if key[0] == userID {
+			ids[key[1]] = true
+		} else if key[1] == userID {
+			ids[key[0]] = true
+		}
+	}
+	return ids
+}
+
+func (s *Store) ListFriends(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	friends := []model.UserSummary{}
+	for key, f := range s.friends {
+		if key[0] == userID && f.status == "accepted" {
+			friends = append(friends, s.summary(key[1]))
+		}
+	}
+	sort.Slice(friends, func(i, j int) bool { return friends[i].Username < friends[j].Username })
+	return friends, nil
+}
+
+func (s *Store) ListFriendRequests(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listRequests(func(key [2]string, f *friendship) (string, bool) {
+		return key[0], key[1] == userID && f.status == "pending"
+	}), nil
+}
+
+func (s *Store) ListSentFriendRequests(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listRequests(func(key [2]string, f *friendship) (string, bool) {
+		return key[1], key[0] == userID && (f.status == "pending" || f.status == "rejected")
+	}), nil
+}
+
+// listRequests returns the users picked by match, oldest row first. match
+// returns the ID of the other user and whether the row is wanted. The caller
+// holds the lock.
+func (s *Store) listRequests(match func(key [2]string, f *friendship) (string, bool)) []model.UserSummary {
+	type request struct {
+		other model.UserSummary
+		f     *friendship
+	}
+	var requests []request
+	for key, f := range s.friends {
+		if id, ok := match(key, f); ok {
+			requests = append(requests, request{s.summary(id), f})
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].f.createdAt.Before(requests[j].f.createdAt) })
+	users := []model.UserSummary{}
+	for _, r := range requests {
+		users = append(users, r.other)
+	}
+	return users
+}
+
+func (s *Store) ListBlocked(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := []model.UserSummary{}
+	for key, f := range s.friends {
+		if key[0] == userID && f.status == "blocked" {
+			users = append(users, s.summary(key[1]))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (s *Store) FriendIDs(ctx context.Context, userID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := []string{}
+	for key, f := range s.friends {
+		if key[0] == userID && f.status == "accepted" {
+			ids = append(ids, key[1])
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// friendIDs returns the set of userID's accepted friends. The caller holds
+// the lock.
+func (s *Store) friendIDs(userID string) map[string]bool {
+	ids := map[string]bool{}
+	for key, f := range s.friends {
+		if key[0] == userID && f.status == "accepted" {
+			ids[key[1]] = true
+		}
+	}
+	return ids
+}
@@ -0,0 +1,227 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+const (
+	// maxAttempts matches the ai_jobs.max_attempts default
+	maxAttempts = 5
+	// retryBackoff is the delay added per failed attempt
+	retryBackoff = time.Second
+	// pollInterval is how often Run looks for jobs whose backoff expired
+	pollInterval = time.Second
+)
+
+// enqueue adds a job unless an unfinished one of the same kind exists for
+// the dream, mirroring the partial unique index on ai_jobs. The caller holds
+// the lock.
+func (s *Store) enqueue(kind jobs.Kind, dreamID int) *jobs.Job {
+	for _, j := range s.jobs {
+		if j.Kind == kind && j.DreamID == dreamID && (j.Status == jobs.StatusPending || j.Status == jobs.StatusRunning) {
+			c := *j
+			return &c
+		}
+	}
+	now := s.now()
+	s.jobSeq++
+	j := &jobs.Job{ID: s.jobSeq, Kind: kind, DreamID: dreamID, Status: jobs.StatusPending, MaxAttempts: maxAttempts, RunAt: now, CreatedAt: now, UpdatedAt: now}
+	s.jobs = append(s.jobs, j)
+	c := *j
+	return &c
+}
+
+func (s *Store) job(id int64) *jobs.Job {
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// Enqueue queues a job and wakes the worker
+func (s *Store) Enqueue(ctx context.Context, kind jobs.Kind, dreamID int) (*jobs.Job, error) {
+	s.mu.Lock()
+	if s.dream(dreamID) == nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("dream %d does not exist", dreamID)
+	}
+	job := s.enqueue(kind, dreamID)
+	s.mu.Unlock()
+	s.Notify()
+	return job, nil
+}
+
+func (s *Store) Get(ctx context.Context, id int64) (*jobs.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j := s.job(id); j != nil {
+		c := *j
+		return &c, nil
+	}
+	return nil, jobs.ErrNotFound
+}
+
+// Retry moves a dead job back to pending with a fresh attempt budget
+func (s *Store) Retry(ctx context.Context, id int64) (*jobs.Job, error) {
+	s.mu.Lock()
+	j := s.job(id)
+	if j == nil || j.Status != jobs.StatusDead {
+		s.mu.Unlock()
+		return nil, jobs.ErrNotFound
+	}
+	// Mirror the partial unique index on ai_jobs
+	for _, other := range s.jobs {
+		if other.Kind == j.Kind && other.DreamID == j.DreamID && (other.Status == jobs.StatusPending || other.Status == jobs.StatusRunning) {
+			s.mu.Unlock()
+			return nil, jobs.ErrConflict
+		}
+	}
+	now := s.now()
+	j.Status, j.Attempts, j.RunAt, j.UpdatedAt = jobs.StatusPending, 0, now, now
+	c := *j
+	s.mu.Unlock()
+	s.Notify()
+	return &c, nil
+}
+
+// Notify wakes the worker started by Run
+func (s *Store) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works through queued jobs with dreamAI until ctx is cancelled
+func (s *Store) Run(ctx context.Context, dreamAI ai.DreamAI) {
+	for {
+		s.ProcessJobs(ctx, dreamAI)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// ProcessJobs runs every job that is due, one at a time, and returns how
+// many it ran. Failed jobs are retried after a backoff until they run out of
+// attempts and become dead.
+func (s *Store) ProcessJobs(ctx context.Context, dreamAI ai.DreamAI) int {
+	ran := 0
+	for ctx.Err() == nil {
+		job, text := s.claim()
+		if job == nil {
+			break
+		}
+		ran++
+		result, err := s.execute(ctx, dreamAI, job, text)
+		s.finish(job, text, result, err)
+		if s.OnJobFinish == nil {
+			continue
+		}
+		if j, _ := s.Get(ctx, job.ID); j != nil && (j.Status == jobs.StatusDone || j.Status == jobs.StatusDead) {
+			s.OnJobFinish(ctx, j)
+		}
+	}
+	return ran
+}
+
+// claim marks the oldest due job running and returns it with the dream text
+func (s *Store) claim() (*jobs.Job, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for _, j := range s.jobs {
+		if j.Status != jobs.StatusPending || j.RunAt.After(now) {
+			continue
+		}
+		d := s.dream(j.DreamID)
+		if d == nil {
+			continue
+		}
+		j.Status = jobs.StatusRunning
+		j.Attempts++
+		j.UpdatedAt = s.now()
+		c := *j
+		return &c, d.Text
+	}
+	return nil, ""
+}
+
+// jobResult is the output of one AI call
+type jobResult struct {
+	tags []string
+	text string
+}
+
+func (s *Store) execute(ctx context.Context, dreamAI ai.DreamAI, job *jobs.Job, text string) (jobResult, error) {
+	switch job.Kind {
+	case jobs.KindTags:
+		tags, err := dreamAI.ExtractTags(ctx, text)
+		return jobResult{tags: tags}, err
+	case jobs.KindSummary:
+		summary, err := dreamAI.Summarize(ctx, text)
+		return jobResult{text: summary}, err
+	case jobs.KindProphecy:
+		prophecy, err := dreamAI.Prophesy(ctx, text)
+		return jobResult{text: prophecy}, err
+	}
+	return jobResult{}, fmt.Errorf("unknown job kind %q", job.Kind)
+}
+
+// finish stores a job's result on its dream and records the outcome. As in
+// the Postgres queue, results for a dream edited in the meantime are dropped
+// and AI tags never overwrite tags set by hand.
+func (s *Store) finish(job *jobs.Job, text string, result jobResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.job(job.ID)
+	if j == nil {
+		return
+	}
+	now := s.now()
+	j.UpdatedAt = now
+	if err != nil {
+		log.Printf("[JOBS] %s job %d for dream %d failed (attempt %d/%d): %v", j.Kind, j.ID, j.DreamID, j.Attempts, j.MaxAttempts, err)
+		j.LastError = err.Error()
+		if j.Attempts >= j.MaxAttempts || errors.Is(err, ai.ErrNotConfigured) {
+			j.Status = jobs.StatusDead
+		} else {
+			j.Status = jobs.StatusPending
+			j.RunAt = now.Add(time.Duration(j.Attempts) * retryBackoff)
+		}
+		return
+	}
+	j.Status = jobs.StatusDone
+	j.LastError = ""
+	d := s.dream(j.DreamID)
+	if d == nil {
+		return
+	}
+	switch j.Kind {
+	case jobs.KindTags:
+		if len(d.Tags) == 0 {
+			d.Tags = append([]string{}, result.tags...)
+		}
+	case jobs.KindSummary:
+		if d.Text == text {
+			d.Summary = result.text
+			d.SummaryHash = ai.ContentHash(text)
+		}
+	case jobs.KindProphecy:
+		if d.Text == text {
+			d.Prophecy = result.text
+		}
+	}
+}
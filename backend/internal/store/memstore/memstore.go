@@ -0,0 +1,135 @@
+// Package memstore implements store.Store in memory so the API can be tested
+// and demoed without Postgres. It also stands in for the AI job queue.
+// Nothing is persisted: data is lost when the process exits.
+package memstore
+
+import (
+	"context"
+	crand "crypto/rand"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+// Store is a store.Store held in memory. Every method takes the same lock,
+// which is plenty for tests and single-user demos.
+type Store struct {
+	// OnJobFinish, if set, is called after a job is done or dead, like
+	// jobs.Queue.OnFinish
+	OnJobFinish func(ctx context.Context, job *jobs.Job)
+
+	mu   sync.Mutex
+	last time.Time
+
+	// Sequences, like the SERIAL columns in Postgres
+	userSeq, dreamSeq, commentSeq, reportSeq, reactionSeq, notificationSeq int
+	jobSeq, tokenSeq, accountSeq                                           int64
+
+	users             []*user
+	aliases           map[string]string // user ID by lowercased former username
+	dreams            []*model.Dream
+	revisions         map[int][]model.DreamRevision // by dream row ID
+	friends           map[[2]string]*friendship     // by (user_id, friend_id)
+	comments          []*model.Comment
+	reports           []*report
+	reactions         []*model.Reaction
+	notifications     []*model.Notification
+	notificationPrefs map[string]model.NotificationPrefs // by user ID; only the types set
+	jobs              []*jobs.Job
+	tokens            map[string]*model.RefreshToken // by token hash
+	accounts          map[string]*model.AccountToken // by token hash
+	wake              chan struct{}
+}
+
+type user struct {
+	model.User
+	passwordHash string
+}
+
+type friendship struct {
+	status    string
+	createdAt time.Time
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		aliases:           map[string]string{},
+		revisions:         map[int][]model.DreamRevision{},
+		friends:           map[[2]string]*friendship{},
+		notificationPrefs: map[string]model.NotificationPrefs{},
+		tokens:            map[string]*model.RefreshToken{},
+		accounts:          map[string]*model.AccountToken{},
+		wake:              make(chan struct{}, 1),
+	}
+}
+
+// now returns the current time at the microsecond precision Postgres stores,
+// nudged forward when needed so every write gets a distinct timestamp and
+// listings have a stable order
+func (s *Store) now() time.Time {
+	t := time.Now().UTC().Truncate(time.Microsecond)
+	if !t.After(s.last) {
+		t = s.last.Add(time.Microsecond)
+	}
+	s.last = t
+	return t
+}
+
+// SetAdmin sets a user's is_admin flag. There is no API for granting admin
+// rights, so demos and tests use this instead of editing the database.
+func (s *Store) SetAdmin(userID string, isAdmin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.user(userID)
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.IsAdmin = isAdmin
+	return nil
+}
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func generateShortcode(length int) (string, error) {
+	b := make([]byte, length)
+	for i := range b {
+		n, err := crand.Int(crand.Reader, big.NewInt(int64(len(base62))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = base62[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// after reports whether a comes after b in (created_at, id) order
+func after(a, b model.Cursor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// trimPage cuts rows to the page size and returns the cursor of the
+// following page, or nil on the last page
+func trimPage(p model.Page, n int, cursorOf func(i int) model.Cursor) (int, *model.Cursor) {
+	if p.Limit <= 0 || n <= p.Limit {
+		return n, nil
+	}
+	next := cursorOf(p.Limit - 1)
+	return p.Limit, &next
+}
+
+func copyInt(v *int) *int {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
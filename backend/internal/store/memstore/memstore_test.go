@@ -0,0 +1,79 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store/storetest"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return New() })
+}
+
+// failingAI fails every call with err
+type failingAI struct {
+	ai.Fake
+	err error
+}
+
+func (f failingAI) ExtractTags(ctx context.Context, text string) ([]string, error) {
+	return nil, f.err
+}
+
+func TestProcessJobs(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	u := &model.User{Email: "ann@example.com", Username: "ann"}
+	if err := s.CreateUser(ctx, u, "hash"); err != nil {
+		t.Fatal(err)
+	}
+	d := &model.Dream{UserID: u.ID, Title: "Ocean", Text: "Swimming with whales.", Visibility: model.VisibilityPublic}
+	if err := s.CreateDream(ctx, d, jobs.KindTags, jobs.KindSummary); err != nil {
+		t.Fatal(err)
+	}
+	if ran := s.ProcessJobs(ctx, ai.Fake{}); ran != 2 {
+		t.Fatalf("ran %d jobs, want 2", ran)
+	}
+	got, _ := s.GetDream(ctx, d.ID)
+	if len(got.Tags) == 0 || got.Summary == "" || got.SummaryHash != ai.ContentHash(d.Text) {
+		t.Errorf("AI output not stored: %+v", got)
+	}
+	for _, j := range d.Jobs {
+		if job, err := s.Get(ctx, j.ID); err != nil || job.Status != jobs.StatusDone {
+			t.Errorf("job %d = %+v, %v", j.ID, job, err)
+		}
+	}
+	if ran := s.ProcessJobs(ctx, ai.Fake{}); ran != 0 {
+		t.Errorf("ran %d jobs on an empty queue", ran)
+	}
+
+	// An unconfigured AI kills the job at once; Retry revives it
+	job, err := s.Enqueue(ctx, jobs.KindTags, d.RowID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ProcessJobs(ctx, failingAI{err: ai.ErrNotConfigured})
+	dead, _ := s.Get(ctx, job.ID)
+	if dead.Status != jobs.StatusDead || dead.Attempts != 1 || dead.LastError == "" {
+		t.Errorf("job after ErrNotConfigured = %+v", dead)
+	}
+	if _, err := s.Retry(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Retry(ctx, job.ID); !errors.Is(err, jobs.ErrNotFound) {
+		t.Errorf("retrying a pending job: %v", err)
+	}
+
+	// Other failures are retried later
+	s.ProcessJobs(ctx, failingAI{err: errors.New("timeout")})
+	pending, _ := s.Get(ctx, job.ID)
+	if pending.Status != jobs.StatusPending || pending.Attempts != 1 {
+		t.Errorf("job after a transient failure = %+v", pending)
+	}
+}
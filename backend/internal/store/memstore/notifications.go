@@ -0,0 +1,159 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+// dropNotifications deletes the notifications drop matches. The caller
+// holds the lock.
+func (s *Store) dropNotifications(drop func(n *model.Notification) bool) {
+	kept := s.notifications[:0]
+	for _, n := range s.notifications {
+		if !drop(n) {
+			kept = append(kept, n)
+		}
+	}
+	s.notifications = kept
+}
+
+// viewNotification copies a stored notification and fills in its actor
+// and dream. The caller holds the lock.
+func (s *Store) viewNotification(n *model.Notification) model.Notification {
+	cp := *n
+	cp.Actor = s.summary(n.Actor.ID)
+	if d := s.dream(n.DreamRowID); d != nil {
+		cp.DreamID = d.ID
+	}
+	return cp
+}
+
+// prefs returns a user's preferences with the unset types on. The caller
+// holds the lock.
+func (s *Store) prefs(userID string) model.NotificationPrefs {
+	prefs := model.DefaultNotificationPrefs()
+	for t, on := range s.notificationPrefs[userID] {
+		prefs[t] = on
+	}
+	return prefs
+}
+
+func (s *Store) CreateNotification(ctx context.Context, n *model.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(n.UserID) == nil || s.user(n.Actor.ID) == nil {
+		return store.ErrNotFound
+	}
+	if n.DreamRowID != 0 && s.dream(n.DreamRowID) == nil {
+		return store.ErrNotFound
+	}
+	if n.CommentID != nil && s.comment(*n.CommentID) == nil {
+		return store.ErrNotFound
+	}
+	if !s.prefs(n.UserID)[n.Type] {
+		n.ID = 0
+		return nil
+	}
+	s.notificationSeq++
+	stored := *n
+	stored.ID = s.notificationSeq
+	stored.Actor = model.UserSummary{ID: n.Actor.ID}
+	stored.DreamID = ""
+	stored.Read = false
+	stored.CreatedAt = s.now()
+	s.notifications = append(s.notifications, &stored)
+	*n = s.viewNotification(&stored)
+	return nil
+}
+
+// visibleNotifications returns a user's notifications newest first,
+// leaving out those from users blocked either way. The caller holds the
+// lock.
+func (s *Store) visibleNotifications(userID string) []*model.Notification {
+	blocked := s.blockedIDs(userID)
+	var list []*model.Notification
+	for i := len(s.notifications) - 1; i >= 0; i-- {
+		n := s.notifications[i]
+		if n.UserID == userID && !blocked[n.Actor.ID] {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+func (s *Store) ListNotifications(ctx context.Context, userID string, unreadOnly bool, page model.Page) ([]model.Notification, *model.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notifications := []model.Notification{}
+	for _, n := range s.visibleNotifications(userID) {
+		if unreadOnly && n.Read {
+			continue
+		}
+		if page.After != nil && !after(*page.After, n.Cursor()) {
+			continue
+		}
+		notifications = append(notifications, s.viewNotification(n))
+	}
+	n, next := trimPage(page, len(notifications), func(i int) model.Cursor { return notifications[i].Cursor() })
+	return notifications[:n], next, nil
+}
+
+func (s *Store) MarkNotificationsRead(ctx context.Context, userID string, ids []int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := map[int]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	marked := 0
+	for _, n := range s.notifications {
+		if n.UserID == userID && !n.Read && (ids == nil || want[n.ID]) {
+			n.Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID string) (model.NotificationCounts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := model.NotificationCounts{}
+	for _, t := range model.NotificationTypes {
+		counts[t] = 0
+	}
+	for _, n := range s.visibleNotifications(userID) {
+		if !n.Read {
+			counts[n.Type]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *Store) NotificationPrefs(ctx context.Context, userID string) (model.NotificationPrefs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(userID) == nil {
+		return nil, store.ErrNotFound
+	}
+	return s.prefs(userID), nil
+}
+
+func (s *Store) SetNotificationPrefs(ctx context.Context, userID string, prefs model.NotificationPrefs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(userID) == nil {
+		return store.ErrNotFound
+	}
+	set := s.notificationPrefs[userID]
+	if set == nil {
+		set = model.NotificationPrefs{}
+		s.notificationPrefs[userID] = set
+	}
+	for t, on := range prefs {
+		set[t] = on
+	}
+	return nil
+}
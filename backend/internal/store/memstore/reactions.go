@@ -0,0 +1,108 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+// reactionCounts counts the reactions on target. The caller holds the lock.
+func (s *Store) reactionCounts(target model.ReactionTarget) model.ReactionCounts {
+	counts := model.NewReactionCounts()
+	for _, r := range s.reactions {
+		if r.Target == target {
+			counts[r.Kind]++
+		}
+	}
+	return counts
+}
+
+// dropReactions deletes the reactions drop matches. The caller holds the
+// lock.
+func (s *Store) dropReactions(drop func(r *model.Reaction) bool) {
+	kept := s.reactions[:0]
+	for _, r := range s.reactions {
+		if !drop(r) {
+			kept = append(kept, r)
+		}
+	}
+	s.reactions = kept
+}
+
+// targetExists reports whether the dream or comment a reaction is on
+// exists. The caller holds the lock.
+func (s *Store) targetExists(target model.ReactionTarget) bool {
+	if target.DreamRowID != 0 {
+		return target.CommentID == 0 && s.dream(target.DreamRowID) != nil
+	}
+	return s.comment(target.CommentID) != nil
+}
+
+func (s *Store) AddReaction(ctx context.Context, r *model.Reaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.targetExists(r.Target) || s.user(r.User.ID) == nil {
+		return store.ErrNotFound
+	}
+	for _, existing := range s.reactions {
+		if existing.Target == r.Target && existing.User.ID == r.User.ID && existing.Kind == r.Kind {
+			r.ID, r.CreatedAt = existing.ID, existing.CreatedAt
+			r.User = s.summary(r.User.ID)
+			return nil
+		}
+	}
+	s.reactionSeq++
+	stored := model.Reaction{
+		ID:        s.reactionSeq,
+		Kind:      r.Kind,
+		User:      model.UserSummary{ID: r.User.ID},
+		CreatedAt: s.now(),
+		Target:    r.Target,
+	}
+	s.reactions = append(s.reactions, &stored)
+	*r = stored
+	r.User = s.summary(r.User.ID)
+	return nil
+}
+
+func (s *Store) RemoveReaction(ctx context.Context, target model.ReactionTarget, userID string, kind model.ReactionKind) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropReactions(func(r *model.Reaction) bool {
+		return r.Target == target && r.User.ID == userID && r.Kind == kind
+	})
+	return nil
+}
+
+func (s *Store) CountReactions(ctx context.Context, target model.ReactionTarget) (model.ReactionCounts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.targetExists(target) {
+		return nil, store.ErrNotFound
+	}
+	return s.reactionCounts(target), nil
+}
+
+func (s *Store) ListReactions(ctx context.Context, target model.ReactionTarget, kind model.ReactionKind, viewerID string, page model.Page) ([]model.Reaction, *model.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocked := s.blockedIDs(viewerID)
+	// Newest first: walk the reactions, which are in creation order,
+	// backwards
+	reactions := []model.Reaction{}
+	for i := len(s.reactions) - 1; i >= 0; i-- {
+		r := s.reactions[i]
+		if r.Target != target || (kind != "" && r.Kind != kind) || blocked[r.User.ID] {
+			continue
+		}
+		if page.After != nil && !after(*page.After, r.Cursor()) {
+			continue
+		}
+		cp := *r
+		cp.User = s.summary(r.User.ID)
+		reactions = append(reactions, cp)
+	}
+	n, next := trimPage(page, len(reactions), func(i int) model.Cursor { return reactions[i].Cursor() })
+	return reactions[:n], next, nil
+}
@@ -0,0 +1,98 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+func stateOf(d *model.Dream) model.DreamState {
+	return model.DreamState{
+		Title:                    d.Title,
+		Text:                     d.Text,
+		Visibility:               d.Visibility,
+		NightmareRating:          copyInt(d.NightmareRating),
+		VividnessRating:          copyInt(d.VividnessRating),
+		ClarityRating:            copyInt(d.ClarityRating),
+		EmotionalIntensityRating: copyInt(d.EmotionalIntensityRating),
+	}
+}
+
+func revisionOf(revision int, st model.DreamState, editorID string, at time.Time) model.DreamRevision {
+	return model.DreamRevision{
+		Revision:                 revision,
+		Title:                    st.Title,
+		Text:                     st.Text,
+		Visibility:               st.Visibility,
+		NightmareRating:          copyInt(st.NightmareRating),
+		VividnessRating:          copyInt(st.VividnessRating),
+		ClarityRating:            copyInt(st.ClarityRating),
+		EmotionalIntensityRating: copyInt(st.EmotionalIntensityRating),
+		EditedBy:                 editorID,
+		CreatedAt:                at,
+	}
+}
+
+func (s *Store) EditDream(ctx context.Context, rowID int, editorID string, mutate func(*model.DreamState)) (*model.Dream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.dream(rowID)
+	if d == nil {
+		return nil, store.ErrNotFound
+	}
+	cur := stateOf(d)
+	next := stateOf(d)
+	mutate(&next)
+	if next.Equal(cur) {
+		return nil, store.ErrNoChanges
+	}
+
+	revs := s.revisions[rowID]
+	if len(revs) == 0 {
+		revs = append(revs, revisionOf(1, cur, d.UserID, d.UpdatedAt))
+	}
+	now := s.now()
+	s.revisions[rowID] = append(revs, revisionOf(len(revs)+1, next, editorID, now))
+
+	if next.Text != cur.Text {
+		d.Summary, d.SummaryHash, d.Prophecy = "", "", ""
+	}
+	d.Title, d.Text, d.Visibility = next.Title, next.Text, next.Visibility
+	d.NightmareRating = copyInt(next.NightmareRating)
+	d.VividnessRating = copyInt(next.VividnessRating)
+	d.ClarityRating = copyInt(next.ClarityRating)
+	d.EmotionalIntensityRating = copyInt(next.EmotionalIntensityRating)
+	d.UpdatedAt = now
+	c := s.view(d)
+	return &c, nil
+}
+
+func (s *Store) ListRevisions(ctx context.Context, rowID int) ([]model.DreamRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs := s.revisions[rowID]
+	list := make([]model.DreamRevision, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		list = append(list, revs[i])
+	}
+	return list, nil
+}
+
+func (s *Store) GetRevision(ctx context.Context, rowID, revision int) (*model.DreamRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs := s.revisions[rowID]
+	if revision < 1 || revision > len(revs) {
+		return nil, store.ErrNotFound
+	}
+	rev := revs[revision-1]
+	return &rev, nil
+}
+
+func (s *Store) LatestRevision(ctx context.Context, rowID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.revisions[rowID]), nil
+}
@@ -0,0 +1,263 @@
+package memstore
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+// snippetWords caps the text fragment returned with each search result
+const snippetWords = 35
+
+// stopwords are skipped in queries, as the english text search config does
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "i": true, "if": true, "in": true, "into": true, "is": true, "it": true,
+	"me": true, "my": true, "no": true, "not": true, "of": true, "on": true, "or": true, "so": true,
+	"that": true, "the": true, "their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "we": true, "were": true, "will": true, "with": true,
+}
+
+// stem is a crude English stemmer, close enough to Postgres' for "whale"
+// to match "whales" and "swim" to match "swimming"
+func stem(word string) string {
+	word = strings.ToLower(word)
+	for _, suffix := range []string{"ing", "ed"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			word = strings.TrimSuffix(word, suffix)
+			// swimm -> swim, but keep fall and kiss
+			if n := len(word); word[n-1] == word[n-2] && !strings.ContainsRune("lsz", rune(word[n-1])) {
+				word = word[:n-1]
+			}
+			return word
+		}
+	}
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "zes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && len(word) > 3:
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// words splits text into words, keeping the byte offsets of each one so
+// matches can be highlighted in place
+func words(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// query is a parsed websearch-style query: every include term must match
+// and no exclude term may. Quotes and OR are treated as plain words.
+type query struct {
+	include, exclude map[string]bool
+}
+
+func parseQuery(q string) query {
+	pq := query{include: map[string]bool{}, exclude: map[string]bool{}}
+	for _, field := range strings.Fields(q) {
+		dst := pq.include
+		if strings.HasPrefix(field, "-") {
+			dst = pq.exclude
+		}
+		for _, span := range words(field) {
+			w := strings.ToLower(field[span[0]:span[1]])
+			if !stopwords[w] {
+				dst[stem(w)] = true
+			}
+		}
+	}
+	return pq
+}
+
+// hits counts the words of text matching the query's include terms and
+// reports whether any exclude term appears
+func (q query) hits(text string) (map[string]int, bool) {
+	found := map[string]int{}
+	excluded := false
+	for _, span := range words(text) {
+		st := stem(text[span[0]:span[1]])
+		if q.include[st] {
+			found[st]++
+		}
+		if q.exclude[st] {
+			excluded = true
+		}
+	}
+	return found, excluded
+}
+
+// mark HTML-escapes text and wraps the words matching the query in <mark>
+// tags. With maxWords > 0 only a window of that many words around the first
+// match is kept.
+func (q query) mark(text string, maxWords int) string {
+	spans := words(text)
+	from, to := 0, len(spans)
+	if maxWords > 0 && len(spans) > maxWords {
+		first := 0
+		for i, span := range spans {
+			if q.include[stem(text[span[0]:span[1]])] {
+				first = i
+				break
+			}
+		}
+		from = first - maxWords/3
+		if from < 0 {
+			from = 0
+		}
+		if from+maxWords > len(spans) {
+			from = len(spans) - maxWords
+		}
+		to = from + maxWords
+	}
+	if len(spans) == 0 {
+		return html.EscapeString(text)
+	}
+	start, end := 0, len(text)
+	if from > 0 {
+		start = spans[from][0]
+	}
+	if to < len(spans) {
+		end = spans[to-1][1]
+	}
+	var b strings.Builder
+	pos := start
+	for _, span := range spans[from:to] {
+		if !q.include[stem(text[span[0]:span[1]])] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:span[0]]))
+		b.WriteString("<mark>" + html.EscapeString(text[span[0]:span[1]]) + "</mark>")
+		pos = span[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	return b.String()
+}
+
+// SearchDreams approximates the Postgres full-text search: words are
+// stemmed crudely, title matches rank above text matches and ties go to
+// newer dreams
+func (s *Store) SearchDreams(ctx context.Context, f model.SearchFilter) (*model.SearchResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := parseQuery(f.Query)
+	friends, blocked := s.friendIDs(f.ViewerID), s.blockedIDs(f.ViewerID)
+	listed := model.DreamFilter{Viewer: f.ViewerID}
+
+	type match struct {
+		d    *model.Dream
+		rank float64
+	}
+	var matches []match
+	for _, d := range s.dreams {
+		if blocked[d.UserID] {
+			continue
+		}
+		switch f.Scope {
+		case "mine":
+			if f.ViewerID == "" || d.UserID != f.ViewerID {
+				continue
+			}
+		case "friends":
+			if !friends[d.UserID] || !visible(d, listed, friends) {
+				continue
+			}
+		case "public":
+			if d.Visibility != model.VisibilityPublic {
+				continue
+			}
+		default:
+			if !visible(d, listed, friends) {
+				continue
+			}
+		}
+		if !s.matchesFilters(d, f) || len(q.include) == 0 {
+			continue
+		}
+		titleHits, titleExcluded := q.hits(d.Title)
+		textHits, textExcluded := q.hits(d.Text)
+		if titleExcluded || textExcluded {
+			continue
+		}
+		rank := 0.0
+		for term := range q.include {
+			if titleHits[term]+textHits[term] == 0 {
+				rank = -1
+				break
+			}
+			rank += float64(titleHits[term]) + 0.4*float64(textHits[term])
+		}
+		if rank < 0 {
+			continue
+		}
+		matches = append(matches, match{d, rank / 10})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank > matches[j].rank
+		}
+		return after(matches[i].d.Cursor(), matches[j].d.Cursor())
+	})
+
+	resp := &model.SearchResponse{Results: []model.SearchResult{}, Total: len(matches), Limit: f.Limit, Offset: f.Offset}
+	for i := f.Offset; i < len(matches) && len(resp.Results) < f.Limit; i++ {
+		m := matches[i]
+		resp.Results = append(resp.Results, model.SearchResult{
+			Dream:          s.view(m.d),
+			Rank:           m.rank,
+			TitleHighlight: q.mark(m.d.Title, 0),
+			Snippet:        q.mark(m.d.Text, snippetWords),
+		})
+	}
+	return resp, nil
+}
+
+// matchesFilters applies the tag, date and rating filters of a search
+func (s *Store) matchesFilters(d *model.Dream, f model.SearchFilter) bool {
+	if !inRange(d.CreatedAt, f.Dates) {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		have := map[string]bool{}
+		for _, name := range distinctTags(d) {
+			have[name] = true
+		}
+		for _, tag := range f.Tags {
+			if !have[normalizeTag(tag)] {
+				return false
+			}
+		}
+	}
+	for i, v := range ratings(d) {
+		name := model.RatingNames[i]
+		if min, ok := f.RatingMin[name]; ok && (v == nil || *v < min) {
+			return false
+		}
+		if max, ok := f.RatingMax[name]; ok && (v == nil || *v > max) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,292 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+// defaultFrequencyDays is the dreamFrequency window used when no range is given
+const defaultFrequencyDays = 30
+
+// inRange reports whether t falls in the [From, To) range
+func inRange(t time.Time, dr model.DateRange) bool {
+	return (dr.From == nil || !t.Before(*dr.From)) && (dr.To == nil || t.Before(*dr.To))
+}
+
+// day truncates t to midnight UTC, the equivalent of created_at::date
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// truncate mirrors date_trunc for the day, week (starting Monday) and month
+// units
+func truncate(t time.Time, unit string) time.Time {
+	d := day(t)
+	switch unit {
+	case "week":
+		return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return d
+}
+
+// countPer groups times by truncate(unit), oldest period first
+func countPer(times []time.Time, unit string) []model.PeriodCount {
+	counts := map[time.Time]int{}
+	for _, t := range times {
+		counts[truncate(t, unit)]++
+	}
+	periods := []model.PeriodCount{}
+	for p, n := range counts {
+		periods = append(periods, model.PeriodCount{Period: p, Count: n})
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Period.Before(periods[j].Period) })
+	return periods
+}
+
+// ratings returns the dream's ratings in model.RatingNames order
+func ratings(d *model.Dream) [4]*int {
+	return [4]*int{d.NightmareRating, d.VividnessRating, d.ClarityRating, d.EmotionalIntensityRating}
+}
+
+// normalizeTag is the LOWER(TRIM(tag)) used to compare tags
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// distinctTags returns the dream's normalized tags without duplicates or
+// blanks
+func distinctTags(d *model.Dream) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, tag := range d.Tags {
+		name := normalizeTag(tag)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+func (s *Store) DreamStats(ctx context.Context, userID string, dr model.DateRange, topTags int) (*model.DreamStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := &model.DreamStats{
+		MostCommonTags: []model.TagCount{},
+		Ratings:        map[string]model.RatingStats{},
+		From:           dr.From,
+		To:             dr.To,
+	}
+	var times []time.Time
+	var sums [4]int
+	tagCounts := map[string]int{}
+	for _, name := range model.RatingNames {
+		stats.Ratings[name] = model.RatingStats{}
+	}
+	for _, d := range s.dreams {
+		if d.UserID != userID || !inRange(d.CreatedAt, dr) {
+			continue
+		}
+		stats.TotalDreams++
+		if d.Visibility == model.VisibilityPublic {
+			stats.PublicDreams++
+		}
+		times = append(times, d.CreatedAt)
+		for i, v := range ratings(d) {
+			if v == nil {
+				continue
+			}
+			rs := stats.Ratings[model.RatingNames[i]]
+			rs.Count++
+			sums[i] += *v
+			if *v >= 1 && *v <= 10 {
+				rs.Histogram[*v-1]++
+			}
+			stats.Ratings[model.RatingNames[i]] = rs
+		}
+		for _, name := range distinctTags(d) {
+			tagCounts[name]++
+		}
+	}
+	stats.PrivateDreams = stats.TotalDreams - stats.PublicDreams
+	for i, name := range model.RatingNames {
+		rs := stats.Ratings[name]
+		if rs.Count > 0 {
+			avg := float64(sums[i]) / float64(rs.Count)
+			rs.Average = &avg
+		}
+		stats.Ratings[name] = rs
+	}
+
+	stats.DreamsPerDay = countPer(times, "day")
+	stats.DreamsPerWeek = countPer(times, "week")
+	stats.DreamsPerMonth = countPer(times, "month")
+
+	// Daily frequency series with empty days filled in
+	perDay := map[time.Time]int{}
+	for _, t := range times {
+		perDay[day(t)]++
+	}
+	freqFrom, freqTo := frequencyWindow(dr)
+	stats.DreamFrequency = []int{}
+	for d := day(freqFrom); !d.After(day(freqTo)); d = d.AddDate(0, 0, 1) {
+		stats.DreamFrequency = append(stats.DreamFrequency, perDay[d])
+	}
+
+	// Most common tags, case-insensitively
+	for name, n := range tagCounts {
+		stats.MostCommonTags = append(stats.MostCommonTags, model.TagCount{ID: name, Name: name, Count: n})
+	}
+	sortTagCounts(stats.MostCommonTags)
+	if len(stats.MostCommonTags) > topTags {
+		stats.MostCommonTags = stats.MostCommonTags[:topTags]
+	}
+
+	stats.Streak = streaks(perDay)
+	return stats, nil
+}
+
+// frequencyWindow picks the inclusive day range for dreamFrequency: the
+// requested range, or the last defaultFrequencyDays days ending today. It
+// is cut to the last model.MaxFrequencyDays days so a huge range cannot
+// build a huge series.
+func frequencyWindow(dr model.DateRange) (time.Time, time.Time) {
+	to := time.Now().UTC()
+	if dr.To != nil {
+		// To is exclusive, the series end is inclusive
+		to = dr.To.Add(-time.Nanosecond)
+	}
+	from := to.AddDate(0, 0, -(defaultFrequencyDays - 1))
+	if dr.From != nil {
+		from = *dr.From
+	}
+	if earliest := to.AddDate(0, 0, -(model.MaxFrequencyDays - 1)); from.Before(earliest) {
+		from = earliest
+	}
+	return from, to
+}
+
+// streaks finds runs of consecutive days. The current streak is the most
+// recent run if it reaches today or yesterday.
+func streaks(perDay map[time.Time]int) model.StreakStats {
+	var days []time.Time
+	for d := range perDay {
+		days = append(days, d)
+	}
+	if len(days) == 0 {
+		return model.StreakStats{}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	var st model.StreakStats
+	run := 0
+	for i, d := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > st.Longest {
+			st.Longest = run
+		}
+	}
+	last := days[len(days)-1]
+	st.LastDreamOn = &last
+	if !last.Before(day(time.Now()).AddDate(0, 0, -1)) {
+		st.Current = run
+	}
+	return st
+}
+
+// sortTagCounts orders tags by count, then name
+func sortTagCounts(tags []model.TagCount) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+}
+
+func (s *Store) TagAnalytics(ctx context.Context, userID string, includePrivate bool, dr model.DateRange, limit, related int) ([]model.TagAnalytics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := map[string]*model.TagAnalytics{}
+	var tagged []*model.Dream
+	for _, d := range s.dreams {
+		if d.UserID != userID || !inRange(d.CreatedAt, dr) || (!includePrivate && d.Visibility != model.VisibilityPublic) {
+			continue
+		}
+		tagged = append(tagged, d)
+		for _, name := range distinctTags(d) {
+			ta := usage[name]
+			if ta == nil {
+				ta = &model.TagAnalytics{ID: name, Name: name, FirstSeen: d.CreatedAt, LastSeen: d.CreatedAt}
+				usage[name] = ta
+			}
+			ta.Count++
+			if d.CreatedAt.Before(ta.FirstSeen) {
+				ta.FirstSeen = d.CreatedAt
+			}
+			if d.CreatedAt.After(ta.LastSeen) {
+				ta.LastSeen = d.CreatedAt
+			}
+		}
+	}
+	tags := []model.TagAnalytics{}
+	for _, ta := range usage {
+		tags = append(tags, *ta)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	for i := range tags {
+		name := tags[i].Name
+		pairs := map[string]int{}
+		var times []time.Time
+		for _, d := range tagged {
+			names := distinctTags(d)
+			has := false
+			for _, n := range names {
+				if n == name {
+					has = true
+					break
+				}
+			}
+			if !has {
+				continue
+			}
+			times = append(times, d.CreatedAt)
+			for _, n := range names {
+				if n != name {
+					pairs[n]++
+				}
+			}
+		}
+		tags[i].CoOccurring = []model.TagCount{}
+		if related > 0 {
+			for n, count := range pairs {
+				tags[i].CoOccurring = append(tags[i].CoOccurring, model.TagCount{ID: n, Name: n, Count: count})
+			}
+			sortTagCounts(tags[i].CoOccurring)
+			if len(tags[i].CoOccurring) > related {
+				tags[i].CoOccurring = tags[i].CoOccurring[:related]
+			}
+		}
+		tags[i].Trend = countPer(times, "month")
+	}
+	return tags, nil
+}
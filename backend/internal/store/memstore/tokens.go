@@ -0,0 +1,140 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+// copyToken returns a copy of t that shares no pointers with it
+func copyToken(t *model.RefreshToken) *model.RefreshToken {
+	c := *t
+	if t.UsedAt != nil {
+		usedAt := *t.UsedAt
+		c.UsedAt = &usedAt
+	}
+	if t.RevokedAt != nil {
+		revokedAt := *t.RevokedAt
+		c.RevokedAt = &revokedAt
+	}
+	return &c
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, t *model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(t.UserID) == nil {
+		return store.ErrNotFound
+	}
+	s.tokenSeq++
+	t.ID = s.tokenSeq
+	t.CreatedAt = s.now()
+	t.UsedAt, t.RevokedAt = nil, nil
+	s.tokens[t.TokenHash] = copyToken(t)
+	return nil
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyToken(t), nil
+}
+
+func (s *Store) UseRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if t.UsedAt != nil || t.RevokedAt != nil {
+		s.revokeFamily(t.FamilyID)
+		return nil, store.ErrRevoked
+	}
+	now := s.now()
+	t.UsedAt = &now
+	return copyToken(t), nil
+}
+
+func (s *Store) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeFamily(familyID)
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, t := range s.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			revokedAt := now
+			t.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// revokeFamily revokes the family's unrevoked tokens. The caller holds the
+// lock.
+func (s *Store) revokeFamily(familyID string) {
+	now := s.now()
+	for _, t := range s.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			revokedAt := now
+			t.RevokedAt = &revokedAt
+		}
+	}
+}
+
+// copyAccountToken returns a copy of t that shares no pointers with it
+func copyAccountToken(t *model.AccountToken) *model.AccountToken {
+	c := *t
+	if t.UsedAt != nil {
+		usedAt := *t.UsedAt
+		c.UsedAt = &usedAt
+	}
+	return &c
+}
+
+func (s *Store) CreateAccountToken(ctx context.Context, t *model.AccountToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user(t.UserID) == nil {
+		return store.ErrNotFound
+	}
+	now := s.now()
+	for _, old := range s.accounts {
+		if old.UserID == t.UserID && old.Purpose == t.Purpose && old.UsedAt == nil {
+			usedAt := now
+			old.UsedAt = &usedAt
+		}
+	}
+	s.accountSeq++
+	t.ID = s.accountSeq
+	t.CreatedAt = now
+	t.UsedAt = nil
+	s.accounts[t.TokenHash] = copyAccountToken(t)
+	return nil
+}
+
+func (s *Store) UseAccountToken(ctx context.Context, purpose, hash string) (*model.AccountToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.accounts[hash]
+	if !ok || t.Purpose != purpose {
+		return nil, store.ErrNotFound
+	}
+	if t.UsedAt != nil {
+		return nil, store.ErrRevoked
+	}
+	now := s.now()
+	t.UsedAt = &now
+	return copyAccountToken(t), nil
+}
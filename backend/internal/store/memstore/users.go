@@ -0,0 +1,159 @@
+package memstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+)
+
+func (s *Store) user(id string) *user {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+// summary returns the short form of a user shown on comments and friend lists
+func (s *Store) summary(id string) model.UserSummary {
+	u := s.user(id)
+	if u == nil {
+		return model.UserSummary{ID: id}
+	}
+	return model.UserSummary{ID: u.ID, Username: u.Username, DisplayName: u.DisplayName, ProfileImageURL: u.ProfileImageURL}
+}
+
+// usernameTaken reports whether username belongs to, or was given up by,
+// someone other than id. The caller holds the lock.
+func (s *Store) usernameTaken(username, id string) bool {
+	for _, u := range s.users {
+		if u.ID != id && strings.EqualFold(u.Username, username) {
+			return true
+		}
+	}
+	owner, ok := s.aliases[strings.ToLower(username)]
+	return ok && owner != id
+}
+
+func (s *Store) CreateUser(ctx context.Context, u *model.User, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.users {
+		if existing.Email == u.Email {
+			return store.ErrConflict
+		}
+	}
+	if s.usernameTaken(u.Username, "") {
+		return store.ErrConflict
+	}
+	s.userSeq++
+	created := model.User{
+		ID:          strconv.Itoa(s.userSeq),
+		Email:       u.Email,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		CreatedAt:   s.now().Unix(),
+	}
+	s.users = append(s.users, &user{User: created, passwordHash: passwordHash})
+	*u = created
+	return nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.user(id); u != nil {
+		c := u.User
+		return &c, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, username) {
+			c := u.User
+			return &c, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) GetUserByAlias(ctx context.Context, username string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.user(s.aliases[strings.ToLower(username)]); u != nil {
+		c := u.User
+		return &c, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) RenameUser(ctx context.Context, id, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.user(id)
+	if u == nil {
+		return store.ErrNotFound
+	}
+	if s.usernameTaken(username, id) {
+		return store.ErrConflict
+	}
+	delete(s.aliases, strings.ToLower(username))
+	if !strings.EqualFold(u.Username, username) {
+		s.aliases[strings.ToLower(u.Username)] = id
+	}
+	u.Username = username
+	return nil
+}
+
+func (s *Store) GetCredentials(ctx context.Context, email string) (*model.User, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			c := u.User
+			return &c, u.passwordHash, nil
+		}
+	}
+	return nil, "", store.ErrNotFound
+}
+
+func (s *Store) UpdateProfile(ctx context.Context, id, displayName, description, profileImageURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.user(id)
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.DisplayName, u.Description, u.ProfileImageURL = displayName, description, profileImageURL
+	return nil
+}
+
+func (s *Store) SetPassword(ctx context.Context, id, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.user(id)
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.passwordHash = passwordHash
+	return nil
+}
+
+func (s *Store) SetEmailVerified(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.user(id)
+	if u == nil {
+		return store.ErrNotFound
+	}
+	u.EmailVerified = true
+	return nil
+}
@@ -0,0 +1,230 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const commentSelect = `SELECT c.id, c.dream_id, c.parent_id, c.text, c.edited, c.created_at, c.updated_at, u.id::text, u.username, u.display_name, u.profile_image_url
+	FROM comments c
+	JOIN users u ON u.id = c.user_id `
+
+// commentRow holds the nullable columns of a comment while scanning
+type commentRow struct {
+	parentID                     sql.NullInt32
+	displayName, profileImageURL sql.NullString
+}
+
+// dest returns the scan targets for the comment columns of commentSelect
+func (cr *commentRow) dest(c *model.Comment) []interface{} {
+	return []interface{}{&c.ID, &c.DreamRowID, &cr.parentID, &c.Text, &c.Edited, &c.CreatedAt, &c.UpdatedAt,
+		&c.User.ID, &c.User.Username, &cr.displayName, &cr.profileImageURL}
+}
+
+// fill copies the nullable columns into c
+func (cr *commentRow) fill(c *model.Comment) {
+	c.ParentID = nullIntPtr(cr.parentID)
+	c.User.DisplayName = cr.displayName.String
+	c.User.ProfileImageURL = cr.profileImageURL.String
+}
+
+func scanComment(row pgx.Row) (*model.Comment, error) {
+	var c model.Comment
+	var cr commentRow
+	if err := row.Scan(cr.dest(&c)...); err != nil {
+		return nil, err
+	}
+	cr.fill(&c)
+	return &c, nil
+}
+
+func (s *Store) CreateComment(ctx context.Context, c *model.Comment) error {
+	// A reply must be on the same dream as its parent
+	var id int
+	err := s.pool.QueryRow(ctx, `INSERT INTO comments (dream_id, user_id, parent_id, text, created_at, updated_at)
+		SELECT $1, $2, $3, $4, NOW(), NOW()
+		WHERE $3::int IS NULL OR EXISTS (SELECT 1 FROM comments WHERE id = $3 AND dream_id = $1)
+		RETURNING id`,
+		c.DreamRowID, c.User.ID, c.ParentID, c.Text).Scan(&id)
+	if err != nil {
+		return notFound(err)
+	}
+	created, err := s.GetComment(ctx, id)
+	if err != nil {
+		return err
+	}
+	*c = *created
+	return nil
+}
+
+func (s *Store) GetComment(ctx context.Context, id int) (*model.Comment, error) {
+	c, err := scanComment(s.pool.QueryRow(ctx, commentSelect+"WHERE c.id=$1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	comments := []model.Comment{*c}
+	if err := s.attachCommentReactions(ctx, comments); err != nil {
+		return nil, err
+	}
+	return &comments[0], nil
+}
+
+func (s *Store) ListComments(ctx context.Context, dreamRowID int, viewerID string, page model.Page) ([]model.Comment, *model.Cursor, error) {
+	// Comments read oldest first, so the cursor moves forward in time
+	args := []interface{}{dreamRowID}
+	where := "WHERE c.dream_id=$1 AND "
+	if viewerID != "" {
+		args = append(args, viewerID)
+		where += notBlocked("c.user_id", "$2") + " AND "
+	}
+	rows, err := s.pool.Query(ctx, commentSelect+where+
+		keyset(page, "c.created_at", "c.id", false, &args)+" ORDER BY c.created_at ASC, c.id ASC "+limitClause(page), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	comments := []model.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		comments = append(comments, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	rows.Close()
+	n, next := trimPage(page, len(comments), func(i int) model.Cursor { return comments[i].Cursor() })
+	comments = comments[:n]
+	if err := s.attachCommentReactions(ctx, comments); err != nil {
+		return nil, nil, err
+	}
+	return comments, next, nil
+}
+
+func (s *Store) CountComments(ctx context.Context, dreamRowIDs []int) (map[int]int, error) {
+	rows, err := s.pool.Query(ctx, "SELECT dream_id, COUNT(*) FROM comments WHERE dream_id = ANY($1) GROUP BY dream_id", dreamRowIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[int]int, len(dreamRowIDs))
+	for rows.Next() {
+		var dreamID, n int
+		if err := rows.Scan(&dreamID, &n); err != nil {
+			return nil, err
+		}
+		counts[dreamID] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *Store) UpdateComment(ctx context.Context, id int, text string) (*model.Comment, error) {
+	tag, err := s.pool.Exec(ctx, "UPDATE comments SET text=$2, edited=TRUE, updated_at=NOW() WHERE id=$1", id, text)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, store.ErrNotFound
+	}
+	return s.GetComment(ctx, id)
+}
+
+func (s *Store) DeleteComment(ctx context.Context, id int) error {
+	// Replies and reports go with the comment through ON DELETE CASCADE
+	tag, err := s.pool.Exec(ctx, "DELETE FROM comments WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// reportSelect joins a report with its reporter, the reported comment and
+// its author, and the dream's public ID
+const reportSelect = `SELECT r.id, r.reason, r.created_at, r.resolved_at, ru.id::text, ru.username, ru.display_name, ru.profile_image_url, d.public_id,
+	c.id, c.dream_id, c.parent_id, c.text, c.edited, c.created_at, c.updated_at, u.id::text, u.username, u.display_name, u.profile_image_url
+	FROM comment_reports r
+	JOIN users ru ON ru.id = r.reporter_id
+	JOIN comments c ON c.id = r.comment_id
+	JOIN users u ON u.id = c.user_id
+	JOIN dreams d ON d.id = c.dream_id `
+
+func scanReport(row pgx.Row) (*model.CommentReport, error) {
+	var r model.CommentReport
+	var reporterName, reporterImage sql.NullString
+	var cr commentRow
+	dest := []interface{}{&r.ID, &r.Reason, &r.CreatedAt, &r.ResolvedAt, &r.Reporter.ID, &r.Reporter.Username, &reporterName, &reporterImage, &r.DreamID}
+	if err := row.Scan(append(dest, cr.dest(&r.Comment)...)...); err != nil {
+		return nil, err
+	}
+	r.Reporter.DisplayName = reporterName.String
+	r.Reporter.ProfileImageURL = reporterImage.String
+	cr.fill(&r.Comment)
+	return &r, nil
+}
+
+func (s *Store) ReportComment(ctx context.Context, r *model.CommentReport) error {
+	var id int
+	err := s.pool.QueryRow(ctx, `INSERT INTO comment_reports (comment_id, reporter_id, reason, created_at)
+		SELECT $1, $2, $3, NOW()
+		WHERE EXISTS (SELECT 1 FROM comments WHERE id = $1) AND EXISTS (SELECT 1 FROM users WHERE id = $2)
+		RETURNING id`,
+		r.Comment.ID, r.Reporter.ID, r.Reason).Scan(&id)
+	if err != nil {
+		return uniqueViolation(notFound(err))
+	}
+	created, err := s.GetReport(ctx, id)
+	if err != nil {
+		return err
+	}
+	*r = *created
+	return nil
+}
+
+func (s *Store) GetReport(ctx context.Context, id int) (*model.CommentReport, error) {
+	r, err := scanReport(s.pool.QueryRow(ctx, reportSelect+"WHERE r.id=$1", id))
+	return r, notFound(err)
+}
+
+func (s *Store) ListOpenReports(ctx context.Context, page model.Page) ([]model.CommentReport, *model.Cursor, error) {
+	var args []interface{}
+	rows, err := s.pool.Query(ctx, reportSelect+"WHERE r.resolved_at IS NULL AND "+
+		keyset(page, "r.created_at", "r.id", false, &args)+" ORDER BY r.created_at ASC, r.id ASC "+limitClause(page), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	reports := []model.CommentReport{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		reports = append(reports, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	n, next := trimPage(page, len(reports), func(i int) model.Cursor { return reports[i].Cursor() })
+	return reports[:n], next, nil
+}
+
+func (s *Store) DismissReports(ctx context.Context, commentID int, moderatorID string) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE comment_reports SET resolved_at=NOW(), resolved_by=$2 WHERE comment_id=$1 AND resolved_at IS NULL", commentID, moderatorID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package pgstore
+
+import (
+	"context"
+	crand "crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// dreamSelect is the query behind every method that returns dreams. Callers
+// append a WHERE clause over the d (dreams) and u (users) aliases plus any
+// ORDER BY and LIMIT.
+const dreamSelect = `SELECT d.id, d.public_id, d.user_id, u.username, u.display_name, u.profile_image_url, d.title, d.text, d.visibility, d.created_at, d.updated_at,
+	d.nightmare_rating, d.vividness_rating, d.clarity_rating, d.emotional_intensity_rating, d.summary, d.summary_hash, d.prophecy
+	FROM dreams d
+	JOIN users u ON u.id = d.user_id `
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func generateShortcode(length int) (string, error) {
+	b := make([]byte, length)
+	for i := range b {
+		n, err := crand.Int(crand.Reader, big.NewInt(int64(len(base62))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = base62[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// loadDreams runs dreamSelect with the given clauses and attaches tags and
+// reaction counts, so a page of dreams costs three queries however many rows
+// it has
+func (s *Store) loadDreams(ctx context.Context, clauses string, args ...interface{}) ([]model.Dream, error) {
+	rows, err := s.pool.Query(ctx, dreamSelect+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dreams := []model.Dream{}
+	for rows.Next() {
+		d, err := scanDream(rows)
+		if err != nil {
+			return nil, err
+		}
+		dreams = append(dreams, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if err := s.attachTags(ctx, dreams); err != nil {
+		return nil, err
+	}
+	if err := s.attachDreamReactions(ctx, dreams); err != nil {
+		return nil, err
+	}
+	return dreams, nil
+}
+
+func (s *Store) loadDream(ctx context.Context, clause string, arg interface{}) (*model.Dream, error) {
+	dreams, err := s.loadDreams(ctx, clause, arg)
+	if err != nil {
+		return nil, err
+	}
+	if len(dreams) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return &dreams[0], nil
+}
+
+func scanDream(row pgx.Row) (model.Dream, error) {
+	var d model.Dream
+	var title, displayName, profileImageURL, summary, summaryHash, prophecy sql.NullString
+	var nightmareRating, vividnessRating, clarityRating, emotionalIntensityRating sql.NullInt32
+	if err := row.Scan(&d.RowID, &d.ID, &d.UserID, &d.Username, &displayName, &profileImageURL, &title, &d.Text, &d.Visibility, &d.CreatedAt, &d.UpdatedAt,
+		&nightmareRating, &vividnessRating, &clarityRating, &emotionalIntensityRating, &summary, &summaryHash, &prophecy); err != nil {
+		return d, err
+	}
+	d.Title = title.String
+	d.DisplayName = displayName.String
+	d.ProfileImageURL = profileImageURL.String
+	d.NightmareRating = nullIntPtr(nightmareRating)
+	d.VividnessRating = nullIntPtr(vividnessRating)
+	d.ClarityRating = nullIntPtr(clarityRating)
+	d.EmotionalIntensityRating = nullIntPtr(emotionalIntensityRating)
+	d.Summary = summary.String
+	d.SummaryHash = summaryHash.String
+	d.Prophecy = prophecy.String
+	return d, nil
+}
+
+// attachTags fills in Tags for every dream with a single query
+func (s *Store) attachTags(ctx context.Context, dreams []model.Dream) error {
+	if len(dreams) == 0 {
+		return nil
+	}
+	ids := make([]int, len(dreams))
+	for i, d := range dreams {
+		ids[i] = d.RowID
+	}
+	tags, err := s.loadTagsBatch(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range dreams {
+		dreams[i].Tags = tags[dreams[i].RowID]
+		if dreams[i].Tags == nil {
+			dreams[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// loadTagsBatch returns the tags of each dream row, keyed by row ID
+func (s *Store) loadTagsBatch(ctx context.Context, dreamIDs []int) (map[int][]string, error) {
+	rows, err := s.pool.Query(ctx, "SELECT dream_id, tag FROM dream_tags WHERE dream_id = ANY($1) ORDER BY id", dreamIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make(map[int][]string, len(dreamIDs))
+	for rows.Next() {
+		var dreamID int
+		var tag string
+		if err := rows.Scan(&dreamID, &tag); err != nil {
+			return nil, err
+		}
+		tags[dreamID] = append(tags[dreamID], tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *Store) CreateDream(ctx context.Context, d *model.Dream, jobKinds ...jobs.Kind) error {
+	var shortcode string
+	for {
+		sc, err := generateShortcode(10)
+		if err != nil {
+			return err
+		}
+		var exists bool
+		if err := s.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM dreams WHERE public_id=$1)", sc).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			shortcode = sc
+			break
+		}
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+	if d.Visibility == "" {
+		d.Visibility = model.VisibilityPrivate
+	}
+	now := time.Now()
+	err = tx.QueryRow(ctx,
+		"INSERT INTO dreams (user_id, title, text, visibility, created_at, updated_at, public_id, nightmare_rating, vividness_rating, clarity_rating, emotional_intensity_rating) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id",
+		d.UserID, d.Title, d.Text, d.Visibility, now, now, shortcode,
+		d.NightmareRating, d.VividnessRating, d.ClarityRating, d.EmotionalIntensityRating,
+	).Scan(&d.RowID)
+	if err != nil {
+		return err
+	}
+	d.Jobs = nil
+	for _, kind := range jobKinds {
+		job, err := jobs.Enqueue(ctx, tx, kind, d.RowID)
+		if err != nil {
+			return err
+		}
+		d.Jobs = append(d.Jobs, job)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	d.ID = shortcode
+	d.CreatedAt = now
+	d.UpdatedAt = now
+	d.Tags = []string{}
+	d.Reactions = model.NewReactionCounts()
+	return nil
+}
+
+func (s *Store) GetDream(ctx context.Context, publicID string) (*model.Dream, error) {
+	return s.loadDream(ctx, "WHERE d.public_id=$1", publicID)
+}
+
+func (s *Store) GetDreamByRowID(ctx context.Context, rowID int) (*model.Dream, error) {
+	return s.loadDream(ctx, "WHERE d.id=$1", rowID)
+}
+
+func (s *Store) ListDreams(ctx context.Context, f model.DreamFilter) ([]model.Dream, *model.Cursor, error) {
+	var args []interface{}
+	var conds []string
+	if f.Owners != nil {
+		args = append(args, f.Owners)
+		conds = append(conds, "d.user_id = ANY($1)")
+	}
+	if f.Viewer == "" {
+		conds = append(conds, "d.visibility='public'")
+	} else {
+		args = append(args, f.Viewer)
+		viewer := fmt.Sprintf("$%d", len(args))
+		if f.PublicOnly {
+			conds = append(conds, "d.visibility='public'")
+		} else {
+			conds = append(conds, listed(viewer))
+		}
+		conds = append(conds, notBlocked("d.user_id", viewer))
+	}
+	conds = append(conds, keyset(f.Page, "d.created_at", "d.id", true, &args))
+	dreams, err := s.loadDreams(ctx,
+		"WHERE "+strings.Join(conds, " AND ")+" ORDER BY d.created_at DESC, d.id DESC "+limitClause(f.Page), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, next := trimPage(f.Page, len(dreams), func(i int) model.Cursor { return dreams[i].Cursor() })
+	return dreams[:n], next, nil
+}
+
+func (s *Store) PopularDreams(ctx context.Context, viewerID string, since, until time.Time, limit int) ([]model.Dream, error) {
+	args := []interface{}{since, until}
+	where := "WHERE d.visibility='public' AND d.created_at >= $1 AND d.created_at <= $2"
+	if viewerID != "" {
+		args = append(args, viewerID)
+		where += " AND " + notBlocked("d.user_id", "$3")
+	}
+	clauses := where + ` ORDER BY (SELECT COUNT(*) FROM comments c WHERE c.dream_id = d.id) +
+		(SELECT COUNT(*) FROM reactions r WHERE r.dream_id = d.id) DESC, d.created_at DESC, d.id DESC`
+	if limit > 0 {
+		clauses += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	return s.loadDreams(ctx, clauses, args...)
+}
+
+// listed is a SQL condition that holds for the dreams a listing shows the
+// viewer: public ones, their own, and friends-only ones by their friends
+func listed(viewerExpr string) string {
+	return fmt.Sprintf("(d.visibility = 'public' OR d.user_id = %[1]s OR "+
+		"(d.visibility = 'friends' AND d.user_id IN (SELECT friend_id FROM friends WHERE user_id = %[1]s AND status = 'accepted')))", viewerExpr)
+}
+
+func (s *Store) DeleteDream(ctx context.Context, rowID int) error {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM dreams WHERE id=$1", rowID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) ReplaceTags(ctx context.Context, rowID int, tags []string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+	if _, err := tx.Exec(ctx, "DELETE FROM dream_tags WHERE dream_id=$1", rowID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			continue
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO dream_tags (dream_id, tag) VALUES ($1, $2)", rowID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Store) SetSummary(ctx context.Context, rowID int, text, summary string) error {
+	// Skip the write if the dream was edited while the model was running
+	_, err := s.pool.Exec(ctx, "UPDATE dreams SET summary=$1, summary_hash=$2 WHERE id=$3 AND text=$4",
+		summary, ai.ContentHash(text), rowID, text)
+	return err
+}
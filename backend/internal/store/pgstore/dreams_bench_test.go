@@ -0,0 +1,133 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The benchmarks need a migrated Postgres database:
+//
+//	TEST_DATABASE_URL=postgres://... go test ./internal/store/pgstore -run '^$' -bench LoadDreams
+//
+// They seed a throwaway user with benchDreams dreams and delete it afterwards.
+const (
+	benchDreams       = 100
+	benchTagsPerDream = 3
+)
+
+func setupBenchDB(b *testing.B) (*Store, string) {
+	b.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		b.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(pool.Close)
+
+	suffix := time.Now().UnixNano()
+	var userID string
+	err = pool.QueryRow(ctx,
+		"INSERT INTO users (email, username, password_hash) VALUES ($1, $2, 'x') RETURNING id::text",
+		fmt.Sprintf("bench-%d@example.com", suffix), fmt.Sprintf("bench%d", suffix)).Scan(&userID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		pool.Exec(context.Background(), "DELETE FROM users WHERE id=$1", userID)
+	})
+	for i := 0; i < benchDreams; i++ {
+		var dreamID int
+		err := pool.QueryRow(ctx,
+			"INSERT INTO dreams (user_id, title, text, public_id) VALUES ($1, $2, $3, $4) RETURNING id",
+			userID, fmt.Sprintf("Dream %d", i), "I was flying over a city made of glass", fmt.Sprintf("b%d-%d", suffix%1e6, i)).Scan(&dreamID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for t := 0; t < benchTagsPerDream; t++ {
+			if _, err := pool.Exec(ctx, "INSERT INTO dream_tags (dream_id, tag) VALUES ($1, $2)", dreamID, fmt.Sprintf("tag%d", t)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return New(pool), userID
+}
+
+// loadDreamsNPlusOne is how GET /api/dreams used to load dreams: one query
+// for the list, then a public_id lookup and a tag query per row
+func loadDreamsNPlusOne(ctx context.Context, pool *pgxpool.Pool, userID string) ([]model.Dream, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT d.public_id, d.user_id, u.username, d.title, d.text, d.visibility, d.created_at, d.updated_at
+		 FROM dreams d JOIN users u ON d.user_id = u.id WHERE d.user_id=$1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var dreams []model.Dream
+	for rows.Next() {
+		var d model.Dream
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Username, &d.Title, &d.Text, &d.Visibility, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		dreams = append(dreams, d)
+	}
+	rows.Close()
+	for i := range dreams {
+		var rowID int
+		if err := pool.QueryRow(ctx, "SELECT id FROM dreams WHERE public_id=$1", dreams[i].ID).Scan(&rowID); err != nil {
+			return nil, err
+		}
+		tagRows, err := pool.Query(ctx, "SELECT tag FROM dream_tags WHERE dream_id=$1", rowID)
+		if err != nil {
+			return nil, err
+		}
+		dreams[i].Tags = []string{}
+		for tagRows.Next() {
+			var tag string
+			tagRows.Scan(&tag)
+			dreams[i].Tags = append(dreams[i].Tags, tag)
+		}
+		tagRows.Close()
+	}
+	return dreams, nil
+}
+
+func BenchmarkLoadDreamsNPlusOne(b *testing.B) {
+	s, userID := setupBenchDB(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dreams, err := loadDreamsNPlusOne(ctx, s.pool, userID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(dreams) != benchDreams {
+			b.Fatalf("got %d dreams, want %d", len(dreams), benchDreams)
+		}
+	}
+}
+
+func BenchmarkLoadDreamsBatched(b *testing.B) {
+	s, userID := setupBenchDB(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dreams, err := s.loadDreams(ctx, "WHERE d.user_id=$1", userID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(dreams) != benchDreams || len(dreams[0].Tags) != benchTagsPerDream {
+			b.Fatalf("got %d dreams, want %d", len(dreams), benchDreams)
+		}
+	}
+}
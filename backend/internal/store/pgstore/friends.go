@@ -0,0 +1,246 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) FriendStatus(ctx context.Context, userID, friendID string) (string, error) {
+	var status string
+	err := s.pool.QueryRow(ctx, "SELECT status FROM friends WHERE user_id=$1 AND friend_id=$2", userID, friendID).Scan(&status)
+	return status, notFound(err)
+}
+
+func (s *Store) RequestFriend(ctx context.Context, userID, friendID string) (string, bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback(context.Background())
+	// Lock both users, in ID order so crossing requests cannot deadlock,
+	// so that two crossing requests run one after the other and cannot
+	// both end up pending. Locking the friends rows would not do: there are
+	// none yet for a first request.
+	locked, err := tx.Query(ctx, "SELECT id::text FROM users WHERE id IN ($1, $2) ORDER BY id FOR NO KEY UPDATE", userID, friendID)
+	if err != nil {
+		return "", false, err
+	}
+	exists := false
+	for locked.Next() {
+		var id string
+		if err := locked.Scan(&id); err != nil {
+			locked.Close()
+			return "", false, err
+		}
+		exists = exists || id == friendID
+	}
+	locked.Close()
+	if err := locked.Err(); err != nil {
+		return "", false, err
+	}
+	if !exists {
+		return "", false, store.ErrNotFound
+	}
+	var status, reverse string
+	rows, err := tx.Query(ctx, "SELECT user_id::text, status FROM friends WHERE (user_id=$1 AND friend_id=$2) OR (user_id=$2 AND friend_id=$1)", userID, friendID)
+	if err != nil {
+		return "", false, err
+	}
+	for rows.Next() {
+		var from, st string
+		if err := rows.Scan(&from, &st); err != nil {
+			rows.Close()
+			return "", false, err
+		}
+		if from == userID {
+			status = st
+		} else {
+			reverse = st
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", false, err
+	}
+	switch {
+	case reverse == "blocked":
+		return "", false, store.ErrNotFound
+	case status == "blocked":
+		return "", false, store.ErrConflict
+	case reverse == "pending" || reverse == "rejected":
+		// Both want to be friends
+		if err := befriend(ctx, tx, friendID, userID); err != nil {
+			return "", false, err
+		}
+		return "accepted", true, tx.Commit(ctx)
+	case status == "rejected":
+		return "pending", false, nil
+	case status != "":
+		return status, false, nil
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO friends (user_id, friend_id, status) VALUES ($1, $2, 'pending')", userID, friendID); err != nil {
+		return "", false, uniqueViolation(err)
+	}
+	return "pending", true, tx.Commit(ctx)
+}
+
+func (s *Store) AcceptFriend(ctx context.Context, userID, friendID string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+	var id int
+	err = tx.QueryRow(ctx, "SELECT id FROM friends WHERE user_id=$1 AND friend_id=$2 AND status IN ('pending', 'rejected') FOR UPDATE", userID, friendID).Scan(&id)
+	if err != nil {
+		return notFound(err)
+	}
+	if err := befriend(ctx, tx, userID, friendID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// befriend accepts userID's request to friendID and adds the reciprocal row
+func befriend(ctx context.Context, tx pgx.Tx, userID, friendID string) error {
+	_, err := tx.Exec(ctx, "UPDATE friends SET status='accepted', updated_at=NOW() WHERE user_id=$1 AND friend_id=$2", userID, friendID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO friends (user_id, friend_id, status) VALUES ($1, $2, 'accepted') ON CONFLICT (user_id, friend_id) DO UPDATE SET status='accepted', updated_at=NOW()", friendID, userID)
+	return err
+}
+
+func (s *Store) RejectFriend(ctx context.Context, userID, friendID string) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE friends SET status='rejected', updated_at=NOW() WHERE user_id=$1 AND friend_id=$2 AND status='pending'", userID, friendID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) CancelFriendRequest(ctx context.Context, userID, friendID string) error {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM friends WHERE user_id=$1 AND friend_id=$2 AND status IN ('pending', 'rejected')", userID, friendID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) RemoveFriend(ctx context.Context, userID, friendID string) error {
+	_, err := s.pool.Exec(ctx, "DELETE FROM friends WHERE ((user_id=$1 AND friend_id=$2) OR (user_id=$2 AND friend_id=$1)) AND status <> 'blocked'", userID, friendID)
+	return err
+}
+
+func (s *Store) BlockUser(ctx context.Context, userID, blockedID string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id=$1)", blockedID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return store.ErrNotFound
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM friends WHERE user_id=$2 AND friend_id=$1 AND status <> 'blocked'", userID, blockedID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO friends (user_id, friend_id, status) VALUES ($1, $2, 'blocked') ON CONFLICT (user_id, friend_id) DO UPDATE SET status='blocked', updated_at=NOW()", userID, blockedID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Store) UnblockUser(ctx context.Context, userID, blockedID string) error {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM friends WHERE user_id=$1 AND friend_id=$2 AND status='blocked'", userID, blockedID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) Blocked(ctx context.Context, a, b string) (bool, error) {
+	var ok bool
+	err := s.pool.QueryRow(ctx, "SELECT "+notBlocked("$1", "$2"), a, b).Scan(&ok)
+	return !ok, err
+}
+
+// notBlocked is a SQL condition that holds unless the users identified by
+// the two expressions have blocked one another
+func notBlocked(userExpr, viewerExpr string) string {
+	return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM friends b WHERE b.status = 'blocked' AND "+
+		"((b.user_id = %[1]s AND b.friend_id = %[2]s) OR (b.user_id = %[2]s AND b.friend_id = %[1]s)))", userExpr, viewerExpr)
+}
+
+func (s *Store) ListFriends(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	return s.listUsers(ctx, "SELECT u.id::text, u.username, u.display_name, u.profile_image_url FROM friends f JOIN users u ON f.friend_id = u.id WHERE f.user_id=$1 AND f.status='accepted' ORDER BY u.username", userID)
+}
+
+func (s *Store) ListFriendRequests(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	return s.listUsers(ctx, "SELECT u.id::text, u.username, u.display_name, u.profile_image_url FROM friends f JOIN users u ON f.user_id = u.id WHERE f.friend_id=$1 AND f.status='pending' ORDER BY f.created_at, f.id", userID)
+}
+
+func (s *Store) ListSentFriendRequests(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	return s.listUsers(ctx, "SELECT u.id::text, u.username, u.display_name, u.profile_image_url FROM friends f JOIN users u ON f.friend_id = u.id WHERE f.user_id=$1 AND f.status IN ('pending', 'rejected') ORDER BY f.created_at, f.id", userID)
+}
+
+func (s *Store) ListBlocked(ctx context.Context, userID string) ([]model.UserSummary, error) {
+	return s.listUsers(ctx, "SELECT u.id::text, u.username, u.display_name, u.profile_image_url FROM friends f JOIN users u ON f.friend_id = u.id WHERE f.user_id=$1 AND f.status='blocked' ORDER BY u.username", userID)
+}
+
+func (s *Store) listUsers(ctx context.Context, query string, args ...interface{}) ([]model.UserSummary, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []model.UserSummary{}
+	for rows.Next() {
+		var u model.UserSummary
+		var displayName, profileImageURL sql.NullString
+		if err := rows.Scan(&u.ID, &u.Username, &displayName, &profileImageURL); err != nil {
+			return nil, err
+		}
+		u.DisplayName = displayName.String
+		u.ProfileImageURL = profileImageURL.String
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *Store) FriendIDs(ctx context.Context, userID string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, "SELECT friend_id::text FROM friends WHERE user_id=$1 AND status='accepted'", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,185 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const notificationSelect = `SELECT n.id, n.type, n.user_id::text, n.dream_id, d.public_id, n.comment_id, n.read_at IS NOT NULL, n.created_at,
+	u.id::text, u.username, u.display_name, u.profile_image_url
+	FROM notifications n
+	JOIN users u ON u.id = n.actor_id
+	LEFT JOIN dreams d ON d.id = n.dream_id `
+
+func scanNotification(row pgx.Row) (*model.Notification, error) {
+	var n model.Notification
+	var dreamID, commentID sql.NullInt32
+	var publicID, displayName, profileImageURL sql.NullString
+	if err := row.Scan(&n.ID, &n.Type, &n.UserID, &dreamID, &publicID, &commentID, &n.Read, &n.CreatedAt,
+		&n.Actor.ID, &n.Actor.Username, &displayName, &profileImageURL); err != nil {
+		return nil, err
+	}
+	n.DreamRowID = int(dreamID.Int32)
+	n.DreamID = publicID.String
+	n.CommentID = nullIntPtr(commentID)
+	n.Actor.DisplayName = displayName.String
+	n.Actor.ProfileImageURL = profileImageURL.String
+	return &n, nil
+}
+
+func (s *Store) CreateNotification(ctx context.Context, n *model.Notification) error {
+	var dreamID interface{}
+	if n.DreamRowID != 0 {
+		dreamID = n.DreamRowID
+	}
+	var exists, enabled bool
+	err := s.pool.QueryRow(ctx, `SELECT
+		EXISTS (SELECT 1 FROM users WHERE id = $1) AND EXISTS (SELECT 1 FROM users WHERE id = $2)
+		AND ($3::int IS NULL OR EXISTS (SELECT 1 FROM dreams WHERE id = $3))
+		AND ($4::int IS NULL OR EXISTS (SELECT 1 FROM comments WHERE id = $4)),
+		COALESCE((SELECT enabled FROM notification_preferences WHERE user_id = $1 AND type = $5), TRUE)`,
+		n.UserID, n.Actor.ID, dreamID, n.CommentID, n.Type).Scan(&exists, &enabled)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return store.ErrNotFound
+	}
+	if !enabled {
+		n.ID = 0
+		return nil
+	}
+	var id int
+	if err := s.pool.QueryRow(ctx, `INSERT INTO notifications (user_id, actor_id, type, dream_id, comment_id)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`, n.UserID, n.Actor.ID, n.Type, dreamID, n.CommentID).Scan(&id); err != nil {
+		return err
+	}
+	created, err := scanNotification(s.pool.QueryRow(ctx, notificationSelect+"WHERE n.id=$1", id))
+	if err != nil {
+		return err
+	}
+	*n = *created
+	return nil
+}
+
+func (s *Store) ListNotifications(ctx context.Context, userID string, unreadOnly bool, page model.Page) ([]model.Notification, *model.Cursor, error) {
+	args := []interface{}{userID}
+	where := "WHERE n.user_id=$1 AND " + notBlocked("n.actor_id", "$1") + " AND "
+	if unreadOnly {
+		where += "n.read_at IS NULL AND "
+	}
+	rows, err := s.pool.Query(ctx, notificationSelect+where+
+		keyset(page, "n.created_at", "n.id", true, &args)+" ORDER BY n.created_at DESC, n.id DESC "+limitClause(page), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	notifications := []model.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		notifications = append(notifications, *n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	n, next := trimPage(page, len(notifications), func(i int) model.Cursor { return notifications[i].Cursor() })
+	return notifications[:n], next, nil
+}
+
+func (s *Store) MarkNotificationsRead(ctx context.Context, userID string, ids []int) (int, error) {
+	query := "UPDATE notifications SET read_at=NOW() WHERE user_id=$1 AND read_at IS NULL"
+	args := []interface{}{userID}
+	if ids != nil {
+		query += " AND id = ANY($2)"
+		args = append(args, ids)
+	}
+	tag, err := s.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID string) (model.NotificationCounts, error) {
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`SELECT n.type, count(*) FROM notifications n
+		WHERE n.user_id=$1 AND n.read_at IS NULL AND %s
+		GROUP BY n.type`, notBlocked("n.actor_id", "$1")), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := model.NotificationCounts{}
+	for _, t := range model.NotificationTypes {
+		counts[t] = 0
+	}
+	for rows.Next() {
+		var t model.NotificationType
+		var n int
+		if err := rows.Scan(&t, &n); err != nil {
+			return nil, err
+		}
+		counts[t] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *Store) NotificationPrefs(ctx context.Context, userID string) (model.NotificationPrefs, error) {
+	rows, err := s.pool.Query(ctx, `SELECT p.type, p.enabled FROM users u
+		LEFT JOIN notification_preferences p ON p.user_id = u.id
+		WHERE u.id=$1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	prefs := model.DefaultNotificationPrefs()
+	found := false
+	for rows.Next() {
+		found = true
+		var t sql.NullString
+		var enabled sql.NullBool
+		if err := rows.Scan(&t, &enabled); err != nil {
+			return nil, err
+		}
+		if t.Valid {
+			prefs[model.NotificationType(t.String)] = enabled.Bool
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, store.ErrNotFound
+	}
+	return prefs, nil
+}
+
+func (s *Store) SetNotificationPrefs(ctx context.Context, userID string, prefs model.NotificationPrefs) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id=$1)", userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return store.ErrNotFound
+	}
+	for t, enabled := range prefs {
+		if _, err := tx.Exec(ctx, `INSERT INTO notification_preferences (user_id, type, enabled) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled`, userID, t, enabled); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,78 @@
+// Package pgstore implements store.Store on top of the Postgres schema in
+// backend/migrations
+package pgstore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Store is a store.Store backed by a pgx connection pool
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New(pool *pgxpool.Pool) *Store {
+	return &Store{pool: pool}
+}
+
+// notFound maps pgx.ErrNoRows to store.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// nullIntPtr converts a nullable integer column into the *int used in JSON
+func nullIntPtr(v sql.NullInt32) *int {
+	if !v.Valid {
+		return nil
+	}
+	val := int(v.Int32)
+	return &val
+}
+
+// keyset returns the SQL condition that continues after the page cursor for
+// rows ordered by (createdAt, id) in the given direction, or TRUE on the
+// first page. The cursor values are appended to args.
+func keyset(p model.Page, createdAt, id string, desc bool, args *[]interface{}) string {
+	if p.After == nil {
+		return "TRUE"
+	}
+	*args = append(*args, p.After.CreatedAt, p.After.ID)
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s ($%d::timestamp, $%d::int)", createdAt, id, op, len(*args)-1, len(*args))
+}
+
+// limitClause fetches one row more than the page size so we can tell whether
+// there is a next page
+func limitClause(p model.Page) string {
+	if p.Limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("LIMIT %d", p.Limit+1)
+}
+
+// trimPage drops the extra row fetched by limitClause and returns the page
+// size and the cursor of the following page, or nil on the last page.
+// cursorOf returns the cursor of row i.
+func trimPage(p model.Page, n int, cursorOf func(i int) model.Cursor) (int, *model.Cursor) {
+	if p.Limit <= 0 || n <= p.Limit {
+		return n, nil
+	}
+	next := cursorOf(p.Limit - 1)
+	return p.Limit, &next
+}
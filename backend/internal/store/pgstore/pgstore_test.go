@@ -0,0 +1,37 @@
+package pgstore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store/storetest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The conformance suite needs a migrated Postgres database:
+//
+//	TEST_DATABASE_URL=postgres://... go test ./internal/store/pgstore
+//
+// Every user it creates is deleted afterwards, along with their rows.
+func TestConformance(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	t.Cleanup(func() {
+		if _, err := pool.Exec(ctx, `DELETE FROM users WHERE email LIKE '%@' || $1`, storetest.EmailDomain); err != nil {
+			t.Errorf("cleaning up: %v", err)
+		}
+	})
+	st := New(pool)
+	storetest.Run(t, func(t *testing.T) store.Store { return st })
+}
@@ -0,0 +1,185 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// targetArgs returns the dream_id and comment_id values of a reaction
+// target, with NULL for the one it is not on
+func targetArgs(t model.ReactionTarget) (dreamID, commentID interface{}) {
+	if t.DreamRowID != 0 {
+		return t.DreamRowID, nil
+	}
+	return nil, t.CommentID
+}
+
+// targetColumn returns the reactions column a target is matched on and its
+// value
+func targetColumn(t model.ReactionTarget) (string, int) {
+	if t.DreamRowID != 0 {
+		return "dream_id", t.DreamRowID
+	}
+	return "comment_id", t.CommentID
+}
+
+// countReactionsBatch counts reactions by kind for each ID in ids, matched
+// on column (dream_id or comment_id), with a single query
+func (s *Store) countReactionsBatch(ctx context.Context, column string, ids []int) (map[int]model.ReactionCounts, error) {
+	counts := make(map[int]model.ReactionCounts, len(ids))
+	for _, id := range ids {
+		counts[id] = model.NewReactionCounts()
+	}
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	rows, err := s.pool.Query(ctx, fmt.Sprintf("SELECT %[1]s, kind, count(*) FROM reactions WHERE %[1]s = ANY($1) GROUP BY %[1]s, kind", column), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, n int
+		var kind model.ReactionKind
+		if err := rows.Scan(&id, &kind, &n); err != nil {
+			return nil, err
+		}
+		counts[id][kind] = n
+	}
+	return counts, rows.Err()
+}
+
+// attachDreamReactions fills in Reactions for every dream with a single
+// query
+func (s *Store) attachDreamReactions(ctx context.Context, dreams []model.Dream) error {
+	ids := make([]int, len(dreams))
+	for i, d := range dreams {
+		ids[i] = d.RowID
+	}
+	counts, err := s.countReactionsBatch(ctx, "dream_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range dreams {
+		dreams[i].Reactions = counts[dreams[i].RowID]
+	}
+	return nil
+}
+
+// attachCommentReactions fills in Reactions for every comment with a
+// single query
+func (s *Store) attachCommentReactions(ctx context.Context, comments []model.Comment) error {
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	counts, err := s.countReactionsBatch(ctx, "comment_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Reactions = counts[comments[i].ID]
+	}
+	return nil
+}
+
+func (s *Store) AddReaction(ctx context.Context, r *model.Reaction) error {
+	dreamID, commentID := targetArgs(r.Target)
+	err := s.pool.QueryRow(ctx, `INSERT INTO reactions (user_id, dream_id, comment_id, kind, created_at)
+		SELECT $1, $2, $3, $4, NOW()
+		WHERE EXISTS (SELECT 1 FROM users WHERE id = $1)
+		AND (EXISTS (SELECT 1 FROM dreams WHERE id = $2) OR EXISTS (SELECT 1 FROM comments WHERE id = $3))
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at`,
+		r.User.ID, dreamID, commentID, r.Kind).Scan(&r.ID, &r.CreatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		// Either the reaction exists already or its user or target is
+		// missing
+		column, id := targetColumn(r.Target)
+		err = s.pool.QueryRow(ctx, "SELECT id, created_at FROM reactions WHERE user_id=$1 AND "+column+"=$2 AND kind=$3",
+			r.User.ID, id, r.Kind).Scan(&r.ID, &r.CreatedAt)
+		if err != nil {
+			return notFound(err)
+		}
+	}
+	var displayName, profileImageURL sql.NullString
+	if err := s.pool.QueryRow(ctx, "SELECT username, display_name, profile_image_url FROM users WHERE id=$1", r.User.ID).
+		Scan(&r.User.Username, &displayName, &profileImageURL); err != nil {
+		return notFound(err)
+	}
+	r.User.DisplayName = displayName.String
+	r.User.ProfileImageURL = profileImageURL.String
+	return nil
+}
+
+func (s *Store) RemoveReaction(ctx context.Context, target model.ReactionTarget, userID string, kind model.ReactionKind) error {
+	column, id := targetColumn(target)
+	_, err := s.pool.Exec(ctx, "DELETE FROM reactions WHERE user_id=$1 AND "+column+"=$2 AND kind=$3", userID, id, kind)
+	return err
+}
+
+func (s *Store) CountReactions(ctx context.Context, target model.ReactionTarget) (model.ReactionCounts, error) {
+	dreamID, commentID := targetArgs(target)
+	var exists bool
+	if err := s.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM dreams WHERE id = $1) OR EXISTS (SELECT 1 FROM comments WHERE id = $2)",
+		dreamID, commentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, store.ErrNotFound
+	}
+	column, id := targetColumn(target)
+	counts, err := s.countReactionsBatch(ctx, column, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	return counts[id], nil
+}
+
+func (s *Store) ListReactions(ctx context.Context, target model.ReactionTarget, kind model.ReactionKind, viewerID string, page model.Page) ([]model.Reaction, *model.Cursor, error) {
+	column, id := targetColumn(target)
+	args := []interface{}{id}
+	where := "WHERE r." + column + "=$1 AND "
+	if kind != "" {
+		args = append(args, kind)
+		where += fmt.Sprintf("r.kind=$%d AND ", len(args))
+	}
+	if viewerID != "" {
+		args = append(args, viewerID)
+		where += notBlocked("r.user_id", fmt.Sprintf("$%d", len(args))) + " AND "
+	}
+	rows, err := s.pool.Query(ctx, `SELECT r.id, r.kind, r.created_at, u.id::text, u.username, u.display_name, u.profile_image_url
+		FROM reactions r
+		JOIN users u ON u.id = r.user_id `+where+
+		keyset(page, "r.created_at", "r.id", true, &args)+" ORDER BY r.created_at DESC, r.id DESC "+limitClause(page), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	reactions := []model.Reaction{}
+	for rows.Next() {
+		r := model.Reaction{Target: target}
+		var displayName, profileImageURL sql.NullString
+		if err := rows.Scan(&r.ID, &r.Kind, &r.CreatedAt, &r.User.ID, &r.User.Username, &displayName, &profileImageURL); err != nil {
+			return nil, nil, err
+		}
+		r.User.DisplayName = displayName.String
+		r.User.ProfileImageURL = profileImageURL.String
+		reactions = append(reactions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	n, next := trimPage(page, len(reactions), func(i int) model.Cursor { return reactions[i].Cursor() })
+	return reactions[:n], next, nil
+}
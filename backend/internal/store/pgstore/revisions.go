@@ -0,0 +1,128 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const revisionColumns = "revision, title, text, visibility, nightmare_rating, vividness_rating, clarity_rating, emotional_intensity_rating, edited_by::text, created_at"
+
+func (s *Store) EditDream(ctx context.Context, rowID int, editorID string, mutate func(*model.DreamState)) (*model.Dream, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var cur model.DreamState
+	var title sql.NullString
+	var nightmareRating, vividnessRating, clarityRating, emotionalIntensityRating sql.NullInt32
+	var ownerID string
+	var updatedAt time.Time
+	err = tx.QueryRow(ctx,
+		"SELECT user_id, title, text, visibility, updated_at, nightmare_rating, vividness_rating, clarity_rating, emotional_intensity_rating FROM dreams WHERE id=$1 FOR UPDATE",
+		rowID,
+	).Scan(&ownerID, &title, &cur.Text, &cur.Visibility, &updatedAt, &nightmareRating, &vividnessRating, &clarityRating, &emotionalIntensityRating)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	cur.Title = title.String
+	cur.NightmareRating = nullIntPtr(nightmareRating)
+	cur.VividnessRating = nullIntPtr(vividnessRating)
+	cur.ClarityRating = nullIntPtr(clarityRating)
+	cur.EmotionalIntensityRating = nullIntPtr(emotionalIntensityRating)
+
+	next := cur
+	mutate(&next)
+	if next.Equal(cur) {
+		return nil, store.ErrNoChanges
+	}
+
+	var latest int
+	if err := tx.QueryRow(ctx, "SELECT COALESCE(MAX(revision), 0) FROM dream_revisions WHERE dream_id=$1", rowID).Scan(&latest); err != nil {
+		return nil, err
+	}
+	if latest == 0 {
+		if err := insertRevision(ctx, tx, rowID, 1, cur, ownerID, updatedAt); err != nil {
+			return nil, err
+		}
+		latest = 1
+	}
+
+	now := time.Now()
+	textChanged := next.Text != cur.Text
+	_, err = tx.Exec(ctx,
+		`UPDATE dreams SET title=$1, text=$2, visibility=$3, nightmare_rating=$4, vividness_rating=$5, clarity_rating=$6, emotional_intensity_rating=$7, updated_at=$8,
+		 summary = CASE WHEN $9 THEN NULL ELSE summary END,
+		 summary_hash = CASE WHEN $9 THEN NULL ELSE summary_hash END,
+		 prophecy = CASE WHEN $9 THEN NULL ELSE prophecy END
+		 WHERE id=$10`,
+		next.Title, next.Text, next.Visibility, next.NightmareRating, next.VividnessRating, next.ClarityRating, next.EmotionalIntensityRating, now, textChanged, rowID)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertRevision(ctx, tx, rowID, latest+1, next, editorID, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.GetDreamByRowID(ctx, rowID)
+}
+
+func insertRevision(ctx context.Context, tx pgx.Tx, rowID, revision int, st model.DreamState, editorID string, at time.Time) error {
+	_, err := tx.Exec(ctx,
+		"INSERT INTO dream_revisions (dream_id, revision, title, text, visibility, nightmare_rating, vividness_rating, clarity_rating, emotional_intensity_rating, edited_by, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		rowID, revision, st.Title, st.Text, st.Visibility, st.NightmareRating, st.VividnessRating, st.ClarityRating, st.EmotionalIntensityRating, editorID, at)
+	return err
+}
+
+func (s *Store) ListRevisions(ctx context.Context, rowID int) ([]model.DreamRevision, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+revisionColumns+" FROM dream_revisions WHERE dream_id=$1 ORDER BY revision DESC", rowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []model.DreamRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *Store) GetRevision(ctx context.Context, rowID, revision int) (*model.DreamRevision, error) {
+	rev, err := scanRevision(s.pool.QueryRow(ctx, "SELECT "+revisionColumns+" FROM dream_revisions WHERE dream_id=$1 AND revision=$2", rowID, revision))
+	return rev, notFound(err)
+}
+
+func (s *Store) LatestRevision(ctx context.Context, rowID int) (int, error) {
+	var latest int
+	err := s.pool.QueryRow(ctx, "SELECT COALESCE(MAX(revision), 0) FROM dream_revisions WHERE dream_id=$1", rowID).Scan(&latest)
+	return latest, err
+}
+
+func scanRevision(row pgx.Row) (*model.DreamRevision, error) {
+	var rev model.DreamRevision
+	var title, editedBy sql.NullString
+	var nightmareRating, vividnessRating, clarityRating, emotionalIntensityRating sql.NullInt32
+	if err := row.Scan(&rev.Revision, &title, &rev.Text, &rev.Visibility, &nightmareRating, &vividnessRating, &clarityRating, &emotionalIntensityRating, &editedBy, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	rev.Title = title.String
+	rev.EditedBy = editedBy.String
+	rev.NightmareRating = nullIntPtr(nightmareRating)
+	rev.VividnessRating = nullIntPtr(vividnessRating)
+	rev.ClarityRating = nullIntPtr(clarityRating)
+	rev.EmotionalIntensityRating = nullIntPtr(emotionalIntensityRating)
+	return &rev, nil
+}
@@ -0,0 +1,148 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+// Headline markers are control characters so the text can be HTML-escaped
+// before they are turned into <mark> tags
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+)
+
+var markReplacer = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// SearchDreams runs a websearch-style query against the dreams' search
+// vectors, ranked by ts_rank_cd with newer dreams first on ties
+func (s *Store) SearchDreams(ctx context.Context, f model.SearchFilter) (*model.SearchResponse, error) {
+	args := []interface{}{f.Query}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := []string{"d.search_vector @@ query"}
+	switch f.Scope {
+	case "mine":
+		where = append(where, "d.user_id = "+arg(f.ViewerID))
+	case "friends":
+		where = append(where, "d.visibility IN ('public', 'friends')", "d.user_id IN (SELECT friend_id FROM friends WHERE user_id = "+arg(f.ViewerID)+" AND status = 'accepted')")
+	case "public":
+		where = append(where, "d.visibility = 'public'")
+	default:
+		if f.ViewerID != "" {
+			where = append(where, listed(arg(f.ViewerID)))
+		} else {
+			where = append(where, "d.visibility = 'public'")
+		}
+	}
+	if f.ViewerID != "" {
+		where = append(where, notBlocked("d.user_id", arg(f.ViewerID)))
+	}
+	if len(f.Tags) > 0 {
+		// Every requested tag must be present
+		p := arg(f.Tags)
+		where = append(where, fmt.Sprintf(
+			"(SELECT COUNT(DISTINCT LOWER(TRIM(t.tag))) FROM dream_tags t WHERE t.dream_id = d.id AND LOWER(TRIM(t.tag)) = ANY(%s)) = cardinality(%s::text[])", p, p))
+	}
+	if f.Dates.From != nil {
+		where = append(where, "d.created_at >= "+arg(*f.Dates.From))
+	}
+	if f.Dates.To != nil {
+		where = append(where, "d.created_at < "+arg(*f.Dates.To))
+	}
+	for _, name := range model.RatingNames {
+		if v, ok := f.RatingMin[name]; ok {
+			where = append(where, fmt.Sprintf("d.%s_rating >= %s", name, arg(v)))
+		}
+		if v, ok := f.RatingMax[name]; ok {
+			where = append(where, fmt.Sprintf("d.%s_rating <= %s", name, arg(v)))
+		}
+	}
+
+	filterArgs := len(args)
+	titleOpts := arg("StartSel=" + markStart + ", StopSel=" + markStop + ", HighlightAll=true")
+	textOpts := arg("StartSel=" + markStart + ", StopSel=" + markStop + `, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`)
+	limit := arg(f.Limit)
+	offset := arg(f.Offset)
+
+	// Headlines are computed on the page only, after ranking and paging
+	rows, err := s.pool.Query(ctx,
+		`WITH matches AS (
+			SELECT d.id, ts_rank_cd(d.search_vector, query) AS rank, COUNT(*) OVER () AS total
+			FROM dreams d, websearch_to_tsquery('english', $1) AS query
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY rank DESC, d.created_at DESC, d.id DESC
+			LIMIT `+limit+` OFFSET `+offset+`
+		 )
+		 SELECT m.id, m.rank, m.total,
+		        ts_headline('english', COALESCE(d.title, ''), query, `+titleOpts+`),
+		        ts_headline('english', d.text, query, `+textOpts+`)
+		 FROM matches m
+		 JOIN dreams d ON d.id = m.id,
+		      websearch_to_tsquery('english', $1) AS query
+		 ORDER BY m.rank DESC, d.created_at DESC, d.id DESC`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &model.SearchResponse{Results: []model.SearchResult{}, Limit: f.Limit, Offset: f.Offset}
+	var ids []int
+	for rows.Next() {
+		var res model.SearchResult
+		var id int
+		var rank float32
+		var total int64
+		var titleHL, textHL string
+		if err := rows.Scan(&id, &rank, &total, &titleHL, &textHL); err != nil {
+			return nil, err
+		}
+		res.Rank = float64(rank)
+		res.TitleHighlight = highlight(titleHL)
+		res.Snippet = highlight(textHL)
+		resp.Total = int(total)
+		resp.Results = append(resp.Results, res)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if len(ids) > 0 {
+		dreams, err := s.loadDreams(ctx, "WHERE d.id = ANY($1)", ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int]model.Dream, len(dreams))
+		for _, d := range dreams {
+			byID[d.RowID] = d
+		}
+		for i, id := range ids {
+			resp.Results[i].Dream = byID[id]
+		}
+	}
+	// An offset past the last match returns no rows, and so no total
+	if len(resp.Results) == 0 && f.Offset > 0 {
+		err := s.pool.QueryRow(ctx,
+			`SELECT COUNT(*) FROM dreams d, websearch_to_tsquery('english', $1) AS query WHERE `+strings.Join(where, " AND "),
+			args[:filterArgs]...).Scan(&resp.Total)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// highlight HTML-escapes a ts_headline fragment and turns its match markers
+// into <mark> tags
+func highlight(s string) string {
+	return markReplacer.Replace(html.EscapeString(s))
+}
@@ -0,0 +1,205 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultFrequencyDays is the dreamFrequency window used when no range is given
+const defaultFrequencyDays = 30
+
+// DreamStats runs the aggregation queries for one user. Every query
+// takes the same ($1 user, $2 from, $3 to) parameters.
+func (s *Store) DreamStats(ctx context.Context, userID string, dr model.DateRange, topTags int) (*model.DreamStats, error) {
+	const filter = `d.user_id=$1 AND ($2::timestamp IS NULL OR d.created_at >= $2) AND ($3::timestamp IS NULL OR d.created_at < $3)`
+	args := []interface{}{userID, dr.From, dr.To}
+	stats := &model.DreamStats{
+		MostCommonTags: []model.TagCount{},
+		Ratings:        map[string]model.RatingStats{},
+		From:           dr.From,
+		To:             dr.To,
+	}
+
+	// Totals and rating averages
+	var avgs [4]*float64
+	var counts [4]int
+	err := s.pool.QueryRow(ctx, `SELECT COUNT(*), COUNT(*) FILTER (WHERE d.visibility = 'public'),
+			AVG(d.nightmare_rating)::float8, COUNT(d.nightmare_rating),
+			AVG(d.vividness_rating)::float8, COUNT(d.vividness_rating),
+			AVG(d.clarity_rating)::float8, COUNT(d.clarity_rating),
+			AVG(d.emotional_intensity_rating)::float8, COUNT(d.emotional_intensity_rating)
+		 FROM dreams d WHERE `+filter, args...,
+	).Scan(&stats.TotalDreams, &stats.PublicDreams,
+		&avgs[0], &counts[0], &avgs[1], &counts[1], &avgs[2], &counts[2], &avgs[3], &counts[3])
+	if err != nil {
+		return nil, fmt.Errorf("totals: %w", err)
+	}
+	stats.PrivateDreams = stats.TotalDreams - stats.PublicDreams
+	for i, name := range model.RatingNames {
+		stats.Ratings[name] = model.RatingStats{Average: avgs[i], Count: counts[i]}
+	}
+
+	// Rating histograms, one row per (rating, value)
+	rows, err := s.pool.Query(ctx, `SELECT r.name, r.value, COUNT(*)
+		 FROM dreams d
+		 CROSS JOIN LATERAL (VALUES
+			('nightmare', d.nightmare_rating),
+			('vividness', d.vividness_rating),
+			('clarity', d.clarity_rating),
+			('emotional_intensity', d.emotional_intensity_rating)
+		 ) AS r(name, value)
+		 WHERE `+filter+` AND r.value BETWEEN 1 AND 10
+		 GROUP BY r.name, r.value`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("histograms: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		var value, count int
+		if err := rows.Scan(&name, &value, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("histograms: %w", err)
+		}
+		rs := stats.Ratings[name]
+		rs.Histogram[value-1] = count
+		stats.Ratings[name] = rs
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("histograms: %w", err)
+	}
+
+	// Dream counts per day, week and month
+	for _, p := range []struct {
+		unit string
+		dst  *[]model.PeriodCount
+	}{
+		{"day", &stats.DreamsPerDay},
+		{"week", &stats.DreamsPerWeek},
+		{"month", &stats.DreamsPerMonth},
+	} {
+		periods, err := s.countDreamsPer(ctx, p.unit, filter, args)
+		if err != nil {
+			return nil, fmt.Errorf("per %s: %w", p.unit, err)
+		}
+		*p.dst = periods
+	}
+
+	// Daily frequency series with empty days filled in
+	freqFrom, freqTo := frequencyWindow(dr)
+	rows, err = s.pool.Query(ctx, `SELECT COUNT(d.id)
+		 FROM generate_series($4::date, $5::date, INTERVAL '1 day') AS g(day)
+		 LEFT JOIN dreams d ON d.created_at::date = g.day::date AND `+filter+`
+		 GROUP BY g.day
+		 ORDER BY g.day`, append(args, freqFrom, freqTo)...)
+	if err != nil {
+		return nil, fmt.Errorf("frequency: %w", err)
+	}
+	stats.DreamFrequency = []int{}
+	for rows.Next() {
+		var count int
+		if err := rows.Scan(&count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("frequency: %w", err)
+		}
+		stats.DreamFrequency = append(stats.DreamFrequency, count)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("frequency: %w", err)
+	}
+
+	// Most common tags, case-insensitively
+	rows, err = s.pool.Query(ctx, `SELECT LOWER(TRIM(t.tag)) AS name, COUNT(DISTINCT t.dream_id)
+		 FROM dream_tags t
+		 JOIN dreams d ON d.id = t.dream_id
+		 WHERE `+filter+`
+		 GROUP BY name
+		 ORDER BY 2 DESC, name
+		 LIMIT $4`, append(args, topTags)...)
+	if err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+	for rows.Next() {
+		var tc model.TagCount
+		if err := rows.Scan(&tc.Name, &tc.Count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("tags: %w", err)
+		}
+		tc.ID = tc.Name
+		stats.MostCommonTags = append(stats.MostCommonTags, tc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+
+	// Streaks: consecutive days form an island where day - row_number is constant
+	var current, longest int
+	var lastDay *time.Time
+	err = s.pool.QueryRow(ctx, `WITH days AS (
+			SELECT DISTINCT d.created_at::date AS day FROM dreams d WHERE `+filter+`
+		 ), islands AS (
+			SELECT day, day - (ROW_NUMBER() OVER (ORDER BY day))::int AS grp FROM days
+		 ), runs AS (
+			SELECT MAX(day) AS last_day, COUNT(*) AS length FROM islands GROUP BY grp
+		 )
+		 SELECT
+			COALESCE((SELECT length FROM runs WHERE last_day >= CURRENT_DATE - 1 ORDER BY last_day DESC LIMIT 1), 0),
+			COALESCE((SELECT MAX(length) FROM runs), 0),
+			(SELECT MAX(last_day) FROM runs)::timestamp`, args...,
+	).Scan(&current, &longest, &lastDay)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("streaks: %w", err)
+	}
+	stats.Streak = model.StreakStats{Current: current, Longest: longest, LastDreamOn: lastDay}
+
+	return stats, nil
+}
+
+// countDreamsPer groups the filtered dreams by date_trunc(unit, created_at)
+func (s *Store) countDreamsPer(ctx context.Context, unit, filter string, args []interface{}) ([]model.PeriodCount, error) {
+	rows, err := s.pool.Query(ctx, `SELECT date_trunc('`+unit+`', d.created_at) AS period, COUNT(*)
+		 FROM dreams d
+		 WHERE `+filter+`
+		 GROUP BY period
+		 ORDER BY period`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	periods := []model.PeriodCount{}
+	for rows.Next() {
+		var pc model.PeriodCount
+		if err := rows.Scan(&pc.Period, &pc.Count); err != nil {
+			return nil, err
+		}
+		periods = append(periods, pc)
+	}
+	return periods, rows.Err()
+}
+
+// frequencyWindow picks the inclusive day range for dreamFrequency: the
+// requested range, or the last defaultFrequencyDays days ending today. It
+// is cut to the last model.MaxFrequencyDays days so a huge range cannot
+// build a huge series.
+func frequencyWindow(dr model.DateRange) (time.Time, time.Time) {
+	to := time.Now()
+	if dr.To != nil {
+		// To is exclusive, the series end is inclusive
+		to = dr.To.Add(-time.Nanosecond)
+	}
+	from := to.AddDate(0, 0, -(defaultFrequencyDays - 1))
+	if dr.From != nil {
+		from = *dr.From
+	}
+	if earliest := to.AddDate(0, 0, -(model.MaxFrequencyDays - 1)); from.Before(earliest) {
+		from = earliest
+	}
+	return from, to
+}
@@ -0,0 +1,111 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+)
+
+func (s *Store) TagAnalytics(ctx context.Context, userID string, includePrivate bool, dr model.DateRange, limit, related int) ([]model.TagAnalytics, error) {
+	// $1 user, $2 from, $3 to, $4 include private, $5 tag limit
+	const tagged = `WITH tagged AS (
+			SELECT DISTINCT t.dream_id, LOWER(TRIM(t.tag)) AS name, d.created_at
+			FROM dream_tags t
+			JOIN dreams d ON d.id = t.dream_id
+			WHERE d.user_id=$1
+			  AND ($2::timestamp IS NULL OR d.created_at >= $2)
+			  AND ($3::timestamp IS NULL OR d.created_at < $3)
+			  AND ($4 OR d.visibility = 'public')
+			  AND TRIM(t.tag) <> ''
+		 ), top AS (
+			SELECT name, COUNT(*) AS count, MIN(created_at) AS first_seen, MAX(created_at) AS last_seen
+			FROM tagged
+			GROUP BY name
+			ORDER BY count DESC, name
+			LIMIT $5
+		 )`
+	args := []interface{}{userID, dr.From, dr.To, includePrivate, limit}
+
+	rows, err := s.pool.Query(ctx, tagged+` SELECT name, count, first_seen, last_seen FROM top ORDER BY count DESC, name`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("usage: %w", err)
+	}
+	tags := []model.TagAnalytics{}
+	index := map[string]int{}
+	for rows.Next() {
+		ta := model.TagAnalytics{CoOccurring: []model.TagCount{}, Trend: []model.PeriodCount{}}
+		if err := rows.Scan(&ta.Name, &ta.Count, &ta.FirstSeen, &ta.LastSeen); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("usage: %w", err)
+		}
+		ta.ID = ta.Name
+		index[ta.Name] = len(tags)
+		tags = append(tags, ta)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("usage: %w", err)
+	}
+	if len(tags) == 0 {
+		return tags, nil
+	}
+
+	// Co-occurrence: pairs of tags on the same dream, top N partners per tag
+	if related > 0 {
+		rows, err = s.pool.Query(ctx, tagged+`, pairs AS (
+				SELECT a.name AS tag, b.name AS other, COUNT(*) AS count,
+					ROW_NUMBER() OVER (PARTITION BY a.name ORDER BY COUNT(*) DESC, b.name) AS rank
+				FROM tagged a
+				JOIN tagged b ON a.dream_id = b.dream_id AND a.name <> b.name
+				WHERE a.name IN (SELECT name FROM top)
+				GROUP BY a.name, b.name
+			 )
+			 SELECT tag, other, count FROM pairs WHERE rank <= $6 ORDER BY tag, rank`, append(args, related)...)
+		if err != nil {
+			return nil, fmt.Errorf("co-occurrence: %w", err)
+		}
+		for rows.Next() {
+			var tag string
+			var tc model.TagCount
+			if err := rows.Scan(&tag, &tc.Name, &tc.Count); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("co-occurrence: %w", err)
+			}
+			tc.ID = tc.Name
+			if i, ok := index[tag]; ok {
+				tags[i].CoOccurring = append(tags[i].CoOccurring, tc)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("co-occurrence: %w", err)
+		}
+	}
+
+	// Monthly trend per tag
+	rows, err = s.pool.Query(ctx, tagged+`
+		 SELECT name, date_trunc('month', created_at) AS period, COUNT(*)
+		 FROM tagged
+		 WHERE name IN (SELECT name FROM top)
+		 GROUP BY name, period
+		 ORDER BY name, period`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("trend: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		var pc model.PeriodCount
+		if err := rows.Scan(&tag, &pc.Period, &pc.Count); err != nil {
+			return nil, fmt.Errorf("trend: %w", err)
+		}
+		if i, ok := index[tag]; ok {
+			tags[i].Trend = append(tags[i].Trend, pc)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("trend: %w", err)
+	}
+	return tags, nil
+}
@@ -0,0 +1,125 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const refreshTokenSelect = `SELECT id, user_id::text, family_id, token_hash, expires_at, created_at, used_at, revoked_at
+	FROM refresh_tokens `
+
+func scanRefreshToken(row pgx.Row) (*model.RefreshToken, error) {
+	var t model.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &usedAt, &revokedAt); err != nil {
+		return nil, notFound(err)
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return &t, nil
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, t *model.RefreshToken) error {
+	// Selecting from users turns a missing user into no rows
+	err := s.pool.QueryRow(ctx, `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		SELECT id, $2, $3, $4 FROM users WHERE id=$1
+		RETURNING id, created_at`, t.UserID, t.FamilyID, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+	return notFound(err)
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	return scanRefreshToken(s.pool.QueryRow(ctx, refreshTokenSelect+"WHERE token_hash=$1", hash))
+}
+
+func (s *Store) UseRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := scanRefreshToken(tx.QueryRow(ctx, refreshTokenSelect+"WHERE token_hash=$1 FOR UPDATE", hash))
+	if err != nil {
+		return nil, err
+	}
+	if t.UsedAt != nil || t.RevokedAt != nil {
+		if _, err := tx.Exec(ctx, "UPDATE refresh_tokens SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL", t.FamilyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, store.ErrRevoked
+	}
+	var usedAt sql.NullTime
+	if err := tx.QueryRow(ctx, "UPDATE refresh_tokens SET used_at=NOW() WHERE id=$1 RETURNING used_at", t.ID).Scan(&usedAt); err != nil {
+		return nil, err
+	}
+	t.UsedAt = &usedAt.Time
+	return t, tx.Commit(ctx)
+}
+
+func (s *Store) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	_, err := s.pool.Exec(ctx, "UPDATE refresh_tokens SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL", familyID)
+	return err
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	_, err := s.pool.Exec(ctx, "UPDATE refresh_tokens SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+func (s *Store) CreateAccountToken(ctx context.Context, t *model.AccountToken) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "UPDATE account_tokens SET used_at=NOW() WHERE user_id=$1 AND purpose=$2 AND used_at IS NULL", t.UserID, t.Purpose); err != nil {
+		return err
+	}
+	// Selecting from users turns a missing user into no rows
+	err = tx.QueryRow(ctx, `INSERT INTO account_tokens (user_id, purpose, token_hash, expires_at)
+		SELECT id, $2, $3, $4 FROM users WHERE id=$1
+		RETURNING id, created_at`, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return notFound(err)
+	}
+	t.UsedAt = nil
+	return tx.Commit(ctx)
+}
+
+func (s *Store) UseAccountToken(ctx context.Context, purpose, hash string) (*model.AccountToken, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var t model.AccountToken
+	var usedAt sql.NullTime
+	err = tx.QueryRow(ctx, `SELECT id, user_id::text, purpose, token_hash, expires_at, created_at, used_at
+		FROM account_tokens WHERE token_hash=$1 AND purpose=$2 FOR UPDATE`, hash, purpose).
+		Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &usedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if usedAt.Valid {
+		return nil, store.ErrRevoked
+	}
+	if err := tx.QueryRow(ctx, "UPDATE account_tokens SET used_at=NOW() WHERE id=$1 RETURNING used_at", t.ID).Scan(&usedAt); err != nil {
+		return nil, err
+	}
+	t.UsedAt = &usedAt.Time
+	return &t, tx.Commit(ctx)
+}
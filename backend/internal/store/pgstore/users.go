@@ -0,0 +1,156 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const userColumns = "id::text, email, username, display_name, description, profile_image_url, created_at, email_verified, is_admin"
+
+func scanUser(row pgx.Row, extra ...interface{}) (*model.User, error) {
+	var u model.User
+	var createdAt time.Time
+	var displayName, description, profileImageURL sql.NullString
+	dest := append([]interface{}{&u.ID, &u.Email, &u.Username, &displayName, &description, &profileImageURL, &createdAt, &u.EmailVerified, &u.IsAdmin}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, notFound(err)
+	}
+	u.DisplayName = displayName.String
+	u.Description = description.String
+	u.ProfileImageURL = profileImageURL.String
+	u.CreatedAt = createdAt.Unix()
+	return &u, nil
+}
+
+// usernameTaken returns an SQL condition that is true when the username
+// expression belongs to, or was given up by, a user other than id
+func usernameTaken(username, id string) string {
+	return fmt.Sprintf(`(EXISTS(SELECT 1 FROM users WHERE lower(username)=lower(%[1]s) AND id::text IS DISTINCT FROM %[2]s)
+		OR EXISTS(SELECT 1 FROM username_aliases WHERE lower(username)=lower(%[1]s) AND user_id::text IS DISTINCT FROM %[2]s))`, username, id)
+}
+
+// uniqueViolation maps a unique constraint error, from a race the checks
+// before an insert or update lost, to store.ErrConflict
+func uniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return store.ErrConflict
+	}
+	return err
+}
+
+func (s *Store) CreateUser(ctx context.Context, u *model.User, passwordHash string) error {
+	var exists bool
+	err := s.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email=$1) OR "+usernameTaken("$2", "NULL"), u.Email, u.Username).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return store.ErrConflict
+	}
+	created, err := scanUser(s.pool.QueryRow(ctx,
+		"INSERT INTO users (email, username, password_hash, display_name) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING "+userColumns,
+		u.Email, u.Username, passwordHash, u.DisplayName))
+	if err != nil {
+		return uniqueViolation(err)
+	}
+	*u = *created
+	return nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id string) (*model.User, error) {
+	return scanUser(s.pool.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id=$1", id))
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	return scanUser(s.pool.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE lower(username)=lower($1)", username))
+}
+
+func (s *Store) GetUserByAlias(ctx context.Context, username string) (*model.User, error) {
+	return scanUser(s.pool.QueryRow(ctx,
+		"SELECT "+userColumns+" FROM users WHERE id=(SELECT user_id FROM username_aliases WHERE lower(username)=lower($1))", username))
+}
+
+func (s *Store) RenameUser(ctx context.Context, id, username string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var old string
+	if err := tx.QueryRow(ctx, "SELECT username FROM users WHERE id=$1 FOR UPDATE", id).Scan(&old); err != nil {
+		return notFound(err)
+	}
+	var taken bool
+	if err := tx.QueryRow(ctx, "SELECT "+usernameTaken("$1", "$2"), username, id).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return store.ErrConflict
+	}
+	// Taking back one of the user's own old names drops that alias
+	if _, err := tx.Exec(ctx, "DELETE FROM username_aliases WHERE lower(username)=lower($1)", username); err != nil {
+		return err
+	}
+	if !strings.EqualFold(old, username) {
+		if _, err := tx.Exec(ctx, "INSERT INTO username_aliases (user_id, username) VALUES ($1, $2)", id, old); err != nil {
+			return uniqueViolation(err)
+		}
+	}
+	if _, err := tx.Exec(ctx, "UPDATE users SET username=$1 WHERE id=$2", username, id); err != nil {
+		return uniqueViolation(err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Store) GetCredentials(ctx context.Context, email string) (*model.User, string, error) {
+	var hash string
+	u, err := scanUser(s.pool.QueryRow(ctx, "SELECT "+userColumns+", password_hash FROM users WHERE email=$1", email), &hash)
+	if err != nil {
+		return nil, "", err
+	}
+	return u, hash, nil
+}
+
+func (s *Store) UpdateProfile(ctx context.Context, id, displayName, description, profileImageURL string) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE users SET display_name=$1, description=$2, profile_image_url=$3 WHERE id=$4", displayName, description, profileImageURL, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SetPassword(ctx context.Context, id, passwordHash string) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE users SET password_hash=$1 WHERE id=$2", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SetEmailVerified(ctx context.Context, id string) error {
+	tag, err := s.pool.Exec(ctx, "UPDATE users SET email_verified=TRUE WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
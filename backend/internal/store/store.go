@@ -0,0 +1,261 @@
+// Package store defines the persistence layer used by the HTTP and gRPC
+// servers. Implementations return ErrNotFound for missing rows so callers
+// never depend on a particular database driver.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a unique value such as an email is taken
+	ErrConflict = errors.New("already exists")
+	// ErrNoChanges is returned by EditDream when the edit changes nothing
+	ErrNoChanges = errors.New("no changes")
+	// ErrRevoked is returned for a refresh token that was revoked or already
+	// used
+	ErrRevoked = errors.New("revoked")
+)
+
+// Store is everything the servers need from the database. pgstore backs it
+// with Postgres and memstore keeps it in memory for tests and demos; both
+// pass the storetest conformance suite.
+type Store interface {
+	UserStore
+	DreamStore
+	FriendStore
+	CommentStore
+	ReactionStore
+	NotificationStore
+	TokenStore
+}
+
+// UserStore manages accounts and profiles
+type UserStore interface {
+	// CreateUser inserts u and fills in its ID, CreatedAt and IsAdmin.
+	// Returns ErrConflict if the email is already registered or the
+	// username is taken, in any case, by a user or an alias.
+	CreateUser(ctx context.Context, u *model.User, passwordHash string) error
+	GetUser(ctx context.Context, id string) (*model.User, error)
+	// GetUserByUsername matches usernames case-insensitively
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	// GetUserByAlias returns the user who gave up username by renaming
+	GetUserByAlias(ctx context.Context, username string) (*model.User, error)
+	// RenameUser changes the user's username and keeps the old one as an
+	// alias that only they can take back. Returns ErrConflict if username
+	// belongs to, or was given up by, another user.
+	RenameUser(ctx context.Context, id, username string) error
+	// GetCredentials returns the user registered with email and their
+	// password hash
+	GetCredentials(ctx context.Context, email string) (*model.User, string, error)
+	UpdateProfile(ctx context.Context, id, displayName, description, profileImageURL string) error
+	// SetPassword replaces the user's password hash
+	SetPassword(ctx context.Context, id, passwordHash string) error
+	// SetEmailVerified marks the user's email address as verified
+	SetEmailVerified(ctx context.Context, id string) error
+}
+
+// DreamStore manages dreams with their tags, revisions and the queries
+// built on them
+type DreamStore interface {
+	// CreateDream inserts d with a fresh public ID and queues the given AI
+	// jobs in the same transaction. It fills in ID, RowID, the timestamps,
+	// Tags and Jobs.
+	CreateDream(ctx context.Context, d *model.Dream, jobKinds ...jobs.Kind) error
+	GetDream(ctx context.Context, publicID string) (*model.Dream, error)
+	GetDreamByRowID(ctx context.Context, rowID int) (*model.Dream, error)
+	// ListDreams returns dreams newest first and the cursor of the next
+	// page, which is nil on the last page
+	ListDreams(ctx context.Context, f model.DreamFilter) ([]model.Dream, *model.Cursor, error)
+	// PopularDreams returns up to limit public dreams created between since
+	// and until inclusive, those with the most comments and reactions first
+	// and newest first among equals. Dreams by users who blocked, or were
+	// blocked by, viewerID are left out.
+	PopularDreams(ctx context.Context, viewerID string, since, until time.Time, limit int) ([]model.Dream, error)
+	DeleteDream(ctx context.Context, rowID int) error
+	// ReplaceTags swaps the dream's tags for tags, skipping blank ones
+	ReplaceTags(ctx context.Context, rowID int, tags []string) error
+	// SetSummary caches an AI summary unless the dream's text has changed
+	// since it was read
+	SetSummary(ctx context.Context, rowID int, text, summary string) error
+	SearchDreams(ctx context.Context, f model.SearchFilter) (*model.SearchResponse, error)
+
+	// EditDream applies mutate to the dream's current state and records the
+	// result as a new revision. The first edit also stores the original
+	// state as revision 1. Changing the text clears the cached AI summary
+	// and prophecy. Returns ErrNoChanges if mutate leaves it unchanged.
+	EditDream(ctx context.Context, rowID int, editorID string, mutate func(*model.DreamState)) (*model.Dream, error)
+	// ListRevisions returns every stored revision, newest first
+	ListRevisions(ctx context.Context, rowID int) ([]model.DreamRevision, error)
+	GetRevision(ctx context.Context, rowID, revision int) (*model.DreamRevision, error)
+	// LatestRevision returns the highest revision number, or 0 if the dream
+	// was never edited
+	LatestRevision(ctx context.Context, rowID int) (int, error)
+
+	DreamStats(ctx context.Context, userID string, dr model.DateRange, topTags int) (*model.DreamStats, error)
+	// TagAnalytics returns the user's top tags ordered by usage. Tags are
+	// compared case-insensitively and counted once per dream.
+	TagAnalytics(ctx context.Context, userID string, includePrivate bool, dr model.DateRange, limit, related int) ([]model.TagAnalytics, error)
+}
+
+// FriendStore manages friend requests, friendships and blocks. Each
+// direction of a friendship is its own row; a block is a single row owned by
+// the blocker.
+type FriendStore interface {
+	// FriendStatus returns the status of the userID -> friendID row:
+	// pending, accepted, rejected or blocked
+	FriendStatus(ctx context.Context, userID, friendID string) (string, error)
+	// RequestFriend sends userID's request to friendID and returns the
+	// status the sender sees. A pending request the other way is accepted
+	// instead, an existing request or friendship is left alone, and a
+	// rejected request still looks pending. created reports whether this
+	// call made a new request or friendship rather than finding one.
+	// Returns ErrNotFound if friendID does not exist or has blocked userID,
+	// and ErrConflict if userID has blocked friendID.
+	RequestFriend(ctx context.Context, userID, friendID string) (status string, created bool, err error)
+	// AcceptFriend accepts userID's pending or rejected request to friendID
+	// and adds the reciprocal row. Returns ErrNotFound if there is no such
+	// request.
+	AcceptFriend(ctx context.Context, userID, friendID string) error
+	// RejectFriend marks userID's pending request to friendID rejected.
+	// Returns ErrNotFound if there is no pending request.
+	RejectFriend(ctx context.Context, userID, friendID string) error
+	// CancelFriendRequest withdraws userID's pending or rejected request to
+	// friendID. Returns ErrNotFound if there is no such request.
+	CancelFriendRequest(ctx context.Context, userID, friendID string) error
+	// RemoveFriend deletes the friendship or request in both directions.
+	// Blocks are left in place.
+	RemoveFriend(ctx context.Context, userID, friendID string) error
+	// BlockUser ends any friendship or request between the two users and
+	// records that userID blocked blockedID. Returns ErrNotFound if
+	// blockedID does not exist.
+	BlockUser(ctx context.Context, userID, blockedID string) error
+	// UnblockUser lifts userID's block on blockedID. Returns ErrNotFound if
+	// there is no such block.
+	UnblockUser(ctx context.Context, userID, blockedID string) error
+	// Blocked reports whether either user has blocked the other
+	Blocked(ctx context.Context, a, b string) (bool, error)
+	ListFriends(ctx context.Context, userID string) ([]model.UserSummary, error)
+	// ListFriendRequests returns the users with a pending request to
+	// userID, oldest first
+	ListFriendRequests(ctx context.Context, userID string) ([]model.UserSummary, error)
+	// ListSentFriendRequests returns the users userID has an unanswered
+	// request to, oldest first. Rejected requests are included since the
+	// sender is not told about the rejection.
+	ListSentFriendRequests(ctx context.Context, userID string) ([]model.UserSummary, error)
+	// ListBlocked returns the users userID has blocked, by username
+	ListBlocked(ctx context.Context, userID string) ([]model.UserSummary, error)
+	FriendIDs(ctx context.Context, userID string) ([]string, error)
+}
+
+// CommentStore manages comments on dreams and the reports users file
+// against them
+type CommentStore interface {
+	// CreateComment inserts c and fills in its ID, timestamps and User.
+	// Returns ErrNotFound if c.ParentID is set but is not a comment on the
+	// same dream.
+	CreateComment(ctx context.Context, c *model.Comment) error
+	GetComment(ctx context.Context, id int) (*model.Comment, error)
+	// ListComments returns a dream's comments oldest first, replies
+	// included, leaving out those by users who blocked, or were blocked by,
+	// viewerID
+	ListComments(ctx context.Context, dreamRowID int, viewerID string, page model.Page) ([]model.Comment, *model.Cursor, error)
+	// CountComments returns how many comments, replies included, each of
+	// the dreams has, keyed by row ID. Dreams without comments are missing
+	// from the map.
+	CountComments(ctx context.Context, dreamRowIDs []int) (map[int]int, error)
+	// UpdateComment replaces a comment's text and marks it edited
+	UpdateComment(ctx context.Context, id int, text string) (*model.Comment, error)
+	// DeleteComment deletes a comment along with its replies and reports
+	DeleteComment(ctx context.Context, id int) error
+
+	// ReportComment inserts r, filling in its ID, CreatedAt and the
+	// Comment, Reporter and DreamID from r.Comment.ID and r.Reporter.ID.
+	// Returns ErrNotFound if the comment does not exist and ErrConflict if
+	// the reporter already has an open report on it.
+	ReportComment(ctx context.Context, r *model.CommentReport) error
+	GetReport(ctx context.Context, id int) (*model.CommentReport, error)
+	// ListOpenReports returns the moderation queue, oldest first
+	ListOpenReports(ctx context.Context, page model.Page) ([]model.CommentReport, *model.Cursor, error)
+	// DismissReports resolves every open report on a comment, leaving the
+	// comment in place. Returns ErrNotFound if there were none.
+	DismissReports(ctx context.Context, commentID int, moderatorID string) error
+}
+
+// ReactionStore manages reactions on dreams and comments. Counts are
+// filled into Dream.Reactions and Comment.Reactions by the dream and
+// comment methods.
+type ReactionStore interface {
+	// AddReaction records r.User's reaction of r.Kind on r.Target and fills
+	// in its ID and CreatedAt. Adding one that exists already is a no-op.
+	// Returns ErrNotFound if the user or target does not exist.
+	AddReaction(ctx context.Context, r *model.Reaction) error
+	// RemoveReaction deletes a user's reaction, if any
+	RemoveReaction(ctx context.Context, target model.ReactionTarget, userID string, kind model.ReactionKind) error
+	CountReactions(ctx context.Context, target model.ReactionTarget) (model.ReactionCounts, error)
+	// ListReactions returns who reacted to target, newest first, leaving
+	// out users who blocked, or were blocked by, viewerID. An empty kind
+	// lists every kind.
+	ListReactions(ctx context.Context, target model.ReactionTarget, kind model.ReactionKind, viewerID string, page model.Page) ([]model.Reaction, *model.Cursor, error)
+}
+
+// NotificationStore manages users' notifications and which types of them
+// they want
+type NotificationStore interface {
+	// CreateNotification inserts n and fills in its ID, CreatedAt, Actor
+	// and DreamID. When the recipient has turned n.Type off it stores
+	// nothing and leaves n.ID zero. Returns ErrNotFound if the recipient,
+	// actor, dream or comment does not exist.
+	CreateNotification(ctx context.Context, n *model.Notification) error
+	// ListNotifications returns a user's notifications newest first,
+	// leaving out those from users they blocked or were blocked by
+	ListNotifications(ctx context.Context, userID string, unreadOnly bool, page model.Page) ([]model.Notification, *model.Cursor, error)
+	// MarkNotificationsRead marks the user's notifications in ids read, or
+	// all of them when ids is nil, and returns how many were unread
+	MarkNotificationsRead(ctx context.Context, userID string, ids []int) (int, error)
+	// CountUnreadNotifications counts the notifications ListNotifications
+	// would return as unread, by type
+	CountUnreadNotifications(ctx context.Context, userID string) (model.NotificationCounts, error)
+	// NotificationPrefs returns which types the user wants. Returns
+	// ErrNotFound if the user does not exist.
+	NotificationPrefs(ctx context.Context, userID string) (model.NotificationPrefs, error)
+	// SetNotificationPrefs turns the types in prefs on or off, leaving the
+	// others as they are. Returns ErrNotFound if the user does not exist.
+	SetNotificationPrefs(ctx context.Context, userID string, prefs model.NotificationPrefs) error
+}
+
+// TokenStore manages refresh tokens and one-time account tokens, looked up
+// by the hash of the token
+type TokenStore interface {
+	// CreateRefreshToken inserts t and fills in its ID and CreatedAt.
+	// Returns ErrNotFound if the user does not exist.
+	CreateRefreshToken(ctx context.Context, t *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error)
+	// UseRefreshToken marks an unused token used and returns it. If the
+	// token was already used or revoked it revokes the token's whole family
+	// and returns ErrRevoked. Expiry is left to the caller.
+	UseRefreshToken(ctx context.Context, hash string) (*model.RefreshToken, error)
+	// RevokeRefreshFamily revokes every token in the family
+	RevokeRefreshFamily(ctx context.Context, familyID string) error
+	// RevokeUserRefreshTokens revokes every refresh token of the user,
+	// signing them out everywhere
+	RevokeUserRefreshTokens(ctx context.Context, userID string) error
+
+	// CreateAccountToken inserts t and fills in its ID and CreatedAt. The
+	// user's earlier unused tokens for the same purpose are marked used, so
+	// only the latest link works. Returns ErrNotFound if the user does not
+	// exist.
+	CreateAccountToken(ctx context.Context, t *model.AccountToken) error
+	// UseAccountToken marks an unused token for purpose used and returns
+	// it. Returns ErrNotFound for an unknown token or one issued for
+	// another purpose, and ErrRevoked if it was already used. Expiry is
+	// left to the caller.
+	UseAccountToken(ctx context.Context, purpose, hash string) (*model.AccountToken, error)
+}
@@ -0,0 +1,1732 @@
+// Package storetest is the conformance suite every store.Store
+// implementation must pass. Implementations call Run from their own tests.
+//
+// The suite only creates users with addresses in EmailDomain and only
+// asserts on rows it created, so it can run against a shared database.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+	"github.com/Calrus/ourdreamjournal/backend/internal/model"
+	"github.com/Calrus/ourdreamjournal/backend/internal/store"
+	"github.com/Calrus/ourdreamjournal/backend/jobs"
+)
+
+// EmailDomain is the domain of every user the suite creates, so database
+// backed runs can clean up after themselves
+const EmailDomain = "storetest.invalid"
+
+// seq makes usernames and emails unique across subtests and runs
+var seq atomic.Int64
+
+func init() {
+	seq.Store(time.Now().UnixNano() % 1e9)
+}
+
+// Run runs the suite. newStore returns an empty store, or one whose other
+// contents the suite can ignore, for each subtest.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, st store.Store)
+	}{
+		{"Users", testUsers},
+		{"Usernames", testUsernames},
+		{"Dreams", testDreams},
+		{"ListDreams", testListDreams},
+		{"Visibility", testVisibility},
+		{"TagsAndSummary", testTagsAndSummary},
+		{"Revisions", testRevisions},
+		{"Stats", testStats},
+		{"TagAnalytics", testTagAnalytics},
+		{"Search", testSearch},
+		{"Friends", testFriends},
+		{"FriendRequests", testFriendRequests},
+		{"Blocks", testBlocks},
+		{"Comments", testComments},
+		{"CommentThreads", testCommentThreads},
+		{"CommentReports", testCommentReports},
+		{"Reactions", testReactions},
+		{"PopularDreams", testPopularDreams},
+		{"Notifications", testNotifications},
+		{"RefreshTokens", testRefreshTokens},
+		{"AccountTokens", testAccountTokens},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// newUser creates a user whose username starts with name
+func newUser(t *testing.T, st store.Store, name string) *model.User {
+	t.Helper()
+	n := seq.Add(1)
+	u := &model.User{
+		Email:    fmt.Sprintf("%s%d@%s", name, n, EmailDomain),
+		Username: fmt.Sprintf("%s%d", name, n),
+	}
+	if err := st.CreateUser(context.Background(), u, "hash-"+name); err != nil {
+		t.Fatalf("CreateUser(%s): %v", name, err)
+	}
+	return u
+}
+
+// newDream creates a public or private dream owned by userID
+func newDream(t *testing.T, st store.Store, userID, title, text string, public bool, jobKinds ...jobs.Kind) *model.Dream {
+	t.Helper()
+	d := &model.Dream{UserID: userID, Title: title, Text: text, Visibility: model.VisibilityOf(public)}
+	if err := st.CreateDream(context.Background(), d, jobKinds...); err != nil {
+		t.Fatalf("CreateDream(%q): %v", title, err)
+	}
+	return d
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// ids returns the public IDs of dreams, keeping only those in keep when it
+// is non-nil
+func ids(dreams []model.Dream, keep map[string]bool) []string {
+	list := []string{}
+	for _, d := range dreams {
+		if keep == nil || keep[d.ID] {
+			list = append(list, d.ID)
+		}
+	}
+	return list
+}
+
+func set(dreams ...*model.Dream) map[string]bool {
+	m := map[string]bool{}
+	for _, d := range dreams {
+		m[d.ID] = true
+	}
+	return m
+}
+
+func expectIDs(t *testing.T, what string, got []string, want ...*model.Dream) {
+	t.Helper()
+	wantIDs := []string{}
+	for _, d := range want {
+		wantIDs = append(wantIDs, d.ID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantIDs) {
+		t.Errorf("%s = %v, want %v", what, got, wantIDs)
+	}
+}
+
+func expectErr(t *testing.T, what string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: got error %v, want %v", what, err, want)
+	}
+}
+
+func testUsers(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st, "ann")
+	if u.ID == "" || u.CreatedAt == 0 || u.IsAdmin {
+		t.Fatalf("CreateUser did not fill in the user: %+v", u)
+	}
+
+	dup := &model.User{Email: u.Email, Username: "other"}
+	expectErr(t, "duplicate email", st.CreateUser(ctx, dup, "x"), store.ErrConflict)
+
+	got, err := st.GetUser(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != u.Email || got.Username != u.Username {
+		t.Errorf("GetUser = %+v, want %+v", got, u)
+	}
+	got, err = st.GetUserByUsername(ctx, u.Username)
+	if err != nil || got.ID != u.ID {
+		t.Errorf("GetUserByUsername = %+v, %v", got, err)
+	}
+	got, hash, err := st.GetCredentials(ctx, u.Email)
+	if err != nil || got.ID != u.ID || hash != "hash-ann" {
+		t.Errorf("GetCredentials = %+v, %q, %v", got, hash, err)
+	}
+
+	if err := st.UpdateProfile(ctx, u.ID, "Ann", "dreamer", "https://example.com/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = st.GetUser(ctx, u.ID)
+	if got.DisplayName != "Ann" || got.Description != "dreamer" || got.ProfileImageURL != "https://example.com/a.png" {
+		t.Errorf("profile not updated: %+v", got)
+	}
+
+	_, err = st.GetUser(ctx, "999999999")
+	expectErr(t, "GetUser of a missing user", err, store.ErrNotFound)
+	_, err = st.GetUserByUsername(ctx, "nobody-"+u.Username)
+	expectErr(t, "GetUserByUsername of a missing user", err, store.ErrNotFound)
+	_, _, err = st.GetCredentials(ctx, "nobody@"+EmailDomain)
+	expectErr(t, "GetCredentials of a missing user", err, store.ErrNotFound)
+	expectErr(t, "UpdateProfile of a missing user", st.UpdateProfile(ctx, "999999999", "", "", ""), store.ErrNotFound)
+}
+
+func testUsernames(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	email := func() string { return fmt.Sprintf("u%d@%s", seq.Add(1), EmailDomain) }
+
+	// Usernames are unique in any case
+	upper := &model.User{Email: email(), Username: strings.ToUpper(ann.Username)}
+	expectErr(t, "username differing in case", st.CreateUser(ctx, upper, "x"), store.ErrConflict)
+	got, err := st.GetUserByUsername(ctx, strings.ToUpper(ann.Username))
+	if err != nil || got.ID != ann.ID {
+		t.Errorf("GetUserByUsername in upper case = %+v, %v", got, err)
+	}
+
+	// Renaming keeps the old name as an alias
+	renamed := ann.Username + "_new"
+	if err := st.RenameUser(ctx, ann.ID, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := st.GetUserByUsername(ctx, renamed); err != nil || got.ID != ann.ID {
+		t.Errorf("GetUserByUsername after rename = %+v, %v", got, err)
+	}
+	_, err = st.GetUserByUsername(ctx, ann.Username)
+	expectErr(t, "GetUserByUsername of the old name", err, store.ErrNotFound)
+	if got, err := st.GetUserByAlias(ctx, strings.ToUpper(ann.Username)); err != nil || got.ID != ann.ID || got.Username != renamed {
+		t.Errorf("GetUserByAlias = %+v, %v", got, err)
+	}
+	_, err = st.GetUserByAlias(ctx, renamed)
+	expectErr(t, "GetUserByAlias of a current name", err, store.ErrNotFound)
+
+	// Nobody else can take the old name or the new one
+	expectErr(t, "registering a former name", st.CreateUser(ctx, &model.User{Email: email(), Username: ann.Username}, "x"), store.ErrConflict)
+	expectErr(t, "renaming to a former name", st.RenameUser(ctx, bob.ID, ann.Username), store.ErrConflict)
+	expectErr(t, "renaming to a taken name", st.RenameUser(ctx, bob.ID, strings.ToUpper(renamed)), store.ErrConflict)
+	expectErr(t, "renaming a missing user", st.RenameUser(ctx, "999999999", "nobody"+renamed), store.ErrNotFound)
+
+	// but the owner can take it back, and changing case leaves no alias
+	if err := st.RenameUser(ctx, ann.ID, strings.ToUpper(ann.Username)); err != nil {
+		t.Fatalf("taking back a former name: %v", err)
+	}
+	_, err = st.GetUserByAlias(ctx, ann.Username)
+	expectErr(t, "GetUserByAlias of a name taken back", err, store.ErrNotFound)
+	if err := st.RenameUser(ctx, ann.ID, ann.Username); err != nil {
+		t.Fatalf("changing case: %v", err)
+	}
+	_, err = st.GetUserByAlias(ctx, strings.ToUpper(ann.Username))
+	expectErr(t, "GetUserByAlias after a change of case", err, store.ErrNotFound)
+	if got, _ := st.GetUser(ctx, ann.ID); got.Username != ann.Username {
+		t.Errorf("username = %q, want %q", got.Username, ann.Username)
+	}
+	if got, err := st.GetUserByAlias(ctx, renamed); err != nil || got.ID != ann.ID {
+		t.Errorf("GetUserByAlias of the intermediate name = %+v, %v", got, err)
+	}
+}
+
+func testDreams(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st, "ann")
+	st.UpdateProfile(ctx, u.ID, "Ann", "", "https://example.com/a.png")
+
+	d := &model.Dream{UserID: u.ID, Title: "Ocean", Text: "Swimming with whales.", Visibility: model.VisibilityPublic, NightmareRating: intPtr(2), ClarityRating: intPtr(9)}
+	if err := st.CreateDream(ctx, d, jobs.KindTags); err != nil {
+		t.Fatal(err)
+	}
+	if d.ID == "" || d.RowID == 0 || d.CreatedAt.IsZero() || d.Tags == nil {
+		t.Fatalf("CreateDream did not fill in the dream: %+v", d)
+	}
+	if len(d.Jobs) != 1 || d.Jobs[0].Kind != jobs.KindTags || d.Jobs[0].Status != jobs.StatusPending || d.Jobs[0].DreamID != d.RowID {
+		t.Errorf("CreateDream queued %+v, want one pending tags job", d.Jobs)
+	}
+	other := newDream(t, st, u.ID, "Ocean", "Again.", true)
+	if other.ID == d.ID {
+		t.Errorf("two dreams share the public ID %s", d.ID)
+	}
+
+	for _, get := range []func() (*model.Dream, error){
+		func() (*model.Dream, error) { return st.GetDream(ctx, d.ID) },
+		func() (*model.Dream, error) { return st.GetDreamByRowID(ctx, d.RowID) },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != d.ID || got.RowID != d.RowID || got.UserID != u.ID || got.Title != "Ocean" || got.Text != d.Text || got.Visibility != model.VisibilityPublic {
+			t.Errorf("loaded dream = %+v", got)
+		}
+		if got.Username != u.Username || got.DisplayName != "Ann" || got.ProfileImageURL != "https://example.com/a.png" {
+			t.Errorf("loaded dream is missing the author's profile: %+v", got)
+		}
+		if got.NightmareRating == nil || *got.NightmareRating != 2 || got.ClarityRating == nil || *got.ClarityRating != 9 || got.VividnessRating != nil {
+			t.Errorf("loaded dream ratings are wrong: %+v", got)
+		}
+		if got.Tags == nil || len(got.Tags) != 0 || len(got.Jobs) != 0 {
+			t.Errorf("loaded dream should have empty tags and no jobs: %+v", got)
+		}
+	}
+
+	_, err := st.GetDream(ctx, "missing!!!")
+	expectErr(t, "GetDream of a missing dream", err, store.ErrNotFound)
+	_, err = st.GetDreamByRowID(ctx, -1)
+	expectErr(t, "GetDreamByRowID of a missing dream", err, store.ErrNotFound)
+
+	if err := st.DeleteDream(ctx, d.RowID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.GetDream(ctx, d.ID)
+	expectErr(t, "GetDream after delete", err, store.ErrNotFound)
+	expectErr(t, "second delete", st.DeleteDream(ctx, d.RowID), store.ErrNotFound)
+}
+
+func testListDreams(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	annPublic := newDream(t, st, ann.ID, "a1", "Public.", true)
+	annPrivate := newDream(t, st, ann.ID, "a2", "Private.", false)
+	bobPublic := newDream(t, st, bob.ID, "b1", "Public.", true)
+	bobPrivate := newDream(t, st, bob.ID, "b2", "Private.", false)
+	ours := set(annPublic, annPrivate, bobPublic, bobPrivate)
+
+	cases := []struct {
+		name string
+		f    model.DreamFilter
+		want []*model.Dream
+	}{
+		{"anonymous", model.DreamFilter{}, []*model.Dream{bobPublic, annPublic}},
+		{"viewer", model.DreamFilter{Viewer: ann.ID}, []*model.Dream{bobPublic, annPrivate, annPublic}},
+		{"public only", model.DreamFilter{Viewer: ann.ID, PublicOnly: true}, []*model.Dream{bobPublic, annPublic}},
+		{"own dreams", model.DreamFilter{Owners: []string{ann.ID}, Viewer: ann.ID}, []*model.Dream{annPrivate, annPublic}},
+		{"someone else's dreams", model.DreamFilter{Owners: []string{ann.ID}, Viewer: bob.ID}, []*model.Dream{annPublic}},
+		{"several owners", model.DreamFilter{Owners: []string{ann.ID, bob.ID}}, []*model.Dream{bobPublic, annPublic}},
+		{"no owners", model.DreamFilter{Owners: []string{}, Viewer: ann.ID}, nil},
+	}
+	for _, c := range cases {
+		dreams, next, err := st.ListDreams(ctx, c.f)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if next != nil {
+			t.Errorf("%s: unlimited listing returned a cursor", c.name)
+		}
+		expectIDs(t, c.name, ids(dreams, ours), c.want...)
+	}
+
+	// Page through ann's dreams one at a time
+	extra := newDream(t, st, ann.ID, "a3", "Later.", true)
+	var seen []string
+	page := model.Page{Limit: 1}
+	for i := 0; ; i++ {
+		if i > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		dreams, next, err := st.ListDreams(ctx, model.DreamFilter{Owners: []string{ann.ID}, Viewer: ann.ID, Page: page})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dreams) > 1 {
+			t.Fatalf("page of %d dreams, want at most 1", len(dreams))
+		}
+		seen = append(seen, ids(dreams, nil)...)
+		if next == nil {
+			break
+		}
+		page.After = next
+	}
+	expectIDs(t, "paginated listing", seen, extra, annPrivate, annPublic)
+
+	dreams, next, err := st.ListDreams(ctx, model.DreamFilter{Owners: []string{ann.ID}, Viewer: ann.ID, Page: model.Page{Limit: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dreams) != 3 || next != nil {
+		t.Errorf("exactly full page: %d dreams, cursor %v; want 3 and no cursor", len(dreams), next)
+	}
+}
+
+func testVisibility(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+	st.RequestFriend(ctx, bob.ID, ann.ID)
+	st.AcceptFriend(ctx, bob.ID, ann.ID)
+
+	create := func(title string, v model.Visibility) *model.Dream {
+		t.Helper()
+		d := &model.Dream{UserID: ann.ID, Title: title, Text: "A whale at the window.", Visibility: v}
+		if err := st.CreateDream(ctx, d); err != nil {
+			t.Fatalf("CreateDream(%q): %v", title, err)
+		}
+		return d
+	}
+	private := create("private", model.VisibilityPrivate)
+	friends := create("friends", model.VisibilityFriends)
+	public := create("public", model.VisibilityPublic)
+	unlisted := create("unlisted", model.VisibilityUnlisted)
+	ours := set(private, friends, public, unlisted)
+
+	got, err := st.GetDream(ctx, unlisted.ID)
+	if err != nil || got.Visibility != model.VisibilityUnlisted {
+		t.Errorf("GetDream(unlisted) = %+v, %v", got, err)
+	}
+	d := &model.Dream{UserID: ann.ID, Title: "default", Text: "No visibility given."}
+	if err := st.CreateDream(ctx, d); err != nil || d.Visibility != model.VisibilityPrivate {
+		t.Errorf("CreateDream without visibility: %q, %v; want private", d.Visibility, err)
+	}
+
+	for _, c := range []struct {
+		name string
+		f    model.DreamFilter
+		want []*model.Dream
+	}{
+		{"owner", model.DreamFilter{Owners: []string{ann.ID}, Viewer: ann.ID}, []*model.Dream{unlisted, public, friends, private}},
+		{"friend", model.DreamFilter{Owners: []string{ann.ID}, Viewer: bob.ID}, []*model.Dream{public, friends}},
+		{"stranger", model.DreamFilter{Owners: []string{ann.ID}, Viewer: carl.ID}, []*model.Dream{public}},
+		{"anonymous", model.DreamFilter{Owners: []string{ann.ID}}, []*model.Dream{public}},
+		{"friend, public only", model.DreamFilter{Owners: []string{ann.ID}, Viewer: bob.ID, PublicOnly: true}, []*model.Dream{public}},
+	} {
+		dreams, _, err := st.ListDreams(ctx, c.f)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		expectIDs(t, c.name, ids(dreams, ours), c.want...)
+	}
+
+	search := func(f model.SearchFilter) []string {
+		t.Helper()
+		f.Query, f.Limit = "whale", 20
+		resp, err := st.SearchDreams(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dreams []model.Dream
+		for _, r := range resp.Results {
+			dreams = append(dreams, r.Dream)
+		}
+		return ids(dreams, ours)
+	}
+	expectIDs(t, "search mine", search(model.SearchFilter{Scope: "mine", ViewerID: ann.ID}), unlisted, public, friends, private)
+	expectIDs(t, "search as friend", search(model.SearchFilter{Scope: "all", ViewerID: bob.ID}), public, friends)
+	expectIDs(t, "search friends", search(model.SearchFilter{Scope: "friends", ViewerID: bob.ID}), public, friends)
+	expectIDs(t, "search public as friend", search(model.SearchFilter{Scope: "public", ViewerID: bob.ID}), public)
+	expectIDs(t, "search as stranger", search(model.SearchFilter{Scope: "all", ViewerID: carl.ID}), public)
+	expectIDs(t, "search anonymously", search(model.SearchFilter{Scope: "all"}), public)
+}
+
+func testTagsAndSummary(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st, "ann")
+	d := newDream(t, st, u.ID, "Ocean", "Swimming with whales.", true)
+
+	if err := st.ReplaceTags(ctx, d.RowID, []string{"water", " ", "whales"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := st.GetDream(ctx, d.ID)
+	if fmt.Sprint(got.Tags) != "[water whales]" {
+		t.Errorf("tags = %v, want [water whales]", got.Tags)
+	}
+	if err := st.ReplaceTags(ctx, d.RowID, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = st.GetDream(ctx, d.ID)
+	if len(got.Tags) != 0 {
+		t.Errorf("tags after clearing = %v", got.Tags)
+	}
+
+	// A summary of text that has since changed is dropped
+	if err := st.SetSummary(ctx, d.RowID, "Old text.", "Stale."); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = st.GetDream(ctx, d.ID)
+	if got.Summary != "" {
+		t.Errorf("stale summary stored: %q", got.Summary)
+	}
+	if err := st.SetSummary(ctx, d.RowID, d.Text, "Whales."); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = st.GetDream(ctx, d.ID)
+	if got.Summary != "Whales." || got.SummaryHash != ai.ContentHash(d.Text) {
+		t.Errorf("summary = %q (hash %q)", got.Summary, got.SummaryHash)
+	}
+}
+
+func testRevisions(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	admin := newUser(t, st, "admin")
+	d := newDream(t, st, ann.ID, "Ocean", "Swimming with whales.", false)
+	st.SetSummary(ctx, d.RowID, d.Text, "Whales.")
+
+	latest, err := st.LatestRevision(ctx, d.RowID)
+	if err != nil || latest != 0 {
+		t.Errorf("LatestRevision of an unedited dream = %d, %v", latest, err)
+	}
+	_, err = st.EditDream(ctx, d.RowID, ann.ID, func(s *model.DreamState) {})
+	expectErr(t, "no-op edit", err, store.ErrNoChanges)
+	_, err = st.EditDream(ctx, -1, ann.ID, func(s *model.DreamState) { s.Title = "x" })
+	expectErr(t, "editing a missing dream", err, store.ErrNotFound)
+
+	edited, err := st.EditDream(ctx, d.RowID, ann.ID, func(s *model.DreamState) {
+		s.Title = "Deep ocean"
+		s.ClarityRating = intPtr(7)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Title != "Deep ocean" || edited.ClarityRating == nil || *edited.ClarityRating != 7 || edited.Summary != "Whales." {
+		t.Errorf("after a title edit = %+v", edited)
+	}
+	edited, err = st.EditDream(ctx, d.RowID, admin.ID, func(s *model.DreamState) {
+		s.Text = "Swimming with sharks."
+		s.Visibility = model.VisibilityFriends
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Text != "Swimming with sharks." || edited.Visibility != model.VisibilityFriends || edited.Summary != "" || edited.SummaryHash != "" || edited.Prophecy != "" {
+		t.Errorf("a text edit should clear the cached AI output: %+v", edited)
+	}
+
+	latest, _ = st.LatestRevision(ctx, d.RowID)
+	if latest != 3 {
+		t.Errorf("LatestRevision = %d, want 3", latest)
+	}
+	revs, err := st.ListRevisions(ctx, d.RowID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("ListRevisions returned %d revisions, want 3", len(revs))
+	}
+	for i, want := range []int{3, 2, 1} {
+		if revs[i].Revision != want {
+			t.Errorf("revision order = %d at %d, want %d", revs[i].Revision, i, want)
+		}
+	}
+	first, err := st.GetRevision(ctx, d.RowID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Title != "Ocean" || first.Text != "Swimming with whales." || first.Visibility != model.VisibilityPrivate || first.ClarityRating != nil || first.EditedBy != ann.ID {
+		t.Errorf("revision 1 = %+v", first)
+	}
+	third, _ := st.GetRevision(ctx, d.RowID, 3)
+	if third.Text != "Swimming with sharks." || third.EditedBy != admin.ID || third.ClarityRating == nil {
+		t.Errorf("revision 3 = %+v", third)
+	}
+	_, err = st.GetRevision(ctx, d.RowID, 4)
+	expectErr(t, "GetRevision past the latest", err, store.ErrNotFound)
+
+	// Restoring is an edit like any other
+	restored, err := st.EditDream(ctx, d.RowID, ann.ID, func(s *model.DreamState) { *s = first.State() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Title != "Ocean" || restored.Visibility != model.VisibilityPrivate || restored.ClarityRating != nil {
+		t.Errorf("restored dream = %+v", restored)
+	}
+	if latest, _ = st.LatestRevision(ctx, d.RowID); latest != 4 {
+		t.Errorf("LatestRevision after restore = %d, want 4", latest)
+	}
+}
+
+func testStats(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st, "ann")
+	for i, r := range []struct {
+		public    bool
+		nightmare *int
+		tags      []string
+	}{
+		{true, intPtr(3), []string{"Water", "flying"}},
+		{false, intPtr(5), []string{"water"}},
+		{false, nil, []string{" WATER ", "teeth", "water"}},
+	} {
+		d := &model.Dream{UserID: u.ID, Title: fmt.Sprint("dream ", i), Text: "Text.", Visibility: model.VisibilityOf(r.public), NightmareRating: r.nightmare}
+		if err := st.CreateDream(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.ReplaceTags(ctx, d.RowID, r.tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := st.DreamStats(ctx, u.ID, model.DateRange{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalDreams != 3 || stats.PublicDreams != 1 || stats.PrivateDreams != 2 {
+		t.Errorf("totals = %d/%d/%d, want 3/1/2", stats.TotalDreams, stats.PublicDreams, stats.PrivateDreams)
+	}
+	nightmare := stats.Ratings["nightmare"]
+	if nightmare.Count != 2 || nightmare.Average == nil || *nightmare.Average != 4 || nightmare.Histogram[2] != 1 || nightmare.Histogram[4] != 1 {
+		t.Errorf("nightmare rating stats = %+v", nightmare)
+	}
+	if clarity := stats.Ratings["clarity"]; clarity.Count != 0 || clarity.Average != nil {
+		t.Errorf("unrated clarity stats = %+v", clarity)
+	}
+	if fmt.Sprint(stats.MostCommonTags) != "[{water water 3} {flying flying 1}]" {
+		t.Errorf("most common tags = %v", stats.MostCommonTags)
+	}
+	perDay := 0
+	for _, p := range stats.DreamsPerDay {
+		perDay += p.Count
+	}
+	if perDay != 3 || len(stats.DreamsPerMonth) == 0 || len(stats.DreamsPerWeek) == 0 {
+		t.Errorf("per-period counts = %v / %v / %v", stats.DreamsPerDay, stats.DreamsPerWeek, stats.DreamsPerMonth)
+	}
+	if len(stats.DreamFrequency) != 30 {
+		t.Errorf("dream frequency covers %d days, want 30", len(stats.DreamFrequency))
+	}
+	if stats.Streak.Longest != 1 || stats.Streak.LastDreamOn == nil {
+		t.Errorf("streak = %+v", stats.Streak)
+	}
+
+	from := time.Now().Add(time.Hour)
+	stats, err = st.DreamStats(ctx, u.ID, model.DateRange{From: &from}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalDreams != 0 || len(stats.MostCommonTags) != 0 || stats.Streak.Longest != 0 {
+		t.Errorf("stats for a future range = %+v", stats)
+	}
+
+	// A huge range keeps the daily series to its most recent days
+	from = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats, err = st.DreamStats(ctx, u.ID, model.DateRange{From: &from}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalDreams != 3 || len(stats.DreamFrequency) != model.MaxFrequencyDays {
+		t.Errorf("stats since year 1: %d dreams, %d days", stats.TotalDreams, len(stats.DreamFrequency))
+	}
+}
+
+func testTagAnalytics(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st, "ann")
+	for _, r := range []struct {
+		public bool
+		tags   []string
+	}{
+		{true, []string{"Water", "flying"}},
+		{true, []string{"water"}},
+		{false, []string{"water", "teeth"}},
+	} {
+		d := newDream(t, st, u.ID, "Dream", "Text.", r.public)
+		if err := st.ReplaceTags(ctx, d.RowID, r.tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	public, err := st.TagAnalytics(ctx, u.ID, false, model.DateRange{}, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(public) != 2 || public[0].Name != "water" || public[0].Count != 2 || public[1].Name != "flying" {
+		t.Fatalf("public tags = %+v", public)
+	}
+	if fmt.Sprint(public[0].CoOccurring) != "[{flying flying 1}]" {
+		t.Errorf("water co-occurs with %v", public[0].CoOccurring)
+	}
+	trend := 0
+	for _, p := range public[0].Trend {
+		trend += p.Count
+	}
+	if trend != 2 || public[0].FirstSeen.After(public[0].LastSeen) {
+		t.Errorf("water trend = %v, first %v, last %v", public[0].Trend, public[0].FirstSeen, public[0].LastSeen)
+	}
+
+	all, err := st.TagAnalytics(ctx, u.ID, true, model.DateRange{}, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Name != "water" || all[0].Count != 3 {
+		t.Fatalf("all tags = %+v", all)
+	}
+	if len(all[0].CoOccurring) != 1 || all[0].CoOccurring[0].Name != "flying" {
+		t.Errorf("top related tag of water = %v, want flying (ties break by name)", all[0].CoOccurring)
+	}
+
+	top, err := st.TagAnalytics(ctx, u.ID, true, model.DateRange{}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 1 || top[0].Name != "water" || len(top[0].CoOccurring) != 0 {
+		t.Errorf("limit 1 without related tags = %+v", top)
+	}
+}
+
+func testSearch(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+	titleMatch := newDream(t, st, ann.ID, "Whale song", "I heard singing in the deep.", true)
+	textMatch := newDream(t, st, ann.ID, "Ocean", "Swimming with whales near a lighthouse.", true)
+	private := newDream(t, st, ann.ID, "Secret", "A whale in my bathtub.", false)
+	bobs := newDream(t, st, bob.ID, "Forest", "A whale in the forest.", true)
+	newDream(t, st, bob.ID, "Exam", "Late for an exam.", true)
+	ours := set(titleMatch, textMatch, private, bobs)
+	st.RequestFriend(ctx, carl.ID, bob.ID)
+	st.AcceptFriend(ctx, carl.ID, bob.ID)
+
+	search := func(f model.SearchFilter) *model.SearchResponse {
+		t.Helper()
+		if f.Limit == 0 {
+			f.Limit = 20
+		}
+		resp, err := st.SearchDreams(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resultIDs := func(resp *model.SearchResponse) []string {
+		var dreams []model.Dream
+		for _, r := range resp.Results {
+			dreams = append(dreams, r.Dream)
+		}
+		return ids(dreams, ours)
+	}
+
+	// Title matches rank first, then newer dreams
+	resp := search(model.SearchFilter{Query: "whale", Scope: "mine", ViewerID: ann.ID})
+	expectIDs(t, "mine", resultIDs(resp), titleMatch, private, textMatch)
+	if resp.Total != 3 || resp.Limit != 20 {
+		t.Errorf("total %d, limit %d", resp.Total, resp.Limit)
+	}
+	top := resp.Results[0]
+	if top.Rank <= 0 || top.TitleHighlight != "<mark>Whale</mark> song" || top.Dream.Username != ann.Username {
+		t.Errorf("top result = %+v", top)
+	}
+
+	expectIDs(t, "anonymous", resultIDs(search(model.SearchFilter{Query: "whale", Scope: "all"})), titleMatch, bobs, textMatch)
+	expectIDs(t, "all for owner", resultIDs(search(model.SearchFilter{Query: "whale", Scope: "all", ViewerID: ann.ID})), titleMatch, bobs, private, textMatch)
+	expectIDs(t, "public", resultIDs(search(model.SearchFilter{Query: "whale", Scope: "public", ViewerID: ann.ID})), titleMatch, bobs, textMatch)
+	expectIDs(t, "friends", resultIDs(search(model.SearchFilter{Query: "whale", Scope: "friends", ViewerID: carl.ID})), bobs)
+	expectIDs(t, "several terms", resultIDs(search(model.SearchFilter{Query: "whale lighthouse", Scope: "all"})), textMatch)
+	expectIDs(t, "no match", resultIDs(search(model.SearchFilter{Query: "volcano", Scope: "all"})))
+
+	if err := st.ReplaceTags(ctx, textMatch.RowID, []string{"Water"}); err != nil {
+		t.Fatal(err)
+	}
+	expectIDs(t, "tag filter", resultIDs(search(model.SearchFilter{Query: "whale", Scope: "all", Tags: []string{"water"}})), textMatch)
+
+	rated, err := st.EditDream(ctx, titleMatch.RowID, ann.ID, func(s *model.DreamState) { s.NightmareRating = intPtr(8) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := model.SearchFilter{Query: "whale", Scope: "all", RatingMin: map[string]int{"nightmare": 5}}
+	expectIDs(t, "rating filter", resultIDs(search(f)), rated)
+	f.RatingMax = map[string]int{"nightmare": 7}
+	expectIDs(t, "rating range", resultIDs(search(f)))
+
+	future := time.Now().Add(time.Hour)
+	expectIDs(t, "date filter", resultIDs(search(model.SearchFilter{Query: "whale", Scope: "all", Dates: model.DateRange{From: &future}})))
+
+	paged := search(model.SearchFilter{Query: "whale", Scope: "mine", ViewerID: ann.ID, Limit: 2, Offset: 2})
+	if paged.Total != 3 || len(paged.Results) != 1 || paged.Offset != 2 {
+		t.Errorf("second page: total %d, %d results", paged.Total, len(paged.Results))
+	}
+	past := search(model.SearchFilter{Query: "whale", Scope: "mine", ViewerID: ann.ID, Limit: 2, Offset: 10})
+	if past.Total != 3 || len(past.Results) != 0 {
+		t.Errorf("offset past the end: total %d, %d results", past.Total, len(past.Results))
+	}
+}
+
+func testFriends(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+
+	_, err := st.FriendStatus(ctx, ann.ID, bob.ID)
+	expectErr(t, "FriendStatus without a request", err, store.ErrNotFound)
+
+	if status, created, err := st.RequestFriend(ctx, ann.ID, bob.ID); err != nil || status != "pending" || !created {
+		t.Fatalf("RequestFriend = %q, %v, %v", status, created, err)
+	}
+	if _, _, err := st.RequestFriend(ctx, carl.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status, created, err := st.RequestFriend(ctx, ann.ID, bob.ID); err != nil || status != "pending" || created {
+		t.Fatalf("repeated request = %q, %v, %v", status, created, err)
+	}
+	_, _, err = st.RequestFriend(ctx, ann.ID, "999999")
+	expectErr(t, "request to a missing user", err, store.ErrNotFound)
+	if status, err := st.FriendStatus(ctx, ann.ID, bob.ID); err != nil || status != "pending" {
+		t.Errorf("status after request = %q, %v", status, err)
+	}
+	requests, err := st.ListFriendRequests(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[0].ID != ann.ID || requests[1].ID != carl.ID || requests[0].Username != ann.Username {
+		t.Errorf("bob's requests = %+v, want ann then carl", requests)
+	}
+	sent, err := st.ListSentFriendRequests(ctx, ann.ID)
+	if err != nil || len(sent) != 1 || sent[0].ID != bob.ID {
+		t.Errorf("ann's sent requests = %+v, %v", sent, err)
+	}
+	expectErr(t, "accepting a request that was not sent", st.AcceptFriend(ctx, bob.ID, ann.ID), store.ErrNotFound)
+
+	if err := st.AcceptFriend(ctx, ann.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range [][2]string{{ann.ID, bob.ID}, {bob.ID, ann.ID}} {
+		if status, err := st.FriendStatus(ctx, pair[0], pair[1]); err != nil || status != "accepted" {
+			t.Errorf("status %s -> %s = %q, %v", pair[0], pair[1], status, err)
+		}
+	}
+	requests, _ = st.ListFriendRequests(ctx, bob.ID)
+	if len(requests) != 1 || requests[0].ID != carl.ID {
+		t.Errorf("bob's requests after accepting ann = %+v", requests)
+	}
+	if err := st.AcceptFriend(ctx, carl.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	sent, _ = st.ListSentFriendRequests(ctx, ann.ID)
+	if len(sent) != 0 {
+		t.Errorf("ann's sent requests after acceptance = %+v", sent)
+	}
+
+	friends, err := st.ListFriends(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) != 2 || friends[0].ID != ann.ID || friends[1].ID != carl.ID {
+		t.Errorf("bob's friends = %+v, want ann and carl by username", friends)
+	}
+	friendIDs, err := st.FriendIDs(ctx, ann.ID)
+	if err != nil || fmt.Sprint(friendIDs) != fmt.Sprint([]string{bob.ID}) {
+		t.Errorf("ann's friend IDs = %v, %v", friendIDs, err)
+	}
+
+	if err := st.RemoveFriend(ctx, bob.ID, ann.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range [][2]string{{ann.ID, bob.ID}, {bob.ID, ann.ID}} {
+		_, err := st.FriendStatus(ctx, pair[0], pair[1])
+		expectErr(t, "FriendStatus after removal", err, store.ErrNotFound)
+	}
+	friends, _ = st.ListFriends(ctx, ann.ID)
+	if len(friends) != 0 {
+		t.Errorf("ann's friends after removal = %+v", friends)
+	}
+}
+
+func testFriendRequests(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+
+	// Crossing requests make friends
+	st.RequestFriend(ctx, ann.ID, bob.ID)
+	if status, created, err := st.RequestFriend(ctx, bob.ID, ann.ID); err != nil || status != "accepted" || !created {
+		t.Fatalf("request back = %q, %v, %v", status, created, err)
+	}
+	for _, pair := range [][2]string{{ann.ID, bob.ID}, {bob.ID, ann.ID}} {
+		if status, err := st.FriendStatus(ctx, pair[0], pair[1]); err != nil || status != "accepted" {
+			t.Errorf("status %s -> %s = %q, %v", pair[0], pair[1], status, err)
+		}
+	}
+
+	// Requests sent both ways at once make friends too, not two pending
+	// requests
+	for i := 0; i < 10; i++ {
+		x, y := newUser(t, st, "x"), newUser(t, st, "y")
+		var wg sync.WaitGroup
+		statuses := make([]string, 2)
+		for j, pair := range [][2]string{{x.ID, y.ID}, {y.ID, x.ID}} {
+			wg.Add(1)
+			go func(j int, from, to string) {
+				defer wg.Done()
+				status, _, err := st.RequestFriend(ctx, from, to)
+				if err != nil {
+					t.Errorf("concurrent RequestFriend: %v", err)
+				}
+				statuses[j] = status
+			}(j, pair[0], pair[1])
+		}
+		wg.Wait()
+		if got := fmt.Sprint(statuses); got != "[pending accepted]" && got != "[accepted pending]" {
+			t.Errorf("concurrent requests returned %s", got)
+		}
+		for _, pair := range [][2]string{{x.ID, y.ID}, {y.ID, x.ID}} {
+			if status, err := st.FriendStatus(ctx, pair[0], pair[1]); err != nil || status != "accepted" {
+				t.Errorf("status %s -> %s after concurrent requests = %q, %v", pair[0], pair[1], status, err)
+			}
+		}
+	}
+	if status, created, err := st.RequestFriend(ctx, ann.ID, bob.ID); err != nil || status != "accepted" || created {
+		t.Errorf("request between friends = %q, %v, %v", status, created, err)
+	}
+
+	// A rejected request still looks pending to its sender
+	st.RequestFriend(ctx, carl.ID, ann.ID)
+	expectErr(t, "rejecting a request that was not sent", st.RejectFriend(ctx, ann.ID, carl.ID), store.ErrNotFound)
+	if err := st.RejectFriend(ctx, carl.ID, ann.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectErr(t, "second rejection", st.RejectFriend(ctx, carl.ID, ann.ID), store.ErrNotFound)
+	if status, _ := st.FriendStatus(ctx, carl.ID, ann.ID); status != "rejected" {
+		t.Errorf("status after rejection = %q", status)
+	}
+	if status, created, err := st.RequestFriend(ctx, carl.ID, ann.ID); err != nil || status != "pending" || created {
+		t.Errorf("request after rejection = %q, %v, %v", status, created, err)
+	}
+	if requests, _ := st.ListFriendRequests(ctx, ann.ID); len(requests) != 0 {
+		t.Errorf("ann's requests after rejection = %+v", requests)
+	}
+	if sent, _ := st.ListSentFriendRequests(ctx, carl.ID); len(sent) != 1 || sent[0].ID != ann.ID {
+		t.Errorf("carl's sent requests after rejection = %+v", sent)
+	}
+
+	// The recipient may change their mind
+	if err := st.AcceptFriend(ctx, carl.ID, ann.ID); err != nil {
+		t.Fatalf("accepting a rejected request: %v", err)
+	}
+	st.RemoveFriend(ctx, carl.ID, ann.ID)
+
+	// Or the sender withdraws
+	st.RequestFriend(ctx, carl.ID, bob.ID)
+	if err := st.CancelFriendRequest(ctx, carl.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err := st.FriendStatus(ctx, carl.ID, bob.ID)
+	expectErr(t, "FriendStatus after cancelling", err, store.ErrNotFound)
+	expectErr(t, "second cancel", st.CancelFriendRequest(ctx, carl.ID, bob.ID), store.ErrNotFound)
+	expectErr(t, "cancelling a friendship", st.CancelFriendRequest(ctx, ann.ID, bob.ID), store.ErrNotFound)
+}
+
+func testBlocks(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+
+	st.RequestFriend(ctx, ann.ID, bob.ID)
+	st.AcceptFriend(ctx, ann.ID, bob.ID)
+	st.RequestFriend(ctx, carl.ID, ann.ID)
+	annDream := newDream(t, st, ann.ID, "Ann's", "A whale sang", true)
+	bobDream := newDream(t, st, bob.ID, "Bob's", "A whale swam", true)
+	comment := model.Comment{DreamRowID: annDream.RowID, Text: "Hi", User: model.UserSummary{ID: bob.ID}}
+	if err := st.CreateComment(ctx, &comment); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.BlockUser(ctx, ann.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectErr(t, "blocking a missing user", st.BlockUser(ctx, ann.ID, "999999"), store.ErrNotFound)
+	if status, _ := st.FriendStatus(ctx, ann.ID, bob.ID); status != "blocked" {
+		t.Errorf("status after block = %q", status)
+	}
+	_, err := st.FriendStatus(ctx, bob.ID, ann.ID)
+	expectErr(t, "blocked user's row", err, store.ErrNotFound)
+	for _, pair := range [][2]string{{ann.ID, bob.ID}, {bob.ID, ann.ID}} {
+		if blocked, err := st.Blocked(ctx, pair[0], pair[1]); err != nil || !blocked {
+			t.Errorf("Blocked(%s, %s) = %v, %v", pair[0], pair[1], blocked, err)
+		}
+	}
+	if blocked, err := st.Blocked(ctx, ann.ID, carl.ID); err != nil || blocked {
+		t.Errorf("Blocked(ann, carl) = %v, %v", blocked, err)
+	}
+	if friends, _ := st.ListFriends(ctx, ann.ID); len(friends) != 0 {
+		t.Errorf("ann's friends after block = %+v", friends)
+	}
+	if list, err := st.ListBlocked(ctx, ann.ID); err != nil || len(list) != 1 || list[0].ID != bob.ID {
+		t.Errorf("ann's blocked users = %+v, %v", list, err)
+	}
+	if list, _ := st.ListBlocked(ctx, bob.ID); len(list) != 0 {
+		t.Errorf("bob's blocked users = %+v", list)
+	}
+
+	// Neither side can befriend the other, and removal keeps the block
+	_, _, err = st.RequestFriend(ctx, bob.ID, ann.ID)
+	expectErr(t, "request to a blocker", err, store.ErrNotFound)
+	_, _, err = st.RequestFriend(ctx, ann.ID, bob.ID)
+	expectErr(t, "request to a blocked user", err, store.ErrConflict)
+	st.RemoveFriend(ctx, bob.ID, ann.ID)
+	if blocked, _ := st.Blocked(ctx, ann.ID, bob.ID); !blocked {
+		t.Error("RemoveFriend lifted the block")
+	}
+
+	// Both sides' dreams and comments disappear for the other
+	for _, tc := range []struct {
+		viewer, other string
+		hidden        *model.Dream
+	}{{ann.ID, bob.ID, bobDream}, {bob.ID, ann.ID, annDream}} {
+		dreams, _, err := st.ListDreams(ctx, model.DreamFilter{Viewer: tc.viewer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range dreams {
+			if d.UserID == tc.other {
+				t.Errorf("listing for %s includes %q", tc.viewer, d.Title)
+			}
+		}
+		resp, err := st.SearchDreams(ctx, model.SearchFilter{Query: "whale", Scope: "all", ViewerID: tc.viewer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range resp.Results {
+			if r.Dream.ID == tc.hidden.ID {
+				t.Errorf("search for %s finds %q", tc.viewer, tc.hidden.Title)
+			}
+		}
+	}
+	dreams, _, _ := st.ListDreams(ctx, model.DreamFilter{Viewer: carl.ID})
+	if len(dreams) != 2 {
+		t.Errorf("carl sees %d dreams, want 2", len(dreams))
+	}
+	comments, _, err := st.ListComments(ctx, annDream.RowID, ann.ID, model.Page{})
+	if err != nil || len(comments) != 0 {
+		t.Errorf("ann's view of comments = %+v, %v", comments, err)
+	}
+	if comments, _, _ = st.ListComments(ctx, annDream.RowID, carl.ID, model.Page{}); len(comments) != 1 {
+		t.Errorf("carl sees %d comments, want 1", len(comments))
+	}
+
+	if err := st.UnblockUser(ctx, ann.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectErr(t, "second unblock", st.UnblockUser(ctx, ann.ID, bob.ID), store.ErrNotFound)
+	if blocked, _ := st.Blocked(ctx, bob.ID, ann.ID); blocked {
+		t.Error("still blocked after unblock")
+	}
+	if comments, _, _ = st.ListComments(ctx, annDream.RowID, ann.ID, model.Page{}); len(comments) != 1 {
+		t.Errorf("ann sees %d comments after unblock, want 1", len(comments))
+	}
+	if status, created, err := st.RequestFriend(ctx, bob.ID, ann.ID); err != nil || status != "pending" || !created {
+		t.Errorf("request after unblock = %q, %v, %v", status, created, err)
+	}
+}
+
+func testComments(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	st.UpdateProfile(ctx, bob.ID, "Bob", "", "")
+	d := newDream(t, st, ann.ID, "Ocean", "Swimming with whales.", true)
+	other := newDream(t, st, ann.ID, "Forest", "Lost.", true)
+
+	var created []*model.Comment
+	for _, c := range []struct {
+		dream  *model.Dream
+		author *model.User
+		text   string
+	}{
+		{d, bob, "Lovely"},
+		{other, bob, "Elsewhere"},
+		{d, ann, "Thanks"},
+		{d, bob, "Again"},
+	} {
+		comment := &model.Comment{DreamRowID: c.dream.RowID, Text: c.text, User: model.UserSummary{ID: c.author.ID}}
+		if err := st.CreateComment(ctx, comment); err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, comment)
+	}
+	first := created[0]
+	if first.ID == 0 || first.CreatedAt.IsZero() || first.User.Username != bob.Username || first.User.DisplayName != "Bob" || first.DreamRowID != d.RowID {
+		t.Errorf("CreateComment did not fill in the comment: %+v", first)
+	}
+
+	got, err := st.GetComment(ctx, first.ID)
+	if err != nil || got.Text != "Lovely" || got.User.ID != bob.ID || got.DreamRowID != d.RowID {
+		t.Errorf("GetComment = %+v, %v", got, err)
+	}
+
+	var seen []int
+	page := model.Page{Limit: 2}
+	for i := 0; ; i++ {
+		if i > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		comments, next, err := st.ListComments(ctx, d.RowID, "", page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range comments {
+			seen = append(seen, c.ID)
+		}
+		if next == nil {
+			break
+		}
+		page.After = next
+	}
+	want := []int{created[0].ID, created[2].ID, created[3].ID}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("comments oldest first = %v, want %v", seen, want)
+	}
+
+	if err := st.DeleteComment(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.GetComment(ctx, first.ID)
+	expectErr(t, "GetComment after delete", err, store.ErrNotFound)
+	expectErr(t, "second delete", st.DeleteComment(ctx, first.ID), store.ErrNotFound)
+	comments, _, _ := st.ListComments(ctx, d.RowID, "", model.Page{})
+	if len(comments) != 2 {
+		t.Errorf("%d comments left, want 2", len(comments))
+	}
+
+	// Comments go with their dream
+	if err := st.DeleteDream(ctx, other.RowID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.GetComment(ctx, created[1].ID)
+	expectErr(t, "comment on a deleted dream", err, store.ErrNotFound)
+}
+
+func testCommentThreads(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	d := newDream(t, st, ann.ID, "Ocean", "Swimming with whales.", true)
+	other := newDream(t, st, ann.ID, "Forest", "Lost.", true)
+
+	comment := func(dream *model.Dream, author *model.User, text string, parentID *int) (*model.Comment, error) {
+		c := &model.Comment{DreamRowID: dream.RowID, ParentID: parentID, Text: text, User: model.UserSummary{ID: author.ID}}
+		return c, st.CreateComment(ctx, c)
+	}
+	root, err := comment(d, bob, "Lovely", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := comment(d, ann, "Thanks", intPtr(root.ID))
+	if err != nil || reply.ParentID == nil || *reply.ParentID != root.ID {
+		t.Fatalf("reply = %+v, %v", reply, err)
+	}
+	nested, err := comment(d, bob, "You're welcome", intPtr(reply.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sibling, err := comment(d, bob, "Another thought", nil)
+	if err != nil || sibling.ParentID != nil {
+		t.Fatalf("top-level comment = %+v, %v", sibling, err)
+	}
+	_, err = comment(d, bob, "Orphan", intPtr(9999))
+	expectErr(t, "reply to a missing comment", err, store.ErrNotFound)
+	_, err = comment(other, bob, "Cross-post", intPtr(root.ID))
+	expectErr(t, "reply on another dream", err, store.ErrNotFound)
+
+	got, err := st.GetComment(ctx, nested.ID)
+	if err != nil || got.ParentID == nil || *got.ParentID != reply.ID {
+		t.Errorf("GetComment(nested) = %+v, %v", got, err)
+	}
+
+	if root.Edited {
+		t.Error("new comment is marked edited")
+	}
+	edited, err := st.UpdateComment(ctx, root.ID, "Lovely dream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Text != "Lovely dream" || !edited.Edited || !edited.UpdatedAt.After(root.UpdatedAt) || !edited.CreatedAt.Equal(root.CreatedAt) || edited.User.Username != bob.Username {
+		t.Errorf("UpdateComment = %+v", edited)
+	}
+	_, err = st.UpdateComment(ctx, 9999, "Nope")
+	expectErr(t, "editing a missing comment", err, store.ErrNotFound)
+
+	// Deleting a comment takes the replies under it along
+	if err := st.DeleteComment(ctx, root.ID); err != nil {
+		t.Fatal(err)
+	}
+	comments, _, err := st.ListComments(ctx, d.RowID, "", model.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].ID != sibling.ID {
+		t.Errorf("comments after deleting the thread = %+v", comments)
+	}
+	_, err = st.GetComment(ctx, nested.ID)
+	expectErr(t, "nested reply after deleting the thread", err, store.ErrNotFound)
+}
+
+func testCommentReports(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+	admin := newUser(t, st, "ada")
+	d := newDream(t, st, ann.ID, "Ocean", "Swimming with whales.", true)
+
+	comment := func(text string) *model.Comment {
+		c := &model.Comment{DreamRowID: d.RowID, Text: text, User: model.UserSummary{ID: bob.ID}}
+		if err := st.CreateComment(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	spam, rude := comment("Buy now"), comment("Boring")
+	report := func(c *model.Comment, reporter *model.User, reason string) (*model.CommentReport, error) {
+		r := &model.CommentReport{Reason: reason, Reporter: model.UserSummary{ID: reporter.ID}, Comment: model.Comment{ID: c.ID}}
+		return r, st.ReportComment(ctx, r)
+	}
+
+	first, err := report(spam, ann, "Spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == 0 || first.CreatedAt.IsZero() || first.Reporter.Username != ann.Username || first.Comment.Text != "Buy now" ||
+		first.Comment.User.Username != bob.Username || first.DreamID != d.ID {
+		t.Errorf("ReportComment did not fill in the report: %+v", first)
+	}
+	_, err = report(spam, ann, "Still spam")
+	expectErr(t, "second open report by the same user", err, store.ErrConflict)
+	_, err = report(&model.Comment{ID: 9999}, ann, "Gone")
+	expectErr(t, "report on a missing comment", err, store.ErrNotFound)
+	second, err := report(spam, carl, "Ads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := report(rude, carl, "Mean")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := st.GetReport(ctx, second.ID)
+	if err != nil || got.Reason != "Ads" || got.Comment.ID != spam.ID || got.Reporter.ID != carl.ID || got.ResolvedAt != nil {
+		t.Errorf("GetReport = %+v, %v", got, err)
+	}
+	_, err = st.GetReport(ctx, 9999)
+	expectErr(t, "missing report", err, store.ErrNotFound)
+
+	queue := func() []int {
+		t.Helper()
+		var ids []int
+		page := model.Page{Limit: 2}
+		for i := 0; ; i++ {
+			if i > 5 {
+				t.Fatal("pagination did not terminate")
+			}
+			reports, next, err := st.ListOpenReports(ctx, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range reports {
+				ids = append(ids, r.ID)
+			}
+			if next == nil {
+				return ids
+			}
+			page.After = next
+		}
+	}
+	if got, want := fmt.Sprint(queue()), fmt.Sprint([]int{first.ID, second.ID, third.ID}); got != want {
+		t.Errorf("moderation queue = %v, want %v", got, want)
+	}
+
+	// Dismissing clears every open report on the comment, and the reporter
+	// may report it again afterwards
+	if err := st.DismissReports(ctx, spam.ID, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectErr(t, "dismissing twice", st.DismissReports(ctx, spam.ID, admin.ID), store.ErrNotFound)
+	if got, want := fmt.Sprint(queue()), fmt.Sprint([]int{third.ID}); got != want {
+		t.Errorf("queue after dismissal = %v, want %v", got, want)
+	}
+	if _, err := st.GetComment(ctx, spam.ID); err != nil {
+		t.Errorf("dismissal removed the comment: %v", err)
+	}
+	if got, err := st.GetReport(ctx, second.ID); err != nil || got.ResolvedAt == nil {
+		t.Errorf("GetReport after dismissal = %+v, %v, want it resolved", got, err)
+	}
+	again, err := report(spam, ann, "Back again")
+	if err != nil {
+		t.Fatalf("reporting after dismissal: %v", err)
+	}
+
+	// Reports go with their comment
+	if err := st.DeleteComment(ctx, spam.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.GetReport(ctx, again.ID)
+	expectErr(t, "report on a deleted comment", err, store.ErrNotFound)
+	if got, want := fmt.Sprint(queue()), fmt.Sprint([]int{third.ID}); got != want {
+		t.Errorf("queue after deleting the comment = %v, want %v", got, want)
+	}
+}
+
+func testReactions(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+	d := newDream(t, st, ann.ID, "Ocean", "Swimming with whales.", true)
+	other := newDream(t, st, bob.ID, "Teeth", "They fell out.", true)
+	c := &model.Comment{DreamRowID: d.RowID, Text: "Lovely", User: model.UserSummary{ID: bob.ID}}
+	if err := st.CreateComment(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	onDream := model.ReactionTarget{DreamRowID: d.RowID}
+	onComment := model.ReactionTarget{CommentID: c.ID}
+
+	react := func(target model.ReactionTarget, user *model.User, kind model.ReactionKind) *model.Reaction {
+		t.Helper()
+		r := &model.Reaction{Kind: kind, User: model.UserSummary{ID: user.ID}, Target: target}
+		if err := st.AddReaction(ctx, r); err != nil {
+			t.Fatalf("AddReaction(%s, %s): %v", user.Username, kind, err)
+		}
+		return r
+	}
+	counts := func(what string, target model.ReactionTarget, want string) {
+		t.Helper()
+		got, err := st.CountReactions(ctx, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != want {
+			t.Errorf("%s = %v, want %s", what, got, want)
+		}
+	}
+
+	first := react(onDream, bob, model.ReactionSpooky)
+	if first.ID == 0 || first.CreatedAt.IsZero() || first.User.Username != bob.Username {
+		t.Errorf("AddReaction did not fill in the reaction: %+v", first)
+	}
+	again := react(onDream, bob, model.ReactionSpooky)
+	if again.ID != first.ID {
+		t.Errorf("reacting twice made a second reaction: %d, want %d", again.ID, first.ID)
+	}
+	react(onDream, bob, model.ReactionBeautiful)
+	react(onDream, carl, model.ReactionSpooky)
+	react(onComment, ann, model.ReactionRelatable)
+	counts("dream counts", onDream, "map[beautiful:1 relatable:0 spooky:2]")
+	counts("comment counts", onComment, "map[beautiful:0 relatable:1 spooky:0]")
+	counts("counts with no reactions", model.ReactionTarget{DreamRowID: other.RowID}, "map[beautiful:0 relatable:0 spooky:0]")
+
+	err := st.AddReaction(ctx, &model.Reaction{Kind: model.ReactionSpooky, User: model.UserSummary{ID: bob.ID}, Target: model.ReactionTarget{DreamRowID: 999999}})
+	expectErr(t, "reaction on a missing dream", err, store.ErrNotFound)
+	err = st.AddReaction(ctx, &model.Reaction{Kind: model.ReactionSpooky, User: model.UserSummary{ID: bob.ID}, Target: model.ReactionTarget{CommentID: 999999}})
+	expectErr(t, "reaction on a missing comment", err, store.ErrNotFound)
+	_, err = st.CountReactions(ctx, model.ReactionTarget{CommentID: 999999})
+	expectErr(t, "counts on a missing comment", err, store.ErrNotFound)
+
+	// Counts ride along with the dream and comment reads
+	got, err := st.GetDream(ctx, d.ID)
+	if err != nil || fmt.Sprint(got.Reactions) != "map[beautiful:1 relatable:0 spooky:2]" {
+		t.Errorf("GetDream reactions = %v, %v", got.Reactions, err)
+	}
+	dreams, _, err := st.ListDreams(ctx, model.DreamFilter{Owners: []string{ann.ID, bob.ID}, Viewer: ann.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, listed := range dreams {
+		want := "map[beautiful:0 relatable:0 spooky:0]"
+		if listed.ID == d.ID {
+			want = "map[beautiful:1 relatable:0 spooky:2]"
+		}
+		if fmt.Sprint(listed.Reactions) != want {
+			t.Errorf("ListDreams reactions on %q = %v, want %s", listed.Title, listed.Reactions, want)
+		}
+	}
+	comments, _, err := st.ListComments(ctx, d.RowID, ann.ID, model.Page{})
+	if err != nil || len(comments) != 1 || comments[0].Reactions[model.ReactionRelatable] != 1 {
+		t.Errorf("ListComments reactions = %+v, %v", comments, err)
+	}
+
+	who := func(target model.ReactionTarget, kind model.ReactionKind, viewer *model.User) []string {
+		t.Helper()
+		var names []string
+		page := model.Page{Limit: 1}
+		for i := 0; ; i++ {
+			if i > 5 {
+				t.Fatal("pagination did not terminate")
+			}
+			reactions, next, err := st.ListReactions(ctx, target, kind, viewer.ID, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range reactions {
+				names = append(names, r.User.Username+":"+string(r.Kind))
+			}
+			if next == nil {
+				return names
+			}
+			page.After = next
+		}
+	}
+	if got, want := fmt.Sprint(who(onDream, "", ann)), fmt.Sprint([]string{carl.Username + ":spooky", bob.Username + ":beautiful", bob.Username + ":spooky"}); got != want {
+		t.Errorf("ListReactions = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(who(onDream, model.ReactionSpooky, ann)), fmt.Sprint([]string{carl.Username + ":spooky", bob.Username + ":spooky"}); got != want {
+		t.Errorf("ListReactions(spooky) = %s, want %s", got, want)
+	}
+	if err := st.BlockUser(ctx, ann.ID, carl.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(who(onDream, model.ReactionSpooky, ann)), fmt.Sprint([]string{bob.Username + ":spooky"}); got != want {
+		t.Errorf("ListReactions hiding a blocked user = %s, want %s", got, want)
+	}
+
+	if err := st.RemoveReaction(ctx, onDream, bob.ID, model.ReactionSpooky); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RemoveReaction(ctx, onDream, bob.ID, model.ReactionSpooky); err != nil {
+		t.Errorf("removing a missing reaction: %v", err)
+	}
+	counts("counts after removal", onDream, "map[beautiful:1 relatable:0 spooky:1]")
+
+	if err := st.DeleteComment(ctx, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.CountReactions(ctx, onComment)
+	expectErr(t, "counts on a deleted comment", err, store.ErrNotFound)
+	if err := st.DeleteDream(ctx, d.RowID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.CountReactions(ctx, onDream)
+	expectErr(t, "counts on a deleted dream", err, store.ErrNotFound)
+}
+
+func testPopularDreams(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+	old := newDream(t, st, ann.ID, "Old", "A dream from before.", true)
+	quiet := newDream(t, st, ann.ID, "Quiet", "Nobody noticed.", true)
+	since := quiet.CreatedAt
+	busy := newDream(t, st, ann.ID, "Busy", "Everybody talked about it.", true)
+	liked := newDream(t, st, bob.ID, "Liked", "One reaction.", true)
+	hidden := newDream(t, st, ann.ID, "Hidden", "Kept to myself.", false)
+	blocked := newDream(t, st, carl.ID, "Blocked", "Carl's dream.", true)
+
+	for _, text := range []string{"First", "Second"} {
+		c := &model.Comment{DreamRowID: busy.RowID, Text: text, User: model.UserSummary{ID: bob.ID}}
+		if err := st.CreateComment(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range []*model.Dream{liked, hidden, blocked} {
+		r := &model.Reaction{Kind: model.ReactionSpooky, User: model.UserSummary{ID: ann.ID}, Target: model.ReactionTarget{DreamRowID: d.RowID}}
+		if err := st.AddReaction(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.BlockUser(ctx, carl.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other subtests may have left public dreams behind, so only ours are
+	// compared
+	mine := set(old, quiet, busy, liked, hidden, blocked)
+	popular := func(viewerID string, since, until time.Time) []string {
+		t.Helper()
+		dreams, err := st.PopularDreams(ctx, viewerID, since, until, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids(dreams, mine)
+	}
+	now := time.Now().Add(time.Hour)
+	expectIDs(t, "popular dreams", popular(ann.ID, since, now), busy, blocked, liked, quiet)
+	expectIDs(t, "popular dreams without blocked authors", popular(bob.ID, since, now), busy, liked, quiet)
+	expectIDs(t, "popular dreams for anonymous", popular("", since, now), busy, blocked, liked, quiet)
+	expectIDs(t, "popular dreams of all time", popular(ann.ID, time.Time{}, now), busy, blocked, liked, quiet, old)
+	expectIDs(t, "popular dreams until busy", popular(ann.ID, since, busy.CreatedAt), busy, quiet)
+
+	counts, err := st.CountComments(ctx, []int{busy.RowID, quiet.RowID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(counts) != fmt.Sprintf("map[%d:2]", busy.RowID) {
+		t.Errorf("CountComments = %v, want 2 on busy only", counts)
+	}
+}
+
+func testNotifications(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	ann := newUser(t, st, "ann")
+	bob := newUser(t, st, "bob")
+	carl := newUser(t, st, "carl")
+	d := newDream(t, st, ann.ID, "Ocean", "Swimming with whales.", true)
+	c := &model.Comment{DreamRowID: d.RowID, Text: "Lovely", User: model.UserSummary{ID: bob.ID}}
+	if err := st.CreateComment(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	notify := func(typ model.NotificationType, to, from *model.User, dream *model.Dream, comment *model.Comment) *model.Notification {
+		t.Helper()
+		n := &model.Notification{Type: typ, UserID: to.ID, Actor: model.UserSummary{ID: from.ID}}
+		if dream != nil {
+			n.DreamRowID = dream.RowID
+		}
+		if comment != nil {
+			n.CommentID = &comment.ID
+		}
+		if err := st.CreateNotification(ctx, n); err != nil {
+			t.Fatalf("CreateNotification(%s): %v", typ, err)
+		}
+		return n
+	}
+	list := func(user *model.User, unreadOnly bool) []int {
+		t.Helper()
+		var got []int
+		page := model.Page{Limit: 2}
+		for i := 0; ; i++ {
+			if i > 5 {
+				t.Fatal("pagination did not terminate")
+			}
+			notifications, next, err := st.ListNotifications(ctx, user.ID, unreadOnly, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range notifications {
+				got = append(got, n.ID)
+			}
+			if next == nil {
+				return got
+			}
+			page.After = next
+		}
+	}
+	unread := func(what string, user *model.User, want string) {
+		t.Helper()
+		counts, err := st.CountUnreadNotifications(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(counts) != want {
+			t.Errorf("%s = %v, want %s", what, counts, want)
+		}
+	}
+
+	request := notify(model.NotifyFriendRequest, ann, carl, nil, nil)
+	comment := notify(model.NotifyComment, ann, bob, d, c)
+	if comment.ID == 0 || comment.CreatedAt.IsZero() || comment.Actor.Username != bob.Username || comment.DreamID != d.ID ||
+		comment.CommentID == nil || *comment.CommentID != c.ID || comment.Read {
+		t.Errorf("CreateNotification did not fill in the notification: %+v", comment)
+	}
+	accepted := notify(model.NotifyFriendAccepted, ann, bob, nil, nil)
+	if got, want := fmt.Sprint(list(ann, false)), fmt.Sprint([]int{accepted.ID, comment.ID, request.ID}); got != want {
+		t.Errorf("ListNotifications = %s, want %s", got, want)
+	}
+	if got := list(bob, false); len(got) != 0 {
+		t.Errorf("someone else's notifications = %v", got)
+	}
+	unread("unread counts", ann, "map[comment:1 friend_accepted:1 friend_request:1 reply:0]")
+
+	err := st.CreateNotification(ctx, &model.Notification{Type: model.NotifyComment, UserID: ann.ID, Actor: model.UserSummary{ID: bob.ID}, DreamRowID: 999999})
+	expectErr(t, "notification on a missing dream", err, store.ErrNotFound)
+	err = st.CreateNotification(ctx, &model.Notification{Type: model.NotifyFriendRequest, UserID: "999999", Actor: model.UserSummary{ID: bob.ID}})
+	expectErr(t, "notification to a missing user", err, store.ErrNotFound)
+
+	// Marking read
+	if n, err := st.MarkNotificationsRead(ctx, bob.ID, []int{comment.ID}); err != nil || n != 0 {
+		t.Errorf("marking someone else's notification read = %d, %v", n, err)
+	}
+	if n, err := st.MarkNotificationsRead(ctx, ann.ID, []int{comment.ID}); err != nil || n != 1 {
+		t.Errorf("MarkNotificationsRead = %d, %v", n, err)
+	}
+	if got, want := fmt.Sprint(list(ann, true)), fmt.Sprint([]int{accepted.ID, request.ID}); got != want {
+		t.Errorf("unread notifications = %s, want %s", got, want)
+	}
+	unread("unread counts after reading one", ann, "map[comment:0 friend_accepted:1 friend_request:1 reply:0]")
+	if n, err := st.MarkNotificationsRead(ctx, ann.ID, nil); err != nil || n != 2 {
+		t.Errorf("MarkNotificationsRead(all) = %d, %v", n, err)
+	}
+	unread("unread counts after reading all", ann, "map[comment:0 friend_accepted:0 friend_request:0 reply:0]")
+
+	// Preferences
+	prefs, err := st.NotificationPrefs(ctx, ann.ID)
+	if err != nil || fmt.Sprint(prefs) != "map[comment:true friend_accepted:true friend_request:true reply:true]" {
+		t.Errorf("default prefs = %v, %v", prefs, err)
+	}
+	if err := st.SetNotificationPrefs(ctx, ann.ID, model.NotificationPrefs{model.NotifyComment: false}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetNotificationPrefs(ctx, ann.ID, model.NotificationPrefs{model.NotifyReply: false}); err != nil {
+		t.Fatal(err)
+	}
+	prefs, err = st.NotificationPrefs(ctx, ann.ID)
+	if err != nil || fmt.Sprint(prefs) != "map[comment:false friend_accepted:true friend_request:true reply:false]" {
+		t.Errorf("prefs = %v, %v", prefs, err)
+	}
+	_, err = st.NotificationPrefs(ctx, "999999")
+	expectErr(t, "prefs of a missing user", err, store.ErrNotFound)
+	if muted := notify(model.NotifyComment, ann, bob, d, c); muted.ID != 0 {
+		t.Errorf("notification of a muted type was stored: %+v", muted)
+	}
+	unread("unread counts after a muted notification", ann, "map[comment:0 friend_accepted:0 friend_request:0 reply:0]")
+
+	// Blocking hides the other user's notifications; deleting the comment
+	// removes the ones about it
+	reply := notify(model.NotifyReply, bob, ann, d, c)
+	if err := st.BlockUser(ctx, ann.ID, carl.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(list(ann, false)), fmt.Sprint([]int{accepted.ID, comment.ID}); got != want {
+		t.Errorf("notifications after blocking = %s, want %s", got, want)
+	}
+	if err := st.DeleteComment(ctx, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(list(ann, false)), fmt.Sprint([]int{accepted.ID}); got != want {
+		t.Errorf("notifications after deleting the comment = %s, want %s", got, want)
+	}
+	if got := list(bob, false); len(got) != 0 {
+		t.Errorf("reply %d survived its comment: %v", reply.ID, got)
+	}
+}
+
+func testRefreshTokens(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st, "ann")
+	// The suite may run against a shared database, so hashes must be unique
+	prefix := fmt.Sprintf("storetest-%d-", seq.Add(1))
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	newToken := func(family, hash string) *model.RefreshToken {
+		t.Helper()
+		tok := &model.RefreshToken{UserID: u.ID, FamilyID: prefix + family, TokenHash: prefix + hash, ExpiresAt: expires}
+		if err := st.CreateRefreshToken(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+
+	first := newToken("f1", "a")
+	if first.ID == 0 || first.CreatedAt.IsZero() {
+		t.Errorf("CreateRefreshToken did not fill in the token: %+v", first)
+	}
+	got, err := st.GetRefreshToken(ctx, first.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != u.ID || got.FamilyID != first.FamilyID || !got.ExpiresAt.Equal(expires) || got.UsedAt != nil || got.RevokedAt != nil {
+		t.Errorf("GetRefreshToken = %+v", got)
+	}
+	_, err = st.GetRefreshToken(ctx, prefix+"missing")
+	expectErr(t, "GetRefreshToken of a missing token", err, store.ErrNotFound)
+	err = st.CreateRefreshToken(ctx, &model.RefreshToken{UserID: "999999999", FamilyID: prefix + "x", TokenHash: prefix + "x", ExpiresAt: expires})
+	expectErr(t, "CreateRefreshToken for a missing user", err, store.ErrNotFound)
+
+	used, err := st.UseRefreshToken(ctx, first.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used.ID != first.ID || used.UsedAt == nil {
+		t.Errorf("UseRefreshToken = %+v", used)
+	}
+	second := newToken("f1", "b")
+	other := newToken("f2", "c")
+
+	// Reusing the first token revokes the rest of its family only
+	_, err = st.UseRefreshToken(ctx, first.TokenHash)
+	expectErr(t, "reusing a token", err, store.ErrRevoked)
+	got, _ = st.GetRefreshToken(ctx, second.TokenHash)
+	if got.RevokedAt == nil {
+		t.Errorf("reuse did not revoke the family: %+v", got)
+	}
+	_, err = st.UseRefreshToken(ctx, second.TokenHash)
+	expectErr(t, "using a revoked token", err, store.ErrRevoked)
+	_, err = st.UseRefreshToken(ctx, prefix+"missing")
+	expectErr(t, "using a missing token", err, store.ErrNotFound)
+
+	if err := st.RevokeRefreshFamily(ctx, other.FamilyID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.UseRefreshToken(ctx, other.TokenHash)
+	expectErr(t, "using a token after logout", err, store.ErrRevoked)
+}
+
+func testAccountTokens(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := newUser(t, st, "ann")
+	prefix := fmt.Sprintf("storetest-%d-", seq.Add(1))
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	newToken := func(purpose, hash string) *model.AccountToken {
+		t.Helper()
+		tok := &model.AccountToken{UserID: u.ID, Purpose: purpose, TokenHash: prefix + hash, ExpiresAt: expires}
+		if err := st.CreateAccountToken(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+
+	reset := newToken(model.TokenPasswordReset, "a")
+	if reset.ID == 0 || reset.CreatedAt.IsZero() {
+		t.Errorf("CreateAccountToken did not fill in the token: %+v", reset)
+	}
+	err := st.CreateAccountToken(ctx, &model.AccountToken{UserID: "999999999", Purpose: model.TokenPasswordReset, TokenHash: prefix + "x", ExpiresAt: expires})
+	expectErr(t, "CreateAccountToken for a missing user", err, store.ErrNotFound)
+
+	_, err = st.UseAccountToken(ctx, model.TokenEmailVerify, reset.TokenHash)
+	expectErr(t, "using a token for another purpose", err, store.ErrNotFound)
+	_, err = st.UseAccountToken(ctx, model.TokenPasswordReset, prefix+"missing")
+	expectErr(t, "using a missing token", err, store.ErrNotFound)
+	used, err := st.UseAccountToken(ctx, model.TokenPasswordReset, reset.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used.ID != reset.ID || used.UserID != u.ID || !used.ExpiresAt.Equal(expires) || used.UsedAt == nil {
+		t.Errorf("UseAccountToken = %+v", used)
+	}
+	_, err = st.UseAccountToken(ctx, model.TokenPasswordReset, reset.TokenHash)
+	expectErr(t, "reusing a token", err, store.ErrRevoked)
+
+	// A new token replaces earlier ones for the same purpose only
+	verify := newToken(model.TokenEmailVerify, "b")
+	older := newToken(model.TokenPasswordReset, "c")
+	newer := newToken(model.TokenPasswordReset, "d")
+	_, err = st.UseAccountToken(ctx, model.TokenPasswordReset, older.TokenHash)
+	expectErr(t, "using a replaced token", err, store.ErrRevoked)
+	if _, err := st.UseAccountToken(ctx, model.TokenPasswordReset, newer.TokenHash); err != nil {
+		t.Errorf("using the latest token: %v", err)
+	}
+	if _, err := st.UseAccountToken(ctx, model.TokenEmailVerify, verify.TokenHash); err != nil {
+		t.Errorf("using a token for another purpose after a new one: %v", err)
+	}
+
+	if got, _ := st.GetUser(ctx, u.ID); got.EmailVerified {
+		t.Errorf("new user is verified")
+	}
+	if err := st.SetEmailVerified(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := st.GetUser(ctx, u.ID); !got.EmailVerified {
+		t.Errorf("SetEmailVerified did not stick")
+	}
+	expectErr(t, "SetEmailVerified of a missing user", st.SetEmailVerified(ctx, "999999999"), store.ErrNotFound)
+
+	if err := st.SetPassword(ctx, u.ID, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, hash, _ := st.GetCredentials(ctx, u.Email); hash != "new-hash" {
+		t.Errorf("password hash after SetPassword = %q", hash)
+	}
+	expectErr(t, "SetPassword of a missing user", st.SetPassword(ctx, "999999999", "x"), store.ErrNotFound)
+
+	// Revoking a user's refresh tokens leaves other users signed in
+	other := newUser(t, st, "bob")
+	mine := &model.RefreshToken{UserID: u.ID, FamilyID: prefix + "f1", TokenHash: prefix + "r1", ExpiresAt: expires}
+	theirs := &model.RefreshToken{UserID: other.ID, FamilyID: prefix + "f2", TokenHash: prefix + "r2", ExpiresAt: expires}
+	for _, tok := range []*model.RefreshToken{mine, theirs} {
+		if err := st.CreateRefreshToken(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.RevokeUserRefreshTokens(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.UseRefreshToken(ctx, mine.TokenHash)
+	expectErr(t, "using a token after RevokeUserRefreshTokens", err, store.ErrRevoked)
+	if _, err := st.UseRefreshToken(ctx, theirs.TokenHash); err != nil {
+		t.Errorf("another user's token was revoked: %v", err)
+	}
+}
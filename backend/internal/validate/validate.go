@@ -0,0 +1,342 @@
+// Package validate checks request structs against rules declared in
+// `validate` struct tags, for example
+//
+//	type RegisterRequest struct {
+//		Email    string `json:"email" validate:"required,email"`
+//		Password string `json:"password" validate:"required,password"`
+//	}
+//
+// Rules are separated by commas and take a parameter after "=". Pointers
+// are checked through; a nil pointer only fails "required". Fields are
+// reported by their JSON names.
+//
+// Rules:
+//
+//	required   present and, for strings, not blank
+//	notblank   a string, if present, is not blank
+//	min=N      strings and slices have at least N runes or items,
+//	           numbers are at least N
+//	max=N      the same, at most N
+//	oneof=a b  one of the space-separated values
+//	email      a plain email address
+//	username   3-30 letters, digits and underscores, and not reserved
+//	password   8-72 bytes mixing at least two kinds of characters, and not
+//	           a well-known password
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldError is one rule a field failed. Code is stable and meant for
+// programs; Message is for people.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors lists every failed field, in struct order
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Valuer is implemented by field types that wrap an optional value, such as
+// a PATCH field that tells absent and null apart. Rules apply to the
+// wrapped value, and an unset one only fails "required".
+type Valuer interface {
+	FieldValue() (v interface{}, set bool)
+}
+
+// Struct checks v, a struct or pointer to one, and returns Errors or nil.
+// Malformed tags panic, since they are programming errors.
+func Struct(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+	var errs Errors
+	for _, f := range fieldsOf(rv.Type()) {
+		if fe := check(rv.Field(f.index), f); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// field is a struct field with parsed rules
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+var cache sync.Map // reflect.Type -> []field
+
+func fieldsOf(t reflect.Type) []field {
+	if f, ok := cache.Load(t); ok {
+		return f.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || !sf.IsExported() {
+			continue
+		}
+		f := field{index: i, name: jsonName(sf)}
+		for _, part := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if _, ok := checks[name]; !ok && name != "required" {
+				panic(fmt.Sprintf("validate: unknown rule %q on %s.%s", name, t.Name(), sf.Name))
+			}
+			f.rules = append(f.rules, rule{name, param})
+		}
+		fields = append(fields, f)
+	}
+	cache.Store(t, fields)
+	return fields
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// check applies f's rules to v and returns the first failure
+func check(v reflect.Value, f field) *FieldError {
+	if valuer, ok := v.Interface().(Valuer); ok {
+		inner, set := valuer.FieldValue()
+		if !set {
+			v = reflect.Value{}
+		} else {
+			v = reflect.ValueOf(inner)
+		}
+	}
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+	for _, r := range f.rules {
+		if r.name == "required" {
+			if !v.IsValid() || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "") {
+				return &FieldError{Field: f.name, Code: "required", Message: "is required"}
+			}
+			continue
+		}
+		if !v.IsValid() {
+			continue
+		}
+		if code, msg := checks[r.name](v, r.param); code != "" {
+			return &FieldError{Field: f.name, Code: code, Message: msg}
+		}
+	}
+	return nil
+}
+
+// checks maps rule names to functions returning a failure code and message,
+// or "" when the value passes
+var checks = map[string]func(v reflect.Value, param string) (string, string){
+	"notblank": func(v reflect.Value, _ string) (string, string) {
+		if v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "" {
+			return "blank", "must not be blank"
+		}
+		return "", ""
+	},
+	"min": func(v reflect.Value, param string) (string, string) {
+		n := intParam(param)
+		switch size, isLen := measure(v); {
+		case isLen && size < n:
+			return "too_short", fmt.Sprintf("must be at least %d %s long", n, unit(v))
+		case !isLen && size < n:
+			return "too_small", fmt.Sprintf("must be at least %d", n)
+		}
+		return "", ""
+	},
+	"max": func(v reflect.Value, param string) (string, string) {
+		n := intParam(param)
+		switch size, isLen := measure(v); {
+		case isLen && size > n:
+			return "too_long", fmt.Sprintf("must be at most %d %s long", n, unit(v))
+		case !isLen && size > n:
+			return "too_large", fmt.Sprintf("must be at most %d", n)
+		}
+		return "", ""
+	},
+	"oneof": func(v reflect.Value, param string) (string, string) {
+		s := fmt.Sprint(v.Interface())
+		for _, opt := range strings.Fields(param) {
+			if s == opt {
+				return "", ""
+			}
+		}
+		return "invalid_choice", "must be one of " + strings.Join(strings.Fields(param), ", ")
+	},
+	"email": func(v reflect.Value, _ string) (string, string) {
+		if !Email(v.String()) {
+			return "invalid_email", "must be a valid email address"
+		}
+		return "", ""
+	},
+	"username": func(v reflect.Value, _ string) (string, string) {
+		if !Username(v.String()) {
+			return "invalid_username", "must be 3-30 letters, digits or underscores"
+		}
+		if ReservedUsername(v.String()) {
+			return "reserved_username", "is reserved"
+		}
+		return "", ""
+	},
+	"password": func(v reflect.Value, _ string) (string, string) {
+		if msg := Password(v.String()); msg != "" {
+			return "weak_password", msg
+		}
+		return "", ""
+	},
+}
+
+// measure returns the rune count of a string, the length of a slice or map,
+// or the value of a number, and whether it is a length
+func measure(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), false
+	}
+	panic(fmt.Sprintf("validate: cannot measure a %s", v.Kind()))
+}
+
+func unit(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return "characters"
+	}
+	return "items"
+}
+
+func intParam(param string) int {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("validate: bad rule parameter %q", param))
+	}
+	return n
+}
+
+// Email reports whether s is a bare address such as ann@example.com, with
+// a dot in the domain
+func Email(s string) bool {
+	if len(s) > 254 {
+		return false
+	}
+	a, err := mail.ParseAddress(s)
+	if err != nil || a.Address != s || a.Name != "" {
+		return false
+	}
+	_, domain, _ := strings.Cut(s, "@")
+	return strings.Contains(strings.Trim(domain, "."), ".")
+}
+
+// Username reports whether s is 3-30 ASCII letters, digits and underscores
+func Username(s string) bool {
+	if len(s) < 3 || len(s) > 30 {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c))) {
+			return false
+		}
+	}
+	return true
+}
+
+// reservedUsernames could be mistaken for the site itself, or clash with
+// paths such as /api/users/me
+var reservedUsernames = map[string]bool{
+	"about": true, "admin": true, "administrator": true, "anonymous": true,
+	"api": true, "app": true, "assets": true, "dream": true, "dreams": true,
+	"edit": true, "feed": true, "friends": true, "help": true, "login": true,
+	"logout": true, "me": true, "mod": true, "moderator": true, "new": true,
+	"notifications": true, "null": true, "official": true, "privacy": true,
+	"profile": true, "public": true, "register": true, "root": true,
+	"search": true, "security": true, "settings": true, "signup": true,
+	"sleeptalk": true, "staff": true, "static": true, "support": true,
+	"system": true, "terms": true, "undefined": true, "user": true,
+	"users": true, "www": true,
+}
+
+// ReservedUsername reports whether s, in any case, is kept back from
+// registration and renames
+func ReservedUsername(s string) bool {
+	return reservedUsernames[strings.ToLower(s)]
+}
+
+// commonPasswords are rejected outright even though they pass the other
+// checks
+var commonPasswords = map[string]bool{
+	"password1": true, "password123": true, "12345678a": true, "qwerty123": true,
+	"iloveyou1": true, "passw0rd": true, "p@ssw0rd": true, "letmein1": true,
+	"welcome1": true, "abc12345": true, "1q2w3e4r": true, "qwertyuiop1": true,
+}
+
+// Password returns why s is too weak, or "" if it is acceptable. bcrypt
+// ignores everything after 72 bytes, so longer passwords are refused rather
+// than silently truncated.
+func Password(s string) string {
+	if utf8.RuneCountInString(s) < 8 {
+		return "must be at least 8 characters long"
+	}
+	if len(s) > 72 {
+		return "must be at most 72 bytes long"
+	}
+	var lower, upper, digit, other int
+	for _, c := range s {
+		switch {
+		case unicode.IsLower(c):
+			lower = 1
+		case unicode.IsUpper(c):
+			upper = 1
+		case unicode.IsDigit(c):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	if lower+upper+digit+other < 2 {
+		return "must mix at least two of lowercase letters, uppercase letters, digits and symbols"
+	}
+	if commonPasswords[strings.ToLower(s)] {
+		return "is too common"
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type optional struct {
+	Set   bool
+	Value *int
+}
+
+func (o optional) FieldValue() (interface{}, bool) { return o.Value, o.Set }
+
+type request struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Username string   `json:"username" validate:"required,username"`
+	Password string   `json:"password" validate:"required,password"`
+	Title    *string  `json:"title,omitempty" validate:"notblank,max=5"`
+	Rating   *int     `json:"rating" validate:"min=1,max=10"`
+	Mood     optional `json:"mood" validate:"min=1,max=10"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Sort     string   `json:"sort" validate:"oneof=new old"`
+	Ignored  string   `json:"ignored"`
+}
+
+func valid() request {
+	return request{Email: "ann@example.com", Username: "ann_1", Password: "correct horse", Sort: "new"}
+}
+
+func codes(err error) map[string]string {
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return map[string]string{"?": err.Error()}
+	}
+	m := map[string]string{}
+	for _, f := range errs {
+		m[f.Field] = f.Code
+	}
+	return m
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestStruct(t *testing.T) {
+	if err := Struct(valid()); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		edit func(*request)
+		want map[string]string
+	}{
+		"missing": {func(r *request) { *r = request{Sort: "old"} }, map[string]string{
+			"email": "required", "username": "required", "password": "required",
+		}},
+		"blank":         {func(r *request) { r.Email = "  " }, map[string]string{"email": "required"}},
+		"bad email":     {func(r *request) { r.Email = "Ann <ann@example.com>" }, map[string]string{"email": "invalid_email"}},
+		"bad username":  {func(r *request) { r.Username = "ann smith" }, map[string]string{"username": "invalid_username"}},
+		"reserved name": {func(r *request) { r.Username = "Admin" }, map[string]string{"username": "reserved_username"}},
+		"weak password": {func(r *request) { r.Password = "password" }, map[string]string{"password": "weak_password"}},
+		"blank title":   {func(r *request) { r.Title = ptr(" ") }, map[string]string{"title": "blank"}},
+		"long title":    {func(r *request) { r.Title = ptr("dreams") }, map[string]string{"title": "too_long"}},
+		"short title":   {func(r *request) { r.Title = ptr("éééé") }, nil},
+		"low rating":    {func(r *request) { r.Rating = ptr(0) }, map[string]string{"rating": "too_small"}},
+		"high rating":   {func(r *request) { r.Rating = ptr(11) }, map[string]string{"rating": "too_large"}},
+		"mood":          {func(r *request) { r.Mood = optional{Set: true, Value: ptr(12)} }, map[string]string{"mood": "too_large"}},
+		"null mood":     {func(r *request) { r.Mood = optional{Set: true} }, nil},
+		"unset mood":    {func(r *request) { r.Mood = optional{Value: ptr(12)} }, nil},
+		"many tags":     {func(r *request) { r.Tags = []string{"a", "b", "c"} }, map[string]string{"tags": "too_long"}},
+		"bad sort":      {func(r *request) { r.Sort = "top" }, map[string]string{"sort": "invalid_choice"}},
+	} {
+		r := valid()
+		tc.edit(&r)
+		if got := codes(Struct(&r)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestStructPanicsOnBadTags(t *testing.T) {
+	for _, v := range []interface{}{
+		"not a struct",
+		struct {
+			A string `validate:"shiny"`
+		}{},
+		struct {
+			A string `validate:"max=ten"`
+		}{},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Struct(%#v) did not panic", v)
+				}
+			}()
+			Struct(v)
+		}()
+	}
+}
+
+func TestEmail(t *testing.T) {
+	for _, s := range []string{"ann@example.com", "a.b+c@mail.example.co.uk"} {
+		if !Email(s) {
+			t.Errorf("Email(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"", "ann", "ann@", "@example.com", "ann@localhost", "ann@example.com\r\nBcc: x@y.z", "<ann@example.com>"} {
+		if Email(s) {
+			t.Errorf("Email(%q) = true", s)
+		}
+	}
+}
+
+func TestUsername(t *testing.T) {
+	for _, s := range []string{"ann", "Ann_Smith_1", strings.Repeat("a", 30)} {
+		if !Username(s) {
+			t.Errorf("Username(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"an", strings.Repeat("a", 31), "ann-smith", "ann smith", "ännie"} {
+		if Username(s) {
+			t.Errorf("Username(%q) = true", s)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	for _, s := range []string{"correct horse", "Sleepwalk", "12345678!"} {
+		if msg := Password(s); msg != "" {
+			t.Errorf("Password(%q) = %q", s, msg)
+		}
+	}
+	for _, s := range []string{"", "short1", "password", "12345678", "Password1", strings.Repeat("a1", 37)} {
+		if Password(s) == "" {
+			t.Errorf("Password(%q) accepted", s)
+		}
+	}
+}
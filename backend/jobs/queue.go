@@ -0,0 +1,334 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Calrus/ourdreamjournal/backend/ai"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Kind identifies the AI task a job runs
+type Kind string
+
+const (
+	KindTags     Kind = "tags"
+	KindSummary  Kind = "summary"
+	KindProphecy Kind = "prophecy"
+)
+
+// Status is the lifecycle state of a job. Failed jobs go back to pending
+// with a backoff until they run out of attempts and become dead.
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusDead    Status = "dead"
+)
+
+// Job is a row of the ai_jobs table
+type Job struct {
+	ID          int64     `json:"id"`
+	Kind        Kind      `json:"kind"`
+	DreamID     int       `json:"-"`
+	Status      Status    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"maxAttempts"`
+	LastError   string    `json:"lastError,omitempty"`
+	RunAt       time.Time `json:"runAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// DB is the subset of pgxpool.Pool and pgx.Tx used to enqueue jobs, so a job
+// can be created in the same transaction as the dream it belongs to
+type DB interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+const jobColumns = "id, kind, dream_id, status, attempts, max_attempts, COALESCE(last_error, ''), run_at, created_at, updated_at"
+
+// ErrNotFound is returned when a job does not exist
+var ErrNotFound = errors.New("job not found")
+
+// ErrConflict is returned by Retry when an unfinished job of the same kind
+// already exists for the dream
+var ErrConflict = errors.New("job already queued")
+
+// Queue runs AI jobs stored in Postgres with a pool of worker goroutines
+type Queue struct {
+	pool *pgxpool.Pool
+	ai   ai.DreamAI
+	wake chan struct{}
+
+	Workers      int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	StaleAfter   time.Duration // running jobs older than this are reclaimed
+	JobTimeout   time.Duration
+
+	// OnFinish, if set, is called after a job is done or dead
+	OnFinish func(ctx context.Context, job *Job)
+}
+
+// NewQueue creates a queue with default tuning
+func NewQueue(pool *pgxpool.Pool, dreamAI ai.DreamAI) *Queue {
+	return &Queue{
+		pool:         pool,
+		ai:           dreamAI,
+		wake:         make(chan struct{}, 1),
+		Workers:      2,
+		PollInterval: 2 * time.Second,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   10 * time.Minute,
+		StaleAfter:   5 * time.Minute,
+		JobTimeout:   2 * time.Minute,
+	}
+}
+
+// Enqueue inserts a job using db, which may be a transaction. If an
+// unfinished job of the same kind already exists for the dream it is returned
+// instead of creating a duplicate.
+func Enqueue(ctx context.Context, db DB, kind Kind, dreamID int) (*Job, error) {
+	row := db.QueryRow(ctx,
+		`INSERT INTO ai_jobs (kind, dream_id) VALUES ($1, $2)
+		 ON CONFLICT (dream_id, kind) WHERE status IN ('pending', 'running')
+		 DO UPDATE SET updated_at = ai_jobs.updated_at
+		 RETURNING `+jobColumns,
+		kind, dreamID)
+	return scanJob(row)
+}
+
+// Enqueue inserts a job and wakes an idle worker
+func (q *Queue) Enqueue(ctx context.Context, kind Kind, dreamID int) (*Job, error) {
+	job, err := Enqueue(ctx, q.pool, kind, dreamID)
+	if err != nil {
+		return nil, err
+	}
+	q.Notify()
+	return job, nil
+}
+
+// Notify wakes an idle worker, e.g. after committing a transaction that
+// enqueued jobs
+func (q *Queue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Get loads a job by ID
+func (q *Queue) Get(ctx context.Context, id int64) (*Job, error) {
+	job, err := scanJob(q.pool.QueryRow(ctx, "SELECT "+jobColumns+" FROM ai_jobs WHERE id=$1", id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return job, err
+}
+
+// Retry moves a dead job back to pending with a fresh attempt budget.
+// Returns ErrNotFound unless the job is dead, and ErrConflict if the dream
+// has since got an unfinished job of the same kind.
+func (q *Queue) Retry(ctx context.Context, id int64) (*Job, error) {
+	job, err := scanJob(q.pool.QueryRow(ctx,
+		`UPDATE ai_jobs SET status='pending', attempts=0, run_at=NOW(), updated_at=NOW()
+		 WHERE id=$1 AND status='dead'
+		 RETURNING `+jobColumns, id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		// idx_ai_jobs_active allows one unfinished job per dream and kind
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.Notify()
+	return job, nil
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every worker
+// has finished its current job
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.Workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			q.work(ctx, worker)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, worker int) {
+	for {
+		job, err := q.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[JOBS] worker %d: claim failed: %v", worker, err)
+		}
+		if job != nil {
+			q.process(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+// claim locks the next due job, reclaiming jobs whose worker died
+func (q *Queue) claim(ctx context.Context) (*Job, error) {
+	job, err := scanJob(q.pool.QueryRow(ctx,
+		`UPDATE ai_jobs SET status='running', attempts=attempts+1, locked_at=NOW(), updated_at=NOW()
+		 WHERE id = (
+			SELECT id FROM ai_jobs
+			WHERE (status='pending' AND run_at <= NOW())
+			   OR (status='running' AND locked_at < NOW() - make_interval(secs => $1))
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		 )
+		 RETURNING `+jobColumns, q.StaleAfter.Seconds()))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (q *Queue) process(ctx context.Context, job *Job) {
+	runCtx, cancel := context.WithTimeout(ctx, q.JobTimeout)
+	err := q.execute(runCtx, job)
+	cancel()
+	// Record the outcome even if the queue is shutting down
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		q.finish(ctx, job, StatusDone, "", time.Time{})
+		return
+	}
+	log.Printf("[JOBS] %s job %d for dream %d failed (attempt %d/%d): %v", job.Kind, job.ID, job.DreamID, job.Attempts, job.MaxAttempts, err)
+	// A missing API key will not fix itself between retries
+	if job.Attempts >= job.MaxAttempts || errors.Is(err, ai.ErrNotConfigured) {
+		q.finish(ctx, job, StatusDead, err.Error(), time.Time{})
+		return
+	}
+	q.finish(ctx, job, StatusPending, err.Error(), time.Now().Add(q.backoff(job.Attempts)))
+}
+
+// backoff doubles the delay for each attempt, capped at MaxBackoff, with up
+// to 20% jitter so retries from a burst of failures spread out
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.BaseBackoff
+	for i := 1; i < attempts && d < q.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.MaxBackoff {
+		d = q.MaxBackoff
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+func (q *Queue) finish(ctx context.Context, job *Job, status Status, lastError string, runAt time.Time) {
+	_, err := q.pool.Exec(ctx,
+		`UPDATE ai_jobs SET status=$1, last_error=NULLIF($2, ''), run_at=COALESCE($3, run_at), locked_at=NULL, updated_at=NOW() WHERE id=$4`,
+		status, lastError, nullTime(runAt), job.ID)
+	if err != nil {
+		log.Printf("[JOBS] failed to update job %d: %v", job.ID, err)
+		return
+	}
+	job.Status = status
+	job.LastError = lastError
+	if q.OnFinish != nil && (status == StatusDone || status == StatusDead) {
+		q.OnFinish(ctx, job)
+	}
+}
+
+// execute runs the AI call for a job and stores the result on the dream
+func (q *Queue) execute(ctx context.Context, job *Job) error {
+	var text string
+	err := q.pool.QueryRow(ctx, "SELECT text FROM dreams WHERE id=$1", job.DreamID).Scan(&text)
+	if err != nil {
+		return fmt.Errorf("load dream: %w", err)
+	}
+	switch job.Kind {
+	case KindTags:
+		tags, err := q.ai.ExtractTags(ctx, text)
+		if err != nil {
+			return err
+		}
+		return q.storeTags(ctx, job.DreamID, tags)
+	case KindSummary:
+		summary, err := q.ai.Summarize(ctx, text)
+		if err != nil {
+			return err
+		}
+		// Skip the write if the dream was edited while the model was running
+		_, err = q.pool.Exec(ctx, "UPDATE dreams SET summary=$1, summary_hash=$2 WHERE id=$3 AND text=$4", summary, ai.ContentHash(text), job.DreamID, text)
+		return err
+	case KindProphecy:
+		prophecy, err := q.ai.Prophesy(ctx, text)
+		if err != nil {
+			return err
+		}
+		_, err = q.pool.Exec(ctx, "UPDATE dreams SET prophecy=$1 WHERE id=$2 AND text=$3", prophecy, job.DreamID, text)
+		return err
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
+
+// storeTags saves AI tags unless the dream already has tags, so a user who
+// set tags by hand while the job was queued keeps them
+func (q *Queue) storeTags(ctx context.Context, dreamID int, tags []string) error {
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var hasTags bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM dream_tags WHERE dream_id=$1) FROM dreams WHERE id=$1 FOR UPDATE", dreamID).Scan(&hasTags); err != nil {
+		return err
+	}
+	if hasTags {
+		return nil
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(ctx, "INSERT INTO dream_tags (dream_id, tag) VALUES ($1, $2)", dreamID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func scanJob(row pgx.Row) (*Job, error) {
+	var j Job
+	if err := row.Scan(&j.ID, &j.Kind, &j.DreamID, &j.Status, &j.Attempts, &j.MaxAttempts, &j.LastError, &j.RunAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,4 @@
+-- Migration: Indexes for keyset pagination on (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_dreams_public_created_at_id ON dreams (created_at DESC, id DESC) WHERE public;
+CREATE INDEX IF NOT EXISTS idx_dreams_user_created_at_id ON dreams (user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_comments_dream_created_at_id ON comments (dream_id, created_at, id);
@@ -0,0 +1,16 @@
+-- Migration: Email verification and one-time tokens for password resets and
+-- verification links, stored hashed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS account_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL CHECK (purpose IN ('password_reset', 'email_verify')),
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Issuing a token invalidates the user's earlier ones for the same purpose
+CREATE INDEX IF NOT EXISTS idx_account_tokens_user ON account_tokens (user_id, purpose);
@@ -0,0 +1,28 @@
+-- Migration: Case-insensitive unique, URL-safe usernames, and aliases that
+-- keep old profile links working after a rename
+
+-- Replace characters that are not allowed in usernames, pad short ones and
+-- cut long ones to 30 characters
+UPDATE users
+SET username = left(rpad(regexp_replace(username, '[^A-Za-z0-9_]', '_', 'g'), 3, '_'), 30)
+WHERE username !~ '^[A-Za-z0-9_]{3,30}$';
+
+-- The oldest account keeps a contested name; later ones get their ID
+-- appended
+UPDATE users u
+SET username = left(u.username, 30 - length(u.id::text) - 1) || '_' || u.id
+FROM users older
+WHERE lower(older.username) = lower(u.username) AND older.id < u.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (lower(username));
+
+-- Usernames a user gave up by renaming. They stay reserved for that user so
+-- nobody else can take over their old links.
+CREATE TABLE IF NOT EXISTS username_aliases (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    username TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_username_aliases_lower ON username_aliases (lower(username));
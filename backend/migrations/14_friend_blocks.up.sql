@@ -0,0 +1,9 @@
+-- Migration: Friend request rejection and blocking. A block is a single
+-- 'blocked' row owned by the blocker.
+UPDATE friends SET status = 'pending' WHERE status NOT IN ('pending', 'accepted', 'rejected', 'blocked');
+
+ALTER TABLE friends DROP CONSTRAINT IF EXISTS friends_status_check;
+ALTER TABLE friends ADD CONSTRAINT friends_status_check CHECK (status IN ('pending', 'accepted', 'rejected', 'blocked'));
+
+-- Listings look blocks up from both sides
+CREATE INDEX IF NOT EXISTS idx_friends_blocked ON friends (friend_id, user_id) WHERE status = 'blocked';
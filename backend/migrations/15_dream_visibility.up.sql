@@ -0,0 +1,16 @@
+-- Migration: Replace the public flag on dreams and their revisions with a
+-- visibility level: 'private', 'friends', 'public' or 'unlisted'
+ALTER TABLE dreams ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'private'
+    CHECK (visibility IN ('private', 'friends', 'public', 'unlisted'));
+UPDATE dreams SET visibility = CASE WHEN public THEN 'public' ELSE 'private' END;
+
+ALTER TABLE dream_revisions ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'private'
+    CHECK (visibility IN ('private', 'friends', 'public', 'unlisted'));
+UPDATE dream_revisions SET visibility = CASE WHEN public THEN 'public' ELSE 'private' END;
+
+DROP INDEX IF EXISTS idx_dreams_public_created_at_id;
+ALTER TABLE dreams DROP COLUMN public;
+ALTER TABLE dream_revisions DROP COLUMN public;
+
+CREATE INDEX IF NOT EXISTS idx_dreams_public_created_at_id ON dreams (created_at DESC, id DESC) WHERE visibility = 'public';
+CREATE INDEX IF NOT EXISTS idx_dreams_user_visibility ON dreams (user_id, visibility);
@@ -0,0 +1,22 @@
+-- Migration: Comment editing, threaded replies and reports for moderation
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id) WHERE parent_id IS NOT NULL;
+
+-- A user's complaint about a comment. Open reports (resolved_at IS NULL)
+-- make up the admin moderation queue; removing the comment removes its
+-- reports with it.
+CREATE TABLE IF NOT EXISTS comment_reports (
+    id SERIAL PRIMARY KEY,
+    comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP,
+    resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- One open report per user and comment
+CREATE UNIQUE INDEX IF NOT EXISTS idx_comment_reports_open ON comment_reports (comment_id, reporter_id) WHERE resolved_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comment_reports_queue ON comment_reports (created_at, id) WHERE resolved_at IS NULL;
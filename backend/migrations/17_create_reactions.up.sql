@@ -0,0 +1,15 @@
+-- Migration: Reactions on dreams and comments. Each user may leave one
+-- reaction of each kind on a dream or comment.
+CREATE TABLE IF NOT EXISTS reactions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dream_id INTEGER REFERENCES dreams(id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('relatable', 'spooky', 'beautiful')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((dream_id IS NULL) <> (comment_id IS NULL))
+);
+
+-- The unique indexes also serve the per-kind counts and who-reacted lists
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_dream_kind_user ON reactions (dream_id, kind, user_id) WHERE dream_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_comment_kind_user ON reactions (comment_id, kind, user_id) WHERE comment_id IS NOT NULL;
@@ -0,0 +1,26 @@
+-- Migration: In-app notifications and per-type notification preferences
+-- A notification goes to user_id about something actor_id did. Comment and
+-- reply notifications point at the comment and its dream, and go away with
+-- them.
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('friend_request', 'friend_accepted', 'comment', 'reply')),
+    dream_id INTEGER REFERENCES dreams(id) ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created_at_id ON notifications (user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications (user_id, type) WHERE read_at IS NULL;
+
+-- Only the types a user turned off or back on have a row; a missing row
+-- means the type is on
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type)
+);
@@ -0,0 +1,3 @@
+-- Indexes backing the per-user aggregation queries in /api/users/{id}/stats
+CREATE INDEX IF NOT EXISTS idx_dreams_user_created_at ON dreams (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_dream_tags_dream_id ON dream_tags (dream_id);
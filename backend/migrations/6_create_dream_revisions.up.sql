@@ -0,0 +1,16 @@
+-- Migration: Store a snapshot of a dream every time it is edited
+CREATE TABLE IF NOT EXISTS dream_revisions (
+    id SERIAL PRIMARY KEY,
+    dream_id INTEGER NOT NULL REFERENCES dreams(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT,
+    text TEXT NOT NULL,
+    public BOOLEAN NOT NULL,
+    nightmare_rating INTEGER,
+    vividness_rating INTEGER,
+    clarity_rating INTEGER,
+    emotional_intensity_rating INTEGER,
+    edited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(dream_id, revision)
+);
@@ -0,0 +1,20 @@
+-- Migration: Persistent queue for AI work (tagging, summaries, prophecies)
+CREATE TABLE IF NOT EXISTS ai_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL, -- 'tags', 'summary', 'prophecy'
+    dream_id INTEGER NOT NULL REFERENCES dreams(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'running', 'done', 'dead'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Workers poll for due pending jobs
+CREATE INDEX IF NOT EXISTS idx_ai_jobs_pending ON ai_jobs (run_at) WHERE status = 'pending';
+
+-- At most one unfinished job per dream and kind
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_jobs_active ON ai_jobs (dream_id, kind) WHERE status IN ('pending', 'running');
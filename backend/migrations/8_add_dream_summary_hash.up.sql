@@ -0,0 +1,6 @@
+-- Migration: Remember which version of the text a cached summary was made from
+ALTER TABLE dreams ADD COLUMN IF NOT EXISTS summary_hash TEXT;
+
+-- Existing summaries were cleared on every text edit, so they match the current text
+UPDATE dreams SET summary_hash = encode(sha256(convert_to(text, 'UTF8')), 'hex')
+WHERE summary IS NOT NULL AND summary <> '' AND summary_hash IS NULL;
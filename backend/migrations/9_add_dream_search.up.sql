@@ -0,0 +1,9 @@
+-- Migration: Full-text search over dream titles and texts. Title matches are
+-- weighted above text matches when ranking.
+ALTER TABLE dreams ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(text, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_dreams_search_vector ON dreams USING GIN (search_vector);
@@ -7,6 +7,7 @@
 package proto
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -21,33 +22,894 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// Dream represents a single dream entry in the journal
+// User represents a user account
+type User struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Email         string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	Username      string                 `protobuf:"bytes,3,opt,name=username,proto3" json:"username,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *User) Reset() {
+	*x = User{}
+	mi := &file_dream_journal_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *User) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*User) ProtoMessage() {}
+
+func (x *User) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use User.ProtoReflect.Descriptor instead.
+func (*User) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *User) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *User) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *User) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *User) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+// RegisterRequest is used to create a new user account
+type RegisterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Email         string                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Password      string                 `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	mi := &file_dream_journal_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RegisterRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+// LoginRequest is used to authenticate a user
+type LoginRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Email         string                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginRequest) Reset() {
+	*x = LoginRequest{}
+	mi := &file_dream_journal_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginRequest) ProtoMessage() {}
+
+func (x *LoginRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginRequest.ProtoReflect.Descriptor instead.
+func (*LoginRequest) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LoginRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *LoginRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+// AuthResponse is returned after successful authentication
+type AuthResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          *User                  `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	RefreshToken  string                 `protobuf:"bytes,3,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"` // single use; swap it with RefreshToken
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthResponse) Reset() {
+	*x = AuthResponse{}
+	mi := &file_dream_journal_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthResponse) ProtoMessage() {}
+
+func (x *AuthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthResponse.ProtoReflect.Descriptor instead.
+func (*AuthResponse) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AuthResponse) GetUser() *User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *AuthResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *AuthResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+// RefreshTokenRequest carries a refresh token from AuthResponse or Session
+type RefreshTokenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RefreshToken  string                 `protobuf:"bytes,1,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshTokenRequest) Reset() {
+	*x = RefreshTokenRequest{}
+	mi := &file_dream_journal_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshTokenRequest) ProtoMessage() {}
+
+func (x *RefreshTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshTokenRequest.ProtoReflect.Descriptor instead.
+func (*RefreshTokenRequest) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RefreshTokenRequest) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+// Session is a new access token and the refresh token that replaces the
+// one presented
+type Session struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	RefreshToken  string                 `protobuf:"bytes,2,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Session) Reset() {
+	*x = Session{}
+	mi := &file_dream_journal_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Session) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Session) ProtoMessage() {}
+
+func (x *Session) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Session.ProtoReflect.Descriptor instead.
+func (*Session) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Session) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *Session) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+// LogoutResponse is returned by Logout
+type LogoutResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogoutResponse) Reset() {
+	*x = LogoutResponse{}
+	mi := &file_dream_journal_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogoutResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogoutResponse) ProtoMessage() {}
+
+func (x *LogoutResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogoutResponse.ProtoReflect.Descriptor instead.
+func (*LogoutResponse) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{6}
+}
+
+// Dream represents a single dream entry
 type Dream struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId    string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Text      string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	Timestamp int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // Unix timestamp
+	Public    bool                   `protobuf:"varint,5,opt,name=public,proto3" json:"public,omitempty"`       // visibility == "public"
+	Title     string                 `protobuf:"bytes,11,opt,name=title,proto3" json:"title,omitempty"`
+	// Ratings (1-10)
+	NightmareRating          int32  `protobuf:"varint,12,opt,name=nightmare_rating,json=nightmareRating,proto3" json:"nightmare_rating,omitempty"`                              // 1 = nightmare, 10 = great dream
+	VividnessRating          int32  `protobuf:"varint,13,opt,name=vividness_rating,json=vividnessRating,proto3" json:"vividness_rating,omitempty"`                              // 1 = not vivid, 10 = extremely vivid
+	ClarityRating            int32  `protobuf:"varint,14,opt,name=clarity_rating,json=clarityRating,proto3" json:"clarity_rating,omitempty"`                                    // 1 = foggy, 10 = crystal clear
+	EmotionalIntensityRating int32  `protobuf:"varint,15,opt,name=emotional_intensity_rating,json=emotionalIntensityRating,proto3" json:"emotional_intensity_rating,omitempty"` // 1 = flat, 10 = intense
+	Visibility               string `protobuf:"bytes,16,opt,name=visibility,proto3" json:"visibility,omitempty"`                                                                // "private", "friends", "public" or "unlisted"
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *Dream) Reset() {
+	*x = Dream{}
+	mi := &file_dream_journal_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dream) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dream) ProtoMessage() {}
+
+func (x *Dream) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dream.ProtoReflect.Descriptor instead.
+func (*Dream) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Dream) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Dream) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Dream) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Dream) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Dream) GetPublic() bool {
+	if x != nil {
+		return x.Public
+	}
+	return false
+}
+
+func (x *Dream) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Dream) GetNightmareRating() int32 {
+	if x != nil {
+		return x.NightmareRating
+	}
+	return 0
+}
+
+func (x *Dream) GetVividnessRating() int32 {
+	if x != nil {
+		return x.VividnessRating
+	}
+	return 0
+}
+
+func (x *Dream) GetClarityRating() int32 {
+	if x != nil {
+		return x.ClarityRating
+	}
+	return 0
+}
+
+func (x *Dream) GetEmotionalIntensityRating() int32 {
+	if x != nil {
+		return x.EmotionalIntensityRating
+	}
+	return 0
+}
+
+func (x *Dream) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
+// DreamRequest is used to create a new dream entry
+type DreamRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Text   string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Public bool                   `protobuf:"varint,3,opt,name=public,proto3" json:"public,omitempty"` // used only when visibility is empty
+	Title  string                 `protobuf:"bytes,11,opt,name=title,proto3" json:"title,omitempty"`
+	// Ratings (1-10)
+	NightmareRating          int32  `protobuf:"varint,12,opt,name=nightmare_rating,json=nightmareRating,proto3" json:"nightmare_rating,omitempty"`
+	VividnessRating          int32  `protobuf:"varint,13,opt,name=vividness_rating,json=vividnessRating,proto3" json:"vividness_rating,omitempty"`
+	ClarityRating            int32  `protobuf:"varint,14,opt,name=clarity_rating,json=clarityRating,proto3" json:"clarity_rating,omitempty"`
+	EmotionalIntensityRating int32  `protobuf:"varint,15,opt,name=emotional_intensity_rating,json=emotionalIntensityRating,proto3" json:"emotional_intensity_rating,omitempty"`
+	Visibility               string `protobuf:"bytes,16,opt,name=visibility,proto3" json:"visibility,omitempty"` // "private", "friends", "public" or "unlisted"
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *DreamRequest) Reset() {
+	*x = DreamRequest{}
+	mi := &file_dream_journal_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DreamRequest) ProtoMessage() {}
+
+func (x *DreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DreamRequest.ProtoReflect.Descriptor instead.
+func (*DreamRequest) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DreamRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *DreamRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *DreamRequest) GetPublic() bool {
+	if x != nil {
+		return x.Public
+	}
+	return false
+}
+
+func (x *DreamRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *DreamRequest) GetNightmareRating() int32 {
+	if x != nil {
+		return x.NightmareRating
+	}
+	return 0
+}
+
+func (x *DreamRequest) GetVividnessRating() int32 {
+	if x != nil {
+		return x.VividnessRating
+	}
+	return 0
+}
+
+func (x *DreamRequest) GetClarityRating() int32 {
+	if x != nil {
+		return x.ClarityRating
+	}
+	return 0
+}
+
+func (x *DreamRequest) GetEmotionalIntensityRating() int32 {
+	if x != nil {
+		return x.EmotionalIntensityRating
+	}
+	return 0
+}
+
+func (x *DreamRequest) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
+// DreamResponse is returned after creating a dream
+type DreamResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dream         *Dream                 `protobuf:"bytes,1,opt,name=dream,proto3" json:"dream,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DreamResponse) Reset() {
+	*x = DreamResponse{}
+	mi := &file_dream_journal_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DreamResponse) ProtoMessage() {}
+
+func (x *DreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DreamResponse.ProtoReflect.Descriptor instead.
+func (*DreamResponse) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DreamResponse) GetDream() *Dream {
+	if x != nil {
+		return x.Dream
+	}
+	return nil
+}
+
+// DreamSummary is returned after summarizing a dream
+type DreamSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Summary       string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DreamSummary) Reset() {
+	*x = DreamSummary{}
+	mi := &file_dream_journal_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DreamSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DreamSummary) ProtoMessage() {}
+
+func (x *DreamSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DreamSummary.ProtoReflect.Descriptor instead.
+func (*DreamSummary) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DreamSummary) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+// ProphecyResponse is returned after generating a prophecy
+type ProphecyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prophecy      string                 `protobuf:"bytes,1,opt,name=prophecy,proto3" json:"prophecy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProphecyResponse) Reset() {
+	*x = ProphecyResponse{}
+	mi := &file_dream_journal_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProphecyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProphecyResponse) ProtoMessage() {}
+
+func (x *ProphecyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProphecyResponse.ProtoReflect.Descriptor instead.
+func (*ProphecyResponse) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ProphecyResponse) GetProphecy() string {
+	if x != nil {
+		return x.Prophecy
+	}
+	return ""
+}
+
+// TagResponse is returned after extracting tags from a dream
+type TagResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tags          []string               `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TagResponse) Reset() {
+	*x = TagResponse{}
+	mi := &file_dream_journal_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TagResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TagResponse) ProtoMessage() {}
+
+func (x *TagResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TagResponse.ProtoReflect.Descriptor instead.
+func (*TagResponse) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *TagResponse) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+// ListRequest is used to fetch dreams
+type ListRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	IncludePublic bool                   `protobuf:"varint,2,opt,name=include_public,json=includePublic,proto3" json:"include_public,omitempty"` // Whether to include dreams other users may see listed
+	Limit         int32                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`                                      // 0 means the default of 20; at most 100
+	Cursor        string                 `protobuf:"bytes,4,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_dream_journal_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListRequest) GetIncludePublic() bool {
+	if x != nil {
+		return x.IncludePublic
+	}
+	return false
+}
+
+func (x *ListRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+// UserRequest is used to fetch AI insights for a user
+type UserRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Text          string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
-	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // Unix timestamp
-	Public        bool                   `protobuf:"varint,5,opt,name=public,proto3" json:"public,omitempty"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Dream) Reset() {
-	*x = Dream{}
-	mi := &file_dream_journal_proto_msgTypes[0]
+func (x *UserRequest) Reset() {
+	*x = UserRequest{}
+	mi := &file_dream_journal_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Dream) String() string {
+func (x *UserRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Dream) ProtoMessage() {}
+func (*UserRequest) ProtoMessage() {}
 
-func (x *Dream) ProtoReflect() protoreflect.Message {
-	mi := &file_dream_journal_proto_msgTypes[0]
+func (x *UserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -58,71 +920,103 @@ func (x *Dream) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Dream.ProtoReflect.Descriptor instead.
-func (*Dream) Descriptor() ([]byte, []int) {
-	return file_dream_journal_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use UserRequest.ProtoReflect.Descriptor instead.
+func (*UserRequest) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *Dream) GetId() string {
+func (x *UserRequest) GetUserId() string {
 	if x != nil {
-		return x.Id
+		return x.UserId
 	}
 	return ""
 }
 
-func (x *Dream) GetUserId() string {
+// DreamInsight contains AI summary and tags for a dream
+type DreamInsight struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DreamId       string                 `protobuf:"bytes,1,opt,name=dream_id,json=dreamId,proto3" json:"dream_id,omitempty"`
+	Summary       string                 `protobuf:"bytes,2,opt,name=summary,proto3" json:"summary,omitempty"`
+	Tags          []string               `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DreamInsight) Reset() {
+	*x = DreamInsight{}
+	mi := &file_dream_journal_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DreamInsight) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DreamInsight) ProtoMessage() {}
+
+func (x *DreamInsight) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[15]
 	if x != nil {
-		return x.UserId
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *Dream) GetText() string {
+// Deprecated: Use DreamInsight.ProtoReflect.Descriptor instead.
+func (*DreamInsight) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DreamInsight) GetDreamId() string {
 	if x != nil {
-		return x.Text
+		return x.DreamId
 	}
 	return ""
 }
 
-func (x *Dream) GetTimestamp() int64 {
+func (x *DreamInsight) GetSummary() string {
 	if x != nil {
-		return x.Timestamp
+		return x.Summary
 	}
-	return 0
+	return ""
 }
 
-func (x *Dream) GetPublic() bool {
+func (x *DreamInsight) GetTags() []string {
 	if x != nil {
-		return x.Public
+		return x.Tags
 	}
-	return false
+	return nil
 }
 
-// DreamRequest is used to create a new dream entry
-type DreamRequest struct {
+// Friend request/response
+type FriendRequestMsg struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
-	Public        bool                   `protobuf:"varint,3,opt,name=public,proto3" json:"public,omitempty"`
+	FriendId      string                 `protobuf:"bytes,2,opt,name=friend_id,json=friendId,proto3" json:"friend_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DreamRequest) Reset() {
-	*x = DreamRequest{}
-	mi := &file_dream_journal_proto_msgTypes[1]
+func (x *FriendRequestMsg) Reset() {
+	*x = FriendRequestMsg{}
+	mi := &file_dream_journal_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DreamRequest) String() string {
+func (x *FriendRequestMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DreamRequest) ProtoMessage() {}
+func (*FriendRequestMsg) ProtoMessage() {}
 
-func (x *DreamRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_dream_journal_proto_msgTypes[1]
+func (x *FriendRequestMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -133,55 +1027,159 @@ func (x *DreamRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DreamRequest.ProtoReflect.Descriptor instead.
-func (*DreamRequest) Descriptor() ([]byte, []int) {
-	return file_dream_journal_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use FriendRequestMsg.ProtoReflect.Descriptor instead.
+func (*FriendRequestMsg) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{16}
 }
 
-func (x *DreamRequest) GetUserId() string {
+func (x *FriendRequestMsg) GetUserId() string {
 	if x != nil {
 		return x.UserId
 	}
 	return ""
 }
 
-func (x *DreamRequest) GetText() string {
+func (x *FriendRequestMsg) GetFriendId() string {
 	if x != nil {
-		return x.Text
+		return x.FriendId
 	}
 	return ""
 }
 
-func (x *DreamRequest) GetPublic() bool {
+type FriendResponseMsg struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"` // 'pending', 'accepted', 'rejected'
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FriendResponseMsg) Reset() {
+	*x = FriendResponseMsg{}
+	mi := &file_dream_journal_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FriendResponseMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FriendResponseMsg) ProtoMessage() {}
+
+func (x *FriendResponseMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[17]
 	if x != nil {
-		return x.Public
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return false
+	return mi.MessageOf(x)
 }
 
-// DreamResponse is returned after creating a dream
-type DreamResponse struct {
+// Deprecated: Use FriendResponseMsg.ProtoReflect.Descriptor instead.
+func (*FriendResponseMsg) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *FriendResponseMsg) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type Friend struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Username        string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	DisplayName     string                 `protobuf:"bytes,3,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	ProfileImageUrl string                 `protobuf:"bytes,4,opt,name=profile_image_url,json=profileImageUrl,proto3" json:"profile_image_url,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Friend) Reset() {
+	*x = Friend{}
+	mi := &file_dream_journal_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Friend) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Friend) ProtoMessage() {}
+
+func (x *Friend) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Friend.ProtoReflect.Descriptor instead.
+func (*Friend) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Friend) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Friend) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Friend) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *Friend) GetProfileImageUrl() string {
+	if x != nil {
+		return x.ProfileImageUrl
+	}
+	return ""
+}
+
+type FriendList struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Dream         *Dream                 `protobuf:"bytes,1,opt,name=dream,proto3" json:"dream,omitempty"`
+	Friends       []*Friend              `protobuf:"bytes,1,rep,name=friends,proto3" json:"friends,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DreamResponse) Reset() {
-	*x = DreamResponse{}
-	mi := &file_dream_journal_proto_msgTypes[2]
+func (x *FriendList) Reset() {
+	*x = FriendList{}
+	mi := &file_dream_journal_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DreamResponse) String() string {
+func (x *FriendList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DreamResponse) ProtoMessage() {}
+func (*FriendList) ProtoMessage() {}
 
-func (x *DreamResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_dream_journal_proto_msgTypes[2]
+func (x *FriendList) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -192,42 +1190,44 @@ func (x *DreamResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DreamResponse.ProtoReflect.Descriptor instead.
-func (*DreamResponse) Descriptor() ([]byte, []int) {
-	return file_dream_journal_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use FriendList.ProtoReflect.Descriptor instead.
+func (*FriendList) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *DreamResponse) GetDream() *Dream {
+func (x *FriendList) GetFriends() []*Friend {
 	if x != nil {
-		return x.Dream
+		return x.Friends
 	}
 	return nil
 }
 
-// ListRequest is used to fetch dreams
-type ListRequest struct {
+// List of dreams by friends, newest first. Pass next_cursor back as cursor
+// to fetch the following page; it is empty on the last page.
+type FriendsDreamsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	IncludePublic bool                   `protobuf:"varint,2,opt,name=include_public,json=includePublic,proto3" json:"include_public,omitempty"` // Whether to include public dreams from other users
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"` // 0 means the default of 20; at most 100
+	Cursor        string                 `protobuf:"bytes,3,opt,name=cursor,proto3" json:"cursor,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ListRequest) Reset() {
-	*x = ListRequest{}
-	mi := &file_dream_journal_proto_msgTypes[3]
+func (x *FriendsDreamsRequest) Reset() {
+	*x = FriendsDreamsRequest{}
+	mi := &file_dream_journal_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ListRequest) String() string {
+func (x *FriendsDreamsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListRequest) ProtoMessage() {}
+func (*FriendsDreamsRequest) ProtoMessage() {}
 
-func (x *ListRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_dream_journal_proto_msgTypes[3]
+func (x *FriendsDreamsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -238,49 +1238,197 @@ func (x *ListRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
-func (*ListRequest) Descriptor() ([]byte, []int) {
-	return file_dream_journal_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use FriendsDreamsRequest.ProtoReflect.Descriptor instead.
+func (*FriendsDreamsRequest) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *ListRequest) GetUserId() string {
+func (x *FriendsDreamsRequest) GetUserId() string {
 	if x != nil {
 		return x.UserId
 	}
 	return ""
 }
 
-func (x *ListRequest) GetIncludePublic() bool {
+func (x *FriendsDreamsRequest) GetLimit() int32 {
 	if x != nil {
-		return x.IncludePublic
+		return x.Limit
 	}
-	return false
+	return 0
+}
+
+func (x *FriendsDreamsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type FriendsDreamsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dreams        []*Dream               `protobuf:"bytes,1,rep,name=dreams,proto3" json:"dreams,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FriendsDreamsResponse) Reset() {
+	*x = FriendsDreamsResponse{}
+	mi := &file_dream_journal_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FriendsDreamsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FriendsDreamsResponse) ProtoMessage() {}
+
+func (x *FriendsDreamsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dream_journal_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FriendsDreamsResponse.ProtoReflect.Descriptor instead.
+func (*FriendsDreamsResponse) Descriptor() ([]byte, []int) {
+	return file_dream_journal_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *FriendsDreamsResponse) GetDreams() []*Dream {
+	if x != nil {
+		return x.Dreams
+	}
+	return nil
+}
+
+func (x *FriendsDreamsResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
 }
 
 var File_dream_journal_proto protoreflect.FileDescriptor
 
 const file_dream_journal_proto_rawDesc = "" +
 	"\n" +
-	"\x13dream_journal.proto\x12\fdreamjournal\"z\n" +
+	"\x13dream_journal.proto\x12\fdreamjournal\x1a\x1cgoogle/api/annotations.proto\"g\n" +
+	"\x04User\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x12\x1a\n" +
+	"\busername\x18\x03 \x01(\tR\busername\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\x03R\tcreatedAt\"_\n" +
+	"\x0fRegisterRequest\x12\x14\n" +
+	"\x05email\x18\x01 \x01(\tR\x05email\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x1a\n" +
+	"\bpassword\x18\x03 \x01(\tR\bpassword\"@\n" +
+	"\fLoginRequest\x12\x14\n" +
+	"\x05email\x18\x01 \x01(\tR\x05email\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"q\n" +
+	"\fAuthResponse\x12&\n" +
+	"\x04user\x18\x01 \x01(\v2\x12.dreamjournal.UserR\x04user\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12#\n" +
+	"\rrefresh_token\x18\x03 \x01(\tR\frefreshToken\":\n" +
+	"\x13RefreshTokenRequest\x12#\n" +
+	"\rrefresh_token\x18\x01 \x01(\tR\frefreshToken\"D\n" +
+	"\aSession\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12#\n" +
+	"\rrefresh_token\x18\x02 \x01(\tR\frefreshToken\"\x10\n" +
+	"\x0eLogoutResponse\"\xeb\x02\n" +
 	"\x05Dream\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x12\n" +
 	"\x04text\x18\x03 \x01(\tR\x04text\x12\x1c\n" +
 	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\x12\x16\n" +
-	"\x06public\x18\x05 \x01(\bR\x06public\"S\n" +
+	"\x06public\x18\x05 \x01(\bR\x06public\x12\x14\n" +
+	"\x05title\x18\v \x01(\tR\x05title\x12)\n" +
+	"\x10nightmare_rating\x18\f \x01(\x05R\x0fnightmareRating\x12)\n" +
+	"\x10vividness_rating\x18\r \x01(\x05R\x0fvividnessRating\x12%\n" +
+	"\x0eclarity_rating\x18\x0e \x01(\x05R\rclarityRating\x12<\n" +
+	"\x1aemotional_intensity_rating\x18\x0f \x01(\x05R\x18emotionalIntensityRating\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x10 \x01(\tR\n" +
+	"visibility\"\xc4\x02\n" +
 	"\fDreamRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x12\n" +
 	"\x04text\x18\x02 \x01(\tR\x04text\x12\x16\n" +
-	"\x06public\x18\x03 \x01(\bR\x06public\":\n" +
+	"\x06public\x18\x03 \x01(\bR\x06public\x12\x14\n" +
+	"\x05title\x18\v \x01(\tR\x05title\x12)\n" +
+	"\x10nightmare_rating\x18\f \x01(\x05R\x0fnightmareRating\x12)\n" +
+	"\x10vividness_rating\x18\r \x01(\x05R\x0fvividnessRating\x12%\n" +
+	"\x0eclarity_rating\x18\x0e \x01(\x05R\rclarityRating\x12<\n" +
+	"\x1aemotional_intensity_rating\x18\x0f \x01(\x05R\x18emotionalIntensityRating\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x10 \x01(\tR\n" +
+	"visibility\":\n" +
 	"\rDreamResponse\x12)\n" +
-	"\x05dream\x18\x01 \x01(\v2\x13.dreamjournal.DreamR\x05dream\"M\n" +
+	"\x05dream\x18\x01 \x01(\v2\x13.dreamjournal.DreamR\x05dream\"(\n" +
+	"\fDreamSummary\x12\x18\n" +
+	"\asummary\x18\x01 \x01(\tR\asummary\".\n" +
+	"\x10ProphecyResponse\x12\x1a\n" +
+	"\bprophecy\x18\x01 \x01(\tR\bprophecy\"!\n" +
+	"\vTagResponse\x12\x12\n" +
+	"\x04tags\x18\x01 \x03(\tR\x04tags\"{\n" +
 	"\vListRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12%\n" +
-	"\x0einclude_public\x18\x02 \x01(\bR\rincludePublic2\x9a\x01\n" +
-	"\fDreamJournal\x12H\n" +
-	"\vCreateDream\x12\x1a.dreamjournal.DreamRequest\x1a\x1b.dreamjournal.DreamResponse\"\x00\x12@\n" +
+	"\x0einclude_public\x18\x02 \x01(\bR\rincludePublic\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x04 \x01(\tR\x06cursor\"&\n" +
+	"\vUserRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"W\n" +
+	"\fDreamInsight\x12\x19\n" +
+	"\bdream_id\x18\x01 \x01(\tR\adreamId\x12\x18\n" +
+	"\asummary\x18\x02 \x01(\tR\asummary\x12\x12\n" +
+	"\x04tags\x18\x03 \x03(\tR\x04tags\"H\n" +
+	"\x10FriendRequestMsg\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tfriend_id\x18\x02 \x01(\tR\bfriendId\"+\n" +
+	"\x11FriendResponseMsg\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\"\x83\x01\n" +
+	"\x06Friend\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12!\n" +
+	"\fdisplay_name\x18\x03 \x01(\tR\vdisplayName\x12*\n" +
+	"\x11profile_image_url\x18\x04 \x01(\tR\x0fprofileImageUrl\"<\n" +
+	"\n" +
+	"FriendList\x12.\n" +
+	"\afriends\x18\x01 \x03(\v2\x14.dreamjournal.FriendR\afriends\"]\n" +
+	"\x14FriendsDreamsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x03 \x01(\tR\x06cursor\"e\n" +
+	"\x15FriendsDreamsResponse\x12+\n" +
+	"\x06dreams\x18\x01 \x03(\v2\x13.dreamjournal.DreamR\x06dreams\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor2\xa0\v\n" +
+	"\fDreamJournal\x12_\n" +
+	"\bRegister\x12\x1d.dreamjournal.RegisterRequest\x1a\x1a.dreamjournal.AuthResponse\"\x18\x82\xd3\xe4\x93\x02\x12:\x01*\"\r/api/register\x12V\n" +
+	"\x05Login\x12\x1a.dreamjournal.LoginRequest\x1a\x1a.dreamjournal.AuthResponse\"\x15\x82\xd3\xe4\x93\x02\x0f:\x01*\"\n" +
+	"/api/login\x12g\n" +
+	"\fRefreshToken\x12!.dreamjournal.RefreshTokenRequest\x1a\x15.dreamjournal.Session\"\x1d\x82\xd3\xe4\x93\x02\x17:\x01*\"\x12/api/token/refresh\x12a\n" +
+	"\x06Logout\x12!.dreamjournal.RefreshTokenRequest\x1a\x1c.dreamjournal.LogoutResponse\"\x16\x82\xd3\xe4\x93\x02\x10:\x01*\"\v/api/logout\x12]\n" +
+	"\vCreateDream\x12\x1a.dreamjournal.DreamRequest\x1a\x1b.dreamjournal.DreamResponse\"\x15\x82\xd3\xe4\x93\x02\x0f:\x01*\"\n" +
+	"/api/dream\x12S\n" +
 	"\n" +
-	"ListDreams\x12\x19.dreamjournal.ListRequest\x1a\x13.dreamjournal.Dream\"\x000\x01B\x0fZ\rbackend/protob\x06proto3"
+	"ListDreams\x12\x19.dreamjournal.ListRequest\x1a\x13.dreamjournal.Dream\"\x13\x82\xd3\xe4\x93\x02\r\x12\v/api/dreams0\x01\x12H\n" +
+	"\x0eSummarizeDream\x12\x1a.dreamjournal.DreamRequest\x1a\x1a.dreamjournal.DreamSummary\x12K\n" +
+	"\rDreamProphecy\x12\x1a.dreamjournal.DreamRequest\x1a\x1e.dreamjournal.ProphecyResponse\x12A\n" +
+	"\bTagDream\x12\x1a.dreamjournal.DreamRequest\x1a\x19.dreamjournal.TagResponse\x12H\n" +
+	"\rGetAIInsights\x12\x19.dreamjournal.UserRequest\x1a\x1a.dreamjournal.DreamInsight0\x01\x12u\n" +
+	"\x11SendFriendRequest\x12\x1e.dreamjournal.FriendRequestMsg\x1a\x1f.dreamjournal.FriendResponseMsg\"\x1f\x82\xd3\xe4\x93\x02\x19:\x01*\"\x14/api/friends/request\x12v\n" +
+	"\x13AcceptFriendRequest\x12\x1e.dreamjournal.FriendRequestMsg\x1a\x1f.dreamjournal.FriendResponseMsg\"\x1e\x82\xd3\xe4\x93\x02\x18:\x01*\"\x13/api/friends/accept\x12o\n" +
+	"\fRemoveFriend\x12\x1e.dreamjournal.FriendRequestMsg\x1a\x1f.dreamjournal.FriendResponseMsg\"\x1e\x82\xd3\xe4\x93\x02\x18:\x01*\"\x13/api/friends/remove\x12X\n" +
+	"\vListFriends\x12\x19.dreamjournal.UserRequest\x1a\x18.dreamjournal.FriendList\"\x14\x82\xd3\xe4\x93\x02\x0e\x12\f/api/friends\x12y\n" +
+	"\x11ListFriendsDreams\x12\".dreamjournal.FriendsDreamsRequest\x1a#.dreamjournal.FriendsDreamsResponse\"\x1b\x82\xd3\xe4\x93\x02\x15\x12\x13/api/friends/dreamsB1Z/github.com/Calrus/ourdreamjournal/backend/protob\x06proto3"
 
 var (
 	file_dream_journal_proto_rawDescOnce sync.Once
@@ -294,24 +1442,71 @@ func file_dream_journal_proto_rawDescGZIP() []byte {
 	return file_dream_journal_proto_rawDescData
 }
 
-var file_dream_journal_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_dream_journal_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
 var file_dream_journal_proto_goTypes = []any{
-	(*Dream)(nil),         // 0: dreamjournal.Dream
-	(*DreamRequest)(nil),  // 1: dreamjournal.DreamRequest
-	(*DreamResponse)(nil), // 2: dreamjournal.DreamResponse
-	(*ListRequest)(nil),   // 3: dreamjournal.ListRequest
+	(*User)(nil),                  // 0: dreamjournal.User
+	(*RegisterRequest)(nil),       // 1: dreamjournal.RegisterRequest
+	(*LoginRequest)(nil),          // 2: dreamjournal.LoginRequest
+	(*AuthResponse)(nil),          // 3: dreamjournal.AuthResponse
+	(*RefreshTokenRequest)(nil),   // 4: dreamjournal.RefreshTokenRequest
+	(*Session)(nil),               // 5: dreamjournal.Session
+	(*LogoutResponse)(nil),        // 6: dreamjournal.LogoutResponse
+	(*Dream)(nil),                 // 7: dreamjournal.Dream
+	(*DreamRequest)(nil),          // 8: dreamjournal.DreamRequest
+	(*DreamResponse)(nil),         // 9: dreamjournal.DreamResponse
+	(*DreamSummary)(nil),          // 10: dreamjournal.DreamSummary
+	(*ProphecyResponse)(nil),      // 11: dreamjournal.ProphecyResponse
+	(*TagResponse)(nil),           // 12: dreamjournal.TagResponse
+	(*ListRequest)(nil),           // 13: dreamjournal.ListRequest
+	(*UserRequest)(nil),           // 14: dreamjournal.UserRequest
+	(*DreamInsight)(nil),          // 15: dreamjournal.DreamInsight
+	(*FriendRequestMsg)(nil),      // 16: dreamjournal.FriendRequestMsg
+	(*FriendResponseMsg)(nil),     // 17: dreamjournal.FriendResponseMsg
+	(*Friend)(nil),                // 18: dreamjournal.Friend
+	(*FriendList)(nil),            // 19: dreamjournal.FriendList
+	(*FriendsDreamsRequest)(nil),  // 20: dreamjournal.FriendsDreamsRequest
+	(*FriendsDreamsResponse)(nil), // 21: dreamjournal.FriendsDreamsResponse
 }
 var file_dream_journal_proto_depIdxs = []int32{
-	0, // 0: dreamjournal.DreamResponse.dream:type_name -> dreamjournal.Dream
-	1, // 1: dreamjournal.DreamJournal.CreateDream:input_type -> dreamjournal.DreamRequest
-	3, // 2: dreamjournal.DreamJournal.ListDreams:input_type -> dreamjournal.ListRequest
-	2, // 3: dreamjournal.DreamJournal.CreateDream:output_type -> dreamjournal.DreamResponse
-	0, // 4: dreamjournal.DreamJournal.ListDreams:output_type -> dreamjournal.Dream
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: dreamjournal.AuthResponse.user:type_name -> dreamjournal.User
+	7,  // 1: dreamjournal.DreamResponse.dream:type_name -> dreamjournal.Dream
+	18, // 2: dreamjournal.FriendList.friends:type_name -> dreamjournal.Friend
+	7,  // 3: dreamjournal.FriendsDreamsResponse.dreams:type_name -> dreamjournal.Dream
+	1,  // 4: dreamjournal.DreamJournal.Register:input_type -> dreamjournal.RegisterRequest
+	2,  // 5: dreamjournal.DreamJournal.Login:input_type -> dreamjournal.LoginRequest
+	4,  // 6: dreamjournal.DreamJournal.RefreshToken:input_type -> dreamjournal.RefreshTokenRequest
+	4,  // 7: dreamjournal.DreamJournal.Logout:input_type -> dreamjournal.RefreshTokenRequest
+	8,  // 8: dreamjournal.DreamJournal.CreateDream:input_type -> dreamjournal.DreamRequest
+	13, // 9: dreamjournal.DreamJournal.ListDreams:input_type -> dreamjournal.ListRequest
+	8,  // 10: dreamjournal.DreamJournal.SummarizeDream:input_type -> dreamjournal.DreamRequest
+	8,  // 11: dreamjournal.DreamJournal.DreamProphecy:input_type -> dreamjournal.DreamRequest
+	8,  // 12: dreamjournal.DreamJournal.TagDream:input_type -> dreamjournal.DreamRequest
+	14, // 13: dreamjournal.DreamJournal.GetAIInsights:input_type -> dreamjournal.UserRequest
+	16, // 14: dreamjournal.DreamJournal.SendFriendRequest:input_type -> dreamjournal.FriendRequestMsg
+	16, // 15: dreamjournal.DreamJournal.AcceptFriendRequest:input_type -> dreamjournal.FriendRequestMsg
+	16, // 16: dreamjournal.DreamJournal.RemoveFriend:input_type -> dreamjournal.FriendRequestMsg
+	14, // 17: dreamjournal.DreamJournal.ListFriends:input_type -> dreamjournal.UserRequest
+	20, // 18: dreamjournal.DreamJournal.ListFriendsDreams:input_type -> dreamjournal.FriendsDreamsRequest
+	3,  // 19: dreamjournal.DreamJournal.Register:output_type -> dreamjournal.AuthResponse
+	3,  // 20: dreamjournal.DreamJournal.Login:output_type -> dreamjournal.AuthResponse
+	5,  // 21: dreamjournal.DreamJournal.RefreshToken:output_type -> dreamjournal.Session
+	6,  // 22: dreamjournal.DreamJournal.Logout:output_type -> dreamjournal.LogoutResponse
+	9,  // 23: dreamjournal.DreamJournal.CreateDream:output_type -> dreamjournal.DreamResponse
+	7,  // 24: dreamjournal.DreamJournal.ListDreams:output_type -> dreamjournal.Dream
+	10, // 25: dreamjournal.DreamJournal.SummarizeDream:output_type -> dreamjournal.DreamSummary
+	11, // 26: dreamjournal.DreamJournal.DreamProphecy:output_type -> dreamjournal.ProphecyResponse
+	12, // 27: dreamjournal.DreamJournal.TagDream:output_type -> dreamjournal.TagResponse
+	15, // 28: dreamjournal.DreamJournal.GetAIInsights:output_type -> dreamjournal.DreamInsight
+	17, // 29: dreamjournal.DreamJournal.SendFriendRequest:output_type -> dreamjournal.FriendResponseMsg
+	17, // 30: dreamjournal.DreamJournal.AcceptFriendRequest:output_type -> dreamjournal.FriendResponseMsg
+	17, // 31: dreamjournal.DreamJournal.RemoveFriend:output_type -> dreamjournal.FriendResponseMsg
+	19, // 32: dreamjournal.DreamJournal.ListFriends:output_type -> dreamjournal.FriendList
+	21, // 33: dreamjournal.DreamJournal.ListFriendsDreams:output_type -> dreamjournal.FriendsDreamsResponse
+	19, // [19:34] is the sub-list for method output_type
+	4,  // [4:19] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_dream_journal_proto_init() }
@@ -325,7 +1520,7 @@ func file_dream_journal_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dream_journal_proto_rawDesc), len(file_dream_journal_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   22,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
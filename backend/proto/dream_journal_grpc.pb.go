@@ -19,8 +19,21 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	DreamJournal_CreateDream_FullMethodName = "/dreamjournal.DreamJournal/CreateDream"
-	DreamJournal_ListDreams_FullMethodName  = "/dreamjournal.DreamJournal/ListDreams"
+	DreamJournal_Register_FullMethodName            = "/dreamjournal.DreamJournal/Register"
+	DreamJournal_Login_FullMethodName               = "/dreamjournal.DreamJournal/Login"
+	DreamJournal_RefreshToken_FullMethodName        = "/dreamjournal.DreamJournal/RefreshToken"
+	DreamJournal_Logout_FullMethodName              = "/dreamjournal.DreamJournal/Logout"
+	DreamJournal_CreateDream_FullMethodName         = "/dreamjournal.DreamJournal/CreateDream"
+	DreamJournal_ListDreams_FullMethodName          = "/dreamjournal.DreamJournal/ListDreams"
+	DreamJournal_SummarizeDream_FullMethodName      = "/dreamjournal.DreamJournal/SummarizeDream"
+	DreamJournal_DreamProphecy_FullMethodName       = "/dreamjournal.DreamJournal/DreamProphecy"
+	DreamJournal_TagDream_FullMethodName            = "/dreamjournal.DreamJournal/TagDream"
+	DreamJournal_GetAIInsights_FullMethodName       = "/dreamjournal.DreamJournal/GetAIInsights"
+	DreamJournal_SendFriendRequest_FullMethodName   = "/dreamjournal.DreamJournal/SendFriendRequest"
+	DreamJournal_AcceptFriendRequest_FullMethodName = "/dreamjournal.DreamJournal/AcceptFriendRequest"
+	DreamJournal_RemoveFriend_FullMethodName        = "/dreamjournal.DreamJournal/RemoveFriend"
+	DreamJournal_ListFriends_FullMethodName         = "/dreamjournal.DreamJournal/ListFriends"
+	DreamJournal_ListFriendsDreams_FullMethodName   = "/dreamjournal.DreamJournal/ListFriendsDreams"
 )
 
 // DreamJournalClient is the client API for DreamJournal service.
@@ -29,10 +42,35 @@ const (
 //
 // DreamJournal service definition
 type DreamJournalClient interface {
+	// Register creates a new user account
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	// Login authenticates a user
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	// RefreshToken swaps a refresh token for a new session. Replaying a used
+	// refresh token revokes every token in its family.
+	RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*Session, error)
+	// Logout revokes every refresh token in the presented token's family
+	Logout(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*LogoutResponse, error)
 	// CreateDream creates a new dream entry
 	CreateDream(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*DreamResponse, error)
-	// ListDreams streams dreams based on the request parameters
+	// ListDreams streams a page of dreams based on the request parameters.
+	// When there are more, the next-cursor trailer holds the cursor of the
+	// next page.
 	ListDreams(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Dream], error)
+	// SummarizeDream summarizes a dream using OpenAI
+	SummarizeDream(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*DreamSummary, error)
+	// DreamProphecy generates a mystical prophecy from a dream
+	DreamProphecy(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*ProphecyResponse, error)
+	// TagDream extracts tags from a dream using OpenAI
+	TagDream(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*TagResponse, error)
+	// GetAIInsights streams AI insights for a user's dreams
+	GetAIInsights(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DreamInsight], error)
+	// Friend system
+	SendFriendRequest(ctx context.Context, in *FriendRequestMsg, opts ...grpc.CallOption) (*FriendResponseMsg, error)
+	AcceptFriendRequest(ctx context.Context, in *FriendRequestMsg, opts ...grpc.CallOption) (*FriendResponseMsg, error)
+	RemoveFriend(ctx context.Context, in *FriendRequestMsg, opts ...grpc.CallOption) (*FriendResponseMsg, error)
+	ListFriends(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*FriendList, error)
+	ListFriendsDreams(ctx context.Context, in *FriendsDreamsRequest, opts ...grpc.CallOption) (*FriendsDreamsResponse, error)
 }
 
 type dreamJournalClient struct {
@@ -43,6 +81,46 @@ func NewDreamJournalClient(cc grpc.ClientConnInterface) DreamJournalClient {
 	return &dreamJournalClient{cc}
 }
 
+func (c *dreamJournalClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, DreamJournal_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, DreamJournal_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*Session, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Session)
+	err := c.cc.Invoke(ctx, DreamJournal_RefreshToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) Logout(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*LogoutResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LogoutResponse)
+	err := c.cc.Invoke(ctx, DreamJournal_Logout_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *dreamJournalClient) CreateDream(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*DreamResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(DreamResponse)
@@ -72,16 +150,140 @@ func (c *dreamJournalClient) ListDreams(ctx context.Context, in *ListRequest, op
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type DreamJournal_ListDreamsClient = grpc.ServerStreamingClient[Dream]
 
+func (c *dreamJournalClient) SummarizeDream(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*DreamSummary, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DreamSummary)
+	err := c.cc.Invoke(ctx, DreamJournal_SummarizeDream_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) DreamProphecy(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*ProphecyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProphecyResponse)
+	err := c.cc.Invoke(ctx, DreamJournal_DreamProphecy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) TagDream(ctx context.Context, in *DreamRequest, opts ...grpc.CallOption) (*TagResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TagResponse)
+	err := c.cc.Invoke(ctx, DreamJournal_TagDream_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) GetAIInsights(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DreamInsight], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DreamJournal_ServiceDesc.Streams[1], DreamJournal_GetAIInsights_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UserRequest, DreamInsight]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DreamJournal_GetAIInsightsClient = grpc.ServerStreamingClient[DreamInsight]
+
+func (c *dreamJournalClient) SendFriendRequest(ctx context.Context, in *FriendRequestMsg, opts ...grpc.CallOption) (*FriendResponseMsg, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FriendResponseMsg)
+	err := c.cc.Invoke(ctx, DreamJournal_SendFriendRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) AcceptFriendRequest(ctx context.Context, in *FriendRequestMsg, opts ...grpc.CallOption) (*FriendResponseMsg, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FriendResponseMsg)
+	err := c.cc.Invoke(ctx, DreamJournal_AcceptFriendRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) RemoveFriend(ctx context.Context, in *FriendRequestMsg, opts ...grpc.CallOption) (*FriendResponseMsg, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FriendResponseMsg)
+	err := c.cc.Invoke(ctx, DreamJournal_RemoveFriend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) ListFriends(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*FriendList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FriendList)
+	err := c.cc.Invoke(ctx, DreamJournal_ListFriends_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dreamJournalClient) ListFriendsDreams(ctx context.Context, in *FriendsDreamsRequest, opts ...grpc.CallOption) (*FriendsDreamsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FriendsDreamsResponse)
+	err := c.cc.Invoke(ctx, DreamJournal_ListFriendsDreams_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DreamJournalServer is the server API for DreamJournal service.
 // All implementations must embed UnimplementedDreamJournalServer
 // for forward compatibility.
 //
 // DreamJournal service definition
 type DreamJournalServer interface {
+	// Register creates a new user account
+	Register(context.Context, *RegisterRequest) (*AuthResponse, error)
+	// Login authenticates a user
+	Login(context.Context, *LoginRequest) (*AuthResponse, error)
+	// RefreshToken swaps a refresh token for a new session. Replaying a used
+	// refresh token revokes every token in its family.
+	RefreshToken(context.Context, *RefreshTokenRequest) (*Session, error)
+	// Logout revokes every refresh token in the presented token's family
+	Logout(context.Context, *RefreshTokenRequest) (*LogoutResponse, error)
 	// CreateDream creates a new dream entry
 	CreateDream(context.Context, *DreamRequest) (*DreamResponse, error)
-	// ListDreams streams dreams based on the request parameters
+	// ListDreams streams a page of dreams based on the request parameters.
+	// When there are more, the next-cursor trailer holds the cursor of the
+	// next page.
 	ListDreams(*ListRequest, grpc.ServerStreamingServer[Dream]) error
+	// SummarizeDream summarizes a dream using OpenAI
+	SummarizeDream(context.Context, *DreamRequest) (*DreamSummary, error)
+	// DreamProphecy generates a mystical prophecy from a dream
+	DreamProphecy(context.Context, *DreamRequest) (*ProphecyResponse, error)
+	// TagDream extracts tags from a dream using OpenAI
+	TagDream(context.Context, *DreamRequest) (*TagResponse, error)
+	// GetAIInsights streams AI insights for a user's dreams
+	GetAIInsights(*UserRequest, grpc.ServerStreamingServer[DreamInsight]) error
+	// Friend system
+	SendFriendRequest(context.Context, *FriendRequestMsg) (*FriendResponseMsg, error)
+	AcceptFriendRequest(context.Context, *FriendRequestMsg) (*FriendResponseMsg, error)
+	RemoveFriend(context.Context, *FriendRequestMsg) (*FriendResponseMsg, error)
+	ListFriends(context.Context, *UserRequest) (*FriendList, error)
+	ListFriendsDreams(context.Context, *FriendsDreamsRequest) (*FriendsDreamsResponse, error)
 	mustEmbedUnimplementedDreamJournalServer()
 }
 
@@ -92,12 +294,51 @@ type DreamJournalServer interface {
 // pointer dereference when methods are called.
 type UnimplementedDreamJournalServer struct{}
 
+func (UnimplementedDreamJournalServer) Register(context.Context, *RegisterRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedDreamJournalServer) Login(context.Context, *LoginRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedDreamJournalServer) RefreshToken(context.Context, *RefreshTokenRequest) (*Session, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshToken not implemented")
+}
+func (UnimplementedDreamJournalServer) Logout(context.Context, *RefreshTokenRequest) (*LogoutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
 func (UnimplementedDreamJournalServer) CreateDream(context.Context, *DreamRequest) (*DreamResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateDream not implemented")
 }
 func (UnimplementedDreamJournalServer) ListDreams(*ListRequest, grpc.ServerStreamingServer[Dream]) error {
 	return status.Errorf(codes.Unimplemented, "method ListDreams not implemented")
 }
+func (UnimplementedDreamJournalServer) SummarizeDream(context.Context, *DreamRequest) (*DreamSummary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SummarizeDream not implemented")
+}
+func (UnimplementedDreamJournalServer) DreamProphecy(context.Context, *DreamRequest) (*ProphecyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DreamProphecy not implemented")
+}
+func (UnimplementedDreamJournalServer) TagDream(context.Context, *DreamRequest) (*TagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TagDream not implemented")
+}
+func (UnimplementedDreamJournalServer) GetAIInsights(*UserRequest, grpc.ServerStreamingServer[DreamInsight]) error {
+	return status.Errorf(codes.Unimplemented, "method GetAIInsights not implemented")
+}
+func (UnimplementedDreamJournalServer) SendFriendRequest(context.Context, *FriendRequestMsg) (*FriendResponseMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendFriendRequest not implemented")
+}
+func (UnimplementedDreamJournalServer) AcceptFriendRequest(context.Context, *FriendRequestMsg) (*FriendResponseMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptFriendRequest not implemented")
+}
+func (UnimplementedDreamJournalServer) RemoveFriend(context.Context, *FriendRequestMsg) (*FriendResponseMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFriend not implemented")
+}
+func (UnimplementedDreamJournalServer) ListFriends(context.Context, *UserRequest) (*FriendList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFriends not implemented")
+}
+func (UnimplementedDreamJournalServer) ListFriendsDreams(context.Context, *FriendsDreamsRequest) (*FriendsDreamsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFriendsDreams not implemented")
+}
 func (UnimplementedDreamJournalServer) mustEmbedUnimplementedDreamJournalServer() {}
 func (UnimplementedDreamJournalServer) testEmbeddedByValue()                      {}
 
@@ -119,6 +360,78 @@ func RegisterDreamJournalServer(s grpc.ServiceRegistrar, srv DreamJournalServer)
 	s.RegisterService(&DreamJournal_ServiceDesc, srv)
 }
 
+func _DreamJournal_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_RefreshToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).RefreshToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_RefreshToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).RefreshToken(ctx, req.(*RefreshTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_Logout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).Logout(ctx, req.(*RefreshTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _DreamJournal_CreateDream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DreamRequest)
 	if err := dec(in); err != nil {
@@ -148,6 +461,161 @@ func _DreamJournal_ListDreams_Handler(srv interface{}, stream grpc.ServerStream)
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type DreamJournal_ListDreamsServer = grpc.ServerStreamingServer[Dream]
 
+func _DreamJournal_SummarizeDream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).SummarizeDream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_SummarizeDream_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).SummarizeDream(ctx, req.(*DreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_DreamProphecy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).DreamProphecy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_DreamProphecy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).DreamProphecy(ctx, req.(*DreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_TagDream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).TagDream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_TagDream_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).TagDream(ctx, req.(*DreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_GetAIInsights_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UserRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DreamJournalServer).GetAIInsights(m, &grpc.GenericServerStream[UserRequest, DreamInsight]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DreamJournal_GetAIInsightsServer = grpc.ServerStreamingServer[DreamInsight]
+
+func _DreamJournal_SendFriendRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendRequestMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).SendFriendRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_SendFriendRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).SendFriendRequest(ctx, req.(*FriendRequestMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_AcceptFriendRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendRequestMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).AcceptFriendRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_AcceptFriendRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).AcceptFriendRequest(ctx, req.(*FriendRequestMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_RemoveFriend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendRequestMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).RemoveFriend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_RemoveFriend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).RemoveFriend(ctx, req.(*FriendRequestMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_ListFriends_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).ListFriends(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_ListFriends_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).ListFriends(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DreamJournal_ListFriendsDreams_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendsDreamsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DreamJournalServer).ListFriendsDreams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DreamJournal_ListFriendsDreams_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DreamJournalServer).ListFriendsDreams(ctx, req.(*FriendsDreamsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // DreamJournal_ServiceDesc is the grpc.ServiceDesc for DreamJournal service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -155,10 +623,58 @@ var DreamJournal_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "dreamjournal.DreamJournal",
 	HandlerType: (*DreamJournalServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _DreamJournal_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _DreamJournal_Login_Handler,
+		},
+		{
+			MethodName: "RefreshToken",
+			Handler:    _DreamJournal_RefreshToken_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _DreamJournal_Logout_Handler,
+		},
 		{
 			MethodName: "CreateDream",
 			Handler:    _DreamJournal_CreateDream_Handler,
 		},
+		{
+			MethodName: "SummarizeDream",
+			Handler:    _DreamJournal_SummarizeDream_Handler,
+		},
+		{
+			MethodName: "DreamProphecy",
+			Handler:    _DreamJournal_DreamProphecy_Handler,
+		},
+		{
+			MethodName: "TagDream",
+			Handler:    _DreamJournal_TagDream_Handler,
+		},
+		{
+			MethodName: "SendFriendRequest",
+			Handler:    _DreamJournal_SendFriendRequest_Handler,
+		},
+		{
+			MethodName: "AcceptFriendRequest",
+			Handler:    _DreamJournal_AcceptFriendRequest_Handler,
+		},
+		{
+			MethodName: "RemoveFriend",
+			Handler:    _DreamJournal_RemoveFriend_Handler,
+		},
+		{
+			MethodName: "ListFriends",
+			Handler:    _DreamJournal_ListFriends_Handler,
+		},
+		{
+			MethodName: "ListFriendsDreams",
+			Handler:    _DreamJournal_ListFriendsDreams_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -166,6 +682,11 @@ var DreamJournal_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _DreamJournal_ListDreams_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetAIInsights",
+			Handler:       _DreamJournal_GetAIInsights_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "dream_journal.proto",
 }
@@ -4,6 +4,9 @@ import { ThemeProvider } from 'next-themes';
 import { Layout } from './components/Layout';
 import LoginForm from './components/auth/LoginForm';
 import RegisterForm from './components/auth/RegisterForm';
+import ForgotPasswordForm from './components/auth/ForgotPasswordForm';
+import ResetPasswordForm from './components/auth/ResetPasswordForm';
+import VerifyEmailPage from './components/auth/VerifyEmailPage';
 import DreamForm from './components/dream/DreamForm';
 import { DreamList } from './components/dreams/DreamList';
 import { PublicDreamsPage } from './components/dreams/PublicDreamsPage';
@@ -13,6 +16,9 @@ import { EditProfilePage } from './components/dreams/EditProfilePage';
 import { useAuth } from './context/AuthContext';
 import { FriendsDreamsList } from './components/dreams/FriendsDreamsList';
 import { FriendRequestsPage } from './components/dreams/FriendRequestsPage';
+import { ModerationPage } from './components/dreams/ModerationPage';
+import { NotificationsPage } from './components/dreams/NotificationsPage';
+import { FeedPage } from './components/dreams/FeedPage';
 
 const ProtectedRoute: React.FC<{ children: React.ReactNode }> = ({ children }) => {
   const { isAuthenticated } = useAuth();
@@ -48,6 +54,9 @@ const App: React.FC = () => {
                 />
               }
             />
+            <Route path="/forgot-password" element={<ForgotPasswordForm />} />
+            <Route path="/reset-password" element={<ResetPasswordForm />} />
+            <Route path="/verify-email" element={<VerifyEmailPage />} />
             <Route
               path="/"
               element={
@@ -68,6 +77,16 @@ const App: React.FC = () => {
                 </ProtectedRoute>
               }
             />
+            <Route
+              path="/feed"
+              element={
+                <ProtectedRoute>
+                  <Layout>
+                    <FeedPage />
+                  </Layout>
+                </ProtectedRoute>
+              }
+            />
             <Route
               path="/public-dreams"
               element={<PublicDreamsPage />}
@@ -98,6 +117,26 @@ const App: React.FC = () => {
                 </ProtectedRoute>
               }
             />
+            <Route
+              path="/moderation"
+              element={
+                <ProtectedRoute>
+                  <Layout>
+                    <ModerationPage />
+                  </Layout>
+                </ProtectedRoute>
+              }
+            />
+            <Route
+              path="/notifications"
+              element={
+                <ProtectedRoute>
+                  <Layout>
+                    <NotificationsPage />
+                  </Layout>
+                </ProtectedRoute>
+              }
+            />
           </Routes>
     </ThemeProvider>
   );
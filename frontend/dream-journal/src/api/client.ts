@@ -1,7 +1,28 @@
 import axios from 'axios';
+import { authHeader } from './session';
 
 const API_URL = process.env.REACT_APP_API_URL || 'http://localhost:50051';
 
+// ApiError is the body of every error response from the API
+export interface ApiError {
+  error: {
+    code: string;
+    message: string;
+    fields?: { field: string; code: string; message: string }[];
+  };
+}
+
+// errorMessage describes a failed request for the user, listing the fields
+// the server rejected, or returns fallback for other failures
+export const errorMessage = (error: unknown, fallback: string): string => {
+  const body = axios.isAxiosError(error) ? (error.response?.data as ApiError | undefined) : undefined;
+  if (!body?.error) return fallback;
+  if (body.error.fields?.length) {
+    return body.error.fields.map((f) => `${f.field} ${f.message}`).join('; ');
+  }
+  return body.error.message || fallback;
+};
+
 export interface User {
   id: string;
   email: string;
@@ -21,6 +42,17 @@ export interface AuthResponse {
   token: string;
 }
 
+// Who may open a dream: only its owner, their friends, everyone, or anyone
+// with the link (unlisted dreams stay out of listings and search)
+export type Visibility = 'private' | 'friends' | 'public' | 'unlisted';
+
+export const visibilityLabels: Record<Visibility, string> = {
+  private: 'Private',
+  friends: 'Friends',
+  public: 'Public',
+  unlisted: 'Unlisted',
+};
+
 export interface Dream {
   id: string;
   userId: string;
@@ -29,7 +61,7 @@ export interface Dream {
   profileImageURL?: string;
   title?: string;
   text: string;
-  public: boolean;
+  visibility: Visibility;
   createdAt: string;
   updatedAt: string;
   tags?: string[];
@@ -37,6 +69,133 @@ export interface Dream {
   vividness_rating?: number;
   clarity_rating?: number;
   emotional_intensity_rating?: number;
+  reactions?: ReactionCounts;
+}
+
+export type ReactionKind = 'relatable' | 'spooky' | 'beautiful';
+
+export const reactionEmoji: Record<ReactionKind, string> = {
+  relatable: '🙋',
+  spooky: '👻',
+  beautiful: '✨',
+};
+
+export type ReactionCounts = Record<ReactionKind, number>;
+
+// ReactionTarget names what a reaction is on: a dream by its public ID or
+// a comment by its ID
+export type ReactionTarget = { dreamId: string } | { commentId: number };
+
+function reactionsPath(target: ReactionTarget): string {
+  return 'dreamId' in target
+    ? `${API_URL}/api/dreams/${target.dreamId}/reactions`
+    : `${API_URL}/api/comments/${target.commentId}/reactions`;
+}
+
+export type NotificationType = 'friend_request' | 'friend_accepted' | 'comment' | 'reply';
+
+export const notificationLabels: Record<NotificationType, string> = {
+  friend_request: 'Friend requests',
+  friend_accepted: 'Accepted friend requests',
+  comment: 'Comments on my dreams',
+  reply: 'Replies to my comments',
+};
+
+export interface Notification {
+  id: number;
+  type: NotificationType;
+  actor: { id: string; username: string; display_name?: string; profile_image_url?: string };
+  dreamId?: string;
+  commentId?: number;
+  read: boolean;
+  createdAt: string;
+}
+
+// eventsUrl is the URL of the signed-in user's event stream, carrying the
+// access token since EventSource cannot send headers, or null when signed
+// out. Pass dreamId to include new comments on that dream.
+export function eventsUrl(dreamId?: string): string | null {
+  const token = localStorage.getItem('token');
+  if (!token) return null;
+  const params = new URLSearchParams({ access_token: token });
+  if (dreamId) params.set('dream', dreamId);
+  return `${API_URL}/api/events?${params}`;
+}
+
+// List endpoints return pages ordered by (createdAt, id); pass next_cursor
+// back as cursor to fetch the following page. It is null on the last page.
+export interface PageParams {
+  limit?: number;
+  cursor?: string;
+}
+
+export interface DreamPage {
+  dreams: Dream[];
+  next_cursor: string | null;
+}
+
+// The home feed ranks dreams by recency, activity and shared tags instead of
+// by date; sources says where each dream came from
+export type FeedSource = 'own' | 'friends' | 'popular';
+
+export interface FeedItem {
+  dream: Dream;
+  score: number;
+  sources: FeedSource[];
+  comments: number;
+}
+
+export interface FeedPage {
+  items: FeedItem[];
+  next_cursor: string | null;
+}
+
+const MAX_PAGE_LIMIT = 100;
+
+// Follows next_cursor until the last page and concatenates the results
+async function fetchAllPages<T>(
+  fetchPage: (cursor?: string) => Promise<{ items: T[]; next_cursor: string | null }>
+): Promise<T[]> {
+  const items: T[] = [];
+  let cursor: string | undefined;
+  do {
+    const page = await fetchPage(cursor);
+    items.push(...page.items);
+    cursor = page.next_cursor ?? undefined;
+  } while (cursor);
+  return items;
+}
+
+export interface SearchParams {
+  q: string;
+  scope?: 'all' | 'mine' | 'friends' | 'public';
+  tag?: string[];
+  from?: string;
+  to?: string;
+  nightmare_min?: number;
+  nightmare_max?: number;
+  vividness_min?: number;
+  vividness_max?: number;
+  clarity_min?: number;
+  clarity_max?: number;
+  emotional_intensity_min?: number;
+  emotional_intensity_max?: number;
+  limit?: number;
+  offset?: number;
+}
+
+export interface SearchResult {
+  dream: Dream;
+  rank: number;
+  titleHighlight: string; // HTML-escaped, matches wrapped in <mark>
+  snippet: string;
+}
+
+export interface SearchResponse {
+  results: SearchResult[];
+  total: number;
+  limit: number;
+  offset: number;
 }
 
 export interface Tag {
@@ -45,24 +204,74 @@ export interface Tag {
   count?: number;
 }
 
+export interface PeriodCount {
+  period: string;
+  count: number;
+}
+
+export interface RatingStats {
+  average: number | null;
+  count: number;
+  histogram: number[];
+}
+
 export interface Stats {
   totalDreams: number;
   publicDreams: number;
   privateDreams: number;
   mostCommonTags: Tag[];
   dreamFrequency: number[];
+  dreamsPerDay: PeriodCount[];
+  dreamsPerWeek: PeriodCount[];
+  dreamsPerMonth: PeriodCount[];
+  ratings: Record<'nightmare' | 'vividness' | 'clarity' | 'emotional_intensity', RatingStats>;
+  streak: {
+    current: number;
+    longest: number;
+    lastDreamOn?: string;
+  };
 }
 
 export interface CreateDreamRequest {
   title: string;
   text: string;
-  public: boolean;
+  visibility: Visibility;
   nightmare_rating?: number;
   vividness_rating?: number;
   clarity_rating?: number;
   emotional_intensity_rating?: number;
 }
 
+export interface Job {
+  id: number;
+  dreamId: string;
+  kind: 'tags' | 'summary' | 'prophecy';
+  status: 'pending' | 'running' | 'done' | 'dead';
+  attempts: number;
+  maxAttempts: number;
+  lastError?: string;
+}
+
+const JOB_POLL_INTERVAL_MS = 1500;
+const JOB_POLL_ATTEMPTS = 80;
+
+// Polls a background AI job until it finishes; rejects if it dead-letters
+async function waitForJob(jobId: number): Promise<Job> {
+  for (let i = 0; i < JOB_POLL_ATTEMPTS; i++) {
+    const response = await axios.get<Job>(`${API_URL}/api/jobs/${jobId}`, {
+      headers: {
+        ...authHeader(),
+      },
+    });
+    if (response.data.status === 'done') return response.data;
+    if (response.data.status === 'dead') {
+      throw new Error(response.data.lastError || 'AI job failed');
+    }
+    await new Promise((resolve) => setTimeout(resolve, JOB_POLL_INTERVAL_MS));
+  }
+  throw new Error('Timed out waiting for AI job');
+}
+
 const client = {
   // Auth
   async login(email: string, password: string): Promise<AuthResponse> {
@@ -75,20 +284,66 @@ const client = {
     return response.data;
   },
 
+  async forgotPassword(email: string): Promise<void> {
+    await axios.post(`${API_URL}/api/password/forgot`, { email });
+  },
+
+  async resetPassword(token: string, password: string): Promise<void> {
+    await axios.post(`${API_URL}/api/password/reset`, { token, password });
+  },
+
+  async verifyEmail(token: string): Promise<void> {
+    await axios.post(`${API_URL}/api/email/verify`, { token });
+  },
+
+  async resendVerification(): Promise<void> {
+    await axios.post(`${API_URL}/api/email/verify/resend`, {}, { headers: authHeader() });
+  },
+
   async me(): Promise<User> {
     const response = await axios.get<User>(`${API_URL}/api/me`, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
     return response.data;
   },
 
   // Dreams
+  async listDreamsPage(params: PageParams & { userId?: string; public?: boolean } = {}): Promise<DreamPage> {
+    const response = await axios.get<DreamPage>(`${API_URL}/api/dreams`, {
+      params,
+      headers: {
+        ...authHeader(),
+      },
+    });
+    return response.data;
+  },
+
+  async getFeed(page: PageParams = {}): Promise<FeedPage> {
+    const response = await axios.get<FeedPage>(`${API_URL}/api/feed`, {
+      params: page,
+      headers: {
+        ...authHeader(),
+      },
+    });
+    return response.data;
+  },
+
   async getDreams(): Promise<Dream[]> {
-    const response = await axios.get(`${API_URL}/api/dreams`, {
+    return fetchAllPages(async (cursor) => {
+      const page = await client.listDreamsPage({ limit: MAX_PAGE_LIMIT, cursor });
+      return { items: page.dreams, next_cursor: page.next_cursor };
+    });
+  },
+
+  async searchDreams(params: SearchParams): Promise<SearchResponse> {
+    const response = await axios.get<SearchResponse>(`${API_URL}/api/dreams/search`, {
+      params,
+      // Repeat array params as tag=a&tag=b
+      paramsSerializer: { indexes: null },
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
     return response.data;
@@ -97,7 +352,7 @@ const client = {
   async getDream(id: string): Promise<Dream> {
     const response = await axios.get(`${API_URL}/api/dreams/${id}`, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
     return response.data;
@@ -106,7 +361,25 @@ const client = {
   async createDream(dream: CreateDreamRequest): Promise<Dream> {
     const response = await axios.post(`${API_URL}/api/dreams`, dream, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
+      },
+    });
+    return response.data;
+  },
+
+  async updateDream(id: string, changes: Partial<CreateDreamRequest>): Promise<Dream> {
+    const response = await axios.patch(`${API_URL}/api/dreams/${id}`, changes, {
+      headers: {
+        ...authHeader(),
+      },
+    });
+    return response.data;
+  },
+
+  async getJob(id: number): Promise<Job> {
+    const response = await axios.get<Job>(`${API_URL}/api/jobs/${id}`, {
+      headers: {
+        ...authHeader(),
       },
     });
     return response.data;
@@ -115,7 +388,7 @@ const client = {
   async deleteDream(id: string): Promise<void> {
     await axios.delete(`${API_URL}/api/dreams/${id}`, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
   },
@@ -124,7 +397,7 @@ const client = {
   async getStats(userId: string): Promise<Stats> {
     const response = await axios.get<Stats>(`${API_URL}/api/users/${userId}/stats`, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
     return response.data;
@@ -134,125 +407,284 @@ const client = {
   async getTags(userId: string): Promise<Tag[]> {
     const response = await axios.get<Tag[]>(`${API_URL}/api/users/${userId}/tags`, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
     return response.data;
   },
 
   async summarizeDream(id: string): Promise<string> {
-    const response = await axios.post(`${API_URL}/api/dreams/summary`, { id }, {
+    const request = () => axios.post(`${API_URL}/api/dreams/summary`, { id }, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
+    let response = await request();
+    // 202 means the summary is being generated in the background
+    if (response.status === 202) {
+      await waitForJob(response.data.job.id);
+      response = await request();
+    }
     return response.data.summary;
   },
 
   async generateProphecy(id: string): Promise<string> {
-    const response = await axios.post(`${API_URL}/api/dreams/prophecy`, { id }, {
+    const request = () => axios.post(`${API_URL}/api/dreams/prophecy`, { id }, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
+    let response = await request();
+    // 202 means the prophecy is being generated in the background
+    if (response.status === 202) {
+      await waitForJob(response.data.job.id);
+      response = await request();
+    }
     return response.data.prophecy;
   },
 
   async getDreamTags(id: string): Promise<string[]> {
     const response = await axios.get(`${API_URL}/api/dreams/${id}/tags`, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
     return response.data.tags;
   },
 
-  async getPublicProfile(username: string): Promise<{ user: User; dreams: any[] }> {
-    const response = await axios.get(`${API_URL}/api/users/${username}/public`);
+  async getPublicProfile(username: string, page: PageParams = {}): Promise<{ user: User; dreams: any[]; next_cursor: string | null }> {
+    const response = await axios.get(`${API_URL}/api/users/${username}/public`, { params: page });
     return response.data;
   },
 
   async getOwnProfile(): Promise<User> {
     const response = await axios.get(`${API_URL}/api/users/me/profile`, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
     });
     return response.data;
   },
 
   async updateOwnProfile(profile: { displayName: string; description: string; profileImageURL: string }): Promise<void> {
     await axios.put(`${API_URL}/api/users/me/profile`, profile, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
     });
   },
 
+  // Old usernames keep redirecting to the new one
+  async renameUsername(username: string): Promise<User> {
+    const response = await axios.put(`${API_URL}/api/users/me/username`, { username }, {
+      headers: authHeader(),
+    });
+    return response.data;
+  },
+
   // Friend system
   async sendFriendRequest(userId: string, friendId: string): Promise<{ status: string }> {
     const response = await axios.post(`${API_URL}/api/friends/request`, { user_id: userId, friend_id: friendId }, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
     });
     return response.data;
   },
   async acceptFriendRequest(userId: string, friendId: string): Promise<{ status: string }> {
     const response = await axios.post(`${API_URL}/api/friends/accept`, { user_id: userId, friend_id: friendId }, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
+    });
+    return response.data;
+  },
+  // Only the recipient (friendId) may reject; the sender keeps seeing it pending
+  async rejectFriendRequest(userId: string, friendId: string): Promise<{ status: string }> {
+    const response = await axios.post(`${API_URL}/api/friends/reject`, { user_id: userId, friend_id: friendId }, {
+      headers: authHeader(),
+    });
+    return response.data;
+  },
+  async cancelFriendRequest(userId: string, friendId: string): Promise<{ status: string }> {
+    const response = await axios.post(`${API_URL}/api/friends/cancel`, { user_id: userId, friend_id: friendId }, {
+      headers: authHeader(),
     });
     return response.data;
   },
   async removeFriend(userId: string, friendId: string): Promise<{ status: string }> {
     const response = await axios.post(`${API_URL}/api/friends/remove`, { user_id: userId, friend_id: friendId }, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
     });
     return response.data;
   },
   async listFriends(userId: string): Promise<{ friends: any[] }> {
     const response = await axios.get(`${API_URL}/api/friends`, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
       params: { user_id: userId },
     });
     return response.data;
   },
-  async listFriendsDreams(userId: string): Promise<{ dreams: any[] }> {
+  async listFriendsDreams(userId: string, page: PageParams = {}): Promise<{ dreams: any[]; next_cursor: string | null }> {
     const response = await axios.get(`${API_URL}/api/friends/dreams`, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
-      params: { user_id: userId },
+      headers: authHeader(),
+      params: { user_id: userId, ...page },
     });
     return response.data;
   },
   async listPendingFriendRequests(userId: string): Promise<{ requests: any[] }> {
     const response = await axios.get(`${API_URL}/api/friends`, {
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
       params: { pending_for: userId },
     });
     return response.data;
   },
+  async listFriendRequests(direction: 'incoming' | 'outgoing'): Promise<{ requests: any[] }> {
+    const response = await axios.get(`${API_URL}/api/friends/requests`, {
+      headers: authHeader(),
+      params: { direction },
+    });
+    return response.data;
+  },
+  // Blocking ends any friendship and hides both users from each other
+  async blockUser(userId: string, blockedId: string): Promise<{ status: string }> {
+    const response = await axios.post(`${API_URL}/api/friends/block`, { user_id: userId, friend_id: blockedId }, {
+      headers: authHeader(),
+    });
+    return response.data;
+  },
+  async unblockUser(userId: string, blockedId: string): Promise<{ status: string }> {
+    const response = await axios.post(`${API_URL}/api/friends/unblock`, { user_id: userId, friend_id: blockedId }, {
+      headers: authHeader(),
+    });
+    return response.data;
+  },
+  async listBlockedUsers(): Promise<{ blocked: any[] }> {
+    const response = await axios.get(`${API_URL}/api/friends/blocked`, {
+      headers: authHeader(),
+    });
+    return response.data;
+  },
 
   // Comments
   async getComments(dreamId: string): Promise<{ comments: any[] }> {
-    const response = await axios.get(`${API_URL}/api/dreams/${dreamId}/comments`, {
-      withCredentials: true,
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+    const comments = await fetchAllPages(async (cursor) => {
+      const response = await axios.get(`${API_URL}/api/dreams/${dreamId}/comments`, {
+        withCredentials: true,
+        headers: authHeader(),
+        params: { limit: MAX_PAGE_LIMIT, cursor },
+      });
+      return { items: response.data.comments, next_cursor: response.data.next_cursor };
     });
-    return response.data;
+    return { comments };
   },
-  async addComment(dreamId: string, text: string): Promise<any> {
+  // parentId makes the comment a reply to another comment on the dream
+  async addComment(dreamId: string, text: string, parentId?: number): Promise<any> {
     const response = await axios.post(
       `${API_URL}/api/dreams/${dreamId}/comments`,
+      { text, parent_id: parentId },
+      { withCredentials: true, headers: authHeader() }
+    );
+    return response.data;
+  },
+  async editComment(commentId: number, text: string): Promise<any> {
+    const response = await axios.patch(
+      `${API_URL}/api/comments/${commentId}`,
       { text },
-      { withCredentials: true, headers: { Authorization: `Bearer ${localStorage.getItem('token')}` } }
+      { withCredentials: true, headers: authHeader() }
     );
     return response.data;
   },
   async deleteComment(commentId: number): Promise<void> {
     await axios.delete(`${API_URL}/api/comments/${commentId}`, {
       withCredentials: true,
-      headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+      headers: authHeader(),
+    });
+  },
+  async reportComment(commentId: number, reason: string): Promise<void> {
+    await axios.post(
+      `${API_URL}/api/comments/${commentId}/reports`,
+      { reason },
+      { withCredentials: true, headers: authHeader() }
+    );
+  },
+
+  // Reactions. Adding one twice or removing a missing one is harmless;
+  // both answer with the target's new counts.
+  async addReaction(target: ReactionTarget, kind: ReactionKind): Promise<ReactionCounts> {
+    const response = await axios.put(`${reactionsPath(target)}/${kind}`, null, {
+      headers: authHeader(),
+    });
+    return response.data.reactions;
+  },
+  async removeReaction(target: ReactionTarget, kind: ReactionKind): Promise<ReactionCounts> {
+    const response = await axios.delete(`${reactionsPath(target)}/${kind}`, {
+      headers: authHeader(),
+    });
+    return response.data.reactions;
+  },
+  // listReactions pages through who reacted, newest first
+  async listReactions(
+    target: ReactionTarget,
+    params: PageParams & { kind?: ReactionKind } = {}
+  ): Promise<{ reactions: any[]; next_cursor: string | null }> {
+    const response = await axios.get(reactionsPath(target), {
+      headers: authHeader(),
+      params,
+    });
+    return response.data;
+  },
+
+  // Notifications for the signed-in user, newest first
+  async listNotifications(
+    params: PageParams & { unread?: boolean } = {}
+  ): Promise<{ notifications: Notification[]; next_cursor: string | null }> {
+    const response = await axios.get(`${API_URL}/api/notifications`, {
+      headers: authHeader(),
+      params,
+    });
+    return response.data;
+  },
+  async unreadNotifications(): Promise<{ total: number; by_type: Record<NotificationType, number> }> {
+    const response = await axios.get(`${API_URL}/api/notifications/unread`, {
+      headers: authHeader(),
+    });
+    return response.data;
+  },
+  // markNotificationsRead marks the given notifications read, or all of
+  // them when ids is left out, and returns how many were unread
+  async markNotificationsRead(ids?: number[]): Promise<number> {
+    const response = await axios.post(`${API_URL}/api/notifications/read`, ids ? { ids } : { all: true }, {
+      headers: authHeader(),
+    });
+    return response.data.marked;
+  },
+  async getNotificationPrefs(): Promise<Record<NotificationType, boolean>> {
+    const response = await axios.get(`${API_URL}/api/notifications/preferences`, {
+      headers: authHeader(),
+    });
+    return response.data.preferences;
+  },
+  async updateNotificationPrefs(
+    preferences: Partial<Record<NotificationType, boolean>>
+  ): Promise<Record<NotificationType, boolean>> {
+    const response = await axios.put(`${API_URL}/api/notifications/preferences`, { preferences }, {
+      headers: authHeader(),
+    });
+    return response.data.preferences;
+  },
+
+  // Moderation (admins only)
+  async listReports(params: PageParams = {}): Promise<{ reports: any[]; next_cursor: string | null }> {
+    const response = await axios.get(`${API_URL}/api/admin/reports`, {
+      headers: authHeader(),
+      params,
+    });
+    return response.data;
+  },
+  // dismiss keeps the comment; remove deletes it with its replies
+  async resolveReport(reportId: number, action: 'dismiss' | 'remove'): Promise<void> {
+    await axios.post(`${API_URL}/api/admin/reports/${reportId}/resolve`, { action }, {
+      headers: authHeader(),
     });
   },
 
   async updateDreamTags(id: string, tags: string[]): Promise<void> {
     await axios.put(`${API_URL}/api/dreams/${id}/tags`, { tags }, {
       headers: {
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
     });
   },
@@ -0,0 +1,75 @@
+import axios, { AxiosError, InternalAxiosRequestConfig } from 'axios';
+
+const API_URL = process.env.REACT_APP_API_URL || 'http://localhost:50051';
+
+// Access tokens are short-lived. When a request fails with 401 the refresh
+// token is swapped for a new pair once and the request is retried.
+// Concurrent failures share a single refresh call, since each refresh token
+// can only be used once.
+
+let refreshing: Promise<string | null> | null = null;
+
+export function storeSession(token: string, refreshToken?: string) {
+  localStorage.setItem('token', token);
+  if (refreshToken) {
+    localStorage.setItem('refreshToken', refreshToken);
+  }
+  axios.defaults.headers.common['Authorization'] = `Bearer ${token}`;
+}
+
+// authHeader returns the bearer header for the stored access token. Nothing
+// is sent when logged out, since the API rejects a malformed token outright.
+export function authHeader(): { Authorization?: string } {
+  const token = localStorage.getItem('token');
+  return token ? { Authorization: `Bearer ${token}` } : {};
+}
+
+export function clearSession() {
+  localStorage.removeItem('user');
+  localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
+  delete axios.defaults.headers.common['Authorization'];
+}
+
+async function refreshSession(): Promise<string | null> {
+  const refreshToken = localStorage.getItem('refreshToken');
+  if (!refreshToken) return null;
+  try {
+    const response = await axios.post(`${API_URL}/api/token/refresh`, { refreshToken });
+    storeSession(response.data.token, response.data.refreshToken);
+    return response.data.token;
+  } catch {
+    clearSession();
+    return null;
+  }
+}
+
+// Revokes the refresh token on the server; failures are ignored since the
+// local session is cleared either way
+export async function endSession() {
+  const refreshToken = localStorage.getItem('refreshToken');
+  clearSession();
+  if (refreshToken) {
+    await axios.post(`${API_URL}/api/logout`, { refreshToken }).catch(() => undefined);
+  }
+}
+
+axios.interceptors.response.use(undefined, async (error: AxiosError) => {
+  const config = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined;
+  const url = config?.url || '';
+  if (
+    error.response?.status !== 401 ||
+    !config ||
+    config._retried ||
+    url.endsWith('/api/token/refresh') ||
+    url.endsWith('/api/login')
+  ) {
+    throw error;
+  }
+  refreshing = refreshing || refreshSession().finally(() => { refreshing = null; });
+  const token = await refreshing;
+  if (!token) throw error;
+  config._retried = true;
+  config.headers.Authorization = `Bearer ${token}`;
+  return axios(config);
+});
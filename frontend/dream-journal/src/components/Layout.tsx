@@ -4,9 +4,22 @@ import * as React from "react"
 import { ThemeProvider } from "./theme-provider"
 import { ThemeToggle } from "./theme-toggle"
 import { useAuth } from '../context/AuthContext'
+import client from '../api/client'
+import { useEvents } from '../hooks/useEvents'
 
 export function Layout({ children }: { children: React.ReactNode }) {
   const { user } = useAuth();
+  const [unread, setUnread] = React.useState(0);
+
+  React.useEffect(() => {
+    if (!user) return;
+    client.unreadNotifications().then((counts) => setUnread(counts.total)).catch(() => {});
+  }, [user]);
+
+  useEvents((type) => {
+    if (type === 'notification') setUnread((n) => n + 1);
+  }, undefined, !!user);
+
   return (
     <ThemeProvider
       attribute="class"
@@ -24,6 +37,14 @@ export function Layout({ children }: { children: React.ReactNode }) {
             </div>
             <div className="flex flex-1 items-center justify-between space-x-2 md:justify-end">
               <nav className="flex items-center">
+                {user && (
+                  <a
+                    href="/feed"
+                    className="mr-2 px-3 py-1 rounded border border-input bg-background text-sm font-medium hover:bg-accent hover:text-accent-foreground transition-colors"
+                  >
+                    Feed
+                  </a>
+                )}
                 <a
                   href="/public-dreams"
                   className="mr-2 px-3 py-1 rounded border border-input bg-background text-sm font-medium hover:bg-accent hover:text-accent-foreground transition-colors"
@@ -42,6 +63,25 @@ export function Layout({ children }: { children: React.ReactNode }) {
                 >
                   Friend Requests
                 </a>
+                {user && (
+                  <a
+                    href="/notifications"
+                    className="mr-2 px-3 py-1 rounded border border-input bg-background text-sm font-medium hover:bg-accent hover:text-accent-foreground transition-colors"
+                  >
+                    Notifications
+                    {unread > 0 && (
+                      <span className="ml-1 rounded-full bg-primary px-1.5 text-xs text-white">{unread}</span>
+                    )}
+                  </a>
+                )}
+                {user?.isAdmin && (
+                  <a
+                    href="/moderation"
+                    className="mr-2 px-3 py-1 rounded border border-input bg-background text-sm font-medium hover:bg-accent hover:text-accent-foreground transition-colors"
+                  >
+                    Moderation
+                  </a>
+                )}
                 <ThemeToggle />
                 {user && (
                   <a
@@ -0,0 +1,91 @@
+import React, { useState } from 'react';
+import { useFormik } from 'formik';
+import * as Yup from 'yup';
+import { motion } from 'framer-motion';
+import { Link } from 'react-router-dom';
+import { Card, CardHeader, CardTitle, CardContent, CardFooter } from '../ui/card';
+import { Button } from '../ui/button';
+import { Input } from '../ui/input';
+import client from '../../api/client';
+
+const ForgotPasswordForm: React.FC = () => {
+  const [sent, setSent] = useState(false);
+  const formik = useFormik({
+    initialValues: { email: '' },
+    validationSchema: Yup.object({
+      email: Yup.string().email('Invalid email address').required('Required'),
+    }),
+    onSubmit: async (values, { setStatus, setSubmitting }) => {
+      setStatus(undefined);
+      try {
+        await client.forgotPassword(values.email);
+        setSent(true);
+      } catch (error) {
+        setStatus('Something went wrong. Please try again.');
+      } finally {
+        setSubmitting(false);
+      }
+    },
+  });
+
+  return (
+    <motion.div
+      initial={{ opacity: 0, y: 20 }}
+      animate={{ opacity: 1, y: 0 }}
+      exit={{ opacity: 0, y: -20 }}
+      className="w-full max-w-md mx-auto mt-8"
+    >
+      <Card>
+        <form onSubmit={formik.handleSubmit}>
+          <CardHeader>
+            <CardTitle className="text-2xl text-center">Forgot Password</CardTitle>
+          </CardHeader>
+          <CardContent className="space-y-4">
+            {sent ? (
+              <p className="text-sm text-center">
+                If an account exists for {formik.values.email}, we've sent a link to reset its password.
+              </p>
+            ) : (
+              <>
+                {formik.status && (
+                  <div className="text-sm text-destructive text-center mb-2">{formik.status}</div>
+                )}
+                <div className="space-y-2">
+                  <label htmlFor="email" className="text-sm font-medium">
+                    Email
+                  </label>
+                  <Input
+                    id="email"
+                    name="email"
+                    type="email"
+                    onChange={formik.handleChange}
+                    onBlur={formik.handleBlur}
+                    value={formik.values.email}
+                    className={formik.touched.email && formik.errors.email ? 'border-destructive' : ''}
+                  />
+                  {formik.touched.email && formik.errors.email && (
+                    <div className="text-sm text-destructive">{formik.errors.email}</div>
+                  )}
+                </div>
+              </>
+            )}
+          </CardContent>
+          <CardFooter className="flex flex-col gap-4">
+            {!sent && (
+              <Button type="submit" className="w-full" disabled={formik.isSubmitting}>
+                {formik.isSubmitting ? 'Sending...' : 'Send Reset Link'}
+              </Button>
+            )}
+            <div className="text-center text-sm">
+              <Link to="/login" className="text-primary hover:underline">
+                Back to Sign In
+              </Link>
+            </div>
+          </CardFooter>
+        </form>
+      </Card>
+    </motion.div>
+  );
+};
+
+export default ForgotPasswordForm;
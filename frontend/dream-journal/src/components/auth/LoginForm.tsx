@@ -1,4 +1,5 @@
 import React from 'react';
+import axios from 'axios';
 import { useFormik } from 'formik';
 import * as Yup from 'yup';
 import { motion } from 'framer-motion';
@@ -27,7 +28,14 @@ const LoginForm: React.FC<LoginFormProps> = ({ onSubmit, onRegisterClick }) => {
       try {
         await onSubmit(values);
       } catch (error) {
-        setStatus('Invalid email or password');
+        const retryAfter = axios.isAxiosError(error) && error.response?.status === 429
+          ? error.response.headers['retry-after']
+          : undefined;
+        setStatus(
+          retryAfter
+            ? `Too many attempts. Please try again in ${retryAfter} seconds.`
+            : 'Invalid email or password'
+        );
       } finally {
         setSubmitting(false);
       }
@@ -84,6 +92,11 @@ const LoginForm: React.FC<LoginFormProps> = ({ onSubmit, onRegisterClick }) => {
               {formik.touched.password && formik.errors.password && (
                 <div className="text-sm text-destructive">{formik.errors.password}</div>
               )}
+              <div className="text-right text-sm">
+                <Link to="/forgot-password" className="text-primary hover:underline">
+                  Forgot password?
+                </Link>
+              </div>
             </div>
           </CardContent>
           <CardFooter className="flex flex-col gap-4">
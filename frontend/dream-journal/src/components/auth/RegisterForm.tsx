@@ -21,7 +21,9 @@ const RegisterForm: React.FC<RegisterFormProps> = ({ onSubmit, onLoginClick }) =
       confirmPassword: '',
     },
     validationSchema: Yup.object({
-      username: Yup.string().required('Required'),
+      username: Yup.string()
+        .matches(/^[A-Za-z0-9_]{3,30}$/, 'Use 3-30 letters, digits or underscores')
+        .required('Required'),
       email: Yup.string().email('Invalid email address').required('Required'),
       password: Yup.string()
         .min(8, 'Password must be at least 8 characters')
@@ -0,0 +1,96 @@
+import React, { useState } from 'react';
+import { useFormik } from 'formik';
+import * as Yup from 'yup';
+import { motion } from 'framer-motion';
+import { Link, useSearchParams } from 'react-router-dom';
+import { Card, CardHeader, CardTitle, CardContent, CardFooter } from '../ui/card';
+import { Button } from '../ui/button';
+import { Input } from '../ui/input';
+import client, { errorMessage } from '../../api/client';
+
+const ResetPasswordForm: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [done, setDone] = useState(false);
+  const formik = useFormik({
+    initialValues: { password: '', confirmPassword: '' },
+    validationSchema: Yup.object({
+      password: Yup.string().min(8, 'Password must be at least 8 characters').required('Required'),
+      confirmPassword: Yup.string()
+        .oneOf([Yup.ref('password')], 'Passwords must match')
+        .required('Required'),
+    }),
+    onSubmit: async (values, { setStatus, setSubmitting }) => {
+      setStatus(undefined);
+      try {
+        await client.resetPassword(token, values.password);
+        setDone(true);
+      } catch (error) {
+        setStatus(errorMessage(error, 'This link is invalid or has expired. Please request a new one.'));
+      } finally {
+        setSubmitting(false);
+      }
+    },
+  });
+
+  return (
+    <motion.div
+      initial={{ opacity: 0, y: 20 }}
+      animate={{ opacity: 1, y: 0 }}
+      exit={{ opacity: 0, y: -20 }}
+      className="w-full max-w-md mx-auto mt-8"
+    >
+      <Card>
+        <form onSubmit={formik.handleSubmit}>
+          <CardHeader>
+            <CardTitle className="text-2xl text-center">Choose a New Password</CardTitle>
+          </CardHeader>
+          <CardContent className="space-y-4">
+            {done ? (
+              <p className="text-sm text-center">Your password has been changed. Please sign in again.</p>
+            ) : (
+              <>
+                {formik.status && (
+                  <div className="text-sm text-destructive text-center mb-2">{formik.status}</div>
+                )}
+                {(['password', 'confirmPassword'] as const).map((field) => (
+                  <div className="space-y-2" key={field}>
+                    <label htmlFor={field} className="text-sm font-medium">
+                      {field === 'password' ? 'New Password' : 'Confirm Password'}
+                    </label>
+                    <Input
+                      id={field}
+                      name={field}
+                      type="password"
+                      onChange={formik.handleChange}
+                      onBlur={formik.handleBlur}
+                      value={formik.values[field]}
+                      className={formik.touched[field] && formik.errors[field] ? 'border-destructive' : ''}
+                    />
+                    {formik.touched[field] && formik.errors[field] && (
+                      <div className="text-sm text-destructive">{formik.errors[field]}</div>
+                    )}
+                  </div>
+                ))}
+              </>
+            )}
+          </CardContent>
+          <CardFooter className="flex flex-col gap-4">
+            {!done && (
+              <Button type="submit" className="w-full" disabled={formik.isSubmitting || !token}>
+                {formik.isSubmitting ? 'Saving...' : 'Reset Password'}
+              </Button>
+            )}
+            <div className="text-center text-sm">
+              <Link to="/login" className="text-primary hover:underline">
+                Back to Sign In
+              </Link>
+            </div>
+          </CardFooter>
+        </form>
+      </Card>
+    </motion.div>
+  );
+};
+
+export default ResetPasswordForm;
@@ -0,0 +1,45 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { Card, CardHeader, CardTitle, CardContent, CardFooter } from '../ui/card';
+import client from '../../api/client';
+
+const VerifyEmailPage: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [status, setStatus] = useState<'verifying' | 'verified' | 'failed'>('verifying');
+  // Tokens are single use, so StrictMode's double effect must not send it twice
+  const sent = useRef(false);
+
+  useEffect(() => {
+    if (sent.current) return;
+    sent.current = true;
+    client
+      .verifyEmail(token)
+      .then(() => setStatus('verified'))
+      .catch(() => setStatus('failed'));
+  }, [token]);
+
+  return (
+    <div className="w-full max-w-md mx-auto mt-8">
+      <Card>
+        <CardHeader>
+          <CardTitle className="text-2xl text-center">Verify Email</CardTitle>
+        </CardHeader>
+        <CardContent>
+          <p className="text-sm text-center">
+            {status === 'verifying' && 'Verifying your email address...'}
+            {status === 'verified' && 'Thanks! Your email address is verified.'}
+            {status === 'failed' && 'This link is invalid or has expired. Sign in to request a new one.'}
+          </p>
+        </CardContent>
+        <CardFooter className="justify-center text-sm">
+          <Link to="/" className="text-primary hover:underline">
+            Continue to SleepTalk
+          </Link>
+        </CardFooter>
+      </Card>
+    </div>
+  );
+};
+
+export default VerifyEmailPage;
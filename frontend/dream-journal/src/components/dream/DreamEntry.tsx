@@ -14,7 +14,7 @@ const DreamEntry: React.FC<DreamEntryProps> = ({
   id,
   text,
   createdAt,
-  public: isPublic,
+  visibility,
   onDelete,
 }) => {
   const formattedDate = format(new Date(createdAt), 'MMM d, yyyy h:mm a');
@@ -32,7 +32,7 @@ const DreamEntry: React.FC<DreamEntryProps> = ({
             <CardTitle className="text-lg font-semibold line-clamp-1">
               {formattedDate}
             </CardTitle>
-            {isPublic && <Badge variant="secondary">Public</Badge>}
+            {visibility !== 'private' && <Badge variant="secondary" className="capitalize">{visibility}</Badge>}
           </div>
         </CardHeader>
         <CardContent>
@@ -6,11 +6,11 @@ import { useNavigate } from 'react-router-dom';
 import { Card, CardHeader, CardTitle, CardContent, CardFooter } from '../ui/card';
 import { Button } from '../ui/button';
 import { Textarea } from '../ui/textarea';
-import { dreamService } from '../../services/dreamService';
+import { dreamService, Visibility } from '../../services/dreamService';
 import { useAuth } from '../../context/AuthContext';
 
 interface DreamFormProps {
-  onSubmit?: (values: { title: string; text: string; public: boolean }) => void;
+  onSubmit?: (values: { title: string; text: string; visibility: Visibility }) => void;
 }
 
 const DreamForm: React.FC<DreamFormProps> = ({ onSubmit }) => {
@@ -21,7 +21,7 @@ const DreamForm: React.FC<DreamFormProps> = ({ onSubmit }) => {
     initialValues: {
       title: '',
       text: '',
-      public: false,
+      visibility: 'private' as Visibility,
       nightmare_rating: 5,
       vividness_rating: 5,
       clarity_rating: 5,
@@ -30,7 +30,7 @@ const DreamForm: React.FC<DreamFormProps> = ({ onSubmit }) => {
     validationSchema: Yup.object({
       title: Yup.string().required('Title is required'),
       text: Yup.string().required('Required'),
-      public: Yup.boolean(),
+      visibility: Yup.string().oneOf(['private', 'friends', 'public', 'unlisted']),
       nightmare_rating: Yup.number().min(1).max(10).required(),
       vividness_rating: Yup.number().min(1).max(10).required(),
       clarity_rating: Yup.number().min(1).max(10).required(),
@@ -45,7 +45,7 @@ const DreamForm: React.FC<DreamFormProps> = ({ onSubmit }) => {
         await dreamService.createDream({
           title: values.title,
           text: values.text,
-          public: values.public,
+          visibility: values.visibility,
           nightmare_rating: values.nightmare_rating,
           vividness_rating: values.vividness_rating,
           clarity_rating: values.clarity_rating,
@@ -216,17 +216,21 @@ const DreamForm: React.FC<DreamFormProps> = ({ onSubmit }) => {
             </div>
 
             <div className="flex items-center space-x-2">
-              <input
-                type="checkbox"
-                id="public"
-                name="public"
-                onChange={formik.handleChange}
-                checked={formik.values.public}
-                className="h-4 w-4 rounded border-gray-300 text-primary focus:ring-primary"
-              />
-              <label htmlFor="public" className="text-sm font-medium">
-                Make this dream public
+              <label htmlFor="visibility" className="text-sm font-medium">
+                Who can see this dream
               </label>
+              <select
+                id="visibility"
+                name="visibility"
+                onChange={formik.handleChange}
+                value={formik.values.visibility}
+                className="rounded-md border border-input bg-background px-2 py-1 text-sm"
+              >
+                <option value="private">Only me</option>
+                <option value="friends">Friends</option>
+                <option value="unlisted">Anyone with the link</option>
+                <option value="public">Everyone</option>
+              </select>
             </div>
           </CardContent>
           <CardFooter className="flex justify-end gap-4 pt-4">
@@ -1,7 +1,7 @@
 import { useState } from 'react';
 import { useNavigate } from 'react-router-dom';
 import { motion } from 'framer-motion';
-import client from '../../api/client';
+import client, { Visibility } from '../../api/client';
 import { useAuth } from '../../context/AuthContext';
 import axios from 'axios';
 
@@ -10,7 +10,7 @@ export function CreateDream() {
   const { user } = useAuth();
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [title, setTitle] = useState("");
-  const [isPublic, setIsPublic] = useState(true);
+  const [visibility, setVisibility] = useState<Visibility>('public');
   const [nightmareRating, setNightmareRating] = useState(5);
   const [vividnessRating, setVividnessRating] = useState(5);
   const [clarityRating, setClarityRating] = useState(5);
@@ -43,7 +43,7 @@ export function CreateDream() {
       const dream = await client.createDream({
         title,
         text: content,
-        public: isPublic,
+        visibility,
         nightmare_rating: nightmareRating,
         vividness_rating: vividnessRating,
         clarity_rating: clarityRating,
@@ -56,7 +56,7 @@ export function CreateDream() {
         const tagResp = await axios.post(`/api/dreams/tags`, {
           title,
           text: content,
-          public: isPublic,
+          visibility,
           nightmare_rating: nightmareRating,
           vividness_rating: vividnessRating,
           clarity_rating: clarityRating,
@@ -223,14 +223,18 @@ export function CreateDream() {
             </div>
             {ratingError && <div className="text-red-500 text-sm">{ratingError}</div>}
             <div className="flex items-center gap-2">
-              <input
-                type="checkbox"
-                id="private"
-                checked={!isPublic}
-                onChange={e => setIsPublic(!e.target.checked)}
-                className="mr-2"
-              />
-              <label htmlFor="private" className="text-sm">Mark as private</label>
+              <label htmlFor="visibility" className="text-sm">Who can see this dream</label>
+              <select
+                id="visibility"
+                value={visibility}
+                onChange={e => setVisibility(e.target.value as Visibility)}
+                className="rounded-md border border-input bg-background px-2 py-1 text-sm"
+              >
+                <option value="public">Everyone</option>
+                <option value="friends">Friends</option>
+                <option value="unlisted">Anyone with the link</option>
+                <option value="private">Only me</option>
+              </select>
             </div>
             <div className="flex items-center gap-2">
               <button
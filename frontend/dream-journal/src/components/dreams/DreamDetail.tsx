@@ -1,13 +1,38 @@
 import { useEffect, useState } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
 import { motion, AnimatePresence } from 'framer-motion';
-import client, { Dream } from '../../api/client';
+import client, { Dream, errorMessage, visibilityLabels } from '../../api/client';
 import { Badge } from '../ui/badge';
 import { Card, CardHeader, CardTitle, CardContent, CardFooter } from '../ui/card';
 import { Button } from '../ui/button';
 import { format } from 'date-fns';
 import { Tag as TagIcon, Trash, MoreVertical } from 'lucide-react';
 import { useAuth } from '../../context/AuthContext';
+import { ReactionBar } from './ReactionBar';
+import { useEvents } from '../../hooks/useEvents';
+
+// threadComments orders comments so each reply follows its parent, with
+// its depth in the thread. Replies whose parent is hidden start a thread of
+// their own.
+function threadComments(comments: any[]): { comment: any; depth: number }[] {
+  const ids = new Set(comments.map((c) => c.id));
+  const replies = new Map<number, any[]>();
+  const roots: any[] = [];
+  for (const c of comments) {
+    if (c.parentId != null && ids.has(c.parentId)) {
+      replies.set(c.parentId, [...(replies.get(c.parentId) || []), c]);
+    } else {
+      roots.push(c);
+    }
+  }
+  const out: { comment: any; depth: number }[] = [];
+  const walk = (c: any, depth: number) => {
+    out.push({ comment: c, depth });
+    for (const r of replies.get(c.id) || []) walk(r, depth + 1);
+  };
+  roots.forEach((c) => walk(c, 0));
+  return out;
+}
 
 export function DreamDetail() {
   const { id } = useParams<{ id: string }>();
@@ -26,6 +51,7 @@ export function DreamDetail() {
   const [comments, setComments] = useState<any[]>([]);
   const [commentText, setCommentText] = useState('');
   const [commentLoading, setCommentLoading] = useState(false);
+  const [replyTo, setReplyTo] = useState<number | null>(null);
 
   useEffect(() => {
     const fetchDream = async () => {
@@ -55,16 +81,35 @@ export function DreamDetail() {
     fetchComments();
   }, [dream]);
 
+  // New comments by others appear as they are posted, and AI results
+  // once their job finishes
+  useEvents(
+    (type, data) => {
+      if (type === 'comment') {
+        setComments((prev) => (prev.some((c) => c.id === data.id) ? prev : [...prev, data]));
+      } else if (type === 'job' && data.dreamId === dream?.id && data.status === 'done') {
+        client.getDream(dream!.id).then(setDream).catch(() => {});
+      }
+    },
+    dream?.id,
+    !!user && !!dream
+  );
+
+  const refreshComments = async () => {
+    if (!dream) return;
+    const res = await client.getComments(dream.id);
+    setComments(res.comments || []);
+  };
+
   const handleAddComment = async (e: React.FormEvent) => {
     e.preventDefault();
     if (!commentText.trim() || !dream) return;
     setCommentLoading(true);
     try {
-      await client.addComment(dream.id, commentText);
+      await client.addComment(dream.id, commentText, replyTo ?? undefined);
       setCommentText('');
-      // Refresh comments
-      const res = await client.getComments(dream.id);
-      setComments(res.comments || []);
+      setReplyTo(null);
+      await refreshComments();
     } finally {
       setCommentLoading(false);
     }
@@ -178,7 +223,7 @@ export function DreamDetail() {
       <Card className="shadow-xl border bg-background mt-4">
         <CardHeader className="flex flex-row items-center justify-between gap-2 pb-2 relative">
           <CardTitle className="text-2xl font-bold">Dream</CardTitle>
-          {dream.public && <Badge variant="secondary">Public</Badge>}
+          <Badge variant="secondary">{visibilityLabels[dream.visibility]}</Badge>
           {canDelete && (
             <button
               className="absolute top-0 right-0 p-2 text-red-500 hover:text-red-700"
@@ -244,7 +289,8 @@ export function DreamDetail() {
             </div>
           )}
         </CardContent>
-        <CardFooter className="flex justify-end pt-4">
+        <CardFooter className="flex justify-between pt-4">
+          <ReactionBar target={{ dreamId: dream.id }} initial={dream.reactions} canReact={!!user} />
           <Button variant="outline" onClick={() => navigate(-1)}>
             Back
           </Button>
@@ -290,19 +336,38 @@ export function DreamDetail() {
           <div className="text-muted-foreground mb-4">No comments yet.</div>
         ) : (
           <div className="space-y-4 mb-4">
-            {comments.map((c) => {
+            {threadComments(comments).map(({ comment: c, depth }) => {
               const isOwnComment = user && c.user && c.user.id === user.id;
+              const canDeleteComment = isOwnComment || canDelete;
+              const closeMenu = () => {
+                const menu = document.getElementById(`comment-menu-${c.id}`);
+                if (menu) menu.style.display = 'none';
+              };
               return (
-                <div key={c.id} className="border rounded p-3 flex items-start gap-3 relative group">
+                <div key={c.id} className="border rounded p-3 flex items-start gap-3 relative group" style={{ marginLeft: `${Math.min(depth, 4) * 1.5}rem` }}>
                   <img src={c.user.profile_image_url || ''} alt={c.user.display_name || c.user.username} className="w-8 h-8 rounded-full object-cover" />
                   <div className="flex-1">
                     <div className="font-bold text-sm flex items-center gap-2">
                       {c.user.display_name || c.user.username}
                     </div>
-                    <div className="text-xs text-muted-foreground mb-1">{new Date(c.createdAt).toLocaleString()}</div>
+                    <div className="text-xs text-muted-foreground mb-1">
+                      {new Date(c.createdAt).toLocaleString()}
+                      {c.edited && <span title={`Edited ${new Date(c.updatedAt).toLocaleString()}`}> (edited)</span>}
+                    </div>
                     <div className="text-base">{c.text}</div>
+                    <div className="mt-1">
+                      <ReactionBar target={{ commentId: c.id }} initial={c.reactions} canReact={!!user} small />
+                    </div>
+                    {user && (
+                      <button
+                        className="text-xs text-muted-foreground hover:underline mt-1"
+                        onClick={() => setReplyTo(replyTo === c.id ? null : c.id)}
+                      >
+                        {replyTo === c.id ? 'Cancel reply' : 'Reply'}
+                      </button>
+                    )}
                   </div>
-                  {isOwnComment && (
+                  {user && (
                     <div className="absolute top-2 right-2">
                       <button
                         className="p-1 rounded hover:bg-gray-200 dark:hover:bg-gray-700"
@@ -320,30 +385,59 @@ export function DreamDetail() {
                         className="z-10 absolute right-0 mt-2 w-24 bg-white dark:bg-gray-800 border rounded shadow-lg hidden"
                         onMouseLeave={e => (e.currentTarget.style.display = 'none')}
                       >
-                        <button
-                          className="block w-full text-left px-4 py-2 text-sm hover:bg-gray-100 dark:hover:bg-gray-700 text-red-600"
-                          onClick={async () => {
-                            if (!window.confirm('Delete this comment?')) return;
-                            try {
-                              await client.deleteComment(c.id);
-                              // Refresh comments
-                              const res = await client.getComments(dream.id);
-                              setComments(res.comments || []);
-                            } catch {
-                              alert('Failed to delete comment.');
-                            }
-                            const menu = document.getElementById(`comment-menu-${c.id}`);
-                            if (menu) menu.style.display = 'none';
-                          }}
-                        >
-                          Delete
-                        </button>
-                        <button
-                          className="block w-full text-left px-4 py-2 text-sm hover:bg-gray-100 dark:hover:bg-gray-700"
-                          disabled
-                        >
-                          Edit (coming soon)
-                        </button>
+                        {isOwnComment && (
+                          <button
+                            className="block w-full text-left px-4 py-2 text-sm hover:bg-gray-100 dark:hover:bg-gray-700"
+                            onClick={async () => {
+                              closeMenu();
+                              const text = window.prompt('Edit comment', c.text);
+                              if (!text || !text.trim() || text === c.text) return;
+                              try {
+                                await client.editComment(c.id, text);
+                                await refreshComments();
+                              } catch (err) {
+                                alert(errorMessage(err, 'Failed to edit comment.'));
+                              }
+                            }}
+                          >
+                            Edit
+                          </button>
+                        )}
+                        {canDeleteComment && (
+                          <button
+                            className="block w-full text-left px-4 py-2 text-sm hover:bg-gray-100 dark:hover:bg-gray-700 text-red-600"
+                            onClick={async () => {
+                              closeMenu();
+                              if (!window.confirm('Delete this comment and its replies?')) return;
+                              try {
+                                await client.deleteComment(c.id);
+                                await refreshComments();
+                              } catch {
+                                alert('Failed to delete comment.');
+                              }
+                            }}
+                          >
+                            Delete
+                          </button>
+                        )}
+                        {!isOwnComment && (
+                          <button
+                            className="block w-full text-left px-4 py-2 text-sm hover:bg-gray-100 dark:hover:bg-gray-700"
+                            onClick={async () => {
+                              closeMenu();
+                              const reason = window.prompt('Why are you reporting this comment?');
+                              if (!reason || !reason.trim()) return;
+                              try {
+                                await client.reportComment(c.id, reason);
+                                alert('Thanks, a moderator will take a look.');
+                              } catch (err) {
+                                alert(errorMessage(err, 'Failed to report comment.'));
+                              }
+                            }}
+                          >
+                            Report
+                          </button>
+                        )}
                       </div>
                     </div>
                   )}
@@ -359,7 +453,7 @@ export function DreamDetail() {
               value={commentText}
               onChange={e => setCommentText(e.target.value)}
               className="flex-1 border rounded px-3 py-2 text-sm"
-              placeholder="Add a comment..."
+              placeholder={replyTo ? 'Write a reply...' : 'Add a comment...'}
               disabled={commentLoading}
             />
             <button
@@ -1,11 +1,12 @@
 import { useEffect, useState } from 'react';
-import client from '../../api/client';
+import client, { errorMessage } from '../../api/client';
 import { Avatar } from '../ui/avatar';
 import { useNavigate } from 'react-router-dom';
 import { useAuth } from '../../context/AuthContext';
 
 export function EditProfilePage() {
   const [profile, setProfile] = useState<any>(null);
+  const [username, setUsername] = useState('');
   const [loading, setLoading] = useState(true);
   const [saving, setSaving] = useState(false);
   const [success, setSuccess] = useState(false);
@@ -18,6 +19,7 @@ export function EditProfilePage() {
       try {
         const data = await client.getOwnProfile();
         setProfile(data);
+        setUsername(data.username);
       } catch {
         setError('Failed to load profile');
       } finally {
@@ -32,6 +34,10 @@ export function EditProfilePage() {
     setSaving(true);
     setError(null);
     try {
+      if (username !== profile.username) {
+        const renamed = await client.renameUsername(username);
+        setProfile({ ...profile, username: renamed.username });
+      }
       await client.updateOwnProfile({
         displayName: profile.displayName,
         description: profile.description,
@@ -39,8 +45,8 @@ export function EditProfilePage() {
       });
       setSuccess(true);
       setTimeout(() => navigate('/'), 1200);
-    } catch {
-      setError('Failed to update profile');
+    } catch (err) {
+      setError(errorMessage(err, 'Failed to update profile'));
     } finally {
       setSaving(false);
     }
@@ -62,6 +68,19 @@ export function EditProfilePage() {
         <div className="flex flex-col items-center gap-2">
           <Avatar src={profile.profileImageURL} size={64} fallback={profile.displayName?.[0]?.toUpperCase() || profile.username?.[0]?.toUpperCase()} />
         </div>
+        <div>
+          <label className="block text-sm font-medium mb-1">Username</label>
+          <input
+            type="text"
+            className="w-full rounded border px-3 py-2"
+            value={username}
+            onChange={e => setUsername(e.target.value)}
+            pattern="[A-Za-z0-9_]{3,30}"
+            title="3-30 letters, digits or underscores"
+            maxLength={30}
+          />
+          <p className="text-xs text-muted-foreground mt-1">Links to your old username will keep working.</p>
+        </div>
         <div>
           <label className="block text-sm font-medium mb-1">Display Name</label>
           <input
@@ -0,0 +1,90 @@
+import { useEffect, useState } from 'react';
+import { Link } from 'react-router-dom';
+import { format } from 'date-fns';
+import client, { FeedItem, FeedSource, errorMessage } from '../../api/client';
+import { Card } from '../ui/card';
+import { ReactionBar } from './ReactionBar';
+
+const sourceLabels: Record<FeedSource, string> = {
+  own: 'Yours',
+  friends: 'Friend',
+  popular: 'Popular',
+};
+
+// FeedPage is the ranked home feed: the user's own recent dreams, their
+// friends' dreams and popular public dreams, best first
+export function FeedPage() {
+  const [items, setItems] = useState<FeedItem[]>([]);
+  const [cursor, setCursor] = useState<string | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    async function fetchFeed() {
+      setLoading(true);
+      try {
+        const page = await client.getFeed({ limit: 20 });
+        setItems(page.items || []);
+        setCursor(page.next_cursor);
+      } catch (err) {
+        setError(errorMessage(err, 'Failed to load feed'));
+      } finally {
+        setLoading(false);
+      }
+    }
+    fetchFeed();
+  }, []);
+
+  const loadMore = async () => {
+    if (!cursor) return;
+    try {
+      const page = await client.getFeed({ limit: 20, cursor });
+      setItems((prev) => [...prev, ...(page.items || [])]);
+      setCursor(page.next_cursor);
+    } catch (err) {
+      setError(errorMessage(err, 'Failed to load feed'));
+    }
+  };
+
+  if (loading) {
+    return <div className="flex items-center justify-center min-h-[50vh]"><div className="animate-spin rounded-full h-8 w-8 border-t-2 border-b-2 border-primary"></div></div>;
+  }
+
+  return (
+    <div className="space-y-4 max-w-2xl mx-auto mt-10">
+      <h1 className="text-2xl font-bold mb-6">Feed</h1>
+      {error && <div className="text-red-500">{error}</div>}
+      {!items.length && !error && (
+        <div className="text-center text-muted-foreground">Nothing here yet. Write a dream or add some friends.</div>
+      )}
+      {items.map(({ dream, sources, comments }) => (
+        <Card key={dream.id} className="p-4">
+          <div className="flex gap-1 mb-1">
+            {sources.map((s) => (
+              <span key={s} className="rounded bg-muted px-1.5 text-xs text-muted-foreground">{sourceLabels[s]}</span>
+            ))}
+          </div>
+          <Link to={`/dreams/${dream.id}`} className="hover:underline">
+            <h3 className="font-bold text-lg mb-1">{dream.title || (dream.text.length > 40 ? dream.text.slice(0, 40) + '...' : dream.text)}</h3>
+          </Link>
+          <p className="text-sm text-muted-foreground mb-2">
+            {dream.username} &middot; {dream.createdAt ? format(new Date(dream.createdAt), 'MMM d, yyyy h:mm a') : ''}
+            {comments > 0 && <> &middot; {comments} {comments === 1 ? 'comment' : 'comments'}</>}
+          </p>
+          <p className="line-clamp-3 text-base">{dream.text.length > 180 ? dream.text.slice(0, 180) + '...' : dream.text}</p>
+          <div className="mt-2">
+            <ReactionBar target={{ dreamId: dream.id }} initial={dream.reactions} canReact small />
+          </div>
+        </Card>
+      ))}
+      {cursor && (
+        <button className="w-full px-4 py-2 rounded border" onClick={loadMore}>
+          Load more
+        </button>
+      )}
+      {!cursor && items.length > 0 && (
+        <div className="text-center text-sm text-muted-foreground">That's the whole feed. Older dreams are on profiles.</div>
+      )}
+    </div>
+  );
+}
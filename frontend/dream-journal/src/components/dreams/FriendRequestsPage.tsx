@@ -5,23 +5,25 @@ import { Card } from '../ui/card';
 
 export function FriendRequestsPage() {
   const { user } = useAuth();
-  const [requests, setRequests] = useState<any[]>([]);
+  const [incoming, setIncoming] = useState<any[]>([]);
+  const [outgoing, setOutgoing] = useState<any[]>([]);
   const [loading, setLoading] = useState(true);
-  const [accepting, setAccepting] = useState<string | null>(null);
+  const [busy, setBusy] = useState<string | null>(null);
 
   useEffect(() => {
     async function fetchRequests() {
       if (!user) return;
       setLoading(true);
       try {
-        // Fetch all friend requests where the current user is the friend and status is 'pending'
-        const res = await fetch(`/api/friends?pending_for=${user.id}`, {
-          headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
-        });
-        const data = await res.json();
-        setRequests(data.requests || []);
+        const [received, sent] = await Promise.all([
+          client.listFriendRequests('incoming'),
+          client.listFriendRequests('outgoing'),
+        ]);
+        setIncoming(received.requests || []);
+        setOutgoing(sent.requests || []);
       } catch {
-        setRequests([]);
+        setIncoming([]);
+        setOutgoing([]);
       } finally {
         setLoading(false);
       }
@@ -29,45 +31,86 @@ export function FriendRequestsPage() {
     fetchRequests();
   }, [user]);
 
-  const handleAccept = async (fromUserId: string) => {
-    if (!user) return;
-    setAccepting(fromUserId);
+  // run applies an action to one request and drops it from its list
+  const run = async (id: string, action: () => Promise<unknown>, list: 'incoming' | 'outgoing') => {
+    setBusy(id);
     try {
-      await client.acceptFriendRequest(fromUserId, user.id);
-      setRequests((reqs) => reqs.filter((r) => r.id !== fromUserId));
+      await action();
+      const drop = (reqs: any[]) => reqs.filter((r) => r.id !== id);
+      if (list === 'incoming') setIncoming(drop);
+      else setOutgoing(drop);
     } finally {
-      setAccepting(null);
+      setBusy(null);
     }
   };
 
   if (loading) {
     return <div className="flex items-center justify-center min-h-[50vh]">Loading...</div>;
   }
+  if (!user) return null;
 
   return (
     <div className="max-w-2xl mx-auto mt-10">
       <h1 className="text-2xl font-bold mb-6">Friend Requests</h1>
-      {requests.length === 0 ? (
+      {incoming.length === 0 ? (
         <div className="text-center text-muted-foreground">No pending friend requests.</div>
       ) : (
         <div className="space-y-4">
-          {requests.map((req) => (
+          {incoming.map((req) => (
             <Card key={req.id} className="p-4 flex items-center justify-between">
               <div>
                 <div className="font-bold">{req.username}</div>
                 <div className="text-sm text-muted-foreground">{req.display_name}</div>
               </div>
-              <button
-                className="px-4 py-2 rounded bg-primary text-white hover:bg-primary/80 disabled:opacity-50"
-                onClick={() => handleAccept(req.id)}
-                disabled={accepting === req.id}
-              >
-                {accepting === req.id ? 'Accepting...' : 'Accept'}
-              </button>
+              <div className="flex gap-2">
+                <button
+                  className="px-4 py-2 rounded bg-primary text-white hover:bg-primary/80 disabled:opacity-50"
+                  onClick={() => run(req.id, () => client.acceptFriendRequest(req.id, user.id), 'incoming')}
+                  disabled={busy === req.id}
+                >
+                  Accept
+                </button>
+                <button
+                  className="px-4 py-2 rounded border disabled:opacity-50"
+                  onClick={() => run(req.id, () => client.rejectFriendRequest(req.id, user.id), 'incoming')}
+                  disabled={busy === req.id}
+                >
+                  Reject
+                </button>
+                <button
+                  className="px-4 py-2 rounded border text-destructive disabled:opacity-50"
+                  onClick={() => run(req.id, () => client.blockUser(user.id, req.id), 'incoming')}
+                  disabled={busy === req.id}
+                >
+                  Block
+                </button>
+              </div>
             </Card>
           ))}
         </div>
       )}
+      {outgoing.length > 0 && (
+        <>
+          <h2 className="text-xl font-bold mt-10 mb-4">Sent</h2>
+          <div className="space-y-4">
+            {outgoing.map((req) => (
+              <Card key={req.id} className="p-4 flex items-center justify-between">
+                <div>
+                  <div className="font-bold">{req.username}</div>
+                  <div className="text-sm text-muted-foreground">{req.display_name}</div>
+                </div>
+                <button
+                  className="px-4 py-2 rounded border disabled:opacity-50"
+                  onClick={() => run(req.id, () => client.cancelFriendRequest(user.id, req.id), 'outgoing')}
+                  disabled={busy === req.id}
+                >
+                  {busy === req.id ? 'Cancelling...' : 'Cancel'}
+                </button>
+              </Card>
+            ))}
+          </div>
+        </>
+      )}
     </div>
   );
-} 
\ No newline at end of file
+}
@@ -3,6 +3,7 @@ import client from '../../api/client';
 import { useAuth } from '../../context/AuthContext';
 import { Card } from '../ui/card';
 import { format } from 'date-fns';
+import { ReactionBar } from './ReactionBar';
 
 export function FriendsDreamsList() {
   const { user } = useAuth();
@@ -39,6 +40,9 @@ export function FriendsDreamsList() {
           <h3 className="font-bold text-lg mb-1">{dream.title || (dream.text.length > 40 ? dream.text.slice(0, 40) + '...' : dream.text)}</h3>
           <p className="text-sm text-muted-foreground mb-2">{dream.username} &middot; {dream.createdAt ? format(new Date(dream.createdAt), 'MMM d, yyyy h:mm a') : ''}</p>
           <p className="line-clamp-3 text-base">{dream.text.length > 180 ? dream.text.slice(0, 180) + '...' : dream.text}</p>
+          <div className="mt-2">
+            <ReactionBar target={{ dreamId: dream.id }} initial={dream.reactions} canReact small />
+          </div>
         </Card>
       ))}
     </div>
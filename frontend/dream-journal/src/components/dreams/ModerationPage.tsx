@@ -0,0 +1,111 @@
+import { useEffect, useState } from 'react';
+import { Link } from 'react-router-dom';
+import client, { errorMessage } from '../../api/client';
+import { useAuth } from '../../context/AuthContext';
+import { Card } from '../ui/card';
+
+// ModerationPage lists open comment reports for admins, oldest first
+export function ModerationPage() {
+  const { user } = useAuth();
+  const [reports, setReports] = useState<any[]>([]);
+  const [cursor, setCursor] = useState<string | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [busy, setBusy] = useState<number | null>(null);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    async function fetchReports() {
+      if (!user?.isAdmin) return;
+      setLoading(true);
+      try {
+        const page = await client.listReports({ limit: 50 });
+        setReports(page.reports || []);
+        setCursor(page.next_cursor);
+      } catch (err) {
+        setError(errorMessage(err, 'Failed to load reports'));
+      } finally {
+        setLoading(false);
+      }
+    }
+    fetchReports();
+  }, [user]);
+
+  const loadMore = async () => {
+    if (!cursor) return;
+    const page = await client.listReports({ limit: 50, cursor });
+    setReports((prev) => [...prev, ...(page.reports || [])]);
+    setCursor(page.next_cursor);
+  };
+
+  // resolve settles every open report on the comment, so they all leave
+  // the queue
+  const resolve = async (report: any, action: 'dismiss' | 'remove') => {
+    if (action === 'remove' && !window.confirm('Delete this comment and its replies?')) return;
+    setBusy(report.id);
+    try {
+      await client.resolveReport(report.id, action);
+      setReports((prev) => prev.filter((r) => r.comment.id !== report.comment.id));
+    } catch (err) {
+      setError(errorMessage(err, 'Failed to resolve report'));
+    } finally {
+      setBusy(null);
+    }
+  };
+
+  if (!user?.isAdmin) {
+    return <div className="text-center mt-10">Only admins can see the moderation queue.</div>;
+  }
+  if (loading) {
+    return <div className="flex items-center justify-center min-h-[50vh]">Loading...</div>;
+  }
+
+  return (
+    <div className="max-w-2xl mx-auto mt-10">
+      <h1 className="text-2xl font-bold mb-6">Reported Comments</h1>
+      {error && <div className="text-red-500 text-sm mb-4">{error}</div>}
+      {reports.length === 0 ? (
+        <div className="text-center text-muted-foreground">Nothing to review.</div>
+      ) : (
+        <div className="space-y-4">
+          {reports.map((report) => (
+            <Card key={report.id} className="p-4 space-y-2">
+              <div className="text-sm text-muted-foreground">
+                Reported by {report.reporter.username} on {new Date(report.createdAt).toLocaleString()}: {report.reason}
+              </div>
+              <div className="border-l-2 pl-3">
+                <div className="font-bold text-sm">{report.comment.user.username}</div>
+                <div>{report.comment.text}</div>
+              </div>
+              <div className="flex items-center justify-between">
+                <Link to={`/dreams/${report.dreamId}`} className="text-sm underline">
+                  View dream
+                </Link>
+                <div className="flex gap-2">
+                  <button
+                    className="px-4 py-2 rounded border disabled:opacity-50"
+                    onClick={() => resolve(report, 'dismiss')}
+                    disabled={busy === report.id}
+                  >
+                    Dismiss
+                  </button>
+                  <button
+                    className="px-4 py-2 rounded bg-destructive text-white hover:bg-destructive/80 disabled:opacity-50"
+                    onClick={() => resolve(report, 'remove')}
+                    disabled={busy === report.id}
+                  >
+                    Remove comment
+                  </button>
+                </div>
+              </div>
+            </Card>
+          ))}
+          {cursor && (
+            <button className="w-full px-4 py-2 rounded border" onClick={loadMore}>
+              Load more
+            </button>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
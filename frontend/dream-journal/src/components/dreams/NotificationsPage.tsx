@@ -0,0 +1,137 @@
+import { useEffect, useState } from 'react';
+import { Link } from 'react-router-dom';
+import client, { Notification, NotificationType, errorMessage, notificationLabels } from '../../api/client';
+import { Card } from '../ui/card';
+
+// describe says what a notification is about, after the actor's name
+function describe(n: Notification): string {
+  switch (n.type) {
+    case 'friend_request':
+      return 'sent you a friend request';
+    case 'friend_accepted':
+      return 'accepted your friend request';
+    case 'comment':
+      return 'commented on your dream';
+    case 'reply':
+      return 'replied to your comment';
+  }
+}
+
+// NotificationsPage lists the user's notifications, newest first, and lets
+// them choose which kinds they get
+export function NotificationsPage() {
+  const [notifications, setNotifications] = useState<Notification[]>([]);
+  const [cursor, setCursor] = useState<string | null>(null);
+  const [prefs, setPrefs] = useState<Record<NotificationType, boolean> | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    async function fetchNotifications() {
+      setLoading(true);
+      try {
+        const [page, prefs] = await Promise.all([client.listNotifications({ limit: 20 }), client.getNotificationPrefs()]);
+        setNotifications(page.notifications || []);
+        setCursor(page.next_cursor);
+        setPrefs(prefs);
+      } catch (err) {
+        setError(errorMessage(err, 'Failed to load notifications'));
+      } finally {
+        setLoading(false);
+      }
+    }
+    fetchNotifications();
+  }, []);
+
+  const loadMore = async () => {
+    if (!cursor) return;
+    const page = await client.listNotifications({ limit: 20, cursor });
+    setNotifications((prev) => [...prev, ...(page.notifications || [])]);
+    setCursor(page.next_cursor);
+  };
+
+  const markRead = async (ids?: number[]) => {
+    try {
+      await client.markNotificationsRead(ids);
+      setNotifications((prev) => prev.map((n) => (!ids || ids.includes(n.id) ? { ...n, read: true } : n)));
+    } catch (err) {
+      setError(errorMessage(err, 'Failed to mark notifications read'));
+    }
+  };
+
+  const togglePref = async (type: NotificationType, on: boolean) => {
+    try {
+      setPrefs(await client.updateNotificationPrefs({ [type]: on }));
+    } catch (err) {
+      setError(errorMessage(err, 'Failed to save preferences'));
+    }
+  };
+
+  if (loading) {
+    return <div className="flex items-center justify-center min-h-[50vh]">Loading...</div>;
+  }
+
+  return (
+    <div className="max-w-2xl mx-auto mt-10">
+      <div className="flex items-center justify-between mb-6">
+        <h1 className="text-2xl font-bold">Notifications</h1>
+        {notifications.some((n) => !n.read) && (
+          <button className="px-4 py-2 rounded border text-sm" onClick={() => markRead()}>
+            Mark all read
+          </button>
+        )}
+      </div>
+      {error && <div className="text-red-500 text-sm mb-4">{error}</div>}
+      {notifications.length === 0 ? (
+        <div className="text-center text-muted-foreground">Nothing new.</div>
+      ) : (
+        <div className="space-y-2">
+          {notifications.map((n) => (
+            <Card key={n.id} className={`p-3 flex items-center justify-between ${n.read ? 'opacity-70' : 'border-primary'}`}>
+              <div>
+                <span className="font-bold">{n.actor.display_name || n.actor.username}</span> {describe(n)}
+                <div className="text-xs text-muted-foreground">{new Date(n.createdAt).toLocaleString()}</div>
+              </div>
+              <div className="flex items-center gap-3 text-sm">
+                {n.dreamId ? (
+                  <Link to={`/dreams/${n.dreamId}`} className="underline" onClick={() => !n.read && markRead([n.id])}>
+                    View
+                  </Link>
+                ) : (
+                  n.type === 'friend_request' && (
+                    <Link to="/friend-requests" className="underline" onClick={() => !n.read && markRead([n.id])}>
+                      View
+                    </Link>
+                  )
+                )}
+                {!n.read && (
+                  <button className="text-muted-foreground hover:underline" onClick={() => markRead([n.id])}>
+                    Mark read
+                  </button>
+                )}
+              </div>
+            </Card>
+          ))}
+          {cursor && (
+            <button className="w-full px-4 py-2 rounded border" onClick={loadMore}>
+              Load more
+            </button>
+          )}
+        </div>
+      )}
+      {prefs && (
+        <div className="mt-10">
+          <h2 className="text-xl font-semibold mb-3">Notify me about</h2>
+          <div className="space-y-2">
+            {(Object.keys(notificationLabels) as NotificationType[]).map((type) => (
+              <label key={type} className="flex items-center gap-2">
+                <input type="checkbox" checked={prefs[type]} onChange={(e) => togglePref(type, e.target.checked)} />
+                {notificationLabels[type]}
+              </label>
+            ))}
+          </div>
+        </div>
+      )}
+    </div>
+  );
+}
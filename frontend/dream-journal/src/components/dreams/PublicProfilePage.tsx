@@ -1,4 +1,4 @@
-import { useParams } from 'react-router-dom';
+import { useNavigate, useParams } from 'react-router-dom';
 import { useEffect, useState } from 'react';
 import client from '../../api/client';
 import { Avatar } from '../ui/avatar';
@@ -8,6 +8,7 @@ import { useAuth } from '../../context/AuthContext';
 export function PublicProfilePage() {
   const { username } = useParams<{ username: string }>();
   const { user: currentUser } = useAuth();
+  const navigate = useNavigate();
   const [profile, setProfile] = useState<any>(null);
   const [loading, setLoading] = useState(true);
   const [friendStatus, setFriendStatus] = useState<string | null>(null);
@@ -18,13 +19,17 @@ export function PublicProfilePage() {
       try {
         const data = await client.getPublicProfile(username!);
         setProfile(data);
+        // A former username resolves to the user's current one
+        if (data.user.username !== username) {
+          navigate(`/users/${data.user.username}`, { replace: true });
+        }
         // Optionally, fetch friend status here
       } finally {
         setLoading(false);
       }
     }
     fetchProfile();
-  }, [username]);
+  }, [username, navigate]);
 
   const handleFriend = async () => {
     if (!currentUser || !profile?.user?.id) return;
@@ -0,0 +1,59 @@
+import { useState } from 'react';
+import client, { ReactionCounts, ReactionKind, ReactionTarget, errorMessage, reactionEmoji } from '../../api/client';
+
+const kinds = Object.keys(reactionEmoji) as ReactionKind[];
+
+// ReactionBar shows a dream's or comment's reaction counts. Signed-in users
+// toggle their own reactions; the counts don't say who reacted, so the bar
+// remembers the ones picked on this page.
+export function ReactionBar({
+  target,
+  initial,
+  canReact,
+  small = false,
+}: {
+  target: ReactionTarget;
+  initial?: ReactionCounts;
+  canReact: boolean;
+  small?: boolean;
+}) {
+  const [counts, setCounts] = useState<Partial<ReactionCounts>>(initial || {});
+  const [mine, setMine] = useState<Set<ReactionKind>>(new Set());
+  const [error, setError] = useState<string | null>(null);
+
+  const toggle = async (kind: ReactionKind) => {
+    const has = mine.has(kind);
+    try {
+      setCounts(has ? await client.removeReaction(target, kind) : await client.addReaction(target, kind));
+      setMine((prev) => {
+        const next = new Set(prev);
+        if (has) next.delete(kind);
+        else next.add(kind);
+        return next;
+      });
+      setError(null);
+    } catch (err) {
+      setError(errorMessage(err, 'Failed to react'));
+    }
+  };
+
+  return (
+    <div className="flex items-center gap-2">
+      {kinds.map((kind) => (
+        <button
+          key={kind}
+          type="button"
+          title={kind}
+          disabled={!canReact}
+          onClick={() => toggle(kind)}
+          className={`rounded-full border px-2 ${small ? 'text-xs' : 'text-sm py-0.5'} ${
+            mine.has(kind) ? 'bg-accent border-primary' : 'border-input'
+          } disabled:cursor-default`}
+        >
+          {reactionEmoji[kind]} {counts[kind] || 0}
+        </button>
+      ))}
+      {error && <span className="text-red-500 text-xs">{error}</span>}
+    </div>
+  );
+}
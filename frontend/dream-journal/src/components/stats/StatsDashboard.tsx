@@ -1,6 +1,7 @@
 import React, { useEffect, useState } from 'react';
 import { useAuth } from '../../context/AuthContext';
 import client, { Stats, Dream } from '../../api/client';
+import { authHeader } from '../../api/session';
 import { Badge } from '../ui/badge';
 import { DreamCalendar } from './DreamCalendar';
 
@@ -13,7 +14,6 @@ export const StatsDashboard: React.FC = () => {
   const [aiError, setAiError] = useState<string | null>(null);
   const [dreamDates, setDreamDates] = useState<string[]>([]);
   const [dreamsLoading, setDreamsLoading] = useState(true);
-  const [dreams, setDreams] = useState<Dream[]>([]);
 
   useEffect(() => {
     const fetchStats = async () => {
@@ -39,7 +39,7 @@ export const StatsDashboard: React.FC = () => {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
-        Authorization: `Bearer ${localStorage.getItem('token')}`,
+        ...authHeader(),
       },
       body: JSON.stringify({ userId: user.id }),
     })
@@ -57,10 +57,8 @@ export const StatsDashboard: React.FC = () => {
         const dreams: Dream[] = await client.getDreams();
         const userDreams = dreams.filter((d) => d.userId === user.id);
         setDreamDates(userDreams.map((d) => d.createdAt.slice(0, 10)));
-        setDreams(userDreams);
       } catch (error) {
         setDreamDates([]);
-        setDreams([]);
       } finally {
         setDreamsLoading(false);
       }
@@ -68,24 +66,19 @@ export const StatsDashboard: React.FC = () => {
     fetchDreams();
   }, [user]);
 
-  // Aggregate ratings
-  function avg(arr: (number | undefined)[]) {
-    const nums = arr.filter((n): n is number => typeof n === 'number');
-    if (!nums.length) return null;
-    return (nums.reduce((a, b) => a + b, 0) / nums.length).toFixed(2);
+  // Ratings are aggregated server-side
+  function avg(key: keyof Stats['ratings']) {
+    const value = stats?.ratings?.[key]?.average;
+    return typeof value === 'number' ? value.toFixed(2) : null;
   }
-  const nightmareAvg = avg(dreams.map(d => d.nightmare_rating));
-  const vividnessAvg = avg(dreams.map(d => d.vividness_rating));
-  const clarityAvg = avg(dreams.map(d => d.clarity_rating));
-  const emotionalAvg = avg(dreams.map(d => d.emotional_intensity_rating));
+  const nightmareAvg = avg('nightmare');
+  const vividnessAvg = avg('vividness');
+  const clarityAvg = avg('clarity');
+  const emotionalAvg = avg('emotional_intensity');
 
   // Simple histogram/bar for each rating (1-10)
-  function ratingHistogram(key: keyof Dream) {
-    const counts = Array(10).fill(0);
-    dreams.forEach(d => {
-      const val = d[key] as number | undefined;
-      if (typeof val === 'number' && val >= 1 && val <= 10) counts[val - 1]++;
-    });
+  function ratingHistogram(key: keyof Stats['ratings']) {
+    const counts = stats?.ratings?.[key]?.histogram ?? Array(10).fill(0);
     return (
       <div className="flex items-end gap-1 h-12 mt-2">
         {counts.map((count, i) => (
@@ -134,6 +127,12 @@ export const StatsDashboard: React.FC = () => {
           <h3 className="text-lg font-medium">Private Dreams</h3>
           <p className="mt-2 text-3xl font-bold">{stats.privateDreams}</p>
         </div>
+
+        <div className="rounded-lg border bg-card p-6">
+          <h3 className="text-lg font-medium">Current Streak</h3>
+          <p className="mt-2 text-3xl font-bold">{stats.streak?.current ?? 0} days</p>
+          <p className="text-sm text-muted-foreground">Longest: {stats.streak?.longest ?? 0} days</p>
+        </div>
       </div>
 
       {/* Ratings Averages and Histograms */}
@@ -143,22 +142,22 @@ export const StatsDashboard: React.FC = () => {
           <div>
             <div className="font-semibold">Nightmare → Great Dream</div>
             <div className="text-2xl font-bold">{nightmareAvg ?? '—'}</div>
-            {ratingHistogram('nightmare_rating')}
+            {ratingHistogram('nightmare')}
           </div>
           <div>
             <div className="font-semibold">Vividness</div>
             <div className="text-2xl font-bold">{vividnessAvg ?? '—'}</div>
-            {ratingHistogram('vividness_rating')}
+            {ratingHistogram('vividness')}
           </div>
           <div>
             <div className="font-semibold">Clarity</div>
             <div className="text-2xl font-bold">{clarityAvg ?? '—'}</div>
-            {ratingHistogram('clarity_rating')}
+            {ratingHistogram('clarity')}
           </div>
           <div>
             <div className="font-semibold">Emotional Intensity</div>
             <div className="text-2xl font-bold">{emotionalAvg ?? '—'}</div>
-            {ratingHistogram('emotional_intensity_rating')}
+            {ratingHistogram('emotional_intensity')}
           </div>
         </div>
       </div>
@@ -1,6 +1,8 @@
 import React, { createContext, useContext, useState, useEffect } from 'react';
 import { useNavigate, useLocation } from 'react-router-dom';
 import axios from 'axios';
+import { storeSession, clearSession, endSession } from '../api/session';
+import { errorMessage } from '../api/client';
 
 const API_URL = process.env.REACT_APP_API_URL || 'http://localhost:50051';
 
@@ -39,9 +41,7 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
           await axios.get(`${API_URL}/api/me`);
         } catch (err) {
           setUser(null);
-          localStorage.removeItem('user');
-          localStorage.removeItem('token');
-          delete axios.defaults.headers.common['Authorization'];
+          clearSession();
         }
       }
     }
@@ -53,8 +53,7 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
         verifyToken();
       } catch (error) {
         console.error('Error parsing stored user:', error);
-        localStorage.removeItem('user');
-        localStorage.removeItem('token');
+        clearSession();
       }
     }
     setIsLoading(false);
@@ -63,23 +62,20 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
   const login = async (email: string, password: string) => {
     try {
       const response = await axios.post(`${API_URL}/api/login`, { email, password });
-      const { user, token, isAdmin } = response.data;
+      const { user, token, refreshToken, isAdmin } = response.data;
       if (!user || !token) {
         throw new Error('Invalid response from server');
       }
       const userWithAdmin = { ...user, isAdmin };
       setUser(userWithAdmin);
       localStorage.setItem('user', JSON.stringify(userWithAdmin));
-      localStorage.setItem('token', token);
-      axios.defaults.headers.common['Authorization'] = `Bearer ${token}`;
+      storeSession(token, refreshToken);
       // Only navigate if login is successful
       const from = location.state?.from?.pathname || '/';
       navigate(from, { replace: true });
     } catch (error: any) {
       setUser(null);
-      localStorage.removeItem('user');
-      localStorage.removeItem('token');
-      delete axios.defaults.headers.common['Authorization'];
+      clearSession();
       // Do not navigate on error
       // Optionally show a user-friendly error
       // alert('Login failed: Invalid email or password, or server unavailable.');
@@ -94,32 +90,27 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
         username, 
         password 
       });
-      const { user, token, isAdmin } = response.data;
+      const { user, token, refreshToken, isAdmin } = response.data;
       if (!user || !token) {
         throw new Error('Invalid response from server');
       }
       const userWithAdmin = { ...user, isAdmin };
       setUser(userWithAdmin);
       localStorage.setItem('user', JSON.stringify(userWithAdmin));
-      localStorage.setItem('token', token);
-      axios.defaults.headers.common['Authorization'] = `Bearer ${token}`;
+      storeSession(token, refreshToken);
       const from = location.state?.from?.pathname || '/';
       navigate(from, { replace: true });
     } catch (error: any) {
       setUser(null);
-      localStorage.removeItem('user');
-      localStorage.removeItem('token');
-      delete axios.defaults.headers.common['Authorization'];
-      alert('Registration failed: Email may already be in use, or server unavailable.');
+      clearSession();
+      alert('Registration failed: ' + errorMessage(error, 'email may already be in use, or server unavailable.'));
       throw error;
     }
   };
 
   const logout = () => {
     setUser(null);
-    localStorage.removeItem('user');
-    localStorage.removeItem('token');
-    delete axios.defaults.headers.common['Authorization'];
+    endSession();
     navigate('/login', { replace: true });
   };
 
@@ -0,0 +1,40 @@
+import { useEffect, useRef } from 'react';
+import { eventsUrl } from '../api/client';
+
+export type EventType = 'comment' | 'friend' | 'notification' | 'job';
+
+// useEvents streams GET /api/events while mounted, calling onEvent with
+// each event's type and parsed data. Pass dreamId to also get new comments
+// on that dream. A stream the server closed, say because the access token
+// expired, is reopened with the current token after a pause.
+export function useEvents(onEvent: (type: EventType, data: any) => void, dreamId?: string, enabled = true) {
+  const handler = useRef(onEvent);
+  handler.current = onEvent;
+
+  useEffect(() => {
+    if (!enabled) return;
+    let source: EventSource | null = null;
+    let retry: ReturnType<typeof setTimeout> | undefined;
+    let stopped = false;
+
+    const open = () => {
+      const url = eventsUrl(dreamId);
+      if (!url) return;
+      source = new EventSource(url);
+      (['comment', 'friend', 'notification', 'job'] as EventType[]).forEach((type) =>
+        source!.addEventListener(type, (e) => handler.current(type, JSON.parse((e as MessageEvent).data)))
+      );
+      source.onerror = () => {
+        if (source?.readyState === EventSource.CLOSED && !stopped) {
+          retry = setTimeout(open, 5000);
+        }
+      };
+    };
+    open();
+    return () => {
+      stopped = true;
+      clearTimeout(retry);
+      source?.close();
+    };
+  }, [dreamId, enabled]);
+}
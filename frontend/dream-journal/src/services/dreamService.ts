@@ -2,6 +2,8 @@ import axios from 'axios';
 
 const API_URL = process.env.REACT_APP_API_URL || 'http://localhost:50051';
 
+export type Visibility = 'private' | 'friends' | 'public' | 'unlisted';
+
 export interface Dream {
   id: string;
   userId: string;
@@ -10,7 +12,7 @@ export interface Dream {
   profileImageURL?: string;
   title?: string;
   text: string;
-  public: boolean;
+  visibility: Visibility;
   createdAt: string;
   updatedAt: string;
   tags?: string[];
@@ -23,7 +25,7 @@ export interface Dream {
 export interface CreateDreamRequest {
   title?: string;
   text: string;
-  public: boolean;
+  visibility: Visibility;
   nightmare_rating?: number;
   vividness_rating?: number;
   clarity_rating?: number;
@@ -43,15 +45,22 @@ export const dreamService = {
     return response.data;
   },
 
+  // Fetches every page of the listing
   async listDreams(publicOnly?: boolean, userId?: string): Promise<Dream[]> {
-    const params: any = {};
+    const params: any = { limit: 100 };
     if (publicOnly) params.public = true;
     if (userId) params.userId = userId;
-    const response = await axios.get(`${API_URL}/api/dreams`, {
-      params,
-      headers: getAuthHeader(),
-    });
-    return response.data;
+    const dreams: Dream[] = [];
+    let cursor: string | null = null;
+    do {
+      const response: { data: { dreams: Dream[]; next_cursor: string | null } } = await axios.get(`${API_URL}/api/dreams`, {
+        params: cursor ? { ...params, cursor } : params,
+        headers: getAuthHeader(),
+      });
+      dreams.push(...response.data.dreams);
+      cursor = response.data.next_cursor;
+    } while (cursor);
+    return dreams;
   },
 
   async deleteDream(id: string): Promise<void> {